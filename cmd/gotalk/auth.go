@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/EliasLd/gotalk-backend/pkg/client"
+)
+
+func runLogin(ctx context.Context, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	server := fs.String("server", firstNonEmpty(os.Getenv("GOTALK_SERVER"), cfg.Server, defaultServer), "URL of the gotalk server")
+	username := fs.String("username", cfg.Username, "account to log in with, prompted when empty")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gotalk login [-server URL] [-username NAME]")
+		fmt.Fprintln(fs.Output(), "\nThe password is prompted for, or read from the first line of stdin when it is not a terminal.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	stdin := bufio.NewReader(os.Stdin)
+	if *username == "" {
+		fmt.Fprint(os.Stderr, "Username: ")
+		line, err := stdin.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		*username = strings.TrimSpace(line)
+	}
+
+	password, err := readPassword(stdin)
+	if err != nil {
+		return err
+	}
+
+	c := client.NewClient(*server)
+	token, err := c.Login(ctx, *username, password)
+	if err != nil {
+		return err
+	}
+
+	cfg.Server = strings.TrimRight(*server, "/")
+	cfg.Username = *username
+	cfg.Token = token
+	cfg.RefreshToken = c.RefreshToken()
+	if err := saveConfig(cfg); err != nil {
+		return fmt.Errorf("saving configuration: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Logged in to %s as %s\n", cfg.Server, cfg.Username)
+	return nil
+}
+
+// Prompts without echo on terminals, reads a line otherwise
+func readPassword(stdin *bufio.Reader) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.New("expected the password on stdin")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func runLogout(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("logout", flag.ExitOnError)
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	// The session is forgotten locally even when the server cannot be reached
+	if cfg.Token != "" {
+		server := firstNonEmpty(os.Getenv("GOTALK_SERVER"), cfg.Server, defaultServer)
+		c := client.NewClient(server, client.WithToken(cfg.Token), client.WithRefreshToken(cfg.RefreshToken))
+		if err := c.Logout(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "gotalk: revoking the session: %v\n", err)
+		}
+	}
+
+	cfg.Token = ""
+	cfg.RefreshToken = ""
+	return saveConfig(cfg)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/EliasLd/gotalk-backend/pkg/client"
+)
+
+func runSend(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	ref := fs.String("conversation", "", "conversation ID, name, or @username (required)")
+	ttl := fs.Duration("ttl", 0, "lifetime of a disappearing message, e.g. 10m")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gotalk send -conversation X [-ttl D] [text...]")
+		fmt.Fprintln(fs.Output(), "\nThe message is read from stdin when no text is given, or when it is \"-\".")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *ref == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	content := strings.Join(fs.Args(), " ")
+	if content == "" || content == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		content = strings.TrimRight(string(data), "\n")
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	conversationID, _, err := resolveConversation(ctx, c, *ref)
+	if err != nil {
+		return err
+	}
+
+	result, err := c.SendMessage(ctx, conversationID, client.SendMessageInput{Content: content, TTL: *ttl})
+	if err != nil {
+		return explain(err)
+	}
+
+	// Scripts get the ID of the message posted, or the command feedback
+	if result.Reply != "" {
+		fmt.Println(result.Reply)
+	}
+	if result.Message != nil {
+		fmt.Println(result.Message.ID)
+	}
+	return nil
+}
+
+// Prints messages, resolving sender IDs into usernames
+type printer struct {
+	client		*client.Client
+	usernames	map[uuid.UUID]string
+	// Already printed, history and live events can overlap
+	printed		map[uuid.UUID]bool
+}
+
+func (p *printer) username(ctx context.Context, id uuid.UUID) string {
+	if name, ok := p.usernames[id]; ok {
+		return name
+	}
+
+	name := id.String()[:8]
+	if user, err := p.client.GetUser(ctx, id); err == nil {
+		name = user.Username
+	}
+	p.usernames[id] = name
+	return name
+}
+
+func (p *printer) message(ctx context.Context, message *client.Message) {
+	if p.printed[message.ID] {
+		return
+	}
+	p.printed[message.ID] = true
+
+	at := message.CreatedAt.Local().Format("15:04")
+	sender := p.username(ctx, message.SenderID)
+
+	switch message.ContentType {
+	case client.ContentTypeAction:
+		fmt.Printf("%s * %s %s\n", at, sender, message.Content)
+	case client.ContentTypeEncrypted:
+		fmt.Printf("%s %s: [encrypted message]\n", at, sender)
+	default:
+		fmt.Printf("%s %s: %s\n", at, sender, message.Content)
+	}
+}
+
+func runChat(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("chat", flag.ExitOnError)
+	scrollback := fs.Int("scrollback", 30, "number of past messages shown")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gotalk chat [-scrollback N] <conversation ID, name, or @username>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	me, err := c.Me(ctx)
+	if err != nil {
+		return explain(err)
+	}
+
+	conversationID, label, err := resolveConversation(ctx, c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	out := &printer {
+		client:		c,
+		usernames:	map[uuid.UUID]string{me.ID: me.Username},
+		printed:	map[uuid.UUID]bool{},
+	}
+
+	// Subscribed before loading the history so that no message falls in between.
+	// The stream is opened in the background, the history waits for it.
+	sub := c.Subscribe(ctx, conversationID, client.SubscribeOptions {
+		OnReconnect: func() {
+			fmt.Fprintln(os.Stderr, "-- reconnected, messages sent meanwhile are not shown")
+		},
+	})
+	defer sub.Close()
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-sub.Connected():
+	case _, ok := <-sub.Events():
+		// Nothing can be received before the first connection, the subscription ended
+		if !ok {
+			if err := sub.Err(); err != nil {
+				return explain(err)
+			}
+			return nil
+		}
+	}
+
+	history, err := c.ListMessages(ctx, conversationID, client.ListMessagesOptions{Limit: *scrollback})
+	if err != nil {
+		return explain(err)
+	}
+	slices.Reverse(history)
+	for i := range history {
+		out.message(ctx, &history[i])
+	}
+
+	fmt.Fprintf(os.Stderr, "-- %s, type a message and press Enter, Ctrl-D to quit\n", label)
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case line, ok := <-lines:
+			if !ok {
+				return nil
+			}
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			send(ctx, c, conversationID, line)
+
+		case event, ok := <-sub.Events():
+			if !ok {
+				if err := sub.Err(); err != nil {
+					return explain(err)
+				}
+				return nil
+			}
+			switch event.Type {
+			case client.EventMessageCreated:
+				out.message(ctx, event.Message)
+			case client.EventConversationUpdated:
+				if event.Conversation.Topic != nil {
+					fmt.Fprintf(os.Stderr, "-- topic: %s\n", *event.Conversation.Topic)
+				}
+			}
+		}
+	}
+}
+
+// Sent messages are printed when their event comes back, only feedback is shown here
+func send(ctx context.Context, c *client.Client, conversationID uuid.UUID, content string) {
+	result, err := c.SendMessage(ctx, conversationID, client.SendMessageInput{Content: content})
+
+	var apiErr *client.APIError
+	switch {
+	case errors.Is(err, client.ErrRateLimited) && errors.As(err, &apiErr):
+		fmt.Fprintf(os.Stderr, "-- slow down, retry in %s\n", apiErr.RetryAfter.Round(time.Second))
+	case errors.As(err, &apiErr):
+		fmt.Fprintf(os.Stderr, "-- %s\n", apiErr.Message)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "-- %v\n", err)
+	case result.Reply != "":
+		fmt.Fprintf(os.Stderr, "-- %s\n", result.Reply)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/uuid"
+
+	"github.com/EliasLd/gotalk-backend/pkg/client"
+)
+
+func runConversations(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("conversations", flag.ExitOnError)
+	fs.Parse(args)
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	conversations, err := c.ListConversations(ctx)
+	if err != nil {
+		return explain(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tROLE\tTOPIC")
+	for _, conversation := range conversations {
+		topic := ""
+		if conversation.Topic != nil {
+			topic = *conversation.Topic
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", conversation.ID, conversationLabel(conversation), conversation.Role, topic)
+	}
+	return w.Flush()
+}
+
+// Name shown for a conversation, direct ones are named after the other member
+func conversationLabel(conversation client.UserConversation) string {
+	switch {
+	case conversation.IsDirect:
+		return "@" + conversation.PeerUsername
+	case conversation.Name != nil && *conversation.Name != "":
+		return *conversation.Name
+	}
+	return "(unnamed)"
+}
+
+// Accepts a conversation ID, a conversation name, or "@username" for a
+// direct conversation, which is opened when it does not exist yet
+func resolveConversation(ctx context.Context, c *client.Client, ref string) (uuid.UUID, string, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		return id, ref, nil
+	}
+
+	conversations, err := c.ListConversations(ctx)
+	if err != nil {
+		return uuid.Nil, "", explain(err)
+	}
+
+	var matches []client.UserConversation
+	for _, conversation := range conversations {
+		if conversationLabel(conversation) == ref {
+			matches = append(matches, conversation)
+		}
+	}
+	switch {
+	case len(matches) == 1:
+		return matches[0].ID, ref, nil
+	case len(matches) > 1:
+		return uuid.Nil, "", fmt.Errorf("%d conversations are named %q, use its ID", len(matches), ref)
+	}
+
+	username, ok := strings.CutPrefix(ref, "@")
+	if !ok {
+		return uuid.Nil, "", fmt.Errorf("no conversation named %q", ref)
+	}
+
+	user, err := findUser(ctx, c, username)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	conversation, _, err := c.OpenDirectConversation(ctx, user.ID)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return conversation.ID, ref, nil
+}
+
+// Search matches prefixes, only an exact match is accepted
+func findUser(ctx context.Context, c *client.Client, username string) (*client.PublicUser, error) {
+	cursor := ""
+	for {
+		page, err := c.SearchUsers(ctx, username, cursor, 50)
+		if err != nil {
+			return nil, explain(err)
+		}
+		for _, user := range page.Users {
+			if user.Username == username {
+				return &user, nil
+			}
+		}
+		if page.NextCursor == "" {
+			return nil, fmt.Errorf("no user named %q", username)
+		}
+		cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,111 @@
+// Command gotalk is a terminal client of the gotalk HTTP API, for developers
+// and for smoke-testing deployments.
+//
+// GOTALK_SERVER and GOTALK_TOKEN take precedence over the saved
+// configuration, so that scripts can run without "gotalk login".
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/EliasLd/gotalk-backend/pkg/client"
+)
+
+const defaultServer = "http://localhost:8080"
+
+const usage = `Usage: gotalk <command> [flags]
+
+Commands:
+  login          Log in and save the session
+  logout         Forget the saved session
+  conversations  List your conversations
+  chat           Open a conversation: scrollback, live messages and a prompt
+  send           Send a message, for scripts
+
+Run "gotalk <command> -h" for the flags of a command.
+`
+
+type command func(ctx context.Context, args []string) error
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	commands := map[string]command {
+		"login":		runLogin,
+		"logout":		runLogout,
+		"conversations":	runConversations,
+		"chat":			runChat,
+		"send":			runSend,
+	}
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		fmt.Print(usage)
+		return
+	}
+	run, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "gotalk: unknown command %q\n\n%s", name, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "gotalk: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Builds a client out of the environment and the saved configuration
+func newClient() (*client.Client, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("reading configuration: %w", err)
+	}
+
+	server := cfg.Server
+	if env := os.Getenv("GOTALK_SERVER"); env != "" {
+		server = env
+	}
+	if server == "" {
+		server = defaultServer
+	}
+
+	if env := os.Getenv("GOTALK_TOKEN"); env != "" {
+		return client.NewClient(server, client.WithToken(env)), nil
+	}
+	if cfg.Token == "" {
+		return nil, errors.New(`not logged in, run "gotalk login" or set GOTALK_TOKEN`)
+	}
+
+	// Renewed sessions are saved right away, the previous refresh token no longer works
+	saveSession := func(token, refreshToken string) {
+		cfg.Token = token
+		cfg.RefreshToken = refreshToken
+		if err := saveConfig(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "gotalk: saving the renewed session: %v\n", err)
+		}
+	}
+	return client.NewClient(server,
+		client.WithToken(cfg.Token),
+		client.WithRefreshToken(cfg.RefreshToken),
+		client.WithSessionListener(saveSession),
+	), nil
+}
+
+// Adds a hint to the errors users can fix themselves
+func explain(err error) error {
+	if errors.Is(err, client.ErrUnauthorized) {
+		return fmt.Errorf(`%w (session expired? run "gotalk login")`, err)
+	}
+	return err
+}
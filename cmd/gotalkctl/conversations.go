@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+)
+
+type conversationView struct {
+	ID		uuid.UUID	`json:"id"`
+	Name		*string		`json:"name"`
+	Topic		*string		`json:"topic"`
+	Public		bool		`json:"public"`
+	Direct		bool		`json:"direct"`
+	Members		int		`json:"members"`
+	Messages	int		`json:"messages"`
+	CreatedAt	time.Time	`json:"createdAt"`
+	LastMessageAt	*time.Time	`json:"lastMessageAt"`
+}
+
+func newConversationView(conversation *models.ConversationOverview) conversationView {
+	return conversationView {
+		ID:		conversation.ID,
+		Name:		conversation.Name,
+		Topic:		conversation.Topic,
+		Public:		conversation.IsPublic,
+		Direct:		conversation.IsDirect,
+		Members:	conversation.MemberCount,
+		Messages:	conversation.MessageCount,
+		CreatedAt:	conversation.CreatedAt,
+		LastMessageAt:	conversation.LastMessageAt,
+	}
+}
+
+func runConversations(ctx context.Context, app *app, args []string) error {
+	fs := flag.NewFlagSet("conversations", flag.ExitOnError)
+	limit := fs.Int("limit", 50, "number of conversations listed, latest created first")
+	asJSON := fs.Bool("json", false, "print JSON")
+	fs.Parse(args)
+
+	if *limit <= 0 {
+		return fmt.Errorf("invalid limit %d", *limit)
+	}
+
+	conversations, err := app.conversations.GetConversations(ctx, *limit)
+	if err != nil {
+		return err
+	}
+
+	views := make([]conversationView, 0, len(conversations))
+	for _, conversation := range conversations {
+		views = append(views, newConversationView(conversation))
+	}
+
+	if *asJSON {
+		return printJSON(views)
+	}
+
+	w := newTable(os.Stdout)
+	fmt.Fprintln(w, "ID\tNAME\tKIND\tMEMBERS\tMESSAGES\tCREATED\tLAST MESSAGE")
+	for _, view := range views {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\t%s\n",
+			view.ID, conversationName(view), conversationKind(view),
+			view.Members, view.Messages, formatTime(&view.CreatedAt), formatTime(view.LastMessageAt))
+	}
+	return w.Flush()
+}
+
+func conversationName(view conversationView) string {
+	if view.Name == nil || *view.Name == "" {
+		return "-"
+	}
+	return *view.Name
+}
+
+func conversationKind(view conversationView) string {
+	switch {
+	case view.Direct:
+		return "direct"
+	case view.Public:
+		return "public"
+	}
+	return "private"
+}
@@ -0,0 +1,172 @@
+// Command gotalkctl manages a gotalk server through its database: user
+// accounts, conversations and the state of the schema.
+//
+// It reads DATABASE_URL from the environment or from a .env file, like the
+// server. Every command prints human-readable output, or JSON with -json.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/EliasLd/gotalk-backend/internal/database"
+	"github.com/EliasLd/gotalk-backend/internal/database/migrate"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service"
+	"github.com/EliasLd/gotalk-backend/internal/storage"
+	"github.com/EliasLd/gotalk-backend/migrations"
+)
+
+const usage = `Usage: gotalkctl [-v] <command> [flags]
+
+Commands:
+  user create          Create an account, with a generated password unless one is given
+  user show            Show an account
+  user disable         Prevent an account from logging in and using its access tokens
+  user enable          Allow a disabled account again
+  user delete          Anonymize an account right away, its messages are kept
+  user reset-password  Replace the password of an account with a generated one
+  user admin           Grant or revoke server administration
+  conversations        List the latest conversations
+  migrate              Apply or revert the schema migrations built into gotalkctl
+  status               Check the database connection and the schema version
+
+Users are given by username or ID. Run "gotalkctl <command> -h" for the flags of a command.
+-v shows the logs of the server packages, hidden otherwise.
+`
+
+type command func(ctx context.Context, app *app, args []string) error
+
+// Repositories and services shared by the commands, built like the server does
+type app struct {
+	users		service.UserService
+	userRepo	repository.UserRepository
+	conversations	repository.ConversationRepository
+	deletion	*service.AccountDeletionWorker
+	migrator	migrate.Migrator
+	// Empty when the uploaded files were not found, accounts cannot be deleted then
+	storageDir	string
+}
+
+func main() {
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "-v" {
+		args = args[1:]
+	} else {
+		log.SetOutput(io.Discard)
+	}
+
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	commands := map[string]command {
+		"user":			runUser,
+		"conversations":	runConversations,
+		"migrate":		runMigrate,
+		"status":		runStatus,
+	}
+
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		fmt.Print(usage)
+		return
+	}
+	run, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "gotalkctl: unknown command %q\n\n%s", name, usage)
+		os.Exit(2)
+	}
+
+	// Help is printed by the flag sets, which exit before the database is used
+	if slices.ContainsFunc(args[1:], isHelpFlag) {
+		run(context.Background(), &app{}, args[1:])
+		return
+	}
+
+	godotenv.Load()
+	if os.Getenv("DATABASE_URL") == "" {
+		fmt.Fprintln(os.Stderr, "gotalkctl: DATABASE_URL is not set")
+		os.Exit(1)
+	}
+	if err := database.Connect(); err != nil {
+		fmt.Fprintf(os.Stderr, "gotalkctl: connecting to the database: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	app, err := newApp()
+	if err == nil {
+		err = run(ctx, app, args[1:])
+	}
+	stop()
+	database.Close()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gotalkctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func isHelpFlag(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help" || arg == "--h"
+}
+
+func newApp() (*app, error) {
+	schemaMigrations, err := migrate.Load(migrations.FS)
+	if err != nil {
+		return nil, err
+	}
+
+	userRepo := repository.NewUserRepository(database.DB)
+	conversationRepo := repository.NewConversationRepository(database.DB)
+
+	// Webhook deliveries are queued in the database and sent by the server
+	webhookService := service.NewWebhookService(repository.NewWebhookRepository(database.DB), conversationRepo, userRepo)
+
+	// Same default as the server. Unlike the server, the directory is not created
+	// when missing: gotalkctl is likely not running next to the uploaded files.
+	storageDir := os.Getenv("STORAGE_DIR")
+	if storageDir == "" {
+		storageDir = "data"
+	}
+	var store storage.Store = missingStore{}
+	if info, err := os.Stat(storageDir); err == nil && info.IsDir() {
+		store, _ = storage.NewLocalStore(storageDir)
+	} else {
+		storageDir = ""
+	}
+
+	return &app {
+		users:		service.NewUserService(userRepo, repository.NewSessionRepository(database.DB), nil, service.DefaultAccountDeletionGracePeriod, webhookService),
+		userRepo:	userRepo,
+		conversations:	conversationRepo,
+		deletion:	service.NewAccountDeletionWorker(userRepo, repository.NewExportRepository(database.DB), store, time.Minute),
+		migrator:	migrate.NewMigrator(database.DB, schemaMigrations),
+		storageDir:	storageDir,
+	}, nil
+}
+
+// Stands in for the storage directory when it cannot be found
+type missingStore struct{}
+
+func (missingStore) Put(ctx context.Context, key string, r io.Reader) error {
+	return storage.ErrObjectNotFound
+}
+
+func (missingStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return nil, storage.ErrObjectNotFound
+}
+
+func (missingStore) Delete(ctx context.Context, key string) error {
+	return storage.ErrObjectNotFound
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/EliasLd/gotalk-backend/internal/database/migrate"
+)
+
+const migrateUsage = `Usage: gotalkctl migrate [-json] <command>
+
+Commands:
+  up              Apply every pending migration
+  down [N]        Revert the N latest migrations, 1 by default
+  goto VERSION    Apply or revert migrations until VERSION is the latest applied, 0 reverts everything
+  baseline VERSION
+                  Record the migrations up to VERSION as applied without running them,
+                  for databases migrated by hand before gotalkctl
+
+Flags:
+`
+
+type migrationView struct {
+	Version	uint64	`json:"version"`
+	Name	string	`json:"name"`
+	// File name without the direction, as in 000001_create_users_table
+	File	string	`json:"file"`
+}
+
+func newMigrationView(migration migrate.Migration) migrationView {
+	return migrationView {
+		Version:	migration.Version,
+		Name:		migration.Name,
+		File:		fmt.Sprintf("%06d_%s", migration.Version, migration.Name),
+	}
+}
+
+func newMigrationViews(migrations []migrate.Migration) []migrationView {
+	views := make([]migrationView, 0, len(migrations))
+	for _, migration := range migrations {
+		views = append(views, newMigrationView(migration))
+	}
+	return views
+}
+
+func runMigrate(ctx context.Context, app *app, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), migrateUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var ran []migrate.Migration
+	var err error
+	verb := "Applied"
+	switch command, operands := fs.Arg(0), fs.Args()[1:]; {
+	case command == "up" && len(operands) == 0:
+		ran, err = app.migrator.Up(ctx)
+	case command == "down" && len(operands) <= 1:
+		steps := 1
+		if len(operands) == 1 {
+			if steps, err = strconv.Atoi(operands[0]); err != nil {
+				return fmt.Errorf("invalid number of migrations %q", operands[0])
+			}
+		}
+		verb = "Reverted"
+		ran, err = app.migrator.Down(ctx, steps)
+	case (command == "goto" || command == "baseline") && len(operands) == 1:
+		version, parseErr := strconv.ParseUint(operands[0], 10, 64)
+		if parseErr != nil {
+			return fmt.Errorf("invalid version %q", operands[0])
+		}
+		if command == "goto" {
+			ran, err = app.migrator.Goto(ctx, version)
+		} else {
+			verb = "Recorded"
+			ran, err = app.migrator.Baseline(ctx, version)
+		}
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	// What ran before a failure stays applied, so it is reported either way
+	if *asJSON {
+		if printErr := printJSON(newMigrationViews(ran)); printErr != nil {
+			return printErr
+		}
+	} else {
+		for _, migration := range ran {
+			fmt.Printf("%s %06d_%s\n", verb, migration.Version, migration.Name)
+		}
+		if err == nil && len(ran) == 0 {
+			fmt.Fprintln(os.Stderr, "Nothing to do.")
+		}
+	}
+
+	if errors.Is(err, migrate.ErrChecksumMismatch) {
+		return fmt.Errorf("%w\nRestore the original file: released migrations must not be edited, add a new one instead", err)
+	}
+	return err
+}
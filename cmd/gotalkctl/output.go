@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+)
+
+// Indented for people, still a single document for jq and scripts
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func newTable(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// Account as printed by the user commands, the password hash is never shown
+type userView struct {
+	ID			uuid.UUID	`json:"id"`
+	Username		string		`json:"username"`
+	DisplayName		string		`json:"displayName"`
+	Admin			bool		`json:"admin"`
+	Bot			bool		`json:"bot"`
+	Status			string		`json:"status"`
+	CreatedAt		time.Time	`json:"createdAt"`
+	UpdatedAt		time.Time	`json:"updatedAt"`
+	DisabledAt		*time.Time	`json:"disabledAt,omitempty"`
+	DeletionScheduledAt	*time.Time	`json:"deletionScheduledAt,omitempty"`
+	DeletedAt		*time.Time	`json:"deletedAt,omitempty"`
+	// Only set by the commands generating one
+	Password		string		`json:"password,omitempty"`
+}
+
+func newUserView(user *models.User) userView {
+	return userView {
+		ID:			user.ID,
+		Username:		user.Username,
+		DisplayName:		user.DisplayName,
+		Admin:			user.IsAdmin,
+		Bot:			user.IsBot,
+		Status:			userStatus(user),
+		CreatedAt:		user.CreatedAt,
+		UpdatedAt:		user.UpdatedAt,
+		DisabledAt:		user.DisabledAt,
+		DeletionScheduledAt:	user.DeletionScheduledAt,
+		DeletedAt:		user.DeletedAt,
+	}
+}
+
+func userStatus(user *models.User) string {
+	switch {
+	case user.IsDeleted():
+		return "deleted"
+	case user.IsDisabled():
+		return "disabled"
+	case user.DeletionScheduledAt != nil:
+		return "pending deletion"
+	}
+	return "active"
+}
+
+func printUser(user userView, asJSON bool) error {
+	if asJSON {
+		return printJSON(user)
+	}
+
+	w := newTable(os.Stdout)
+	fmt.Fprintf(w, "ID:\t%s\n", user.ID)
+	fmt.Fprintf(w, "Username:\t%s\n", user.Username)
+	if user.DisplayName != "" {
+		fmt.Fprintf(w, "Display name:\t%s\n", user.DisplayName)
+	}
+	fmt.Fprintf(w, "Status:\t%s\n", user.Status)
+	fmt.Fprintf(w, "Admin:\t%t\n", user.Admin)
+	if user.Bot {
+		fmt.Fprintf(w, "Bot:\t%t\n", user.Bot)
+	}
+	fmt.Fprintf(w, "Created:\t%s\n", formatTime(&user.CreatedAt))
+	fmt.Fprintf(w, "Updated:\t%s\n", formatTime(&user.UpdatedAt))
+	if user.DisabledAt != nil {
+		fmt.Fprintf(w, "Disabled:\t%s\n", formatTime(user.DisabledAt))
+	}
+	if user.DeletionScheduledAt != nil {
+		fmt.Fprintf(w, "Deletion scheduled:\t%s\n", formatTime(user.DeletionScheduledAt))
+	}
+	if user.DeletedAt != nil {
+		fmt.Fprintf(w, "Deleted:\t%s\n", formatTime(user.DeletedAt))
+	}
+	if user.Password != "" {
+		fmt.Fprintf(w, "Password:\t%s\n", user.Password)
+	}
+	return w.Flush()
+}
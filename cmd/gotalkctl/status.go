@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/database"
+	"github.com/EliasLd/gotalk-backend/internal/database/migrate"
+)
+
+// Schema state as printed by status
+type migrationsView struct {
+	// 0 when no migration was applied
+	Current		uint64		`json:"current"`
+	Latest		uint64		`json:"latest"`
+	Pending		[]migrationView	`json:"pending"`
+	// Applied files edited since, the migration commands refuse to run
+	Modified	[]migrationView	`json:"modified"`
+	// Applied by a newer build
+	Unknown		[]uint64	`json:"unknown"`
+}
+
+func newMigrationsView(status *migrate.Status) *migrationsView {
+	view := &migrationsView {
+		Current:	status.Current,
+		Latest:		status.Latest(),
+		Pending:	newMigrationViews(status.Pending()),
+		Modified:	[]migrationView{},
+		Unknown:	status.Unknown,
+	}
+	for _, state := range status.Migrations {
+		if state.Modified {
+			view.Modified = append(view.Modified, newMigrationView(state.Migration))
+		}
+	}
+	if view.Unknown == nil {
+		view.Unknown = []uint64{}
+	}
+	return view
+}
+
+type databaseStatus struct {
+	Connected	bool	`json:"connected"`
+	Error		string	`json:"error,omitempty"`
+	Version		string	`json:"version,omitempty"`
+	LatencyMs	float64	`json:"latencyMs"`
+}
+
+type statusView struct {
+	Database	databaseStatus		`json:"database"`
+	Migrations	*migrationsView		`json:"migrations,omitempty"`
+	Users		map[string]int		`json:"users,omitempty"`
+	Conversations	int			`json:"conversations"`
+	Messages	int			`json:"messages"`
+}
+
+func runStatus(ctx context.Context, app *app, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	fs.Parse(args)
+
+	var status statusView
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err := database.DB.Ping(ctx)
+	status.Database.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		status.Database.Error = err.Error()
+	} else {
+		status.Database.Connected = true
+		var migrationStatus *migrate.Status
+		migrationStatus, err = app.migrator.Status(ctx)
+		if err == nil {
+			status.Migrations = newMigrationsView(migrationStatus)
+			err = readCounts(ctx, &status)
+		}
+	}
+	if err != nil && status.Database.Connected {
+		return err
+	}
+
+	if *asJSON {
+		if err := printJSON(status); err != nil {
+			return err
+		}
+	} else {
+		printStatus(status)
+	}
+
+	// Scripts and health checks rely on the exit status
+	if !status.Database.Connected {
+		return errors.New("database unreachable")
+	}
+	migrations := status.Migrations
+	if len(migrations.Pending) > 0 || len(migrations.Modified) > 0 || len(migrations.Unknown) > 0 {
+		return errors.New("database schema is not up to date")
+	}
+	return nil
+}
+
+func readCounts(ctx context.Context, status *statusView) error {
+	err := database.DB.QueryRow(ctx, `SELECT version()`).Scan(&status.Database.Version)
+	if err != nil {
+		return err
+	}
+
+	var active, disabled, pending, deleted, bots int
+	err = database.DB.QueryRow(ctx, `
+		SELECT
+			count(*) FILTER (WHERE deleted_at IS NULL AND disabled_at IS NULL AND deletion_scheduled_at IS NULL AND NOT is_bot),
+			count(*) FILTER (WHERE deleted_at IS NULL AND disabled_at IS NOT NULL),
+			count(*) FILTER (WHERE deleted_at IS NULL AND disabled_at IS NULL AND deletion_scheduled_at IS NOT NULL),
+			count(*) FILTER (WHERE deleted_at IS NOT NULL),
+			count(*) FILTER (WHERE deleted_at IS NULL AND is_bot)
+		FROM users
+	`).Scan(&active, &disabled, &pending, &deleted, &bots)
+	if err != nil {
+		return err
+	}
+	status.Users = map[string]int {
+		"active":		active,
+		"disabled":		disabled,
+		"pendingDeletion":	pending,
+		"deleted":		deleted,
+		"bots":			bots,
+	}
+
+	return database.DB.QueryRow(ctx, `SELECT (SELECT count(*) FROM conversations), (SELECT count(*) FROM messages)`).
+		Scan(&status.Conversations, &status.Messages)
+}
+
+func printStatus(status statusView) {
+	w := newTable(os.Stdout)
+	defer w.Flush()
+
+	if !status.Database.Connected {
+		fmt.Fprintf(w, "Database:\tunreachable (%s)\n", status.Database.Error)
+		return
+	}
+	fmt.Fprintf(w, "Database:\tconnected, %.1fms\n", status.Database.LatencyMs)
+	fmt.Fprintf(w, "Server:\t%s\n", status.Database.Version)
+
+	migrations := status.Migrations
+	fmt.Fprintf(w, "Schema:\tversion %d of %d, %d pending\n", migrations.Current, migrations.Latest, len(migrations.Pending))
+	for _, migration := range migrations.Pending {
+		fmt.Fprintf(w, "\t  pending %s\n", migration.File)
+	}
+	for _, migration := range migrations.Modified {
+		fmt.Fprintf(w, "\t  MODIFIED since applied: %s\n", migration.File)
+	}
+	for _, version := range migrations.Unknown {
+		fmt.Fprintf(w, "\t  UNKNOWN version %d, applied by a newer build\n", version)
+	}
+
+	fmt.Fprintf(w, "Users:\t%d active, %d disabled, %d pending deletion, %d deleted, %d bots\n",
+		status.Users["active"], status.Users["disabled"], status.Users["pendingDeletion"], status.Users["deleted"], status.Users["bots"])
+	fmt.Fprintf(w, "Conversations:\t%d\n", status.Conversations)
+	fmt.Fprintf(w, "Messages:\t%d\n", status.Messages)
+}
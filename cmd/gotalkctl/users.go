@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/service"
+	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
+)
+
+const userUsage = `Usage: gotalkctl user <create|show|disable|enable|delete|reset-password|admin> [flags] <username or ID>
+`
+
+func runUser(ctx context.Context, app *app, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, userUsage)
+		os.Exit(2)
+	}
+
+	subcommands := map[string]command {
+		"create":		runUserCreate,
+		"show":			runUserShow,
+		"disable":		runUserDisable,
+		"enable":		runUserEnable,
+		"delete":		runUserDelete,
+		"reset-password":	runUserResetPassword,
+		"admin":		runUserAdmin,
+	}
+
+	if isHelpFlag(args[0]) {
+		fmt.Print(userUsage)
+		return nil
+	}
+	run, ok := subcommands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "gotalkctl: unknown user command %q\n\n%s", args[0], userUsage)
+		os.Exit(2)
+	}
+	return run(ctx, app, args[1:])
+}
+
+// Parses the flags of a user command, which all take a single user
+func parseUserFlags(fs *flag.FlagSet, usage string, args []string) (string, *bool) {
+	asJSON := fs.Bool("json", false, "print JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gotalkctl user %s\n", usage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return fs.Arg(0), asJSON
+}
+
+// Looks a user up by ID, or by username otherwise
+func findUser(ctx context.Context, app *app, ref string) (*models.User, error) {
+	var user *models.User
+	var err error
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		user, err = app.userRepo.GetUserByID(ctx, id)
+	} else {
+		user, err = app.userRepo.GetUserByUsername(ctx, ref)
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("no user %q", ref)
+	}
+	return user, err
+}
+
+func runUserCreate(ctx context.Context, app *app, args []string) error {
+	fs := flag.NewFlagSet("user create", flag.ExitOnError)
+	admin := fs.Bool("admin", false, "make the user a server administrator")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from the first line of stdin instead of generating one")
+	username, asJSON := parseUserFlags(fs, "create [-admin] [-password-stdin] [-json] <username>", args)
+
+	password := ""
+	if *passwordStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return errors.New("expected the password on stdin")
+		}
+		password = strings.TrimRight(line, "\r\n")
+	} else {
+		generated, err := service.GeneratePassword()
+		if err != nil {
+			return err
+		}
+		password = generated
+	}
+
+	user, err := app.users.RegisterUser(ctx, username, password)
+	if err != nil {
+		return err
+	}
+
+	if *admin {
+		if user, err = app.users.SetAdmin(ctx, user.ID, true); err != nil {
+			return fmt.Errorf("user created but not made administrator: %w", err)
+		}
+	}
+
+	view := newUserView(user)
+	if !*passwordStdin {
+		view.Password = password
+	}
+	return printUser(view, *asJSON)
+}
+
+func runUserShow(ctx context.Context, app *app, args []string) error {
+	fs := flag.NewFlagSet("user show", flag.ExitOnError)
+	ref, asJSON := parseUserFlags(fs, "show [-json] <username or ID>", args)
+
+	user, err := findUser(ctx, app, ref)
+	if err != nil {
+		return err
+	}
+	return printUser(newUserView(user), *asJSON)
+}
+
+func runUserDisable(ctx context.Context, app *app, args []string) error {
+	fs := flag.NewFlagSet("user disable", flag.ExitOnError)
+	ref, asJSON := parseUserFlags(fs, "disable [-json] <username or ID>", args)
+	return setDisabled(ctx, app, ref, true, *asJSON)
+}
+
+func runUserEnable(ctx context.Context, app *app, args []string) error {
+	fs := flag.NewFlagSet("user enable", flag.ExitOnError)
+	ref, asJSON := parseUserFlags(fs, "enable [-json] <username or ID>", args)
+	return setDisabled(ctx, app, ref, false, *asJSON)
+}
+
+func setDisabled(ctx context.Context, app *app, ref string, disabled bool, asJSON bool) error {
+	user, err := findUser(ctx, app, ref)
+	if err != nil {
+		return err
+	}
+
+	user, err = app.users.SetDisabled(ctx, user.ID, disabled)
+	if errors.Is(err, appErr.ErrUserNotFound) {
+		return fmt.Errorf("user %q has been deleted", ref)
+	}
+	if err != nil {
+		return err
+	}
+
+	return printUser(newUserView(user), asJSON)
+}
+
+func runUserDelete(ctx context.Context, app *app, args []string) error {
+	fs := flag.NewFlagSet("user delete", flag.ExitOnError)
+	yes := fs.Bool("yes", false, "confirm the deletion, which cannot be undone")
+	ref, asJSON := parseUserFlags(fs, "delete -yes [-json] <username or ID>", args)
+
+	if !*yes {
+		return errors.New("deleting an account cannot be undone, pass -yes to confirm")
+	}
+
+	// Avatars and data exports would be left behind, with nothing to retry their deletion
+	if app.storageDir == "" {
+		return errors.New("storage directory not found, set STORAGE_DIR to the server's to delete accounts")
+	}
+
+	user, err := findUser(ctx, app, ref)
+	if err != nil {
+		return err
+	}
+
+	anonymized, err := app.deletion.AnonymizeNow(ctx, user)
+	if err != nil {
+		return err
+	}
+	if !anonymized {
+		return fmt.Errorf("user %q is already deleted", ref)
+	}
+
+	// Reloaded to show the anonymized account
+	if user, err = app.userRepo.GetUserByID(ctx, user.ID); err != nil {
+		return err
+	}
+	return printUser(newUserView(user), *asJSON)
+}
+
+func runUserResetPassword(ctx context.Context, app *app, args []string) error {
+	fs := flag.NewFlagSet("user reset-password", flag.ExitOnError)
+	ref, asJSON := parseUserFlags(fs, "reset-password [-json] <username or ID>", args)
+
+	user, err := findUser(ctx, app, ref)
+	if err != nil {
+		return err
+	}
+
+	password, err := app.users.ResetPassword(ctx, user.ID)
+	if errors.Is(err, appErr.ErrUserNotFound) {
+		return fmt.Errorf("user %q is deleted or a bot, it has no password", ref)
+	}
+	if err != nil {
+		return err
+	}
+
+	view := newUserView(user)
+	view.Password = password
+	return printUser(view, *asJSON)
+}
+
+func runUserAdmin(ctx context.Context, app *app, args []string) error {
+	fs := flag.NewFlagSet("user admin", flag.ExitOnError)
+	revoke := fs.Bool("revoke", false, "revoke server administration instead of granting it")
+	ref, asJSON := parseUserFlags(fs, "admin [-revoke] [-json] <username or ID>", args)
+
+	user, err := findUser(ctx, app, ref)
+	if err != nil {
+		return err
+	}
+
+	user, err = app.users.SetAdmin(ctx, user.ID, !*revoke)
+	if errors.Is(err, appErr.ErrUserNotFound) {
+		return fmt.Errorf("user %q has been deleted", ref)
+	}
+	if err != nil {
+		return err
+	}
+	return printUser(newUserView(user), *asJSON)
+}
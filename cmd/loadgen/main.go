@@ -0,0 +1,163 @@
+// Command loadgen drives a running server with many concurrent simulated
+// users, through the HTTP API and the real-time event streams, then reports
+// the latency percentiles and error rate of each operation.
+//
+// Users log into the <prefix>000001, <prefix>000002... accounts created by
+// cmd/seed, and register the ones that do not exist. Each user subscribes to
+// one of its conversations, so that the delay between sending a message and
+// receiving it on the stream is reported as the delivery operation.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/pkg/client"
+)
+
+type config struct {
+	server		string
+	users		int
+	duration	time.Duration
+	ramp		time.Duration
+	think		time.Duration
+	timeout		time.Duration
+	prefix		string
+	password	string
+	maxErrorRate	float64
+	asJSON		bool
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.server, "server", "http://localhost:8080", "root URL of the server")
+	flag.IntVar(&cfg.users, "users", 100, "number of concurrent simulated users")
+	flag.DurationVar(&cfg.duration, "duration", time.Minute, "length of the run, ramp-up included")
+	flag.DurationVar(&cfg.ramp, "ramp", 10*time.Second, "delay over which the users are started")
+	flag.DurationVar(&cfg.think, "think", time.Second, "mean pause between two operations of a user")
+	flag.DurationVar(&cfg.timeout, "timeout", 10*time.Second, "timeout of each request")
+	flag.StringVar(&cfg.prefix, "prefix", "seed_", "prefix of the usernames, as given to seed")
+	flag.StringVar(&cfg.password, "password", "SeedPass123!", "password of every account")
+	flag.Float64Var(&cfg.maxErrorRate, "max-error-rate", 0, "exit with status 1 when the share of failed requests is above this, 0 disables the check")
+	flag.BoolVar(&cfg.asJSON, "json", false, "print the report as JSON")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: loadgen [flags]\n\nSimulates users against a running server and reports latencies and errors.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	switch {
+	case cfg.users < 1:
+		log.Fatal("-users must be at least 1")
+	case cfg.duration <= 0 || cfg.think <= 0 || cfg.timeout <= 0:
+		log.Fatal("-duration, -think and -timeout must be positive")
+	case cfg.ramp < 0 || cfg.ramp >= cfg.duration:
+		log.Fatal("-ramp must be shorter than -duration")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result := run(ctx, &cfg)
+
+	if cfg.asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			log.Fatal(err)
+		}
+	} else if err := result.print(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.maxErrorRate > 0 && result.ErrorRate > cfg.maxErrorRate {
+		log.Printf("Error rate %.2f%% is above %.2f%%", result.ErrorRate*100, cfg.maxErrorRate*100)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, cfg *config) report {
+	// Cancelled rather than timed out, so that the requests cut by the end
+	// of the run are not counted as failures
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	time.AfterFunc(cfg.duration, cancel)
+
+	// Shared by every user, without a timeout since event streams stay open
+	httpClient := &http.Client {
+		Transport: &http.Transport {
+			Proxy:			http.ProxyFromEnvironment,
+			MaxIdleConns:		cfg.users * 2,
+			MaxIdleConnsPerHost:	cfg.users * 2,
+			IdleConnTimeout:	90 * time.Second,
+		},
+	}
+
+	rec := newRecorder()
+	sent := newDeliveries()
+	start := time.Now()
+
+	log.Printf("Starting %d users against %s for %s", cfg.users, cfg.server, cfg.duration)
+	go reportProgress(ctx, rec, start)
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.users; i++ {
+		// Users are started evenly over the ramp-up
+		if i > 0 && cfg.ramp > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(cfg.ramp / time.Duration(cfg.users)):
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		user := &simulatedUser {
+			index:		i,
+			username:	fmt.Sprintf("%s%06d", cfg.prefix, i+1),
+			cfg:		cfg,
+			api:		client.NewClient(cfg.server, client.WithHTTPClient(httpClient)),
+			rec:		rec,
+			deliveries:	sent,
+			rng:		rand.New(rand.NewSource(start.UnixNano() + int64(i))),
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user.run(ctx)
+		}()
+	}
+
+	<-ctx.Done()
+	wg.Wait()
+	httpClient.CloseIdleConnections()
+
+	return rec.report(time.Since(start), cfg.users)
+}
+
+// Logs the overall throughput every 10 seconds
+func reportProgress(ctx context.Context, rec *recorder, start time.Time) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := rec.report(time.Since(start), 0)
+			log.Printf("%d requests, %.1f/s, %.2f%% errors",
+				current.Requests, float64(current.Requests)/current.DurationSeconds, current.ErrorRate*100)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/pkg/client"
+)
+
+// Collects the outcome of every operation, shared by the simulated users
+type recorder struct {
+	mu		sync.Mutex
+	operations	map[string]*operationStats
+}
+
+type operationStats struct {
+	latencies	[]time.Duration
+	errors		int
+	// 429 responses, expected under load and reported apart from errors
+	limited		int
+	lastError	string
+}
+
+func newRecorder() *recorder {
+	return &recorder{operations: map[string]*operationStats{}}
+}
+
+func (r *recorder) observe(name string, latency time.Duration, err error) {
+	// Requests interrupted by the end of the run say nothing about the server
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats, ok := r.operations[name]
+	if !ok {
+		stats = &operationStats{}
+		r.operations[name] = stats
+	}
+
+	switch {
+	case errors.Is(err, client.ErrRateLimited):
+		stats.limited++
+	case err != nil:
+		stats.errors++
+		stats.lastError = err.Error()
+	default:
+		stats.latencies = append(stats.latencies, latency)
+	}
+}
+
+// Times fn as the given operation
+func (r *recorder) time(name string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	r.observe(name, time.Since(start), err)
+	return err
+}
+
+type operationReport struct {
+	Name		string	`json:"name"`
+	Count		int	`json:"count"`
+	Errors		int	`json:"errors"`
+	RateLimited	int	`json:"rateLimited"`
+	ErrorRate	float64	`json:"errorRate"`
+	PerSecond	float64	`json:"perSecond"`
+	P50Ms		float64	`json:"p50Ms"`
+	P90Ms		float64	`json:"p90Ms"`
+	P99Ms		float64	`json:"p99Ms"`
+	MaxMs		float64	`json:"maxMs"`
+	LastError	string	`json:"lastError,omitempty"`
+}
+
+type report struct {
+	DurationSeconds	float64			`json:"durationSeconds"`
+	Users		int			`json:"users"`
+	Requests	int			`json:"requests"`
+	ErrorRate	float64			`json:"errorRate"`
+	Operations	[]operationReport	`json:"operations"`
+}
+
+func (r *recorder) report(elapsed time.Duration, users int) report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := report {
+		DurationSeconds:	elapsed.Seconds(),
+		Users:			users,
+		Operations:		[]operationReport{},
+	}
+
+	errorCount := 0
+	for name, stats := range r.operations {
+		latencies := slices.Clone(stats.latencies)
+		slices.Sort(latencies)
+
+		count := len(latencies) + stats.errors + stats.limited
+		operation := operationReport {
+			Name:		name,
+			Count:		count,
+			Errors:		stats.errors,
+			RateLimited:	stats.limited,
+			ErrorRate:	float64(stats.errors) / float64(count),
+			PerSecond:	float64(count) / elapsed.Seconds(),
+			P50Ms:		milliseconds(percentile(latencies, 0.50)),
+			P90Ms:		milliseconds(percentile(latencies, 0.90)),
+			P99Ms:		milliseconds(percentile(latencies, 0.99)),
+			LastError:	stats.lastError,
+		}
+		if len(latencies) > 0 {
+			operation.MaxMs = milliseconds(latencies[len(latencies)-1])
+		}
+		out.Operations = append(out.Operations, operation)
+
+		// Deliveries are not requests
+		if name != deliveryOperation {
+			out.Requests += count
+			errorCount += stats.errors
+		}
+	}
+
+	slices.SortFunc(out.Operations, func(a, b operationReport) int {
+		switch {
+		case a.Name < b.Name:
+			return -1
+		case a.Name > b.Name:
+			return 1
+		}
+		return 0
+	})
+	if out.Requests > 0 {
+		out.ErrorRate = float64(errorCount) / float64(out.Requests)
+	}
+	return out
+}
+
+// Nearest-rank percentile of sorted latencies, 0 when there are none
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p*float64(len(sorted)) + 0.5)
+	rank = min(max(rank, 1), len(sorted))
+	return sorted[rank-1]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func (r report) print(w io.Writer) error {
+	fmt.Fprintf(w, "%d users for %.1fs: %d requests (%.1f/s), %.2f%% errors\n\n",
+		r.Users, r.DurationSeconds, r.Requests, float64(r.Requests)/r.DurationSeconds, r.ErrorRate*100)
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "OPERATION\tCOUNT\tPER SEC\tERRORS\t429\tP50 MS\tP90 MS\tP99 MS\tMAX MS\t")
+	for _, op := range r.Operations {
+		fmt.Fprintf(table, "%s\t%d\t%.1f\t%d\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+			op.Name, op.Count, op.PerSecond, op.Errors, op.RateLimited, op.P50Ms, op.P90Ms, op.P99Ms, op.MaxMs)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	for _, op := range r.Operations {
+		if op.LastError != "" {
+			fmt.Fprintf(w, "\nLast %s error: %s", op.Name, op.LastError)
+		}
+	}
+	fmt.Fprintln(w)
+	return nil
+}
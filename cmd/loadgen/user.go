@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/EliasLd/gotalk-backend/pkg/client"
+)
+
+// Name of the send to receive latency of messages over the real-time API
+const deliveryOperation = "delivery"
+
+// Weighted mix of operations run by each simulated user
+var actions = []struct {
+	weight	int
+	run	func(u *simulatedUser, ctx context.Context) error
+}{
+	{35, (*simulatedUser).sendMessage},
+	{30, (*simulatedUser).readHistory},
+	{15, (*simulatedUser).listConversations},
+	{10, (*simulatedUser).searchUsers},
+	{10, (*simulatedUser).viewProfile},
+}
+
+var totalWeight = func() int {
+	total := 0
+	for _, action := range actions {
+		total += action.weight
+	}
+	return total
+}()
+
+// Messages sent and not yet received, keyed by the token they carry
+type deliveries struct {
+	runID	string
+	counter	atomic.Uint64
+
+	mu	sync.Mutex
+	sentAt	map[string]time.Time
+}
+
+func newDeliveries() *deliveries {
+	return &deliveries {
+		runID:	fmt.Sprintf("%x", time.Now().UnixNano()&0xffffff),
+		sentAt:	map[string]time.Time{},
+	}
+}
+
+func (d *deliveries) newToken() string {
+	return fmt.Sprintf("[lg:%s-%d]", d.runID, d.counter.Add(1))
+}
+
+func (d *deliveries) sent(token string, at time.Time) {
+	d.mu.Lock()
+	d.sentAt[token] = at
+	d.mu.Unlock()
+}
+
+// Latency of a received message, false when it was not sent by this run
+func (d *deliveries) received(content string) (time.Duration, bool) {
+	token, _, ok := strings.Cut(content, " ")
+	if !ok {
+		return 0, false
+	}
+
+	d.mu.Lock()
+	at, ok := d.sentAt[token]
+	d.mu.Unlock()
+	if !ok {
+		return 0, false
+	}
+	return time.Since(at), true
+}
+
+type simulatedUser struct {
+	index		int
+	username	string
+	cfg		*config
+	api		*client.Client
+	rec		*recorder
+	deliveries	*deliveries
+	rng		*rand.Rand
+
+	id		uuid.UUID
+	conversations	[]uuid.UUID
+}
+
+func (u *simulatedUser) request(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, u.cfg.timeout)
+	defer cancel()
+	return u.rec.time(name, func() error { return fn(ctx) })
+}
+
+// Logs in, registering the account first when it does not exist yet
+func (u *simulatedUser) login(ctx context.Context) error {
+	login := func(ctx context.Context) error {
+		_, err := u.api.Login(ctx, u.username, u.cfg.password)
+		return err
+	}
+
+	err := u.request(ctx, "login", login)
+	if errors.Is(err, client.ErrUnauthorized) {
+		err = u.request(ctx, "register", func(ctx context.Context) error {
+			_, err := u.api.Register(ctx, u.username, u.cfg.password)
+			return err
+		})
+		if err == nil {
+			err = u.request(ctx, "login", login)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return u.request(ctx, "me", func(ctx context.Context) error {
+		me, err := u.api.Me(ctx)
+		if err == nil {
+			u.id = me.ID
+		}
+		return err
+	})
+}
+
+func (u *simulatedUser) run(ctx context.Context) {
+	if err := u.login(ctx); err != nil {
+		return
+	}
+
+	u.listConversations(ctx)
+	if len(u.conversations) == 0 {
+		u.openDirect(ctx)
+	}
+	if len(u.conversations) > 0 {
+		go u.listen(ctx, u.conversations[0])
+	}
+
+	for {
+		// Exponential think time, as users act independently of each other
+		think := time.Duration(u.rng.ExpFloat64() * float64(u.cfg.think))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(think):
+		}
+
+		n := u.rng.Intn(totalWeight)
+		for _, action := range actions {
+			if n < action.weight {
+				action.run(u, ctx)
+				break
+			}
+			n -= action.weight
+		}
+	}
+}
+
+// Records the delivery latency of the messages received in a conversation
+func (u *simulatedUser) listen(ctx context.Context, conversationID uuid.UUID) {
+	sub := u.api.Subscribe(ctx, conversationID, client.SubscribeOptions{})
+	defer sub.Close()
+
+	for event := range sub.Events() {
+		if event.Type != client.EventMessageCreated || event.Message == nil {
+			continue
+		}
+		if latency, ok := u.deliveries.received(event.Message.Content); ok {
+			u.rec.observe(deliveryOperation, latency, nil)
+		}
+	}
+	if err := sub.Err(); err != nil {
+		u.rec.observe(deliveryOperation, 0, err)
+	}
+}
+
+// Opens a direct conversation with the next simulated user, for accounts
+// that are not a member of anything yet
+func (u *simulatedUser) openDirect(ctx context.Context) error {
+	peer := fmt.Sprintf("%s%06d", u.cfg.prefix, (u.index+1)%u.cfg.users+1)
+	if peer == u.username {
+		return nil
+	}
+
+	var peerID uuid.UUID
+	err := u.request(ctx, "search_users", func(ctx context.Context) error {
+		page, err := u.api.SearchUsers(ctx, peer, "", 1)
+		if err == nil && len(page.Users) > 0 && page.Users[0].Username == peer {
+			peerID = page.Users[0].ID
+		}
+		return err
+	})
+	// The peer may not have registered yet, retried on the next send
+	if err != nil || peerID == uuid.Nil {
+		return err
+	}
+
+	return u.request(ctx, "open_direct", func(ctx context.Context) error {
+		conversation, _, err := u.api.OpenDirectConversation(ctx, peerID)
+		if err == nil {
+			u.conversations = append(u.conversations, conversation.ID)
+		}
+		return err
+	})
+}
+
+func (u *simulatedUser) pickConversation() (uuid.UUID, bool) {
+	if len(u.conversations) == 0 {
+		return uuid.Nil, false
+	}
+	return u.conversations[u.rng.Intn(len(u.conversations))], true
+}
+
+func (u *simulatedUser) sendMessage(ctx context.Context) error {
+	conversationID, ok := u.pickConversation()
+	if !ok {
+		return u.openDirect(ctx)
+	}
+
+	token := u.deliveries.newToken()
+	content := token + " " + sentences[u.rng.Intn(len(sentences))]
+	return u.request(ctx, "send_message", func(ctx context.Context) error {
+		u.deliveries.sent(token, time.Now())
+		_, err := u.api.SendMessage(ctx, conversationID, client.SendMessageInput{Content: content})
+		return err
+	})
+}
+
+// Reads the latest page of a conversation, sometimes scrolling further back
+func (u *simulatedUser) readHistory(ctx context.Context) error {
+	conversationID, ok := u.pickConversation()
+	if !ok {
+		return nil
+	}
+
+	var messages []client.Message
+	err := u.request(ctx, "list_messages", func(ctx context.Context) error {
+		var err error
+		messages, err = u.api.ListMessages(ctx, conversationID, client.ListMessagesOptions{Limit: 50})
+		return err
+	})
+	if err != nil || len(messages) == 0 || u.rng.Float64() >= 0.3 {
+		return err
+	}
+
+	before := messages[len(messages)-1].CreatedAt
+	return u.request(ctx, "list_messages_before", func(ctx context.Context) error {
+		_, err := u.api.ListMessages(ctx, conversationID, client.ListMessagesOptions{Before: before, Limit: 50})
+		return err
+	})
+}
+
+func (u *simulatedUser) listConversations(ctx context.Context) error {
+	return u.request(ctx, "list_conversations", func(ctx context.Context) error {
+		conversations, err := u.api.ListConversations(ctx)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(conversations))
+		for i, conversation := range conversations {
+			ids[i] = conversation.ID
+		}
+		u.conversations = ids
+		return nil
+	})
+}
+
+// Searches a username prefix, as typed in a member picker
+func (u *simulatedUser) searchUsers(ctx context.Context) error {
+	query := u.cfg.prefix + fmt.Sprintf("%06d", u.rng.Intn(u.cfg.users)+1)[:2+u.rng.Intn(3)]
+	return u.request(ctx, "search_users", func(ctx context.Context) error {
+		_, err := u.api.SearchUsers(ctx, query, "", 20)
+		return err
+	})
+}
+
+func (u *simulatedUser) viewProfile(ctx context.Context) error {
+	return u.request(ctx, "get_user", func(ctx context.Context) error {
+		_, err := u.api.GetUser(ctx, u.id)
+		return err
+	})
+}
+
+var sentences = []string {
+	"sounds good to me",
+	"can someone review my branch?",
+	"deploying in five minutes",
+	"lunch at noon?",
+	"the build is green again",
+	"I'll look into it after the meeting",
+	"thanks, that fixed it!",
+	"did anyone check the latency graphs today?",
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var firstNames = []string {
+	"Alice", "Amir", "Ana", "Ben", "Camille", "Chen", "Chloé", "Daniel", "Diego", "Elena",
+	"Elias", "Emma", "Farah", "Felix", "Grace", "Hana", "Hugo", "Ines", "Ivan", "Jade",
+	"Jonas", "Julia", "Kenji", "Lea", "Leo", "Lina", "Lucas", "Maya", "Mehdi", "Mia",
+	"Nadia", "Noah", "Olga", "Omar", "Paul", "Priya", "Quentin", "Rosa", "Sam", "Sara",
+	"Sofia", "Tom", "Yara", "Yusuf", "Zoé",
+}
+
+var lastNames = []string {
+	"Martin", "Bernard", "Dubois", "Garcia", "Müller", "Rossi", "Smith", "Johnson", "Kowalski", "Nguyen",
+	"Tanaka", "Kim", "Silva", "Novak", "Haddad", "Petrov", "Laurent", "Moreau", "Fischer", "Andersen",
+}
+
+var timezones = []string {
+	"", "", "Europe/Paris", "Europe/Berlin", "Europe/London", "America/New_York",
+	"America/Los_Angeles", "America/Sao_Paulo", "Asia/Tokyo", "Asia/Kolkata", "Australia/Sydney",
+}
+
+var pronouns = []string{"", "", "", "she/her", "he/him", "they/them"}
+
+var words = strings.Fields(`
+	the a to and of it is that for on you this we in be have with not are
+	just so but can do what if was will get all about at like yes no ok
+	lunch meeting deploy review branch build test tomorrow today tonight later
+	coffee team release bug fix issue ticket docs weekend plan call idea
+	think know need want see check send push merge ship try look sounds good
+	great thanks sure maybe probably really quick small big new old first last
+	server client database query index cache latency page search message
+`)
+
+var emotes = []string{"waves", "laughs", "nods", "is away for a bit", "shrugs", "is back", "claps"}
+
+// Share of the messages posted at each hour of the day, busiest in the afternoon
+var hourlyActivity = [24]float64 {
+	0.2, 0.1, 0.1, 0.1, 0.1, 0.2, 0.4, 0.7, 1.0, 1.2, 1.3, 1.3,
+	1.1, 1.2, 1.4, 1.5, 1.5, 1.4, 1.2, 1.1, 1.0, 0.8, 0.6, 0.4,
+}
+
+var maxHourlyActivity = slices.Max(hourlyActivity[:])
+
+// Reproducible source of the generated data
+type generator struct {
+	rng	*rand.Rand
+}
+
+func newGenerator(seed int64) *generator {
+	return &generator{rng: rand.New(rand.NewSource(seed))}
+}
+
+func (g *generator) uuid() uuid.UUID {
+	id, err := uuid.NewRandomFromReader(g.rng)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+func (g *generator) pick(values []string) string {
+	return values[g.rng.Intn(len(values))]
+}
+
+func (g *generator) chance(p float64) bool {
+	return g.rng.Float64() < p
+}
+
+// Uniform time in [from, to)
+func (g *generator) timeBetween(from, to time.Time) time.Time {
+	span := to.Sub(from)
+	if span <= 0 {
+		return from
+	}
+	return from.Add(time.Duration(g.rng.Int63n(int64(span))))
+}
+
+// Time in [from, to) following the daily activity curve
+func (g *generator) activeTimeBetween(from, to time.Time) time.Time {
+	for i := 0; i < 20; i++ {
+		t := g.timeBetween(from, to)
+		if g.rng.Float64()*maxHourlyActivity < hourlyActivity[t.Hour()] {
+			return t
+		}
+	}
+	return g.timeBetween(from, to)
+}
+
+func (g *generator) sentence() string {
+	// Mostly short messages, some long ones
+	n := 1 + int(g.rng.ExpFloat64()*6)
+	if n > 40 {
+		n = 40
+	}
+
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = g.pick(words)
+	}
+	text := strings.Join(parts, " ")
+	text = strings.ToUpper(text[:1]) + text[1:]
+
+	switch {
+	case g.chance(0.15):
+		return text + "?"
+	case g.chance(0.1):
+		return text + "!"
+	case g.chance(0.3):
+		return text + "."
+	}
+	return text
+}
+
+// Integer range given as MIN-MAX, or a single value
+type intRange struct {
+	min, max int
+}
+
+func parseIntRange(value string) (intRange, error) {
+	var r intRange
+	if minStr, maxStr, ok := strings.Cut(value, "-"); ok {
+		if _, err := fmt.Sscan(minStr, &r.min); err != nil {
+			return r, fmt.Errorf("invalid range %q", value)
+		}
+		if _, err := fmt.Sscan(maxStr, &r.max); err != nil {
+			return r, fmt.Errorf("invalid range %q", value)
+		}
+	} else {
+		if _, err := fmt.Sscan(value, &r.min); err != nil {
+			return r, fmt.Errorf("invalid range %q", value)
+		}
+		r.max = r.min
+	}
+
+	if r.min < 1 || r.max < r.min {
+		return r, fmt.Errorf("invalid range %q", value)
+	}
+	return r, nil
+}
+
+// Draws sizes in a range, either uniformly or following a power law
+// where small values are the most common
+type sizeDistribution struct {
+	r	intRange
+	zipf	*rand.Zipf
+}
+
+func newSizeDistribution(g *generator, r intRange, name string) (*sizeDistribution, error) {
+	d := &sizeDistribution{r: r}
+	switch name {
+	case "uniform":
+	case "zipf":
+		if r.max > r.min {
+			d.zipf = rand.NewZipf(g.rng, 1.3, 1, uint64(r.max-r.min))
+		}
+	default:
+		return nil, fmt.Errorf("unknown distribution %q, expected uniform or zipf", name)
+	}
+	return d, nil
+}
+
+func (d *sizeDistribution) draw(g *generator) int {
+	if d.zipf != nil {
+		return d.r.min + int(d.zipf.Uint64())
+	}
+	return d.r.min + g.rng.Intn(d.r.max-d.r.min+1)
+}
+
+// Splits total between len(weights) shares proportional to the weights
+func allocate(total int, weights []float64) []int {
+	sum := 0.0
+	for _, w := range weights {
+		sum += w
+	}
+
+	counts := make([]int, len(weights))
+	allocated := 0
+	for i, w := range weights {
+		counts[i] = int(math.Floor(float64(total) * w / sum))
+		allocated += counts[i]
+	}
+
+	// Rounding leftovers go to the busiest shares
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		switch {
+		case weights[a] > weights[b]:
+			return -1
+		case weights[a] < weights[b]:
+			return 1
+		}
+		return 0
+	})
+	for i := 0; allocated < total; i++ {
+		counts[order[i%len(order)]]++
+		allocated++
+	}
+	return counts
+}
@@ -0,0 +1,377 @@
+// Command seed fills a development database with realistic volumes of users,
+// conversations and messages, to exercise pagination, search and the
+// queries of busy conversations. Rows are loaded with COPY in a single
+// transaction, a failed run leaves nothing behind.
+//
+// Accounts are named <prefix>000001, <prefix>000002... and share one
+// password, so that cmd/loadgen can log into them.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/EliasLd/gotalk-backend/internal/database"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/service"
+)
+
+var groupNames = []string {
+	"general", "random", "backend", "frontend", "design", "ops", "on-call", "release planning",
+	"book club", "climbing", "music", "football", "lunch", "announcements", "support", "hiring",
+}
+
+type options struct {
+	users			int
+	conversations		int
+	members			intRange
+	memberDistribution	string
+	directShare		float64
+	messages		int
+	days			int
+	prefix			string
+	password		string
+	seed			int64
+}
+
+type seedUser struct {
+	id		uuid.UUID
+	username	string
+	createdAt	time.Time
+}
+
+type seedMember struct {
+	user		int
+	role		models.MemberRole
+	joinedAt	time.Time
+}
+
+type seedConversation struct {
+	id		uuid.UUID
+	direct		bool
+	public		bool
+	name		*string
+	topic		*string
+	createdAt	time.Time
+	members		[]seedMember
+	messages	int
+}
+
+func main() {
+	var opts options
+	var members string
+	flag.IntVar(&opts.users, "users", 1000, "number of users")
+	flag.IntVar(&opts.conversations, "conversations", 300, "number of conversations")
+	flag.StringVar(&members, "members", "3-50", "size of group conversations, MIN-MAX")
+	flag.StringVar(&opts.memberDistribution, "member-distribution", "zipf", "distribution of group sizes: zipf (mostly small groups) or uniform")
+	flag.Float64Var(&opts.directShare, "direct", 0.4, "share of direct conversations, between 0 and 1")
+	flag.IntVar(&opts.messages, "messages", 100000, "number of messages, spread unevenly between conversations")
+	flag.IntVar(&opts.days, "days", 90, "history covered by the messages, ending now")
+	flag.StringVar(&opts.prefix, "prefix", "seed_", "prefix of the usernames, must not be in use")
+	flag.StringVar(&opts.password, "password", "SeedPass123!", "password of every account")
+	flag.Int64Var(&opts.seed, "seed", 1, "seed of the random generator, the same seed generates the same data")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: seed [flags]\n\nFills the database given by DATABASE_URL (or .env) with generated data.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var err error
+	if opts.members, err = parseIntRange(members); err != nil {
+		log.Fatalf("Invalid -members: %v", err)
+	}
+	switch {
+	case opts.users < 2:
+		log.Fatal("-users must be at least 2")
+	case opts.conversations < 1 || opts.messages < 0 || opts.days < 1:
+		log.Fatal("-conversations and -days must be positive, -messages cannot be negative")
+	case opts.directShare < 0 || opts.directShare > 1:
+		log.Fatal("-direct must be between 0 and 1")
+	case opts.members.min < 2:
+		log.Fatal("group conversations need at least 2 members")
+	}
+	if err := service.ValidatePassword(opts.password); err != nil {
+		log.Fatalf("Invalid -password: %v", err)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println(".env file not found, pursuing with system environment variables")
+	}
+	if err := database.Connect(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := seed(ctx, opts); err != nil {
+		log.Fatalf("Seeding failed, nothing was saved: %v", err)
+	}
+}
+
+func seed(ctx context.Context, opts options) error {
+	var taken bool
+	err := database.DB.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE starts_with(username, $1))`, opts.prefix).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("usernames starting with %q already exist, pick another -prefix", opts.prefix)
+	}
+
+	g := newGenerator(opts.seed)
+	end := time.Now().UTC().Truncate(time.Second)
+	start := end.AddDate(0, 0, -opts.days)
+
+	users := generateUsers(g, opts, start)
+	conversations, err := generateConversations(g, opts, users, start, end)
+	if err != nil {
+		return err
+	}
+
+	// bcrypt is slow on purpose, every account shares the same hash
+	hash, err := bcrypt.GenerateFromPassword([]byte(opts.password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	began := time.Now()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := copyUsers(ctx, tx, g, users, string(hash)); err != nil {
+		return fmt.Errorf("copying users: %w", err)
+	}
+	if err := copyConversations(ctx, tx, conversations); err != nil {
+		return fmt.Errorf("copying conversations: %w", err)
+	}
+	memberCount, err := copyMembers(ctx, tx, users, conversations)
+	if err != nil {
+		return fmt.Errorf("copying members: %w", err)
+	}
+	messageCount, err := copyMessages(ctx, tx, g, users, conversations, end)
+	if err != nil {
+		return fmt.Errorf("copying messages: %w", err)
+	}
+
+	log.Println("Committing...")
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	// Fresh statistics, otherwise the planner keeps assuming small tables for a while
+	log.Println("Analyzing tables...")
+	if _, err := database.DB.Exec(ctx, `ANALYZE users, conversations, conversation_members, messages`); err != nil {
+		return err
+	}
+
+	elapsed := time.Since(began)
+	fmt.Printf("Seeded %d users, %d conversations, %d memberships and %d messages in %s (%.0f messages/s)\n",
+		len(users), len(conversations), memberCount, messageCount, elapsed.Round(time.Millisecond),
+		float64(messageCount)/elapsed.Seconds())
+	fmt.Printf("Accounts %s to %s, password %q\n", users[0].username, users[len(users)-1].username, opts.password)
+	return nil
+}
+
+// Users signed up during the month before the history starts
+func generateUsers(g *generator, opts options, start time.Time) []seedUser {
+	users := make([]seedUser, opts.users)
+	for i := range users {
+		users[i] = seedUser {
+			id:		g.uuid(),
+			username:	fmt.Sprintf("%s%06d", opts.prefix, i+1),
+			createdAt:	g.timeBetween(start.AddDate(0, 0, -30), start),
+		}
+	}
+	return users
+}
+
+func generateConversations(g *generator, opts options, users []seedUser, start, end time.Time) ([]*seedConversation, error) {
+	sizes, err := newSizeDistribution(g, opts.members, opts.memberDistribution)
+	if err != nil {
+		return nil, err
+	}
+
+	// Conversations are created over the history, leaving time for their messages
+	span := end.Sub(start)
+	latestCreation := end.Add(-span / 20)
+
+	directPairs := map[[2]int]bool{}
+	conversations := make([]*seedConversation, 0, opts.conversations)
+	for len(conversations) < opts.conversations {
+		conversation := &seedConversation {
+			id:		g.uuid(),
+			createdAt:	g.timeBetween(start, latestCreation),
+		}
+
+		var memberIndexes []int
+		if g.chance(opts.directShare) {
+			a, b := g.rng.Intn(len(users)), g.rng.Intn(len(users))
+			pair := [2]int{min(a, b), max(a, b)}
+			// Every pair may only have one direct conversation
+			if a == b || directPairs[pair] {
+				if len(directPairs) >= len(users)*(len(users)-1)/2 {
+					return nil, fmt.Errorf("not enough users for %d direct conversations", opts.conversations)
+				}
+				continue
+			}
+			directPairs[pair] = true
+			conversation.direct = true
+			memberIndexes = []int{a, b}
+		} else {
+			size := min(sizes.draw(g), len(users))
+			memberIndexes = sampleIndexes(g, len(users), size)
+
+			name := g.pick(groupNames)
+			conversation.name = &name
+			conversation.public = g.chance(0.2)
+			if g.chance(0.3) {
+				topic := g.sentence()
+				conversation.topic = &topic
+			}
+		}
+
+		// Members join during the first tenth of the conversation's life
+		joinWindow := end.Sub(conversation.createdAt) / 10
+		for i, userIndex := range memberIndexes {
+			member := seedMember {
+				user:		userIndex,
+				role:		models.RoleMember,
+				joinedAt:	conversation.createdAt,
+			}
+			if !conversation.direct {
+				switch {
+				case i == 0:
+					member.role = models.RoleOwner
+				case i <= 2 && g.chance(0.5):
+					member.role = models.RoleAdmin
+				}
+				if i > 0 {
+					member.joinedAt = g.timeBetween(conversation.createdAt, conversation.createdAt.Add(joinWindow))
+				}
+			}
+			conversation.members = append(conversation.members, member)
+		}
+
+		conversations = append(conversations, conversation)
+	}
+
+	// A few conversations hold most of the messages
+	weights := make([]float64, len(conversations))
+	for i, rank := range g.rng.Perm(len(conversations)) {
+		weights[i] = 1 / float64(rank+1)
+	}
+	for i, count := range allocate(opts.messages, weights) {
+		conversations[i].messages = count
+	}
+
+	return conversations, nil
+}
+
+// Distinct indexes below n, without allocating a permutation of n
+func sampleIndexes(g *generator, n, k int) []int {
+	seen := make(map[int]bool, k)
+	indexes := make([]int, 0, k)
+	for len(indexes) < k {
+		i := g.rng.Intn(n)
+		if !seen[i] {
+			seen[i] = true
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+func copyUsers(ctx context.Context, tx pgx.Tx, g *generator, users []seedUser, hash string) error {
+	log.Printf("Copying %d users...", len(users))
+
+	i := 0
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"users"},
+		[]string{"id", "username", "password_hash", "display_name", "pronouns", "timezone", "created_at", "updated_at"},
+		pgx.CopyFromFunc(func() ([]any, error) {
+			if i == len(users) {
+				return nil, nil
+			}
+			user := users[i]
+			i++
+			displayName := g.pick(firstNames) + " " + g.pick(lastNames)
+			return []any{user.id, user.username, hash, displayName, g.pick(pronouns), g.pick(timezones), user.createdAt, user.createdAt}, nil
+		}),
+	)
+	return err
+}
+
+func copyConversations(ctx context.Context, tx pgx.Tx, conversations []*seedConversation) error {
+	log.Printf("Copying %d conversations...", len(conversations))
+
+	rows := make([][]any, len(conversations))
+	for i, c := range conversations {
+		rows[i] = []any{c.id, c.public, c.direct, c.name, c.topic, c.createdAt}
+	}
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"conversations"},
+		[]string{"id", "is_public", "is_direct", "name", "topic", "created_at"},
+		pgx.CopyFromRows(rows),
+	)
+	return err
+}
+
+func copyMembers(ctx context.Context, tx pgx.Tx, users []seedUser, conversations []*seedConversation) (int, error) {
+	var rows [][]any
+	for _, c := range conversations {
+		for _, member := range c.members {
+			rows = append(rows, []any{users[member.user].id, c.id, member.joinedAt, string(member.role)})
+		}
+	}
+	log.Printf("Copying %d memberships...", len(rows))
+
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"conversation_members"},
+		[]string{"user_id", "conversation_id", "joined_at", "role"},
+		pgx.CopyFromRows(rows),
+	)
+	return len(rows), err
+}
+
+// Messages are generated while they are copied, one conversation at a time
+func copyMessages(ctx context.Context, tx pgx.Tx, g *generator, users []seedUser, conversations []*seedConversation, end time.Time) (int64, error) {
+	total := 0
+	for _, c := range conversations {
+		total += c.messages
+	}
+	log.Printf("Copying %d messages...", total)
+
+	stream := &messageStream{g: g, users: users, conversations: conversations, end: end}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				log.Printf("  %d/%d messages", stream.copied.Load(), total)
+			}
+		}
+	}()
+
+	return tx.CopyFrom(ctx, pgx.Identifier{"messages"},
+		[]string{"id", "conversation_id", "sender_id", "content", "content_type", "mention_ids", "created_at"},
+		pgx.CopyFromFunc(stream.next),
+	)
+}
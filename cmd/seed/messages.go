@@ -0,0 +1,98 @@
+package main
+
+import (
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+)
+
+type generatedMessage struct {
+	at	time.Time
+	member	int
+}
+
+// Conversations in a chat happen in bursts: a few members exchange messages
+// for a while, then the conversation stays quiet
+func (g *generator) conversationMessages(c *seedConversation, end time.Time) []generatedMessage {
+	from := c.createdAt
+	for _, member := range c.members {
+		if member.joinedAt.After(from) {
+			from = member.joinedAt
+		}
+	}
+
+	messages := make([]generatedMessage, 0, c.messages)
+	for len(messages) < c.messages {
+		t := g.activeTimeBetween(from, end)
+
+		// Squared, so that the first members are the most talkative
+		speakers := make([]int, 2 + g.rng.Intn(2))
+		for i := range speakers {
+			r := g.rng.Float64()
+			speakers[i] = int(r * r * float64(len(c.members)))
+		}
+		if c.direct {
+			speakers = []int{0, 1}
+		}
+
+		burst := 1 + int(g.rng.ExpFloat64()*8)
+		for i := 0; i < burst && len(messages) < c.messages && t.Before(end); i++ {
+			messages = append(messages, generatedMessage{at: t, member: speakers[g.rng.Intn(len(speakers))]})
+			t = t.Add(time.Duration((1 + g.rng.ExpFloat64()*40) * float64(time.Second)))
+		}
+	}
+
+	slices.SortFunc(messages, func(a, b generatedMessage) int {
+		return a.at.Compare(b.at)
+	})
+	return messages
+}
+
+// Source of the COPY of messages, generating the rows of each conversation in turn
+type messageStream struct {
+	g		*generator
+	users		[]seedUser
+	conversations	[]*seedConversation
+	end		time.Time
+
+	// Position in the conversations and in the messages of the current one
+	conversation	int
+	pending		[]generatedMessage
+	copied		atomic.Int64
+}
+
+func (s *messageStream) next() ([]any, error) {
+	for len(s.pending) == 0 {
+		if s.conversation == len(s.conversations) {
+			return nil, nil
+		}
+		s.pending = s.g.conversationMessages(s.conversations[s.conversation], s.end)
+		s.conversation++
+	}
+
+	c := s.conversations[s.conversation-1]
+	message := s.pending[0]
+	s.pending = s.pending[1:]
+	s.copied.Add(1)
+
+	sender := s.users[c.members[message.member].user]
+	content := s.g.sentence()
+	contentType := models.ContentTypeText
+	mentionIDs := []uuid.UUID{}
+
+	switch {
+	case s.g.chance(0.02):
+		content = s.g.pick(emotes)
+		contentType = models.ContentTypeAction
+	case !c.direct && s.g.chance(0.05):
+		mentioned := s.users[c.members[s.g.rng.Intn(len(c.members))].user]
+		content = "@" + mentioned.username + " " + content
+		mentionIDs = append(mentionIDs, mentioned.id)
+	}
+
+	return []any{s.g.uuid(), c.id, sender.id, content, string(contentType), mentionIDs, message.at}, nil
+}
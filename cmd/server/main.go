@@ -1,41 +1,242 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	"google.golang.org/grpc"
 
+	"github.com/EliasLd/gotalk-backend/internal/auth"
 	"github.com/EliasLd/gotalk-backend/internal/database"
+	"github.com/EliasLd/gotalk-backend/internal/database/migrate"
+	"github.com/EliasLd/gotalk-backend/internal/events"
 	"github.com/EliasLd/gotalk-backend/internal/handlers"
 	httpHandler "github.com/EliasLd/gotalk-backend/internal/http"
+	"github.com/EliasLd/gotalk-backend/internal/http/middleware"
+	"github.com/EliasLd/gotalk-backend/internal/rpc"
 	"github.com/EliasLd/gotalk-backend/internal/service"
 	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/storage"
+	"github.com/EliasLd/gotalk-backend/migrations"
 )
 
+// Time given to requests in progress when the server is stopped
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 
 	if err := godotenv.Load(); err != nil {
 		log.Println(".env file not found, pursuing with system environment variables")
 	}
 
+	// JWTs are signed with the newest PEM key of JWT_KEYS_DIR, and verified with any of them
+	keysDir := os.Getenv("JWT_KEYS_DIR")
+	if keysDir == "" {
+		log.Fatalf("JWT_KEYS_DIR not set, it must hold the Ed25519 or RSA private keys signing JWTs")
+	}
+	keySet, err := auth.LoadKeySet(keysDir)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	auth.SetKeySet(keySet)
+
 	if err := database.Connect(); err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer database.Close()
 
-	userRepo 	:= repository.NewUserRepository(database.DB)
-	userService 	:= service.NewUserService(userRepo) 
+	// The embedded migrations are applied when MIGRATE_ON_START is set, servers
+	// starting together wait for each other. Otherwise pending ones are only reported.
+	schemaMigrations, err := migrate.Load(migrations.FS)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	migrator := migrate.NewMigrator(database.DB, schemaMigrations)
+	if migrateOnStart, _ := strconv.ParseBool(os.Getenv("MIGRATE_ON_START")); migrateOnStart {
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to migrate the database: %v", err)
+		}
+		for _, migration := range applied {
+			log.Printf("Applied migration %06d_%s", migration.Version, migration.Name)
+		}
+	} else if status, err := migrator.Status(context.Background()); err != nil {
+		log.Printf("Failed to read the migration status: %v", err)
+	} else if pending := status.Pending(); len(pending) > 0 {
+		log.Printf("%d migrations pending, run \"gotalkctl migrate up\" or set MIGRATE_ON_START=true", len(pending))
+	}
+
+	// Accounts are anonymized once this delay has passed since the deletion request
+	deletionGracePeriod := service.DefaultAccountDeletionGracePeriod
+	if raw := os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < 0 {
+			log.Fatalf("Invalid ACCOUNT_DELETION_GRACE_PERIOD: %q", raw)
+		}
+		deletionGracePeriod = parsed
+	}
+
+	// Stops the background workers
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sessionRepo := repository.NewSessionRepository(database.DB)
+	// Logged out JWTs, revoked sessions and password changes, reloaded so that
+	// other servers' revocations apply too
+	revokedTokenRepo 		:= repository.NewRevokedTokenRepository(database.DB)
+	tokenRevocationService 	:= service.NewTokenRevocationService(revokedTokenRepo, sessionRepo, 5*time.Second)
+	if err := tokenRevocationService.Sync(ctx); err != nil {
+		log.Fatalf("Failed to load revoked tokens: %v", err)
+	}
+	go tokenRevocationService.Run(ctx)
+
+	userRepo 		:= repository.NewUserRepository(database.DB)
+	conversationRepo 	:= repository.NewConversationRepository(database.DB)
+	webhookRepo 		:= repository.NewWebhookRepository(database.DB)
+	webhookService 		:= service.NewWebhookService(webhookRepo, conversationRepo, userRepo)
+	userService 		:= service.NewUserService(userRepo, sessionRepo, tokenRevocationService, deletionGracePeriod, webhookService)
+
+	// Uploaded files (avatars...) are kept on the local filesystem
+	storageDir := os.Getenv("STORAGE_DIR")
+	if storageDir == "" {
+		storageDir = "data"
+	}
+	store, err := storage.NewLocalStore(storageDir)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	avatarService := service.NewAvatarService(userRepo, store)
 
-	handler := handlers.NewHandler(userService)
-	router 	:= httpHandler.NewRouter(handler)
+	broker 			:= events.NewBroker()
+	messageRepo 		:= repository.NewMessageRepository(database.DB)
+	blockRepo 		:= repository.NewBlockRepository(database.DB)
+	blockService 		:= service.NewBlockService(blockRepo, userRepo, broker)
+
+	// Server-wide filters, applied before each conversation's own filters
+	globalFilters, err := service.ParseContentFilters(os.Getenv("CONTENT_FILTERS"))
+	if err != nil {
+		log.Fatalf("Invalid CONTENT_FILTERS: %v", err)
+	}
+	filterPipeline 		:= service.NewFilterPipeline(conversationRepo, globalFilters)
+
+	// Built-in slash commands, along with the external ones forwarded to HTTP endpoints
+	commands := service.NewBuiltinCommands(conversationRepo, userRepo, blockRepo, broker, webhookService)
+	externalCommands, err := service.ParseExternalCommands(os.Getenv("SLASH_COMMANDS"))
+	if err != nil {
+		log.Fatalf("Invalid SLASH_COMMANDS: %v", err)
+	}
+	if err := service.RegisterExternalCommands(context.Background(), commands, userRepo, externalCommands); err != nil {
+		log.Fatalf("Failed to register slash commands: %v", err)
+	}
+
+	messageService 		:= service.NewMessageService(messageRepo, conversationRepo, blockRepo, broker, filterPipeline, commands, webhookService)
+	conversationService 	:= service.NewConversationService(conversationRepo, userRepo, blockRepo, webhookService, filterPipeline)
+
+	// Notifies clients of disappearing messages and purges them
+	expiryWorker := service.NewMessageExpiryWorker(messageRepo, webhookRepo, broker, time.Second, time.Minute)
+	go expiryWorker.Run(ctx)
+
+	// Download links of data exports are signed with this key
+	exportSigningKey := []byte(os.Getenv("EXPORT_SIGNING_KEY"))
+	if len(exportSigningKey) == 0 {
+		log.Println("EXPORT_SIGNING_KEY not set, using a random key: download links will not survive a restart")
+		exportSigningKey = make([]byte, 32)
+		if _, err := rand.Read(exportSigningKey); err != nil {
+			log.Fatalf("Failed to generate export signing key: %v", err)
+		}
+	}
+	exportRepo 	:= repository.NewExportRepository(database.DB)
+	exportService 	:= service.NewExportService(exportRepo, store, exportSigningKey)
+	exportWorker 	:= service.NewExportWorker(exportRepo, userRepo, conversationRepo, messageRepo, blockRepo, store, 5*time.Second)
+	go exportWorker.Run(ctx)
+
+	// Anonymizes accounts whose deletion grace period is over
+	deletionWorker := service.NewAccountDeletionWorker(userRepo, exportRepo, store, time.Minute)
+	go deletionWorker.Run(ctx)
+
+	accessTokenRepo 	:= repository.NewAccessTokenRepository(database.DB)
+	accessTokenService 	:= service.NewAccessTokenService(accessTokenRepo)
+	refreshTokenRepo 	:= repository.NewRefreshTokenRepository(database.DB)
+	refreshTokenService 	:= service.NewRefreshTokenService(refreshTokenRepo, sessionRepo)
+
+	sessionService := service.NewSessionService(sessionRepo, tokenRevocationService)
+
+	// Sends queued webhook deliveries and retries failed ones
+	webhookWorker := service.NewWebhookWorker(webhookRepo, 2*time.Second)
+	go webhookWorker.Run(ctx)
+
+	incomingWebhookRepo 	:= repository.NewIncomingWebhookRepository(database.DB)
+	incomingWebhookService 	:= service.NewIncomingWebhookService(incomingWebhookRepo, conversationRepo, messageService, avatarService)
+
+	deviceRepo 	:= repository.NewDeviceRepository(database.DB)
+	keyService 	:= service.NewKeyService(deviceRepo, userRepo, blockRepo)
+
+	handler := handlers.NewHandler(userService, messageService, conversationService, blockService, avatarService, exportService, accessTokenService, webhookService, incomingWebhookService, keyService, refreshTokenService, tokenRevocationService, sessionService)
+	// Shared by both APIs, so that searches cannot get around the limit by switching API
+	searchLimiter 	:= middleware.NewSearchRateLimiter()
+	router 		:= httpHandler.NewRouter(handler, accessTokenService, tokenRevocationService, searchLimiter)
+
+	// The gRPC API is served on its own listener, disabled when GRPC_PORT is unset
+	var grpcServer *grpc.Server
+	if grpcPort := os.Getenv("GRPC_PORT"); grpcPort != "" {
+		listener, err := net.Listen("tcp", ":"+grpcPort)
+		if err != nil {
+			log.Fatalf("Failed to listen for gRPC: %v", err)
+		}
+		grpcServer = rpc.NewServer(userService, conversationService, messageService, refreshTokenService, sessionService, tokenRevocationService, searchLimiter)
+
+		log.Printf("gRPC server running on localhost:%s\n", grpcPort)
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatalf("gRPC server failed: %v", err)
+			}
+		}()
+	}
 
 	port := os.Getenv("PORT")
-	addr := ":" + port
-	log.Printf("Server running on http://localhost%s\n", addr)
+	server := &http.Server{Addr: ":" + port, Handler: router}
+	log.Printf("Server running on http://localhost%s\n", server.Addr)
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	// Requests in progress are given some time to complete on SIGINT or SIGTERM
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	<-signals.Done()
+	log.Println("Shutting down")
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	if grpcServer != nil {
+		// Subscription streams never end by themselves, they are cut once the timeout is over
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			grpcServer.Stop()
+		}
+	}
 
-	if err := http.ListenAndServe(addr, router); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down the server gracefully: %v", err)
 	}
 }
@@ -0,0 +1,31 @@
+// Package apidocs serves the OpenAPI description of the HTTP API and a
+// documentation page rendering it, both embedded in the binary.
+package apidocs
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+//go:embed index.html
+var page []byte
+
+// Returns the OpenAPI 3.1 document describing the HTTP API
+func Spec() []byte {
+	return spec
+}
+
+func HandleSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
+
+// Renders /openapi.json client-side, no external assets are loaded
+func HandleUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+	w.Write(page)
+}
@@ -0,0 +1,101 @@
+package apidocs
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Walks the document, calling visit for every "$ref"
+func collectRefs(node any, visit func(ref string)) {
+	switch value := node.(type) {
+	case map[string]any:
+		for key, child := range value {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				visit(ref)
+				continue
+			}
+			collectRefs(child, visit)
+		}
+	case []any:
+		for _, child := range value {
+			collectRefs(child, visit)
+		}
+	}
+}
+
+func lookup(doc map[string]any, ref string) bool {
+	var node any = doc
+	for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		object, ok := node.(map[string]any)
+		if !ok {
+			return false
+		}
+		if node, ok = object[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSpec(t *testing.T) {
+	var doc map[string]any
+	if err := json.Unmarshal(Spec(), &doc); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+
+	if version, _ := doc["openapi"].(string); !strings.HasPrefix(version, "3.1.") {
+		t.Errorf("Expected an OpenAPI 3.1 document, got %q", version)
+	}
+
+	collectRefs(doc, func(ref string) {
+		if !strings.HasPrefix(ref, "#/") || !lookup(doc, ref) {
+			t.Errorf("Unresolved reference %q", ref)
+		}
+	})
+
+	// Operations need a unique ID, and every path wildcard a parameter
+	wildcard := regexp.MustCompile(`\{(\w+)\}`)
+	operationIDs := map[string]bool{}
+	paths, _ := doc["paths"].(map[string]any)
+	for path, rawItem := range paths {
+		item := rawItem.(map[string]any)
+		for method, rawOperation := range item {
+			if method == "parameters" {
+				continue
+			}
+			operation := rawOperation.(map[string]any)
+
+			id, _ := operation["operationId"].(string)
+			if id == "" || operationIDs[id] {
+				t.Errorf("%s %s: missing or duplicate operationId %q", method, path, id)
+			}
+			operationIDs[id] = true
+
+			if _, ok := operation["security"]; !ok {
+				t.Errorf("%s %s: security requirements must be explicit", method, path)
+			}
+
+			declared := map[string]bool{}
+			for _, params := range []any{item["parameters"], operation["parameters"]} {
+				list, _ := params.([]any)
+				for _, rawParam := range list {
+					param := rawParam.(map[string]any)
+					if ref, ok := param["$ref"].(string); ok {
+						name := strings.TrimPrefix(ref, "#/components/parameters/")
+						param = doc["components"].(map[string]any)["parameters"].(map[string]any)[name].(map[string]any)
+					}
+					if param["in"] == "path" {
+						declared[param["name"].(string)] = true
+					}
+				}
+			}
+			for _, match := range wildcard.FindAllStringSubmatch(path, -1) {
+				if !declared[match[1]] {
+					t.Errorf("%s %s: path parameter %q is not declared", method, path, match[1])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// Personal access tokens start with this prefix, which tells them apart from JWTs
+const AccessTokenPrefix = "gtp_"
+
+// Refresh tokens start with this prefix
+const RefreshTokenPrefix = "gtr_"
+
+// Secret tokens embedded in incoming webhook URLs start with this prefix
+const IncomingWebhookTokenPrefix = "gti_"
+
+// Scopes granted to personal access tokens
+const (
+	ScopeProfileRead		= "profile:read"
+	ScopeProfileWrite		= "profile:write"
+	ScopeMessagesRead		= "messages:read"
+	ScopeMessagesWrite		= "messages:write"
+	ScopeConversationsRead		= "conversations:read"
+	ScopeConversationsWrite		= "conversations:write"
+)
+
+// Every scope a token can be granted
+var Scopes = []string {
+	ScopeProfileRead,
+	ScopeProfileWrite,
+	ScopeMessagesRead,
+	ScopeMessagesWrite,
+	ScopeConversationsRead,
+	ScopeConversationsWrite,
+}
+
+func IsValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a new random access token along with the hash to store.
+// The token itself is never stored.
+func GenerateAccessToken() (string, string, error) {
+	return generateToken(AccessTokenPrefix)
+}
+
+// Same as GenerateAccessToken, for refresh tokens
+func GenerateRefreshToken() (string, string, error) {
+	return generateToken(RefreshTokenPrefix)
+}
+
+// Same as GenerateAccessToken, for incoming webhook URLs
+func GenerateIncomingWebhookToken() (string, string, error) {
+	return generateToken(IncomingWebhookTokenPrefix)
+}
+
+func generateToken(prefix string) (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	token := prefix + base64.RawURLEncoding.EncodeToString(secret)
+	return token, HashAccessToken(token), nil
+}
+
+// Tokens carry 256 random bits, so a fast hash is enough to store them safely
+func HashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, AccessTokenPrefix)
+}
+
+func IsRefreshToken(token string) bool {
+	return strings.HasPrefix(token, RefreshTokenPrefix)
+}
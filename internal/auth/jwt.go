@@ -1,7 +1,6 @@
 package auth
 
 import (
-	"os"
 	"time"
 	"errors"
 
@@ -11,45 +10,85 @@ import (
 )
 
 var (
-	jwtSecret = []byte(os.Getenv("JWT_SECRET"))
 	ErrInvalidToken = errors.New("Invalid or expired token")
 )
 
+// Lifetime of the JWTs, sessions are extended with refresh tokens
+const AccessTokenTTL = 15 * time.Minute
+
 // claims represents token encoded data
 type Claims struct {
-	UserID uuid.UUID `json:user_id`
+	UserID uuid.UUID `json:"user_id"`
+	// Session the token was issued for, revoking it revokes the token
+	SessionID uuid.UUID `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-// Returns a signed JWT for a given user
-func GenerateToken(user *models.User) (string, error) {
+// Rejects JWTs revoked before they expire, on logout, password change or session revocation
+type RevocationChecker interface {
+	IsRevoked(claims *Claims) bool
+}
+
+// Issue times are kept to the millisecond, so that tokens issued right before
+// a password change are refused without refusing those issued right after
+func init() {
+	jwt.TimePrecision = time.Millisecond
+}
+
+// Returns a signed JWT for a given user's session, valid for AccessTokenTTL
+func GenerateToken(user *models.User, sessionID uuid.UUID) (string, error) {
 	claims := Claims {
 		UserID: user.ID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims {
-			ExpiresAt: 	jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			// jti, identifies the token in the revocation denylist
+			ID:		uuid.NewString(),
+			ExpiresAt: 	jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:	jwt.NewNumericDate(time.Now()),	
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return sign(claims)
+}
+
+// Signs claims with the newest key of the key set, named by the kid header
+func sign(claims jwt.Claims) (string, error) {
+	keys := keySet.Load()
+	if keys == nil {
+		return "", ErrNoSigningKey
+	}
+	key := keys.signingKey()
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
 }
 
-// Checks token validity and returns associated claims
+// Checks token validity and returns associated claims.
+// Tokens are verified with the key of the key set named by their kid header.
 func ValidateToken(tokenStr string) (*Claims, error) {
+	keys := keySet.Load()
+	if keys == nil {
+		return nil, ErrInvalidToken
+	}
+
 	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys.key(kid)
+		// The algorithm comes from the key, never from the token
+		if !ok || token.Method.Alg() != key.method.Alg() {
 			return nil, ErrInvalidToken
 		}
-		return jwtSecret, nil
+		return key.public, nil
 	})
 
 	if err != nil {
 		return nil, ErrInvalidToken
 	}
 
+	// Every token carries a jti, a session and an expiry, which revocation relies on
 	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
+	if !ok || !token.Valid || claims.ID == "" || claims.SessionID == uuid.Nil || claims.ExpiresAt == nil {
 		return nil, ErrInvalidToken
 	}
 
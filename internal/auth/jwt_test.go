@@ -10,9 +10,10 @@ import (
 )
 
 func TestGenerateTokenAndValidateToken_Succes(t *testing.T) {
+	SetupTestKeys(t)
 	user := repository.NewTestUser(t, "testuser")
 
-	token, err := GenerateToken(user)
+	token, err := GenerateToken(user, uuid.New())
 	if err != nil {
 		t.Fatalf("Unexpected error generating token: %v", err)
 	}
@@ -37,14 +38,16 @@ func TestValidateToken_InvalidToken(t *testing.T) {
 }
 
 func TestValidateToken_ExpiredToken(t *testing.T) {
+	SetupTestKeys(t)
 	userID := uuid.New()
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+	token, err := sign(&Claims{
 		UserID: userID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-1 * time.Hour)),
+			ID:		uuid.NewString(),
+			ExpiresAt:	jwt.NewNumericDate(time.Now().Add(-1 * time.Hour)),
 		},
-	}).SignedString(jwtSecret)
+	})
 
 	if err != nil {
 		t.Fatalf("failed to generate expired token: %v", err)
@@ -57,6 +60,7 @@ func TestValidateToken_ExpiredToken(t *testing.T) {
 }
 
 func TestValidateToken_WrongAlgorithm(t *testing.T) {
+	SetupTestKeys(t)
 	token := jwt.NewWithClaims(jwt.SigningMethodNone, &Claims{
 		UserID: uuid.New(),
 	})
@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Shorter RSA keys are refused
+const minRSAKeyBits = 2048
+
+var ErrNoSigningKey = errors.New("no JWT signing key configured")
+
+// Private key signing JWTs, identified by the kid header of the tokens it signs
+type signingKey struct {
+	kid	string
+	method	jwt.SigningMethod
+	private	crypto.Signer
+	public	crypto.PublicKey
+}
+
+// Keys accepted to verify JWTs. The newest one signs the new tokens, the
+// others stay valid while the tokens they signed have not expired yet.
+type KeySet struct {
+	// Ordered by kid, newest last
+	keys	[]*signingKey
+}
+
+// Key set in use, set once at startup
+var keySet atomic.Pointer[KeySet]
+
+// Installs the key set signing and verifying JWTs
+func SetKeySet(keys *KeySet) {
+	keySet.Store(keys)
+}
+
+// Loads every PEM private key of dir, either Ed25519 (EdDSA) or RSA (RS256).
+// The file name without its .pem extension is the kid, and the key whose kid
+// sorts last signs: name files after their creation date, e.g. 2026-10-19.pem.
+// Keys are rotated by adding a new file, then removing the previous one once
+// the tokens it signed have expired.
+func LoadKeySet(dir string) (*KeySet, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*signingKey, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		key, err := parseSigningKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no key found in %q", dir)
+	}
+	return newKeySet(keys), nil
+}
+
+// Returns a key set made of a new Ed25519 key, used by the test helpers
+func generateKeySet() (*KeySet, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return newKeySet([]*signingKey{{
+		kid:		"generated",
+		method:		jwt.SigningMethodEdDSA,
+		private:	private,
+		public:		private.Public(),
+	}}), nil
+}
+
+func newKeySet(keys []*signingKey) *KeySet {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].kid < keys[j].kid
+	})
+	return &KeySet{keys: keys}
+}
+
+func parseSigningKey(kid string, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q, expected a private key", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{kid: kid}
+	switch private := parsed.(type) {
+	case ed25519.PrivateKey:
+		key.method = jwt.SigningMethodEdDSA
+		key.private = private
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key of %d bits, at least %d are required", private.N.BitLen(), minRSAKeyBits)
+		}
+		key.method = jwt.SigningMethodRS256
+		key.private = private
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, expected Ed25519 or RSA", parsed)
+	}
+	key.public = key.private.Public()
+
+	return key, nil
+}
+
+// Key signing the new tokens
+func (s *KeySet) signingKey() *signingKey {
+	return s.keys[len(s.keys)-1]
+}
+
+func (s *KeySet) key(kid string) (*signingKey, bool) {
+	for _, key := range s.keys {
+		if key.kid == kid {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// Public key in the JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType		string	`json:"kty"`
+	KeyID		string	`json:"kid"`
+	Use		string	`json:"use"`
+	Algorithm	string	`json:"alg"`
+	// Ed25519 keys
+	Curve		string	`json:"crv,omitempty"`
+	X		string	`json:"x,omitempty"`
+	// RSA keys
+	Modulus		string	`json:"n,omitempty"`
+	Exponent	string	`json:"e,omitempty"`
+}
+
+// Public keys of the key set, for services verifying gotalk tokens on their own
+type JWKS struct {
+	Keys	[]JWK	`json:"keys"`
+}
+
+func (s *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: make([]JWK, 0, len(s.keys))}
+	for _, key := range s.keys {
+		jwk := JWK {
+			KeyID:		key.kid,
+			Use:		"sig",
+			Algorithm:	key.method.Alg(),
+		}
+
+		switch public := key.public.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.Modulus = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.Exponent = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+// Returns the public keys of the key set in use, none before it is set
+func CurrentJWKS() JWKS {
+	keys := keySet.Load()
+	if keys == nil {
+		return JWKS{Keys: []JWK{}}
+	}
+	return keys.JWKS()
+}
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Writes a PEM private key to dir/<kid>.pem, either "ed25519" or "rsa<bits>"
+func writeTestKey(t *testing.T, dir, kid, keyType string) {
+	t.Helper()
+
+	var block *pem.Block
+	switch keyType {
+	case "ed25519":
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(private)
+		if err != nil {
+			t.Fatalf("Failed to encode key: %v", err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	case "rsa1024", "rsa2048":
+		bits := 2048
+		if keyType == "rsa1024" {
+			bits = 1024
+		}
+		private, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(private)}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
+
+func mustLoadKeySet(t *testing.T, dir string) *KeySet {
+	t.Helper()
+	keys, err := LoadKeySet(dir)
+	if err != nil {
+		t.Fatalf("LoadKeySet failed: %v", err)
+	}
+	return keys
+}
+
+func tokenKid(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatalf("Failed to parse token: %v", err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestKeyRotation(t *testing.T) {
+	t.Cleanup(func() { SetKeySet(nil) })
+	dir := t.TempDir()
+	user := &models.User{ID: uuid.New()}
+
+	writeTestKey(t, dir, "2026-01-01", "rsa2048")
+	SetKeySet(mustLoadKeySet(t, dir))
+	old, err := GenerateToken(user, uuid.New())
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	if kid := tokenKid(t, old); kid != "2026-01-01" {
+		t.Errorf("Expected kid 2026-01-01, got %q", kid)
+	}
+
+	// The new key signs, tokens of the previous one stay valid
+	writeTestKey(t, dir, "2026-06-01", "ed25519")
+	SetKeySet(mustLoadKeySet(t, dir))
+	current, err := GenerateToken(user, uuid.New())
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	if kid := tokenKid(t, current); kid != "2026-06-01" {
+		t.Errorf("Expected the newest key to sign, got kid %q", kid)
+	}
+	for _, token := range []string{old, current} {
+		if _, err := ValidateToken(token); err != nil {
+			t.Errorf("Expected token signed by %q to be valid, got %v", tokenKid(t, token), err)
+		}
+	}
+
+	// Once the previous key is removed, its tokens are refused
+	os.Remove(filepath.Join(dir, "2026-01-01.pem"))
+	SetKeySet(mustLoadKeySet(t, dir))
+	if _, err := ValidateToken(old); err == nil {
+		t.Errorf("Expected a token signed by a removed key to be refused")
+	}
+	if _, err := ValidateToken(current); err != nil {
+		t.Errorf("Expected the current token to stay valid, got %v", err)
+	}
+}
+
+func TestLoadKeySet_Invalid(t *testing.T) {
+	tests := []struct {
+		name	string
+		setup	func(t *testing.T, dir string)
+	}{
+		{"No key", func(t *testing.T, dir string) {}},
+		{"Not a key", func(t *testing.T, dir string) {
+			os.WriteFile(filepath.Join(dir, "key.pem"), []byte("secret"), 0o600)
+		}},
+		{"Short RSA key", func(t *testing.T, dir string) {
+			writeTestKey(t, dir, "weak", "rsa1024")
+		}},
+		{"One invalid key among valid ones", func(t *testing.T, dir string) {
+			writeTestKey(t, dir, "valid", "ed25519")
+			os.WriteFile(filepath.Join(dir, "invalid.pem"), []byte("secret"), 0o600)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.setup(t, dir)
+			if _, err := LoadKeySet(dir); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestValidateToken_ForgedTokens(t *testing.T) {
+	t.Cleanup(func() { SetKeySet(nil) })
+	dir := t.TempDir()
+	writeTestKey(t, dir, "rsa", "rsa2048")
+	keys := mustLoadKeySet(t, dir)
+	SetKeySet(keys)
+
+	claims := &Claims {
+		UserID: uuid.New(),
+		RegisteredClaims: jwt.RegisteredClaims {
+			ID:		uuid.NewString(),
+			ExpiresAt:	jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+
+	// HMAC keyed with the public key, which anyone can fetch from the JWKS
+	publicDER, err := x509.MarshalPKIXPublicKey(keys.signingKey().public)
+	if err != nil {
+		t.Fatalf("Failed to encode public key: %v", err)
+	}
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	hmacToken.Header["kid"] = "rsa"
+	forged, _ := hmacToken.SignedString(publicDER)
+	if _, err := ValidateToken(forged); err == nil {
+		t.Errorf("Expected an HMAC token to be refused")
+	}
+
+	// Signed with a key outside the key set
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	unknownToken := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	unknownToken.Header["kid"] = "unknown"
+	unknown, _ := unknownToken.SignedString(other)
+	if _, err := ValidateToken(unknown); err == nil {
+		t.Errorf("Expected a token of an unknown key to be refused")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	dir := t.TempDir()
+	writeTestKey(t, dir, "a", "ed25519")
+	writeTestKey(t, dir, "b", "rsa2048")
+
+	jwks := mustLoadKeySet(t, dir).JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("Expected 2 keys, got %d", len(jwks.Keys))
+	}
+
+	ed, rsaKey := jwks.Keys[0], jwks.Keys[1]
+	if ed.KeyID != "a" || ed.KeyType != "OKP" || ed.Curve != "Ed25519" || ed.Algorithm != "EdDSA" || ed.X == "" {
+		t.Errorf("Unexpected Ed25519 JWK: %+v", ed)
+	}
+	if rsaKey.KeyID != "b" || rsaKey.KeyType != "RSA" || rsaKey.Algorithm != "RS256" || rsaKey.Modulus == "" || rsaKey.Exponent != "AQAB" {
+		t.Errorf("Unexpected RSA JWK: %+v", rsaKey)
+	}
+}
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"sync"
+	"testing"
+)
+
+// This file contains helpers for the tests signing or validating JWTs
+
+var (
+	testKeysOnce	sync.Once
+	testKeys	*KeySet
+	testKeysErr	error
+)
+
+// Installs a key set generated once per test binary
+func SetupTestKeys(t testing.TB) {
+	t.Helper()
+
+	testKeysOnce.Do(func() {
+		testKeys, testKeysErr = generateKeySet()
+	})
+	if testKeysErr != nil {
+		t.Fatalf("Failed to generate JWT keys: %v", testKeysErr)
+	}
+	SetKeySet(testKeys)
+}
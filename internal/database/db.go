@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"fmt"
+	"log"
 	"os"
 	"time"
 
@@ -33,7 +34,7 @@ func Connect() error {
 	}
 
 	DB = dbpool
-	fmt.Println("Successfully connected to PostgreSQL")
+	log.Println("Successfully connected to PostgreSQL")
 	return nil
 }
 
@@ -41,6 +42,6 @@ func Connect() error {
 func Close() {
 	if DB != nil {
 		DB.Close()
-		fmt.Println("Closed connection to PostgreSQL")
+		log.Println("Closed connection to PostgreSQL")
 	}
 }
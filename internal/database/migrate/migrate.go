@@ -0,0 +1,485 @@
+// Package migrate applies the SQL migrations of the database schema and
+// records them in the schema_versions table.
+//
+// Runs hold a Postgres advisory lock, so that servers starting together do not
+// apply the same migration twice. The checksum of each applied migration is
+// recorded and verified before any change, so that editing a released
+// migration is caught instead of silently diverging from existing databases.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Arbitrary key of the advisory lock, "gotalk" in ASCII
+const lockKey int64 = 0x676f74616c6b
+
+// Records the applied migrations, one row each
+const createTableQuery = `
+	CREATE TABLE IF NOT EXISTS schema_versions (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)
+`
+
+// Migration files are named NNNNNN_description.up.sql and NNNNNN_description.down.sql
+var fileNameRegex = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+var (
+	ErrInvalidMigrations	= errors.New("invalid migration files")
+	ErrChecksumMismatch	= errors.New("applied migration was modified")
+	ErrUnknownVersion	= errors.New("database has a migration unknown to this build")
+	ErrInvalidSteps		= errors.New("number of migrations to revert must be positive")
+)
+
+// Returned when an applied migration no longer matches its file.
+// Matches ErrChecksumMismatch with errors.Is.
+type ChecksumError struct {
+	Version	uint64
+	Name	string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%v: %06d_%s differs from the version applied to the database", ErrChecksumMismatch, e.Version, e.Name)
+}
+
+func (e *ChecksumError) Unwrap() error {
+	return ErrChecksumMismatch
+}
+
+// Returned when the database was migrated by a newer build.
+// Matches ErrUnknownVersion with errors.Is.
+type UnknownVersionError struct {
+	Version	uint64
+}
+
+func (e *UnknownVersionError) Error() string {
+	return fmt.Sprintf("%v: version %d", ErrUnknownVersion, e.Version)
+}
+
+func (e *UnknownVersionError) Unwrap() error {
+	return ErrUnknownVersion
+}
+
+type Migration struct {
+	Version		uint64
+	Name		string
+	Up		string
+	Down		string
+	// Hex-encoded SHA-256 of Up
+	Checksum	string
+}
+
+// Reads the migration files at the root of fsys, sorted by version.
+// Every version needs both an up and a down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint64]*Migration{}
+	hasDown := map[uint64]bool{}
+	for _, entry := range entries {
+		match := fileNameRegex.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("%w: %s has an invalid version", ErrInvalidMigrations, entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("%w: version %d is used by both %s and %s", ErrInvalidMigrations, version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			sum := sha256.Sum256(content)
+			migration.Up = string(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(content)
+			hasDown[version] = true
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for version, migration := range byVersion {
+		if migration.Checksum == "" {
+			return nil, fmt.Errorf("%w: version %d has no up file", ErrInvalidMigrations, version)
+		}
+		if !hasDown[version] {
+			return nil, fmt.Errorf("%w: version %d has no down file", ErrInvalidMigrations, version)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		switch {
+		case a.Version < b.Version:
+			return -1
+		case a.Version > b.Version:
+			return 1
+		}
+		return 0
+	})
+	return migrations, nil
+}
+
+// State of a migration in the database
+type State struct {
+	Migration
+	Applied		bool
+	// Nil when not applied
+	AppliedAt	*time.Time
+	// The file changed since the migration was applied
+	Modified	bool
+}
+
+type Status struct {
+	// Highest applied version, 0 when none was
+	Current		uint64
+	Migrations	[]State
+	// Applied by a newer build, these versions have no file
+	Unknown		[]uint64
+}
+
+// Migrations Up would apply, in order
+func (s *Status) Pending() []Migration {
+	var pending []Migration
+	for _, state := range s.Migrations {
+		if !state.Applied {
+			pending = append(pending, state.Migration)
+		}
+	}
+	return pending
+}
+
+// Highest version known to this build, 0 without migrations
+func (s *Status) Latest() uint64 {
+	if len(s.Migrations) == 0 {
+		return 0
+	}
+	return s.Migrations[len(s.Migrations)-1].Version
+}
+
+// Contract of the migration engine. Changes return the migrations they ran, in order.
+type Migrator interface {
+	// Applies every pending migration
+	Up(ctx context.Context) ([]Migration, error)
+	// Reverts the given number of latest applied migrations
+	Down(ctx context.Context, steps int) ([]Migration, error)
+	// Applies or reverts migrations until version is the latest applied, 0 reverts everything
+	Goto(ctx context.Context, version uint64) ([]Migration, error)
+	Status(ctx context.Context) (*Status, error)
+	// Records the migrations up to version as applied without running them,
+	// for databases whose schema was created by hand
+	Baseline(ctx context.Context, version uint64) ([]Migration, error)
+}
+
+// Concrete implementation of Migrator
+type migrator struct {
+	db		*pgxpool.Pool
+	migrations	[]Migration
+}
+
+// Creates a new Migrator instance, migrations must be sorted by version as returned by Load.
+func NewMigrator(db *pgxpool.Pool, migrations []Migration) Migrator {
+	return &migrator {
+		db:		db,
+		migrations:	migrations,
+	}
+}
+
+type appliedVersion struct {
+	checksum	string
+	appliedAt	time.Time
+}
+
+// Runs fn on a connection holding the advisory lock, once the schema table exists
+func (m *migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+	defer func() {
+		// Still released when ctx was cancelled, the connection goes back to the pool
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, lockKey)
+	}()
+
+	if _, err := conn.Exec(ctx, createTableQuery); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func readApplied(ctx context.Context, conn *pgxpool.Conn) (map[uint64]appliedVersion, error) {
+	rows, err := conn.Query(ctx, `SELECT version, checksum, applied_at FROM schema_versions`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[uint64]appliedVersion{}
+	for rows.Next() {
+		var version int64
+		var row appliedVersion
+		if err := rows.Scan(&version, &row.checksum, &row.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[uint64(version)] = row
+	}
+	return applied, rows.Err()
+}
+
+// Refuses to change a database whose applied migrations do not match the files
+func (m *migrator) verify(applied map[uint64]appliedVersion) error {
+	known := map[uint64]bool{}
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		if row, ok := applied[migration.Version]; ok && row.checksum != migration.Checksum {
+			return &ChecksumError{Version: migration.Version, Name: migration.Name}
+		}
+	}
+
+	var unknown []uint64
+	for version := range applied {
+		if !known[version] {
+			unknown = append(unknown, version)
+		}
+	}
+	if len(unknown) > 0 {
+		return &UnknownVersionError{Version: slices.Max(unknown)}
+	}
+	return nil
+}
+
+// Runs the migration and records it in the same transaction, a failure leaves nothing behind
+func apply(ctx context.Context, conn *pgxpool.Conn, migration Migration) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, migration.Up); err != nil {
+		return fmt.Errorf("applying %06d_%s: %w", migration.Version, migration.Name, err)
+	}
+	if err := record(ctx, tx, migration); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func revert(ctx context.Context, conn *pgxpool.Conn, migration Migration) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, migration.Down); err != nil {
+		return fmt.Errorf("reverting %06d_%s: %w", migration.Version, migration.Name, err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM schema_versions WHERE version = $1`, int64(migration.Version)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func record(ctx context.Context, tx pgx.Tx, migration Migration) error {
+	_, err := tx.Exec(ctx,
+		`INSERT INTO schema_versions (version, name, checksum) VALUES ($1, $2, $3)`,
+		int64(migration.Version), migration.Name, migration.Checksum,
+	)
+	return err
+}
+
+func (m *migrator) Up(ctx context.Context) ([]Migration, error) {
+	if len(m.migrations) == 0 {
+		return nil, nil
+	}
+	return m.Goto(ctx, m.migrations[len(m.migrations)-1].Version)
+}
+
+func (m *migrator) Goto(ctx context.Context, version uint64) ([]Migration, error) {
+	if version != 0 && !slices.ContainsFunc(m.migrations, func(migration Migration) bool { return migration.Version == version }) {
+		return nil, fmt.Errorf("%w: no migration has version %d", ErrInvalidMigrations, version)
+	}
+
+	var ran []Migration
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := readApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		// Migrations added below the current version are applied too
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok || migration.Version > version {
+				continue
+			}
+			if err := apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			ran = append(ran, migration)
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+				continue
+			}
+			if err := revert(ctx, conn, migration); err != nil {
+				return err
+			}
+			ran = append(ran, migration)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+func (m *migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, ErrInvalidSteps
+	}
+
+	var ran []Migration
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := readApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(ran) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if err := revert(ctx, conn, migration); err != nil {
+				return err
+			}
+			ran = append(ran, migration)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+func (m *migrator) Baseline(ctx context.Context, version uint64) ([]Migration, error) {
+	var recorded []Migration
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := readApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok || migration.Version > version {
+				continue
+			}
+			if err := record(ctx, tx, migration); err != nil {
+				return err
+			}
+			recorded = append(recorded, migration)
+		}
+		return tx.Commit(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recorded, nil
+}
+
+// Read-only: unlike the changes, it neither takes the lock nor creates the schema table
+func (m *migrator) Status(ctx context.Context) (*Status, error) {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	applied := map[uint64]appliedVersion{}
+	var exists bool
+	if err := conn.QueryRow(ctx, `SELECT to_regclass('schema_versions') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		if applied, err = readApplied(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	status := &Status{Migrations: make([]State, 0, len(m.migrations))}
+	known := map[uint64]bool{}
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+
+		state := State{Migration: migration}
+		if row, ok := applied[migration.Version]; ok {
+			state.Applied = true
+			state.AppliedAt = &row.appliedAt
+			state.Modified = row.checksum != migration.Checksum
+		}
+		status.Migrations = append(status.Migrations, state)
+	}
+
+	for version := range applied {
+		if !known[version] {
+			status.Unknown = append(status.Unknown, version)
+		}
+		status.Current = max(status.Current, version)
+	}
+	slices.Sort(status.Unknown)
+
+	return status, nil
+}
@@ -0,0 +1,186 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+
+	"github.com/EliasLd/gotalk-backend/migrations"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS {
+		"000002_add_b.up.sql":		{Data: []byte("ALTER TABLE a ADD COLUMN b INT;")},
+		"000002_add_b.down.sql":	{Data: []byte("ALTER TABLE a DROP COLUMN b;")},
+		"000001_create_a.up.sql":	{Data: []byte("CREATE TABLE a (id INT);")},
+		"000001_create_a.down.sql":	{Data: []byte("DROP TABLE a;")},
+		"README.md":			{Data: []byte("ignored")},
+	}
+
+	loaded, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded) != 2 || loaded[0].Version != 1 || loaded[1].Version != 2 {
+		t.Fatalf("Expected versions 1 and 2 in order, got %+v", loaded)
+	}
+	if loaded[0].Name != "create_a" || loaded[0].Down != "DROP TABLE a;" || loaded[0].Checksum == "" {
+		t.Errorf("Unexpected migration: %+v", loaded[0])
+	}
+	if loaded[0].Checksum == loaded[1].Checksum {
+		t.Errorf("Expected distinct checksums")
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name	string
+		files	fstest.MapFS
+	}{
+		{"Missing down", fstest.MapFS {
+			"000001_create_a.up.sql":	{Data: []byte("CREATE TABLE a (id INT);")},
+		}},
+		{"Missing up", fstest.MapFS {
+			"000001_create_a.down.sql":	{Data: []byte("DROP TABLE a;")},
+		}},
+		{"Version reused", fstest.MapFS {
+			"000001_create_a.up.sql":	{Data: []byte("CREATE TABLE a (id INT);")},
+			"000001_create_a.down.sql":	{Data: []byte("DROP TABLE a;")},
+			"000001_create_b.up.sql":	{Data: []byte("CREATE TABLE b (id INT);")},
+			"000001_create_b.down.sql":	{Data: []byte("DROP TABLE b;")},
+		}},
+		{"Version zero", fstest.MapFS {
+			"000000_create_a.up.sql":	{Data: []byte("CREATE TABLE a (id INT);")},
+			"000000_create_a.down.sql":	{Data: []byte("DROP TABLE a;")},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(tt.files); !errors.Is(err, ErrInvalidMigrations) {
+				t.Errorf("Expected ErrInvalidMigrations, got %v", err)
+			}
+		})
+	}
+}
+
+// The migrations shipped in the binary
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	for i, migration := range loaded {
+		if migration.Version != uint64(i+1) {
+			t.Errorf("Expected version %d, got %06d_%s", i+1, migration.Version, migration.Name)
+		}
+
+		// A missing terminator merges the last statement with the version bookkeeping
+		for direction, sql := range map[string]string{"up": migration.Up, "down": migration.Down} {
+			if !strings.HasSuffix(strings.TrimSpace(sql), ";") {
+				t.Errorf("%06d_%s.%s.sql does not end with a semicolon", migration.Version, migration.Name, direction)
+			}
+		}
+	}
+}
+
+// Runs the migrations in a scratch schema, leaving the development database untouched
+func setupMigrator(t *testing.T, loaded []Migration) Migrator {
+	t.Helper()
+
+	if err := godotenv.Load("../../../.env"); err != nil {
+		t.Fatalf("Failed to load environment variables from ../../../.env")
+	}
+
+	schema := "migrate_test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	cfg, err := pgxpool.ParseConfig(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		t.Fatalf("Invalid DATABASE_URL: %v", err)
+	}
+	// Without public, so that a down migration can never reach the real tables
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+
+	db, err := pgxpool.NewWithConfig(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	if _, err := db.Exec(context.Background(), fmt.Sprintf("CREATE SCHEMA %s", schema)); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(context.Background(), fmt.Sprintf("DROP SCHEMA %s CASCADE", schema))
+		db.Close()
+	})
+
+	return NewMigrator(db, loaded)
+}
+
+func TestMigrator(t *testing.T) {
+	loaded, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	m := setupMigrator(t, loaded)
+	ctx := context.Background()
+	latest := loaded[len(loaded)-1].Version
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if len(applied) != len(loaded) {
+		t.Errorf("Expected %d migrations applied, got %d", len(loaded), len(applied))
+	}
+
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Current != latest || len(status.Pending()) != 0 {
+		t.Errorf("Expected version %d with nothing pending, got %d and %d pending", latest, status.Current, len(status.Pending()))
+	}
+
+	if applied, err := m.Up(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("Expected a second Up to do nothing, got %d migrations and %v", len(applied), err)
+	}
+
+	reverted, err := m.Down(ctx, 2)
+	if err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	if len(reverted) != 2 || reverted[0].Version != latest || reverted[1].Version != latest-1 {
+		t.Errorf("Expected the two latest migrations reverted, got %+v", reverted)
+	}
+
+	if _, err := m.Goto(ctx, 0); err != nil {
+		t.Fatalf("Goto 0 failed: %v", err)
+	}
+	if status, _ := m.Status(ctx); status.Current != 0 {
+		t.Errorf("Expected every migration reverted, current version is %d", status.Current)
+	}
+
+	if _, err := m.Goto(ctx, 3); err != nil {
+		t.Fatalf("Goto 3 failed: %v", err)
+	}
+
+	// An applied migration edited afterwards is refused
+	edited := append([]Migration(nil), loaded...)
+	edited[1].Checksum = "edited"
+	if _, err := NewMigrator(m.(*migrator).db, edited).Up(ctx); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+	}
+
+	// Older builds do not know the latest migrations
+	if _, err := NewMigrator(m.(*migrator).db, loaded[:2]).Up(ctx); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Expected ErrUnknownVersion, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Event types pushed to conversation subscribers
+const (
+	MessageCreated		= "message.created"
+	MessageDeleted		= "message.deleted"
+	ConversationUpdated	= "conversation.updated"
+)
+
+// Event types pushed to user subscribers
+const (
+	// The user placed, changed or lifted a block
+	BlocksUpdated	= "blocks.updated"
+)
+
+// Size of each subscriber's buffer, slow subscribers miss events beyond it
+const subscriberBufferSize = 64
+
+// Real-time notification scoped to a conversation, or to a user
+type Event struct {
+	Type		string		`json:"type"`
+	ConversationID	uuid.UUID	`json:"conversationId"`
+	Payload		interface{}	`json:"payload"`
+}
+
+// Fans out events to the clients connected to this server instance.
+type Broker interface {
+	Publish(event Event)
+	Subscribe(conversationID uuid.UUID) (<-chan Event, func())
+	PublishToUser(userID uuid.UUID, event Event)
+	SubscribeUser(userID uuid.UUID) (<-chan Event, func())
+}
+
+// In-memory implementation of Broker
+type memoryBroker struct {
+	mu		sync.RWMutex
+	subscribers	map[uuid.UUID]map[chan Event]struct{}
+	users		map[uuid.UUID]map[chan Event]struct{}
+}
+
+// Creates a new in-memory Broker instance.
+func NewBroker() Broker {
+	return &memoryBroker{
+		subscribers:	make(map[uuid.UUID]map[chan Event]struct{}),
+		users:		make(map[uuid.UUID]map[chan Event]struct{}),
+	}
+}
+
+// Delivers the event to every subscriber of its conversation without blocking
+func (b *memoryBroker) Publish(event Event) {
+	b.publish(b.subscribers, event.ConversationID, event)
+}
+
+// Returns a channel receiving the conversation's events and a function to unsubscribe
+func (b *memoryBroker) Subscribe(conversationID uuid.UUID) (<-chan Event, func()) {
+	return b.subscribe(b.subscribers, conversationID)
+}
+
+// Delivers the event to every subscriber of the user without blocking
+func (b *memoryBroker) PublishToUser(userID uuid.UUID, event Event) {
+	b.publish(b.users, userID, event)
+}
+
+// Returns a channel receiving the events meant for the user and a function to unsubscribe
+func (b *memoryBroker) SubscribeUser(userID uuid.UUID) (<-chan Event, func()) {
+	return b.subscribe(b.users, userID)
+}
+
+func (b *memoryBroker) publish(subscribers map[uuid.UUID]map[chan Event]struct{}, id uuid.UUID, event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range subscribers[id] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+func (b *memoryBroker) subscribe(subscribers map[uuid.UUID]map[chan Event]struct{}, id uuid.UUID) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBufferSize)
+
+	b.mu.Lock()
+	if subscribers[id] == nil {
+		subscribers[id] = make(map[chan Event]struct{})
+	}
+	subscribers[id][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(subscribers[id], ch)
+			if len(subscribers[id]) == 0 {
+				delete(subscribers, id)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestBroker_PublishToSubscriber(t *testing.T) {
+	broker := NewBroker()
+	conversationID := uuid.New()
+
+	ch, unsubscribe := broker.Subscribe(conversationID)
+	defer unsubscribe()
+
+	broker.Publish(Event{Type: MessageCreated, ConversationID: conversationID})
+
+	select {
+	case event := <-ch:
+		if event.Type != MessageCreated {
+			t.Errorf("Expected event type %s, got %s", MessageCreated, event.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected an event, got nothing")
+	}
+}
+
+func TestBroker_OtherConversationIsolated(t *testing.T) {
+	broker := NewBroker()
+
+	ch, unsubscribe := broker.Subscribe(uuid.New())
+	defer unsubscribe()
+
+	broker.Publish(Event{Type: MessageCreated, ConversationID: uuid.New()})
+
+	select {
+	case event := <-ch:
+		t.Errorf("Expected no event, got %v", event)
+	default:
+	}
+}
+
+func TestBroker_Unsubscribe(t *testing.T) {
+	broker := NewBroker()
+	conversationID := uuid.New()
+
+	ch, unsubscribe := broker.Subscribe(conversationID)
+	unsubscribe()
+	// Calling it twice must be safe
+	unsubscribe()
+
+	// Publishing after unsubscribe must not panic on the closed channel
+	broker.Publish(Event{Type: MessageDeleted, ConversationID: conversationID})
+
+	if _, ok := <-ch; ok {
+		t.Error("Expected channel to be closed after unsubscribe")
+	}
+}
+
+func TestBroker_UserEventsIsolated(t *testing.T) {
+	broker := NewBroker()
+	userID := uuid.New()
+
+	ch, unsubscribe := broker.SubscribeUser(userID)
+	defer unsubscribe()
+
+	// Conversation events never reach user subscribers, even under the same ID
+	broker.Publish(Event{Type: MessageCreated, ConversationID: userID})
+	broker.PublishToUser(uuid.New(), Event{Type: BlocksUpdated})
+	broker.PublishToUser(userID, Event{Type: BlocksUpdated})
+
+	select {
+	case event := <-ch:
+		if event.Type != BlocksUpdated {
+			t.Errorf("Expected event type %s, got %s", BlocksUpdated, event.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected an event, got nothing")
+	}
+
+	select {
+	case event := <-ch:
+		t.Errorf("Expected no other event, got %v", event)
+	default:
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+type createAccessTokenRequest struct {
+	Name		string		`json:"name"`
+	Scopes		[]string	`json:"scopes"`
+	// Never expires when omitted
+	ExpiresAt	*time.Time	`json:"expiresAt,omitempty"`
+}
+
+type accessTokenResponse struct {
+	ID		string		`json:"id"`
+	Name		string		`json:"name"`
+	Scopes		[]string	`json:"scopes"`
+	CreatedAt	string		`json:"createdAt"`
+	ExpiresAt	string		`json:"expiresAt,omitempty"`
+	LastUsedAt	string		`json:"lastUsedAt,omitempty"`
+	// Only returned once, on creation
+	Token		string		`json:"token,omitempty"`
+}
+
+func newAccessTokenResponse(token *models.AccessToken) accessTokenResponse {
+	resp := accessTokenResponse {
+		ID:		token.ID.String(),
+		Name:		token.Name,
+		Scopes:		token.Scopes,
+		CreatedAt:	token.CreatedAt.Format(time.RFC3339),
+	}
+	if token.ExpiresAt != nil {
+		resp.ExpiresAt = token.ExpiresAt.Format(time.RFC3339)
+	}
+	if token.LastUsedAt != nil {
+		resp.LastUsedAt = token.LastUsedAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+func (h *Handler) HandleCreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req createAccessTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	token, plain, err := h.accessTokenService.CreateToken(r.Context(), userID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, appErr.ErrTokenNameInvalid),
+			errors.Is(err, appErr.ErrTokenScopesInvalid),
+			errors.Is(err, appErr.ErrTokenExpiryInvalid):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp := newAccessTokenResponse(token)
+	resp.Token = plain
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleListAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.accessTokenService.ListTokens(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]accessTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		resp = append(resp, newAccessTokenResponse(token))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleRevokeAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	tokenID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.accessTokenService.RevokeToken(r.Context(), userID, tokenID); err != nil {
+		switch {
+		case errors.Is(err, appErr.ErrTokenNotFound):
+			http.Error(w, "Access token not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
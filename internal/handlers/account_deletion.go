@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
+)
+
+type deleteMeRequest struct {
+	Password string `json:"password"`
+}
+
+type deleteMeResponse struct {
+	DeletionScheduledAt string `json:"deletionScheduledAt"`
+}
+
+// Schedules the deletion of the current account.
+// Logging in again before the returned date cancels it.
+func (h *Handler) HandleDeleteMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req deleteMeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Password == "" {
+		http.Error(w, "Password is required", http.StatusBadRequest)
+		return
+	}
+
+	scheduledAt, err := h.userService.RequestDeletion(r.Context(), userID, req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, appErr.ErrInvalidCredentials):
+			http.Error(w, "Invalid password", http.StatusForbidden)
+		case errors.Is(err, appErr.ErrUserNotFound):
+			http.Error(w, "User not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(deleteMeResponse {
+		DeletionScheduledAt: scheduledAt.Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+// Largest accepted avatar upload, in bytes
+const maxAvatarUploadSize = 5 << 20
+
+// Replaces the caller's avatar with the image sent in the "avatar" multipart field
+func (h *Handler) HandleUploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarUploadSize)
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		http.Error(w, "Expected an image of at most 5MB in the 'avatar' form field", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	user, err := h.avatarService.UploadAvatar(r.Context(), userID, file)
+	if err != nil {
+		switch {
+		case errors.Is(err, appErr.ErrAvatarInvalid):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, appErr.ErrUserNotFound):
+			http.Error(w, "User not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"avatarUrl": user.AvatarURL()})
+}
+
+func (h *Handler) HandleDeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.avatarService.DeleteAvatar(r.Context(), userID); err != nil {
+		switch {
+		case errors.Is(err, appErr.ErrUserNotFound):
+			http.Error(w, "User not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Serves a user's avatar, public so that it can be used as an image source
+func (h *Handler) HandleGetAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	avatar, err := h.avatarService.GetAvatar(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, appErr.ErrUserNotFound),
+			errors.Is(err, appErr.ErrAvatarNotFound):
+			http.Error(w, "Avatar not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	defer avatar.Close()
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	io.Copy(w, avatar)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+type blockRequest struct {
+	UserID		string	`json:"userId"`
+	// Defaults to true
+	HideMessages	*bool	`json:"hideMessages,omitempty"`
+}
+
+type blockResponse struct {
+	UserID		string	`json:"userId"`
+	Username	string	`json:"username"`
+	HideMessages	bool	`json:"hideMessages"`
+	CreatedAt	string	`json:"createdAt"`
+}
+
+func (h *Handler) HandleBlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req blockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	blockedID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	hideMessages := true
+	if req.HideMessages != nil {
+		hideMessages = *req.HideMessages
+	}
+
+	if err := h.blockService.BlockUser(r.Context(), userID, blockedID, hideMessages); err != nil {
+		switch {
+		case errors.Is(err, appErr.ErrCannotBlockSelf):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, appErr.ErrUserNotFound):
+			http.Error(w, "User not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) HandleUnblockUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	blockedID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.blockService.UnblockUser(r.Context(), userID, blockedID); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Lists the users blocked by the caller
+func (h *Handler) HandleListBlocks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	blocks, err := h.blockService.ListBlocks(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]blockResponse, 0, len(blocks))
+	for _, block := range blocks {
+		resp = append(resp, blockResponse {
+			UserID:		block.BlockedID.String(),
+			Username:	block.BlockedUsername,
+			HideMessages:	block.HideMessages,
+			CreatedAt:	block.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
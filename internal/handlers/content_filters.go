@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+)
+
+type contentFiltersRequest struct {
+	Filters []models.ContentFilterConfig `json:"filters"`
+}
+
+type contentFiltersResponse struct {
+	Filters []models.ContentFilterConfig `json:"filters"`
+}
+
+// Returns the conversation's own content filters, restricted to its admins
+func (h *Handler) HandleGetContentFilters(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	conversationID, ok := conversationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := h.conversationService.GetContentFilters(r.Context(), userID, conversationID)
+	if err != nil {
+		writeConversationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(contentFiltersResponse{Filters: filters})
+}
+
+// Replaces the conversation's own content filters, restricted to its admins
+func (h *Handler) HandleSetContentFilters(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	conversationID, ok := conversationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req contentFiltersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Filters == nil {
+		req.Filters = []models.ContentFilterConfig{}
+	}
+
+	if err := h.conversationService.SetContentFilters(r.Context(), userID, conversationID, req.Filters); err != nil {
+		writeConversationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(contentFiltersResponse{Filters: req.Filters})
+}
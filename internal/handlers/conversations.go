@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+type openDirectConversationRequest struct {
+	UserID string `json:"userId"`
+}
+
+type conversationResponse struct {
+	ID		string	`json:"id"`
+	IsPublic	bool	`json:"isPublic"`
+	IsDirect	bool	`json:"isDirect"`
+	Name		*string	`json:"name"`
+	Topic		*string	`json:"topic"`
+	SlowModeSeconds	int	`json:"slowModeSeconds"`
+	CreatedAt	string	`json:"createdAt"`
+}
+
+func newConversationResponse(conversation *models.Conversation) conversationResponse {
+	return conversationResponse {
+		ID:			conversation.ID.String(),
+		IsPublic:		conversation.IsPublic,
+		IsDirect:		conversation.IsDirect,
+		Name:			conversation.Name,
+		Topic:			conversation.Topic,
+		SlowModeSeconds:	conversation.SlowModeSeconds,
+		CreatedAt:		conversation.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// Returns the caller's direct conversation with another user, creating it on first use
+func (h *Handler) HandleOpenDirectConversation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req openDirectConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	otherID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversation, created, err := h.conversationService.OpenDirectConversation(r.Context(), userID, otherID)
+	if err != nil {
+		writeConversationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(newConversationResponse(conversation))
+}
+
+type userConversationResponse struct {
+	conversationResponse
+	Role		string	`json:"role"`
+	// Other member of a direct conversation
+	PeerUsername	string	`json:"peerUsername,omitempty"`
+}
+
+// Lists the conversations the caller belongs to, latest joined first
+func (h *Handler) HandleListConversations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	conversations, err := h.conversationService.ListConversations(r.Context(), userID)
+	if err != nil {
+		writeConversationError(w, err)
+		return
+	}
+
+	resp := make([]userConversationResponse, 0, len(conversations))
+	for _, conversation := range conversations {
+		resp = append(resp, userConversationResponse {
+			conversationResponse:	newConversationResponse(&conversation.Conversation),
+			Role:			string(conversation.Role),
+			PeerUsername:		conversation.PeerUsername,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
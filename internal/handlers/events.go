@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/EliasLd/gotalk-backend/internal/events"
+	"github.com/EliasLd/gotalk-backend/internal/http/middleware"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+)
+
+type eventResponse struct {
+	Type		string		`json:"type"`
+	ConversationID	string		`json:"conversationId"`
+	Payload		interface{}	`json:"payload"`
+}
+
+func newEventResponse(event events.Event) eventResponse {
+	resp := eventResponse {
+		Type:		event.Type,
+		ConversationID:	event.ConversationID.String(),
+		Payload:	event.Payload,
+	}
+
+	switch payload := event.Payload.(type) {
+	case *models.Message:
+		if event.Type == events.MessageDeleted {
+			// Clients only need the ID to drop the message from their cache
+			resp.Payload = map[string]string{"id": payload.ID.String()}
+		} else {
+			resp.Payload = newMessageResponse(payload)
+		}
+	case *models.Conversation:
+		resp.Payload = newConversationResponse(payload)
+	}
+
+	return resp
+}
+
+// Streams the conversation's real-time events as Server-Sent Events
+func (h *Handler) HandleConversationEvents(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	conversationID, ok := conversationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// Closed as soon as the session is revoked
+	ctx := r.Context()
+	if claims, ok := middleware.ClaimsFromContext(ctx); ok && h.tokenRevocationService != nil {
+		var release context.CancelFunc
+		ctx, release = h.tokenRevocationService.WatchSession(ctx, claims.SessionID)
+		defer release()
+	}
+
+	stream, unsubscribe, err := h.messageService.Subscribe(ctx, userID, conversationID)
+	if err != nil {
+		writeConversationError(w, err)
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-stream:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(newEventResponse(event))
+			if err != nil {
+				continue
+			}
+
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
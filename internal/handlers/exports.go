@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+type exportResponse struct {
+	ID			string	`json:"id"`
+	Status			string	`json:"status"`
+	CreatedAt		string	`json:"createdAt"`
+	CompletedAt		string	`json:"completedAt,omitempty"`
+	ExpiresAt		string	`json:"expiresAt,omitempty"`
+	// Only set once completed, a fresh link is signed on every poll
+	DownloadURL		string	`json:"downloadUrl,omitempty"`
+	DownloadURLExpiresAt	string	`json:"downloadUrlExpiresAt,omitempty"`
+}
+
+func (h *Handler) newExportResponse(export *models.DataExport) exportResponse {
+	resp := exportResponse {
+		ID:		export.ID.String(),
+		Status:		string(export.Status),
+		CreatedAt:	export.CreatedAt.Format(time.RFC3339),
+	}
+	if export.CompletedAt != nil {
+		resp.CompletedAt = export.CompletedAt.Format(time.RFC3339)
+	}
+	if export.ExpiresAt != nil {
+		resp.ExpiresAt = export.ExpiresAt.Format(time.RFC3339)
+	}
+
+	if export.Status == models.ExportCompleted {
+		url, expiresAt := h.exportService.DownloadURL(export)
+		resp.DownloadURL = url
+		resp.DownloadURLExpiresAt = expiresAt.Format(time.RFC3339)
+	}
+
+	return resp
+}
+
+// Starts an export of the current user's data, to be polled until completed
+func (h *Handler) HandleRequestExport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	export, err := h.exportService.RequestExport(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/me/exports/"+export.ID.String())
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(h.newExportResponse(export))
+}
+
+func (h *Handler) HandleGetExport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	exportID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid export ID", http.StatusBadRequest)
+		return
+	}
+
+	export, err := h.exportService.GetExport(r.Context(), userID, exportID)
+	if err != nil {
+		switch {
+		case errors.Is(err, appErr.ErrExportNotFound):
+			http.Error(w, "Export not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.newExportResponse(export))
+}
+
+// Serves the archive behind a signed link, no token is required
+func (h *Handler) HandleDownloadExport(w http.ResponseWriter, r *http.Request) {
+	exportID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid export ID", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	archive, err := h.exportService.OpenDownload(r.Context(), exportID, query.Get("expires"), query.Get("signature"))
+	if err != nil {
+		switch {
+		case errors.Is(err, appErr.ErrExportLinkInvalid):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, appErr.ErrExportNotFound), errors.Is(err, appErr.ErrExportNotReady):
+			http.Error(w, "Export not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="gotalk-export-`+exportID.String()+`.zip"`)
+	w.Header().Set("Cache-Control", "private, no-store")
+	io.Copy(w, archive)
+}
@@ -1,13 +1,81 @@
 package handlers
 
-import "github.com/EliasLd/gotalk-backend/internal/service"
+import (
+	"net"
+	"net/http"
+
+	"github.com/EliasLd/gotalk-backend/internal/http/middleware"
+	"github.com/EliasLd/gotalk-backend/internal/service"
+	"github.com/google/uuid"
+)
 
 type Handler struct {
-	userService service.UserService
+	userService		service.UserService
+	messageService		service.MessageService
+	conversationService	service.ConversationService
+	blockService		service.BlockService
+	avatarService		service.AvatarService
+	exportService		service.ExportService
+	accessTokenService	service.AccessTokenService
+	webhookService		service.WebhookService
+	incomingWebhookService	service.IncomingWebhookService
+	keyService		service.KeyService
+	refreshTokenService	service.RefreshTokenService
+	tokenRevocationService	service.TokenRevocationService
+	sessionService		service.SessionService
 }
 
-func NewHandler(userService service.UserService) *Handler {
+func NewHandler(userService service.UserService, messageService service.MessageService, conversationService service.ConversationService, blockService service.BlockService, avatarService service.AvatarService, exportService service.ExportService, accessTokenService service.AccessTokenService, webhookService service.WebhookService, incomingWebhookService service.IncomingWebhookService, keyService service.KeyService, refreshTokenService service.RefreshTokenService, tokenRevocationService service.TokenRevocationService, sessionService service.SessionService) *Handler {
 	return &Handler {
-		userService:	userService,
+		userService:		userService,
+		messageService:		messageService,
+		conversationService:	conversationService,
+		blockService:		blockService,
+		avatarService:		avatarService,
+		exportService:		exportService,
+		accessTokenService:	accessTokenService,
+		webhookService:		webhookService,
+		incomingWebhookService:	incomingWebhookService,
+		keyService:		keyService,
+		refreshTokenService:	refreshTokenService,
+		tokenRevocationService:	tokenRevocationService,
+		sessionService:		sessionService,
+	}
+}
+
+// Returns the authenticated user's ID, writing the error response on failure
+func currentUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userIDStr, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+// Address of the client, without its port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
 	}
+	return host
+}
+
+// Parses the {id} path value of conversation routes, writing the error response on failure
+func conversationIDFromPath(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	conversationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+
+	return conversationID, true
 }
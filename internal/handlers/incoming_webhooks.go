@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+// Incoming messages are small, larger bodies are rejected before decoding
+const maxIncomingWebhookBodySize = 64 << 10
+
+type createIncomingWebhookRequest struct {
+	// Display name of the bot the messages are attributed to
+	Name	string	`json:"name"`
+}
+
+type incomingWebhookResponse struct {
+	ID		string	`json:"id"`
+	ConversationID	string	`json:"conversationId"`
+	Name		string	`json:"name"`
+	BotUserID	string	`json:"botUserId"`
+	CreatedAt	string	`json:"createdAt"`
+	LastUsedAt	*string	`json:"lastUsedAt"`
+	// Only returned once, on creation
+	Token		string	`json:"token,omitempty"`
+	URL		string	`json:"url,omitempty"`
+}
+
+type incomingWebhookMessageRequest struct {
+	Content	string	`json:"content"`
+}
+
+func newIncomingWebhookResponse(webhook *models.IncomingWebhook) incomingWebhookResponse {
+	resp := incomingWebhookResponse {
+		ID:		webhook.ID.String(),
+		ConversationID:	webhook.ConversationID.String(),
+		Name:		webhook.Name,
+		BotUserID:	webhook.BotUserID.String(),
+		CreatedAt:	webhook.CreatedAt.Format(time.RFC3339),
+	}
+	if webhook.LastUsedAt != nil {
+		lastUsedAt := webhook.LastUsedAt.Format(time.RFC3339)
+		resp.LastUsedAt = &lastUsedAt
+	}
+	return resp
+}
+
+func writeIncomingWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, appErr.ErrIncomingWebhookNotFound):
+		http.Error(w, "Incoming webhook not found", http.StatusNotFound)
+	case errors.Is(err, appErr.ErrBotNameInvalid),
+		errors.Is(err, appErr.ErrAvatarInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		writeConversationError(w, err)
+	}
+}
+
+// Parses the {webhookId} path value, writing the error response on failure
+func incomingWebhookIDFromPath(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	webhookID, err := uuid.Parse(r.PathValue("webhookId"))
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+
+	return webhookID, true
+}
+
+func (h *Handler) HandleCreateIncomingWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	conversationID, ok := conversationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req createIncomingWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	webhook, token, err := h.incomingWebhookService.CreateIncomingWebhook(r.Context(), userID, conversationID, req.Name)
+	if err != nil {
+		writeIncomingWebhookError(w, err)
+		return
+	}
+
+	resp := newIncomingWebhookResponse(webhook)
+	resp.Token = token
+	resp.URL = "/hooks/incoming/" + token
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleListIncomingWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	conversationID, ok := conversationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	webhooks, err := h.incomingWebhookService.ListIncomingWebhooks(r.Context(), userID, conversationID)
+	if err != nil {
+		writeIncomingWebhookError(w, err)
+		return
+	}
+
+	resp := make([]incomingWebhookResponse, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		resp = append(resp, newIncomingWebhookResponse(webhook))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleRevokeIncomingWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	conversationID, ok := conversationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	webhookID, ok := incomingWebhookIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.incomingWebhookService.RevokeIncomingWebhook(r.Context(), userID, conversationID, webhookID); err != nil {
+		writeIncomingWebhookError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Replaces the bot's avatar with the image sent in the "avatar" multipart field
+func (h *Handler) HandleUploadIncomingWebhookAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	conversationID, ok := conversationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	webhookID, ok := incomingWebhookIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarUploadSize)
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		http.Error(w, "Expected an image of at most 5MB in the 'avatar' form field", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	bot, err := h.incomingWebhookService.UploadBotAvatar(r.Context(), userID, conversationID, webhookID, file)
+	if err != nil {
+		writeIncomingWebhookError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"avatarUrl": bot.AvatarURL()})
+}
+
+// Public endpoint, the secret token in the path authenticates the caller
+func (h *Handler) HandlePostIncomingWebhook(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxIncomingWebhookBodySize)
+
+	var req incomingWebhookMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	message, err := h.incomingWebhookService.PostMessage(r.Context(), r.PathValue("token"), req.Content)
+	if err != nil {
+		writeIncomingWebhookError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newMessageResponse(message))
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/EliasLd/gotalk-backend/internal/auth"
+)
+
+// Publishes the public keys verifying gotalk JWTs, matched by their kid header
+func HandleJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	// Keys only change on restart, verifiers refetch when they meet an unknown kid
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(auth.CurrentJWKS())
+}
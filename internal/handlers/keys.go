@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/service"
+	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+type preKeyPayload struct {
+	KeyID		int	`json:"keyId"`
+	// Base64-encoded public key
+	PublicKey	string	`json:"publicKey"`
+}
+
+type signedPreKeyPayload struct {
+	KeyID		int	`json:"keyId"`
+	PublicKey	string	`json:"publicKey"`
+	// Base64-encoded signature of the public key by the identity key
+	Signature	string	`json:"signature"`
+}
+
+type registerDeviceRequest struct {
+	IdentityKey	string			`json:"identityKey"`
+	SignedPreKey	signedPreKeyPayload	`json:"signedPreKey"`
+	OneTimePreKeys	[]preKeyPayload		`json:"oneTimePreKeys"`
+}
+
+type uploadPreKeysRequest struct {
+	OneTimePreKeys	[]preKeyPayload	`json:"oneTimePreKeys"`
+}
+
+type deviceResponse struct {
+	ID			string			`json:"id"`
+	IdentityKey		string			`json:"identityKey"`
+	SignedPreKey		signedPreKeyPayload	`json:"signedPreKey"`
+	OneTimePreKeyCount	int			`json:"oneTimePreKeyCount"`
+	CreatedAt		string			`json:"createdAt"`
+	UpdatedAt		string			`json:"updatedAt"`
+}
+
+type preKeyBundleResponse struct {
+	DeviceID	string			`json:"deviceId"`
+	IdentityKey	string			`json:"identityKey"`
+	SignedPreKey	signedPreKeyPayload	`json:"signedPreKey"`
+	// Null once the device ran out of one-time prekeys
+	OneTimePreKey	*preKeyPayload		`json:"oneTimePreKey"`
+}
+
+func newSignedPreKeyPayload(device *models.Device) signedPreKeyPayload {
+	return signedPreKeyPayload {
+		KeyID:		device.SignedPreKeyID,
+		PublicKey:	device.SignedPreKey,
+		Signature:	device.SignedPreKeySignature,
+	}
+}
+
+func newDeviceResponse(device *models.Device) deviceResponse {
+	return deviceResponse {
+		ID:			device.ID.String(),
+		IdentityKey:		device.IdentityKey,
+		SignedPreKey:		newSignedPreKeyPayload(device),
+		OneTimePreKeyCount:	device.OneTimePreKeyCount,
+		CreatedAt:		device.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:		device.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func toPreKeys(payloads []preKeyPayload) []models.PreKey {
+	preKeys := make([]models.PreKey, 0, len(payloads))
+	for _, payload := range payloads {
+		preKeys = append(preKeys, models.PreKey{KeyID: payload.KeyID, PublicKey: payload.PublicKey})
+	}
+	return preKeys
+}
+
+func writeKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, appErr.ErrDeviceNotFound):
+		http.Error(w, "Device not found", http.StatusNotFound)
+	case errors.Is(err, appErr.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, appErr.ErrDeviceKeyConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, appErr.ErrKeyInvalid),
+		errors.Is(err, appErr.ErrTooManyPreKeys):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// Parses the {id} path value of device routes, writing the error response on failure
+func deviceIDFromPath(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	deviceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid device ID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+
+	return deviceID, true
+}
+
+// Registers the caller's device under the ID it chose, or rotates its signed prekey
+func (h *Handler) HandleRegisterDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	deviceID, ok := deviceIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req registerDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	device, err := h.keyService.RegisterDevice(r.Context(), userID, deviceID, service.RegisterDeviceInput {
+		IdentityKey:		req.IdentityKey,
+		SignedPreKey:		models.PreKey{KeyID: req.SignedPreKey.KeyID, PublicKey: req.SignedPreKey.PublicKey},
+		SignedPreKeySignature:	req.SignedPreKey.Signature,
+		OneTimePreKeys:		toPreKeys(req.OneTimePreKeys),
+	})
+	if err != nil {
+		writeKeyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newDeviceResponse(device))
+}
+
+// Replenishes the one-time prekeys of one of the caller's devices
+func (h *Handler) HandleUploadPreKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	deviceID, ok := deviceIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req uploadPreKeysRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	count, err := h.keyService.AddOneTimePreKeys(r.Context(), userID, deviceID, toPreKeys(req.OneTimePreKeys))
+	if err != nil {
+		writeKeyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"oneTimePreKeyCount": count})
+}
+
+func (h *Handler) HandleListDevices(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	devices, err := h.keyService.ListDevices(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]deviceResponse, 0, len(devices))
+	for _, device := range devices {
+		resp = append(resp, newDeviceResponse(device))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleDeleteDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	deviceID, ok := deviceIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.keyService.DeleteDevice(r.Context(), userID, deviceID); err != nil {
+		writeKeyError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Hands out a prekey bundle for each of the user's devices.
+// Every call consumes one-time prekeys, hence POST.
+func (h *Handler) HandleClaimPreKeyBundles(w http.ResponseWriter, r *http.Request) {
+	requesterID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	bundles, err := h.keyService.ClaimPreKeyBundles(r.Context(), requesterID, userID)
+	if err != nil {
+		writeKeyError(w, err)
+		return
+	}
+
+	resp := make([]preKeyBundleResponse, 0, len(bundles))
+	for _, bundle := range bundles {
+		bundleResp := preKeyBundleResponse {
+			DeviceID:	bundle.Device.ID.String(),
+			IdentityKey:	bundle.Device.IdentityKey,
+			SignedPreKey:	newSignedPreKeyPayload(bundle.Device),
+		}
+		if bundle.OneTimePreKey != nil {
+			bundleResp.OneTimePreKey = &preKeyPayload {
+				KeyID:		bundle.OneTimePreKey.KeyID,
+				PublicKey:	bundle.OneTimePreKey.PublicKey,
+			}
+		}
+		resp = append(resp, bundleResp)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
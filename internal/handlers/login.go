@@ -2,20 +2,48 @@ package handlers
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"errors"
+	"time"
 
+	"github.com/EliasLd/gotalk-backend/internal/http/middleware"
+	"github.com/EliasLd/gotalk-backend/internal/service"
 	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
-	"github.com/EliasLd/gotalk-backend/internal/auth"
 )
 
 type loginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// Shown in the list of sessions, optional
+	DeviceName string `json:"deviceName"`
+}
+
+type refreshTokenRequest struct {
+	RefreshToken	string	`json:"refreshToken"`
 }
 
 type loginResponse struct {
-	Token string `json:"token"`
+	Token			string	`json:"token"`
+	// Seconds until Token expires
+	ExpiresIn		int	`json:"expiresIn"`
+	RefreshToken		string	`json:"refreshToken"`
+	RefreshTokenExpiresAt	string	`json:"refreshTokenExpiresAt"`
+	SessionID		string	`json:"sessionId"`
+}
+
+func writeTokenPair(w http.ResponseWriter, tokens *service.TokenPair) {
+	resp := loginResponse {
+		Token:			tokens.AccessToken,
+		ExpiresIn:		int(tokens.ExpiresIn.Seconds()),
+		RefreshToken:		tokens.RefreshToken,
+		RefreshTokenExpiresAt:	tokens.RefreshTokenExpiresAt.Format(time.RFC3339),
+		SessionID:		tokens.SessionID.String(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
@@ -23,6 +51,7 @@ func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
 	}
 
 	user, err := h.userService.AuthenticateUser(r.Context(), req.Username, req.Password)
@@ -30,22 +59,89 @@ func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case errors.Is(err, appErr.ErrInvalidCredentials):
 			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		case errors.Is(err, appErr.ErrUserDisabled):
+			http.Error(w, "This account has been disabled", http.StatusForbidden)
 		default:
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
 		return
 	}
 
-	token, err := auth.GenerateToken(user)
+	device := service.SessionDevice {
+		Name:		req.DeviceName,
+		UserAgent:	r.UserAgent(),
+		IPAddress:	clientIP(r),
+	}
+	tokens, err := h.refreshTokenService.IssueTokens(r.Context(), user, device)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, appErr.ErrDeviceNameInvalid):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	resp := loginResponse {
-		Token: token,
+	writeTokenPair(w, tokens)
+}
+
+// Exchanges a refresh token for a new access token and refresh token
+func (h *Handler) HandleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req refreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	tokens, err := h.refreshTokenService.RefreshTokens(r.Context(), req.RefreshToken, clientIP(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, appErr.ErrInvalidRefreshToken),
+			errors.Is(err, appErr.ErrRefreshTokenReused):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeTokenPair(w, tokens)
+}
+
+// Revokes the access token of the request along with its session and, when
+// given, the refresh token of the same login. The body is optional.
+func (h *Handler) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req refreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if req.RefreshToken != "" {
+		if err := h.refreshTokenService.RevokeRefreshToken(r.Context(), claims.UserID, req.RefreshToken); err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := h.tokenRevocationService.RevokeToken(r.Context(), claims); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Already revoked by another request of the session
+	err := h.sessionService.RevokeSession(r.Context(), claims.UserID, claims.SessionID)
+	if err != nil && !errors.Is(err, appErr.ErrSessionNotFound) {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -31,7 +31,14 @@ func(h *Handler) HandleGetMe(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
 		"id":		user.ID,
 		"username":	user.Username,
+		"displayName":	user.DisplayName,
+		"bio":		user.Bio,
+		"pronouns":	user.Pronouns,
+		"timezone":	user.Timezone,
+		"avatarUrl":	user.AvatarURL(),
 		"createdAt":	user.CreatedAt,
+		// Null unless the account is pending deletion
+		"deletionScheduledAt":	user.DeletionScheduledAt,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
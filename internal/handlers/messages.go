@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"errors"
+	"html"
+	"strconv"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/markdown"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/service"
+	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+type sendMessageRequest struct {
+	Content		string	`json:"content"`
+	// "text" by default, or "encrypted" for end-to-end encrypted direct messages
+	ContentType	string	`json:"contentType,omitempty"`
+	// Optional lifetime of a disappearing message
+	TTLSeconds	*int	`json:"ttlSeconds,omitempty"`
+	// Counts the lifetime from the first read by another member instead of from sending
+	TTLFromRead	bool	`json:"ttlFromRead,omitempty"`
+}
+
+type messageResponse struct {
+	ID		string			`json:"id"`
+	ConversationID	string			`json:"conversationId"`
+	SenderID	string			`json:"senderId"`
+	// Raw Markdown as sent
+	Content		string			`json:"content"`
+	// "text", "action" for messages sent with /me, or "encrypted"
+	ContentType	string			`json:"contentType"`
+	// Sanitized rendering of Content, safe to inject as-is. Empty for encrypted messages.
+	HTML		string			`json:"html"`
+	Entities	[]markdown.Entity	`json:"entities"`
+	MentionIDs	[]string		`json:"mentionIds"`
+	CreatedAt	string			`json:"createdAt"`
+	// Unset until read when the TTL counts from the first read
+	ExpiresAt	*string			`json:"expiresAt,omitempty"`
+	TTLSeconds	*int			`json:"ttlSeconds,omitempty"`
+}
+
+// Returned instead of a message when the content was a slash command
+type commandResponse struct {
+	Command	string			`json:"command"`
+	// Feedback only shown to the sender
+	Reply	string			`json:"reply,omitempty"`
+	// Message the command posted, if any
+	Message	*messageResponse	`json:"message,omitempty"`
+}
+
+func newMessageResponse(message *models.Message) messageResponse {
+	resp := messageResponse {
+		ID:		message.ID.String(),
+		ConversationID:	message.ConversationID.String(),
+		SenderID:	message.SenderID.String(),
+		Content:	message.Content,
+		ContentType:	string(message.ContentType),
+		Entities:	[]markdown.Entity{},
+		MentionIDs:	make([]string, 0, len(message.MentionIDs)),
+		CreatedAt:	message.CreatedAt.Format(time.RFC3339Nano),
+		TTLSeconds:	message.TTLSeconds,
+	}
+
+	// Ciphertext is relayed as-is, only clients can render it
+	if message.ContentType != models.ContentTypeEncrypted {
+		// Content was validated when sent, a failure here means it predates
+		// Markdown support and is served as escaped text
+		if doc, err := markdown.Render(message.Content); err == nil {
+			resp.HTML = doc.HTML
+			if doc.Entities != nil {
+				resp.Entities = doc.Entities
+			}
+		} else {
+			resp.HTML = html.EscapeString(message.Content)
+		}
+	}
+
+	for _, id := range message.MentionIDs {
+		resp.MentionIDs = append(resp.MentionIDs, id.String())
+	}
+
+	if message.ExpiresAt != nil {
+		expiresAt := message.ExpiresAt.Format(time.RFC3339Nano)
+		resp.ExpiresAt = &expiresAt
+	}
+
+	return resp
+}
+
+// Maps conversation and message service errors to HTTP responses
+func writeConversationError(w http.ResponseWriter, err error) {
+	var slowModeErr *appErr.SlowModeError
+
+	switch {
+	case errors.Is(err, appErr.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.As(err, &slowModeErr):
+		// Rounded up so that clients never retry too early
+		retryAfter := int((slowModeErr.RetryAfter + time.Second - 1) / time.Second)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, appErr.ErrNotConversationMember),
+		errors.Is(err, appErr.ErrConversationAdminRequired),
+		errors.Is(err, appErr.ErrMemberMuted),
+		errors.Is(err, appErr.ErrDirectConversationClosed),
+		errors.Is(err, appErr.ErrCannotMuteAdmin):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, appErr.ErrAlreadyMember):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, appErr.ErrCommandFailed):
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	case errors.Is(err, appErr.ErrMessageRejected):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, appErr.ErrMessageEmpty),
+		errors.Is(err, appErr.ErrMessageTooLong),
+		errors.Is(err, appErr.ErrMessageUnsafeLink),
+		errors.Is(err, appErr.ErrMessageMalformed),
+		errors.Is(err, appErr.ErrMessageTTLOutOfRange),
+		errors.Is(err, appErr.ErrInvalidContentFilter),
+		errors.Is(err, appErr.ErrSlowModeOutOfRange),
+		errors.Is(err, appErr.ErrCannotMessageSelf),
+		errors.Is(err, appErr.ErrUnknownCommand),
+		errors.Is(err, appErr.ErrCommandUsage),
+		errors.Is(err, appErr.ErrTopicInvalid),
+		errors.Is(err, appErr.ErrDirectConversationMembers),
+		errors.Is(err, appErr.ErrMuteDurationOutOfRange),
+		errors.Is(err, appErr.ErrContentTypeInvalid),
+		errors.Is(err, appErr.ErrEncryptedRequiresDirect):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, appErr.ErrEncryptedMessageTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) HandleSendMessage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	conversationID, ok := conversationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req sendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if req.TTLFromRead && req.TTLSeconds == nil {
+		http.Error(w, "ttlFromRead requires ttlSeconds", http.StatusBadRequest)
+		return
+	}
+
+	input := service.SendMessageInput {
+		Content:	req.Content,
+		ContentType:	models.ContentType(req.ContentType),
+		TTLFromRead:	req.TTLFromRead,
+	}
+	if req.TTLSeconds != nil {
+		ttl := time.Duration(*req.TTLSeconds) * time.Second
+		input.TTL = &ttl
+	}
+
+	result, err := h.messageService.SendMessage(r.Context(), userID, conversationID, input)
+	if err != nil {
+		writeConversationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if result.Command == "" {
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(newMessageResponse(result.Message))
+		return
+	}
+
+	resp := commandResponse {
+		Command:	result.Command,
+		Reply:		result.Reply,
+	}
+	status := http.StatusOK
+	if result.Message != nil {
+		message := newMessageResponse(result.Message)
+		resp.Message = &message
+		status = http.StatusCreated
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Lists the slash commands available in every conversation
+func (h *Handler) HandleListCommands(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.messageService.ListCommands())
+}
+
+// Returns a page of conversation history, newest first.
+// Supports the optional "before" (RFC 3339) and "limit" query parameters.
+func (h *Handler) HandleGetMessages(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	conversationID, ok := conversationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var before *time.Time
+	if raw := r.URL.Query().Get("before"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			http.Error(w, "Invalid 'before' parameter", http.StatusBadRequest)
+			return
+		}
+		before = &parsed
+	}
+
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "Invalid 'limit' parameter", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	messages, err := h.messageService.GetMessages(r.Context(), userID, conversationID, before, limit)
+	if err != nil {
+		writeConversationError(w, err)
+		return
+	}
+
+	resp := make([]messageResponse, 0, len(messages))
+	for _, message := range messages {
+		resp = append(resp, newMessageResponse(message))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Marks a message as read, which starts its countdown when its TTL counts from the first read
+func (h *Handler) HandleMarkMessageRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	conversationID, ok := conversationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	messageID, err := uuid.Parse(r.PathValue("messageId"))
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.messageService.MarkMessageRead(r.Context(), userID, conversationID, messageID); err != nil {
+		writeConversationError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/http/middleware"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+type sessionResponse struct {
+	ID		string	`json:"id"`
+	DeviceName	string	`json:"deviceName"`
+	UserAgent	string	`json:"userAgent"`
+	IPAddress	string	`json:"ipAddress"`
+	CreatedAt	string	`json:"createdAt"`
+	LastSeenAt	string	`json:"lastSeenAt"`
+	// Whether the request was made from this session
+	Current		bool	`json:"current"`
+}
+
+func newSessionResponse(session *models.Session, currentID uuid.UUID) sessionResponse {
+	return sessionResponse {
+		ID:		session.ID.String(),
+		DeviceName:	session.DeviceName,
+		UserAgent:	session.UserAgent,
+		IPAddress:	session.IPAddress,
+		CreatedAt:	session.CreatedAt.Format(time.RFC3339),
+		LastSeenAt:	session.LastSeenAt.Format(time.RFC3339),
+		Current:	session.ID == currentID,
+	}
+}
+
+type revokeSessionsResponse struct {
+	Revoked	int	`json:"revoked"`
+}
+
+// Returns the claims of the JWT session, writing the error response on failure
+func currentClaims(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return claims.UserID, claims.SessionID, true
+}
+
+func (h *Handler) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, currentID, ok := currentClaims(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.sessionService.ListSessions(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		resp = append(resp, newSessionResponse(session, currentID))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Signs a session out, the current one included
+func (h *Handler) HandleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := currentClaims(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.sessionService.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		switch {
+		case errors.Is(err, appErr.ErrSessionNotFound):
+			http.Error(w, "Session not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Signs out every session but the current one
+func (h *Handler) HandleRevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID, currentID, ok := currentClaims(w, r)
+	if !ok {
+		return
+	}
+
+	revoked, err := h.sessionService.RevokeOtherSessions(r.Context(), userID, currentID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revokeSessionsResponse{Revoked: revoked})
+}
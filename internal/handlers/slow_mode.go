@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type slowModeRequest struct {
+	// 0 disables slow mode
+	IntervalSeconds int `json:"intervalSeconds"`
+}
+
+type slowModeResponse struct {
+	IntervalSeconds int `json:"intervalSeconds"`
+}
+
+// Sets the conversation's slow mode interval, restricted to its admins
+func (h *Handler) HandleSetSlowMode(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	conversationID, ok := conversationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req slowModeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	interval := time.Duration(req.IntervalSeconds) * time.Second
+	if err := h.conversationService.SetSlowMode(r.Context(), userID, conversationID, interval); err != nil {
+		writeConversationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(slowModeResponse{IntervalSeconds: req.IntervalSeconds})
+}
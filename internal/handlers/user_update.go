@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"errors"
+	"time"
 
 	"github.com/EliasLd/gotalk-backend/internal/service"
 	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
@@ -12,13 +13,22 @@ import (
 )
 
 type UpdateUserRequest struct {
-	Username *string `json:"username,omitempty"`
-	Password *string `json:"password,omitempty"`
+	Username	*string `json:"username,omitempty"`
+	Password	*string `json:"password,omitempty"`
+	DisplayName	*string `json:"displayName,omitempty"`
+	Bio		*string `json:"bio,omitempty"`
+	Pronouns	*string `json:"pronouns,omitempty"`
+	Timezone	*string `json:"timezone,omitempty"`
 }
 
 type UpdateUserResponse struct {
 	ID		string `json:"id"`
 	Username	string `json:"username"`
+	DisplayName	string `json:"displayName"`
+	Bio		string `json:"bio"`
+	Pronouns	string `json:"pronouns"`
+	Timezone	string `json:"timezone"`
+	AvatarURL	string `json:"avatarUrl"`
 	UpdatedAt	string `json:"updatedAt"`
 }
 
@@ -36,18 +46,29 @@ func (h *Handler) HandleUpdateMe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Username == nil && req.Password == nil {
-		http.Error(w, "At least one field (username of password) must be provided", http.StatusBadRequest)
+	if req.Username == nil && req.Password == nil && req.DisplayName == nil &&
+		req.Bio == nil && req.Pronouns == nil && req.Timezone == nil {
+		http.Error(w, "At least one field (username, password, displayName, bio, pronouns or timezone) must be provided", http.StatusBadRequest)
 		return
 	}
 	
+	// A leaked access token must not be enough to take over the account
+	if req.Password != nil && !middleware.IsSession(r.Context()) {
+		http.Error(w, "Changing the password requires logging in with a password", http.StatusForbidden)
+		return
+	}
+
 	userUUID, err := uuid.Parse(userID)
 	if err != nil {
 		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 	}
 	updatedUser, err := h.userService.UpdateUser(r.Context(), userUUID, service.UpdateUserInput { 
-		Username: req.Username,
-		Password: req.Password,
+		Username:	req.Username,
+		Password:	req.Password,
+		DisplayName:	req.DisplayName,
+		Bio:		req.Bio,
+		Pronouns:	req.Pronouns,
+		Timezone:	req.Timezone,
 	})
 	if err != nil {
 		switch {
@@ -58,7 +79,11 @@ func (h *Handler) HandleUpdateMe(w http.ResponseWriter, r *http.Request) {
 	     		errors.Is(err, appErr.ErrPasswordMissingUpper),
 	     		errors.Is(err, appErr.ErrPasswordMissingLower),
 	     		errors.Is(err, appErr.ErrPasswordMissingSymbol),
-			errors.Is(err, appErr.ErrPasswordHashingFailed):
+			errors.Is(err, appErr.ErrPasswordHashingFailed),
+			errors.Is(err, appErr.ErrDisplayNameInvalid),
+			errors.Is(err, appErr.ErrBioInvalid),
+			errors.Is(err, appErr.ErrPronounsInvalid),
+			errors.Is(err, appErr.ErrTimezoneInvalid):
 			http.Error(w, err.Error(), http.StatusBadRequest)
 		default:
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -69,7 +94,12 @@ func (h *Handler) HandleUpdateMe(w http.ResponseWriter, r *http.Request) {
 	resp := UpdateUserResponse {
 		ID:		updatedUser.ID.String(),
 		Username:	updatedUser.Username,
-		UpdatedAt:	updatedUser.UpdatedAt.Format("2006-01-02T15:04:05z07:00"),
+		DisplayName:	updatedUser.DisplayName,
+		Bio:		updatedUser.Bio,
+		Pronouns:	updatedUser.Pronouns,
+		Timezone:	updatedUser.Timezone,
+		AvatarURL:	updatedUser.AvatarURL(),
+		UpdatedAt:	updatedUser.UpdatedAt.Format(time.RFC3339),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+type searchUsersResponse struct {
+	Users		[]models.PublicUser	`json:"users"`
+	// Empty on the last page
+	NextCursor	string			`json:"nextCursor,omitempty"`
+}
+
+// Searches users by username prefix.
+// Supports the "q", "cursor" and "limit" query parameters.
+func (h *Handler) HandleSearchUsers(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	limit := 0
+	if raw := params.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "Invalid 'limit' parameter", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	users, nextCursor, err := h.userService.SearchUsers(r.Context(), params.Get("q"), params.Get("cursor"), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, appErr.ErrInvalidSearchQuery):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(searchUsersResponse {
+		Users:		users,
+		NextCursor:	nextCursor,
+	})
+}
+
+// Returns another user's public profile
+func (h *Handler) HandleGetUserProfile(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := h.userService.GetPublicProfile(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, appErr.ErrUserNotFound):
+			http.Error(w, "User not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/service"
+	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+type createWebhookRequest struct {
+	URL		string		`json:"url"`
+	Events		[]string	`json:"events"`
+	// Server-wide webhook when omitted
+	ConversationID	*string		`json:"conversationId,omitempty"`
+}
+
+type webhookResponse struct {
+	ID		string		`json:"id"`
+	ConversationID	*string		`json:"conversationId"`
+	URL		string		`json:"url"`
+	Events		[]string	`json:"events"`
+	CreatedAt	string		`json:"createdAt"`
+	// Only returned once, on creation
+	Secret		string		`json:"secret,omitempty"`
+}
+
+type webhookDeliveryResponse struct {
+	ID		string		`json:"id"`
+	Event		string		`json:"event"`
+	Payload		json.RawMessage	`json:"payload"`
+	Status		string		`json:"status"`
+	Attempts	int		`json:"attempts"`
+	NextAttemptAt	string		`json:"nextAttemptAt,omitempty"`
+	LastStatusCode	*int		`json:"lastStatusCode"`
+	LastError	string		`json:"lastError,omitempty"`
+	CreatedAt	string		`json:"createdAt"`
+	DeliveredAt	string		`json:"deliveredAt,omitempty"`
+}
+
+func newWebhookResponse(webhook *models.Webhook) webhookResponse {
+	resp := webhookResponse {
+		ID:		webhook.ID.String(),
+		URL:		webhook.URL,
+		Events:		webhook.Events,
+		CreatedAt:	webhook.CreatedAt.Format(time.RFC3339),
+	}
+	if webhook.ConversationID != nil {
+		conversationID := webhook.ConversationID.String()
+		resp.ConversationID = &conversationID
+	}
+	return resp
+}
+
+func newWebhookDeliveryResponse(delivery *models.WebhookDelivery) webhookDeliveryResponse {
+	resp := webhookDeliveryResponse {
+		ID:		delivery.ID.String(),
+		Event:		delivery.Event,
+		Payload:	delivery.Payload,
+		Status:		string(delivery.Status),
+		Attempts:	delivery.Attempts,
+		LastStatusCode:	delivery.LastStatusCode,
+		LastError:	delivery.LastError,
+		CreatedAt:	delivery.CreatedAt.Format(time.RFC3339),
+	}
+	if delivery.Status == models.DeliveryPending {
+		resp.NextAttemptAt = delivery.NextAttemptAt.Format(time.RFC3339)
+	}
+	if delivery.DeliveredAt != nil {
+		resp.DeliveredAt = delivery.DeliveredAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, appErr.ErrWebhookNotFound):
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+	case errors.Is(err, appErr.ErrDeliveryNotFound):
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+	case errors.Is(err, appErr.ErrDeliveryExpired):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, appErr.ErrServerAdminRequired):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, appErr.ErrWebhookURLInvalid),
+		errors.Is(err, appErr.ErrWebhookHostForbidden),
+		errors.Is(err, appErr.ErrWebhookEventsInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		writeConversationError(w, err)
+	}
+}
+
+// Parses the {id} path value of webhook routes, writing the error response on failure
+func webhookIDFromPath(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	webhookID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+
+	return webhookID, true
+}
+
+func (h *Handler) HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req createWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	input := service.CreateWebhookInput {
+		URL:	req.URL,
+		Events:	req.Events,
+	}
+	if req.ConversationID != nil {
+		conversationID, err := uuid.Parse(*req.ConversationID)
+		if err != nil {
+			http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+			return
+		}
+		input.ConversationID = &conversationID
+	}
+
+	webhook, err := h.webhookService.CreateWebhook(r.Context(), userID, input)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	resp := newWebhookResponse(webhook)
+	resp.Secret = webhook.Secret
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	webhooks, err := h.webhookService.ListWebhooks(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]webhookResponse, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		resp = append(resp, newWebhookResponse(webhook))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	webhookID, ok := webhookIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.webhookService.DeleteWebhook(r.Context(), userID, webhookID); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Returns the delivery log, newest first
+func (h *Handler) HandleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	webhookID, ok := webhookIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(r.Context(), userID, webhookID, limit)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	resp := make([]webhookDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		resp = append(resp, newWebhookDeliveryResponse(delivery))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Sends a past delivery again, as a new entry of the log
+func (h *Handler) HandleReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	webhookID, ok := webhookIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryId"))
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := h.webhookService.ReplayDelivery(r.Context(), userID, webhookID, deliveryID)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(newWebhookDeliveryResponse(delivery))
+}
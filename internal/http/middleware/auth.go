@@ -6,12 +6,31 @@ import (
 	"net/http"
 
 	"github.com/EliasLd/gotalk-backend/internal/auth"
+	"github.com/google/uuid"
 )
 
 const userIDKey string = "userID"
 
+// Scopes granted to the personal access token authenticating the request.
+// Absent for JWT sessions, which are not restricted.
+const scopesKey string = "scopes"
+
+// Claims of the JWT authenticating the request, absent for personal access tokens
+const claimsKey string = "claims"
+
+// Resolves personal access tokens into their owner and granted scopes
+type TokenValidator interface {
+	ValidateAccessToken(ctx context.Context, token string) (uuid.UUID, []string, error)
+}
+
 // Middleware that checks JWT in authorization header
 func AuthMiddleware(next http.Handler) http.Handler {
+	return Authenticate(nil, nil, next)
+}
+
+// Middleware accepting either a JWT or, when tokens is not nil, a personal access token.
+// JWTs are also checked against revocations when it is not nil.
+func Authenticate(tokens TokenValidator, revocations auth.RevocationChecker, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 
@@ -22,13 +41,28 @@ func AuthMiddleware(next http.Handler) http.Handler {
 
 		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
 
+		if tokens != nil && auth.IsAccessToken(tokenStr) {
+			userID, scopes, err := tokens.ValidateAccessToken(r.Context(), tokenStr)
+			if err != nil {
+				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userIDKey, userID.String())
+			ctx = context.WithValue(ctx, scopesKey, scopes)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
 		claims, err := auth.ValidateToken(tokenStr)
-		if err != nil {
+		if err != nil || (revocations != nil && revocations.IsRevoked(claims)) {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
 			return
 		}
 
 		ctx := context.WithValue(r.Context(), userIDKey, claims.UserID.String())
+		ctx = context.WithValue(ctx, claimsKey, claims)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -39,3 +73,61 @@ func UserIDFromContext(ctx context.Context) (string, bool) {
 	userID, ok := ctx.Value(userIDKey).(string)
 	return userID, ok
 }
+
+// Returns the claims of the JWT authenticating the request.
+// The second value is false for personal access tokens.
+func ClaimsFromContext(ctx context.Context) (*auth.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*auth.Claims)
+	return claims, ok
+}
+
+// Returns the scopes of the access token authenticating the request.
+// The second value is false for JWT sessions, which have every permission.
+func ScopesFromContext(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(scopesKey).([]string)
+	return scopes, ok
+}
+
+// Reports whether the request was authenticated with a password-based JWT session
+func IsSession(ctx context.Context) bool {
+	_, restricted := ScopesFromContext(ctx)
+	return !restricted
+}
+
+// Reports whether the request may perform actions covered by scope
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, restricted := ScopesFromContext(ctx)
+	if !restricted {
+		return true
+	}
+
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Must wrap a handler already behind Authenticate
+func RequireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !HasScope(r.Context(), scope) {
+			http.Error(w, "Token is missing the "+scope+" scope", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Rejects personal access tokens, for account management routes.
+// Must wrap a handler already behind Authenticate.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsSession(r.Context()) {
+			http.Error(w, "This action requires logging in with a password", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
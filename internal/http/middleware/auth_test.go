@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -12,6 +14,7 @@ import (
 )
 
 func TestAuthMiddleware_ValidToken(t *testing.T) {
+	auth.SetupTestKeys(t)
 	userID := uuid.New()
 
 	user := &models.User {
@@ -23,7 +26,7 @@ func TestAuthMiddleware_ValidToken(t *testing.T) {
 	}
 
 	// Generate a valid jwt token
-	token, err := auth.GenerateToken(user)
+	token, err := auth.GenerateToken(user, uuid.New())
 	if err != nil {
 		t.Fatalf("failed to generate token: %v", err)
 	}
@@ -70,3 +73,141 @@ func TestAuthMiddleware_InvalidToken(t *testing.T) {
 		t.Fatalf("Expected status 401 Unauthorized, got %d", rr.Code)
 	}
 }
+
+// Accepts a single hard-coded access token
+type stubTokenValidator struct {
+	token	string
+	userID	uuid.UUID
+	scopes	[]string
+}
+
+func (v *stubTokenValidator) ValidateAccessToken(ctx context.Context, token string) (uuid.UUID, []string, error) {
+	if token != v.token {
+		return uuid.Nil, nil, errors.New("invalid token")
+	}
+	return v.userID, v.scopes, nil
+}
+
+func TestAuthenticate_AccessTokenScopes(t *testing.T) {
+	validator := &stubTokenValidator {
+		token:	auth.AccessTokenPrefix + "valid",
+		userID:	uuid.New(),
+		scopes:	[]string{auth.ScopeMessagesRead},
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctxUserID, _ := UserIDFromContext(r.Context())
+		if ctxUserID != validator.userID.String() {
+			t.Errorf("Expected userID %s, got %s", validator.userID, ctxUserID)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name		string
+		token		string
+		handler		http.Handler
+		wantStatus	int
+	}{
+		{"Granted scope", validator.token, RequireScope(auth.ScopeMessagesRead, ok), http.StatusOK},
+		{"Missing scope", validator.token, RequireScope(auth.ScopeMessagesWrite, ok), http.StatusForbidden},
+		{"Session only route", validator.token, RequireSession(ok), http.StatusForbidden},
+		{"Unknown token", auth.AccessTokenPrefix + "revoked", RequireScope(auth.ScopeMessagesRead, ok), http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/protected", nil)
+			req.Header.Set("Authorization", "Bearer " + tt.token)
+
+			rr := httptest.NewRecorder()
+			Authenticate(validator, nil, tt.handler).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestAuthenticate_SessionHasEveryScope(t *testing.T) {
+	auth.SetupTestKeys(t)
+	user := &models.User{ID: uuid.New(), Username: "testuser_session_scopes"}
+
+	token, err := auth.GenerateToken(user, uuid.New())
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+
+	handler := Authenticate(&stubTokenValidator{}, nil, RequireScope(auth.ScopeConversationsWrite, RequireSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))))
+
+	req := httptest.NewRequest("GET", "/protected", nil)
+	req.Header.Set("Authorization", "Bearer " + token)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 OK, got %d", rr.Code)
+	}
+}
+
+// Revokes a single hard-coded jti
+type stubRevocationChecker struct {
+	jti string
+}
+
+func (c *stubRevocationChecker) IsRevoked(claims *auth.Claims) bool {
+	return claims.ID == c.jti
+}
+
+func TestAuthenticate_RevokedToken(t *testing.T) {
+	auth.SetupTestKeys(t)
+	user := &models.User{ID: uuid.New(), Username: "testuser_revoked"}
+
+	revoked, err := auth.GenerateToken(user, uuid.New())
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	valid, err := auth.GenerateToken(user, uuid.New())
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	claims, err := auth.ValidateToken(revoked)
+	if err != nil {
+		t.Fatalf("failed to validate token: %v", err)
+	}
+	checker := &stubRevocationChecker{jti: claims.ID}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, found := ClaimsFromContext(r.Context()); !found {
+			t.Errorf("Expected the claims in the context")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name		string
+		token		string
+		wantStatus	int
+	}{
+		{"Valid token", valid, http.StatusOK},
+		{"Revoked token", revoked, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/protected", nil)
+			req.Header.Set("Authorization", "Bearer " + tt.token)
+
+			rr := httptest.NewRecorder()
+			Authenticate(nil, checker, ok).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
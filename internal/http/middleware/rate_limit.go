@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// In-memory token bucket limiter keyed by an arbitrary string.
+// Limits only apply to the current server instance.
+type RateLimiter struct {
+	mu		sync.Mutex
+	rate		float64
+	burst		float64
+	buckets		map[string]*bucket
+	lastCleanup	time.Time
+}
+
+type bucket struct {
+	tokens		float64
+	updatedAt	time.Time
+}
+
+// Allows up to burst requests at once, refilled at limit requests per window
+func NewRateLimiter(limit int, window time.Duration, burst int) *RateLimiter {
+	return &RateLimiter {
+		rate:		float64(limit) / window.Seconds(),
+		burst:		float64(burst),
+		buckets:	make(map[string]*bucket),
+		lastCleanup:	time.Now(),
+	}
+}
+
+// Limiter of user searches, which could otherwise be used to enumerate accounts
+func NewSearchRateLimiter() *RateLimiter {
+	return NewRateLimiter(30, time.Minute, 10)
+}
+
+// Consumes a token for key, returning how long to wait when none is left
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.cleanup(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, updatedAt: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens + now.Sub(b.updatedAt).Seconds() * l.rate)
+	b.updatedAt = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// Drops buckets which have been full for a while, at most once a minute
+func (l *RateLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < time.Minute {
+		return
+	}
+	l.lastCleanup = now
+
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.updatedAt) > refill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware limiting requests per authenticated user, must run after AuthMiddleware
+func RateLimit(limiter *RateLimiter, next http.Handler) http.Handler {
+	return RateLimitBy(limiter, func(r *http.Request) (string, bool) {
+		return UserIDFromContext(r.Context())
+	}, next)
+}
+
+// Middleware limiting requests per key, requests without a key are rejected as unauthorized
+func RateLimitBy(limiter *RateLimiter, key func(r *http.Request) (string, bool), next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k, ok := key(r)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		allowed, wait := limiter.Allow(k)
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	limiter := NewRateLimiter(1, time.Hour, 2)
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := limiter.Allow("user"); !allowed {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+
+	allowed, wait := limiter.Allow("user")
+	if allowed {
+		t.Fatal("Expected request over burst to be limited")
+	}
+	if wait <= 0 || wait > time.Hour {
+		t.Errorf("Expected a wait within the window, got %s", wait)
+	}
+
+	// Other keys have their own bucket
+	if allowed, _ := limiter.Allow("other_user"); !allowed {
+		t.Error("Expected another key to be allowed")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limiter := NewRateLimiter(1, time.Minute, 1)
+	handler := RateLimit(limiter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("GET", "/limited", nil)
+		return req.WithContext(context.WithValue(req.Context(), userIDKey, "some-user"))
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest())
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 OK, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest())
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 Too Many Requests, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+}
+
+func TestRateLimitBy_CustomKey(t *testing.T) {
+	limiter := NewRateLimiter(1, time.Minute, 1)
+	handler := RateLimitBy(limiter, func(r *http.Request) (string, bool) {
+		return r.URL.Query().Get("key"), true
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tt := range []struct {
+		key	string
+		want	int
+	}{
+		{"a", http.StatusOK},
+		{"a", http.StatusTooManyRequests},
+		{"b", http.StatusOK},
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("POST", "/hooks?key="+tt.key, nil))
+		if rr.Code != tt.want {
+			t.Errorf("Key %q: expected status %d, got %d", tt.key, tt.want, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/EliasLd/gotalk-backend/internal/apidocs"
+	"github.com/EliasLd/gotalk-backend/internal/handlers"
+)
+
+// Records the patterns registered by the router
+type patternRecorder struct {
+	patterns []string
+}
+
+func (r *patternRecorder) Handle(pattern string, _ http.Handler) {
+	r.patterns = append(r.patterns, pattern)
+}
+
+func (r *patternRecorder) HandleFunc(pattern string, _ func(http.ResponseWriter, *http.Request)) {
+	r.patterns = append(r.patterns, pattern)
+}
+
+func TestRoutesDocumented(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(apidocs.Spec(), &spec); err != nil {
+		t.Fatalf("Failed to parse OpenAPI document: %v", err)
+	}
+
+	operations := map[string]bool{}
+	for path, item := range spec.Paths {
+		for method := range item {
+			switch method {
+			case "get", "put", "post", "delete", "patch", "head", "options":
+				operations[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+
+	recorder := &patternRecorder{}
+	registerRoutes(recorder, handlers.NewHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil), nil, nil, nil)
+
+	registered := map[string]bool{}
+	for _, pattern := range recorder.patterns {
+		method, path, found := strings.Cut(pattern, " ")
+		if !found {
+			// Routes registered without a method must document at least one
+			path = method
+			if _, ok := spec.Paths[path]; !ok {
+				t.Errorf("Route %q is missing from the OpenAPI document", pattern)
+			}
+			for operation := range operations {
+				if strings.HasSuffix(operation, " "+path) {
+					registered[operation] = true
+				}
+			}
+			continue
+		}
+
+		registered[pattern] = true
+		if !operations[pattern] {
+			t.Errorf("Route %q is missing from the OpenAPI document", pattern)
+		}
+	}
+
+	for operation := range operations {
+		if !registered[operation] {
+			t.Errorf("Operation %q is documented but not registered", operation)
+		}
+	}
+}
+
+func TestDocsRoutes(t *testing.T) {
+	router := NewRouter(handlers.NewHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil), nil, nil, nil)
+
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if !json.Valid(rr.Body.Bytes()) {
+		t.Errorf("Expected a JSON document")
+	}
+
+	req = httptest.NewRequest("GET", "/docs", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("Expected an HTML page, got %q", rr.Header().Get("Content-Type"))
+	}
+}
@@ -2,22 +2,118 @@ package http
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/EliasLd/gotalk-backend/internal/apidocs"
+	"github.com/EliasLd/gotalk-backend/internal/auth"
 	"github.com/EliasLd/gotalk-backend/internal/handlers"
 	"github.com/EliasLd/gotalk-backend/internal/http/middleware"
 )
 
-func NewRouter(handler * handlers.Handler) http.Handler {
+// Subset of http.ServeMux used to register routes, lets tests list them
+type routeRegistrar interface {
+	Handle(pattern string, handler http.Handler)
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
+// tokens resolves personal access tokens, only JWTs are accepted when nil.
+// revocations rejects JWTs revoked before they expire, none are when nil.
+// searchLimiter limits user searches, shared with the gRPC API. A new one is used when nil.
+func NewRouter(handler * handlers.Handler, tokens middleware.TokenValidator, revocations auth.RevocationChecker, searchLimiter *middleware.RateLimiter) http.Handler {
 	mux := http.NewServeMux()
+	registerRoutes(mux, handler, tokens, revocations, searchLimiter)
+	return mux
+}
+
+// Every route registered here must be described in internal/apidocs/openapi.json
+func registerRoutes(mux routeRegistrar, handler *handlers.Handler, tokens middleware.TokenValidator, revocations auth.RevocationChecker, searchLimiter *middleware.RateLimiter) {
+	// Authenticated routes, reachable with a JWT or a token granted the given scope
+	scoped := func(scope string, h http.HandlerFunc) http.Handler {
+		return middleware.Authenticate(tokens, revocations, middleware.RequireScope(scope, h))
+	}
+	// Account management routes, personal access tokens are refused
+	session := func(h http.HandlerFunc) http.Handler {
+		return middleware.Authenticate(tokens, revocations, middleware.RequireSession(h))
+	}
 
 	// Public routes
 	mux.HandleFunc("/health", handlers.HealthHandler)
+	mux.HandleFunc("GET /openapi.json", apidocs.HandleSpec)
+	mux.HandleFunc("GET /docs", apidocs.HandleUI)
+	mux.HandleFunc("GET /.well-known/jwks.json", handlers.HandleJWKS)
 	mux.HandleFunc("/register", handler.HandleRegister)
 	mux.HandleFunc("/login", handler.HandleLogin)
+	mux.HandleFunc("POST /token/refresh", handler.HandleRefreshToken)
+	mux.HandleFunc("GET /users/{id}/avatar", handler.HandleGetAvatar)
+	mux.HandleFunc("GET /exports/{id}/download", handler.HandleDownloadExport)
+
+	// Incoming webhooks are limited per token, unknown tokens included
+	incomingLimiter := middleware.NewRateLimiter(30, time.Minute, 10)
+	incomingKey := func(r *http.Request) (string, bool) {
+		return r.PathValue("token"), true
+	}
+	mux.Handle("POST /hooks/incoming/{token}", middleware.RateLimitBy(incomingLimiter, incomingKey, http.HandlerFunc(handler.HandlePostIncomingWebhook)))
 
 	// Private routes
-	mux.Handle("/me", middleware.AuthMiddleware(http.HandlerFunc(handler.HandleGetMe)))
-	mux.Handle("/me/update", middleware.AuthMiddleware(http.HandlerFunc(handler.HandleUpdateMe)))
+	mux.Handle("/me", scoped(auth.ScopeProfileRead, handler.HandleGetMe))
+	mux.Handle("POST /logout", session(handler.HandleLogout))
+	mux.Handle("DELETE /me", session(handler.HandleDeleteMe))
+	mux.Handle("/me/update", scoped(auth.ScopeProfileWrite, handler.HandleUpdateMe))
+	mux.Handle("PUT /me/avatar", scoped(auth.ScopeProfileWrite, handler.HandleUploadAvatar))
+	mux.Handle("DELETE /me/avatar", scoped(auth.ScopeProfileWrite, handler.HandleDeleteAvatar))
+	mux.Handle("POST /me/exports", session(handler.HandleRequestExport))
+	mux.Handle("GET /me/exports/{id}", session(handler.HandleGetExport))
 
-	return mux
+	// Sessions, one per login
+	mux.Handle("GET /me/sessions", session(handler.HandleListSessions))
+	mux.Handle("DELETE /me/sessions", session(handler.HandleRevokeOtherSessions))
+	mux.Handle("DELETE /me/sessions/{id}", session(handler.HandleRevokeSession))
+
+	// Personal access tokens
+	mux.Handle("GET /me/tokens", session(handler.HandleListAccessTokens))
+	mux.Handle("POST /me/tokens", session(handler.HandleCreateAccessToken))
+	mux.Handle("DELETE /me/tokens/{id}", session(handler.HandleRevokeAccessToken))
+
+	// Outgoing webhooks
+	mux.Handle("GET /webhooks", session(handler.HandleListWebhooks))
+	mux.Handle("POST /webhooks", session(handler.HandleCreateWebhook))
+	mux.Handle("DELETE /webhooks/{id}", session(handler.HandleDeleteWebhook))
+	mux.Handle("GET /webhooks/{id}/deliveries", session(handler.HandleListWebhookDeliveries))
+	mux.Handle("POST /webhooks/{id}/deliveries/{deliveryId}/replay", session(handler.HandleReplayWebhookDelivery))
+
+	// End-to-end encryption keys, bundles are limited as each claim consumes one-time prekeys
+	mux.Handle("GET /me/devices", session(handler.HandleListDevices))
+	mux.Handle("PUT /me/devices/{id}", session(handler.HandleRegisterDevice))
+	mux.Handle("DELETE /me/devices/{id}", session(handler.HandleDeleteDevice))
+	mux.Handle("POST /me/devices/{id}/prekeys", session(handler.HandleUploadPreKeys))
+	bundleLimiter := middleware.NewRateLimiter(30, time.Minute, 10)
+	mux.Handle("POST /users/{id}/prekey-bundles", middleware.Authenticate(tokens, revocations, middleware.RequireSession(middleware.RateLimit(bundleLimiter, http.HandlerFunc(handler.HandleClaimPreKeyBundles)))))
+
+	// User routes
+	if searchLimiter == nil {
+		searchLimiter = middleware.NewSearchRateLimiter()
+	}
+	mux.Handle("GET /users/search", middleware.Authenticate(tokens, revocations, middleware.RequireScope(auth.ScopeProfileRead, middleware.RateLimit(searchLimiter, http.HandlerFunc(handler.HandleSearchUsers)))))
+	mux.Handle("GET /users/{id}", scoped(auth.ScopeProfileRead, handler.HandleGetUserProfile))
+
+	// Block routes
+	mux.Handle("GET /blocks", scoped(auth.ScopeProfileRead, handler.HandleListBlocks))
+	mux.Handle("POST /blocks", scoped(auth.ScopeProfileWrite, handler.HandleBlockUser))
+	mux.Handle("DELETE /blocks/{userId}", scoped(auth.ScopeProfileWrite, handler.HandleUnblockUser))
+
+	// Conversation routes
+	mux.Handle("GET /commands", scoped(auth.ScopeMessagesRead, handler.HandleListCommands))
+	mux.Handle("GET /conversations", scoped(auth.ScopeConversationsRead, handler.HandleListConversations))
+	mux.Handle("POST /conversations/direct", scoped(auth.ScopeConversationsWrite, handler.HandleOpenDirectConversation))
+	mux.Handle("POST /conversations/{id}/messages", scoped(auth.ScopeMessagesWrite, handler.HandleSendMessage))
+	mux.Handle("GET /conversations/{id}/messages", scoped(auth.ScopeMessagesRead, handler.HandleGetMessages))
+	mux.Handle("POST /conversations/{id}/messages/{messageId}/read", scoped(auth.ScopeMessagesRead, handler.HandleMarkMessageRead))
+	mux.Handle("GET /conversations/{id}/events", scoped(auth.ScopeMessagesRead, handler.HandleConversationEvents))
+	mux.Handle("GET /conversations/{id}/filters", scoped(auth.ScopeConversationsRead, handler.HandleGetContentFilters))
+	mux.Handle("PUT /conversations/{id}/filters", scoped(auth.ScopeConversationsWrite, handler.HandleSetContentFilters))
+	mux.Handle("PUT /conversations/{id}/slow-mode", scoped(auth.ScopeConversationsWrite, handler.HandleSetSlowMode))
+	mux.Handle("GET /conversations/{id}/incoming-webhooks", scoped(auth.ScopeConversationsRead, handler.HandleListIncomingWebhooks))
+	mux.Handle("POST /conversations/{id}/incoming-webhooks", session(handler.HandleCreateIncomingWebhook))
+	mux.Handle("DELETE /conversations/{id}/incoming-webhooks/{webhookId}", scoped(auth.ScopeConversationsWrite, handler.HandleRevokeIncomingWebhook))
+	mux.Handle("PUT /conversations/{id}/incoming-webhooks/{webhookId}/avatar", scoped(auth.ScopeConversationsWrite, handler.HandleUploadIncomingWebhookAvatar))
 }
@@ -7,9 +7,11 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	//	apphttp "github.com/EliasLd/gotalk-backend/internal/http"
 	"github.com/EliasLd/gotalk-backend/internal/auth"
+	"github.com/EliasLd/gotalk-backend/internal/database"
 	"github.com/EliasLd/gotalk-backend/internal/handlers"
 	"github.com/EliasLd/gotalk-backend/internal/repository"
 	"github.com/EliasLd/gotalk-backend/internal/service"
@@ -18,9 +20,10 @@ import (
 
 func TestGetMeRoute(t *testing.T) {
 	repo := repository.SetupTest(t)
-	userService := service.NewUserService(repo)
-	handler := handlers.NewHandler(userService)
-	router := NewRouter(handler)
+	auth.SetupTestKeys(t)
+	userService := service.NewUserService(repo, nil, nil, service.DefaultAccountDeletionGracePeriod, nil)
+	handler := handlers.NewHandler(userService, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	router := NewRouter(handler, nil, nil, nil)
 
 	username := "testuser_GetMeRoute"
 	password := "ValidPasswd123!"
@@ -31,7 +34,7 @@ func TestGetMeRoute(t *testing.T) {
 
 	defer repository.CleanUpUser(t, user.ID, repo)
 
-	token, err := auth.GenerateToken(user)
+	token, err := auth.GenerateToken(user, uuid.New())
 	if err != nil {
 		t.Fatalf("Failed to generate token: %v", err)
 	}
@@ -58,9 +61,10 @@ func TestGetMeRoute(t *testing.T) {
 
 func TestGetMe_Unauthorized(t *testing.T) {
 	repo := repository.SetupTest(t)
-	userService := service.NewUserService(repo)
-	handler := handlers.NewHandler(userService)
-	router := NewRouter(handler)
+	auth.SetupTestKeys(t)
+	userService := service.NewUserService(repo, nil, nil, service.DefaultAccountDeletionGracePeriod, nil)
+	handler := handlers.NewHandler(userService, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	router := NewRouter(handler, nil, nil, nil)
 
 	req := httptest.NewRequest("GET", "/me", nil)
 	rr := httptest.NewRecorder()
@@ -77,9 +81,10 @@ func TestGetMe_Unauthorized(t *testing.T) {
 
 func TestRegisterRoute(t *testing.T) {
 	repo := repository.SetupTest(t)
-	userService := service.NewUserService(repo)
-	handler := handlers.NewHandler(userService)
-	router := NewRouter(handler)
+	auth.SetupTestKeys(t)
+	userService := service.NewUserService(repo, nil, nil, service.DefaultAccountDeletionGracePeriod, nil)
+	handler := handlers.NewHandler(userService, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	router := NewRouter(handler, nil, nil, nil)
 
 	username := "testuser_register"
 	password := "ValidPasswd123!"
@@ -119,9 +124,10 @@ func TestRegisterRoute(t *testing.T) {
 
 func TestRegisterRoute_UserAlreadyExists(t *testing.T) {
 	repo := repository.SetupTest(t)
-	userService := service.NewUserService(repo)
-	handler := handlers.NewHandler(userService)
-	router := NewRouter(handler)
+	auth.SetupTestKeys(t)
+	userService := service.NewUserService(repo, nil, nil, service.DefaultAccountDeletionGracePeriod, nil)
+	handler := handlers.NewHandler(userService, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	router := NewRouter(handler, nil, nil, nil)
 
 	username := "testuser_register_duplicate"
 	password := "ValidPasswd123!"
@@ -153,9 +159,10 @@ func TestRegisterRoute_UserAlreadyExists(t *testing.T) {
 
 func TestRegisterRoute_InvalidPassword(t *testing.T) {
 	repo := repository.SetupTest(t)
-	userService := service.NewUserService(repo)
-	handler := handlers.NewHandler(userService)
-	router := NewRouter(handler)
+	auth.SetupTestKeys(t)
+	userService := service.NewUserService(repo, nil, nil, service.DefaultAccountDeletionGracePeriod, nil)
+	handler := handlers.NewHandler(userService, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	router := NewRouter(handler, nil, nil, nil)
 
 	username := "testuser_invalid_password"
 	invalidPassword := "abc"
@@ -178,9 +185,11 @@ func TestRegisterRoute_InvalidPassword(t *testing.T) {
 
 func TestLoginRoute(t *testing.T) {
 	repo := repository.SetupTest(t)
-	userService := service.NewUserService(repo)
-	handler := handlers.NewHandler(userService)
-	router := NewRouter(handler)
+	auth.SetupTestKeys(t)
+	userService := service.NewUserService(repo, nil, nil, service.DefaultAccountDeletionGracePeriod, nil)
+	refreshTokenService := service.NewRefreshTokenService(repository.NewRefreshTokenRepository(database.DB), repository.NewSessionRepository(database.DB))
+	handler := handlers.NewHandler(userService, nil, nil, nil, nil, nil, nil, nil, nil, nil, refreshTokenService, nil, nil)
+	router := NewRouter(handler, nil, nil, nil)
 
 	username := "testuser_login"
 	password := "ValidPasswd123!"
@@ -214,13 +223,142 @@ func TestLoginRoute(t *testing.T) {
 	if !ok || token == "" {
 		t.Errorf("Expected non-empty token in response")
 	}
+
+	refreshToken, ok := response["refreshToken"].(string)
+	if !ok || !auth.IsRefreshToken(refreshToken) {
+		t.Fatalf("Expected a refresh token in response, got %v", response["refreshToken"])
+	}
+
+	// The refresh token is rotated, then refused when presented again
+	refresh := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/token/refresh", strings.NewReader(`{"refreshToken":"`+refreshToken+`"}`))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr = refresh()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 OK on refresh, got %d", rr.Code)
+	}
+	var refreshed map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&refreshed); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if refreshed["refreshToken"] == refreshToken || refreshed["token"] == "" {
+		t.Errorf("Expected a new token pair, got %v", refreshed)
+	}
+
+	if rr = refresh(); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for a reused refresh token, got %d", rr.Code)
+	}
+}
+
+func TestSessionsRoute(t *testing.T) {
+	repo := repository.SetupTest(t)
+	auth.SetupTestKeys(t)
+	sessionRepo := repository.NewSessionRepository(database.DB)
+	userService := service.NewUserService(repo, nil, nil, service.DefaultAccountDeletionGracePeriod, nil)
+	refreshTokenService := service.NewRefreshTokenService(repository.NewRefreshTokenRepository(database.DB), sessionRepo)
+	revocations := service.NewTokenRevocationService(repository.NewRevokedTokenRepository(database.DB), sessionRepo, time.Minute)
+	sessionService := service.NewSessionService(sessionRepo, revocations)
+	handler := handlers.NewHandler(userService, nil, nil, nil, nil, nil, nil, nil, nil, nil, refreshTokenService, revocations, sessionService)
+	router := NewRouter(handler, nil, revocations, nil)
+
+	username := "testuser_sessions"
+	password := "ValidPasswd123!"
+
+	user, err := userService.RegisterUser(context.Background(), username, password)
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	defer repository.CleanUpUser(t, user.ID, repo)
+
+	login := func(deviceName string) map[string]interface{} {
+		reqBody := `{"username":"` + username + `","password":"` + password + `","deviceName":"` + deviceName + `"}`
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(reqBody))
+		req.Header.Set("User-Agent", "gotalk-test")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 OK on login, got %d", rr.Code)
+		}
+
+		var response map[string]interface{}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response
+	}
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	phone := login("Phone")
+	laptop := login("Laptop")
+	laptopToken := laptop["token"].(string)
+
+	rr := do("GET", "/me/sessions", laptopToken)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 OK, got %d", rr.Code)
+	}
+	var sessions []map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&sessions); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d", len(sessions))
+	}
+	for _, session := range sessions {
+		current := session["id"] == laptop["sessionId"]
+		if session["current"] != current || session["userAgent"] != "gotalk-test" {
+			t.Errorf("Unexpected session: %v", session)
+		}
+		if current && session["deviceName"] != "Laptop" {
+			t.Errorf("Expected the current session to be the laptop, got %v", session)
+		}
+	}
+
+	// The laptop signs the lost phone out
+	if rr = do("DELETE", "/me/sessions/"+phone["sessionId"].(string), laptopToken); rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 No Content, got %d", rr.Code)
+	}
+	if rr = do("GET", "/me", phone["token"].(string)); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the phone's token to be refused, got %d", rr.Code)
+	}
+	req := httptest.NewRequest("POST", "/token/refresh", strings.NewReader(`{"refreshToken":"`+phone["refreshToken"].(string)+`"}`))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the phone's refresh token to be refused, got %d", rr.Code)
+	}
+	if rr = do("DELETE", "/me/sessions/"+phone["sessionId"].(string), laptopToken); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a revoked session, got %d", rr.Code)
+	}
+
+	// Every other session is signed out, the current one is kept
+	tablet := login("Tablet")
+	if rr = do("DELETE", "/me/sessions", laptopToken); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 OK, got %d", rr.Code)
+	}
+	if rr = do("GET", "/me", tablet["token"].(string)); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the tablet's token to be refused, got %d", rr.Code)
+	}
+	if rr = do("GET", "/me", laptopToken); rr.Code != http.StatusOK {
+		t.Errorf("Expected the current session to be kept, got %d", rr.Code)
+	}
 }
 
 func TestLoginRouteFailures(t *testing.T) {
 	repo := repository.SetupTest(t)
-	userService := service.NewUserService(repo)
-	handler := handlers.NewHandler(userService)
-	router := NewRouter(handler)
+	auth.SetupTestKeys(t)
+	userService := service.NewUserService(repo, nil, nil, service.DefaultAccountDeletionGracePeriod, nil)
+	handler := handlers.NewHandler(userService, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	router := NewRouter(handler, nil, nil, nil)
 
 	username := "failing_user"
 	password := "ValidPasswd123!"
@@ -275,9 +413,10 @@ func TestLoginRouteFailures(t *testing.T) {
 
 func TestUpdateMeRoute_Username(t *testing.T) {
 	repo := repository.SetupTest(t)
-	userService := service.NewUserService(repo)
-	handler := handlers.NewHandler(userService)
-	router := NewRouter(handler)
+	auth.SetupTestKeys(t)
+	userService := service.NewUserService(repo, nil, nil, service.DefaultAccountDeletionGracePeriod, nil)
+	handler := handlers.NewHandler(userService, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	router := NewRouter(handler, nil, nil, nil)
 
 	username := "testuser_update"
 	password := "ValidPasswd123!"
@@ -289,7 +428,7 @@ func TestUpdateMeRoute_Username(t *testing.T) {
 	defer repository.CleanUpUser(t, user.ID, repo)
 
 	// Authenticate user
-	token, err := auth.GenerateToken(user)
+	token, err := auth.GenerateToken(user, uuid.New())
 	if err != nil {
 		t.Fatalf("Failed to generate token: %v", err)
 	}
@@ -321,9 +460,10 @@ func TestUpdateMeRoute_Username(t *testing.T) {
 
 func TestUpdateMeRoute_Password(t *testing.T) {
 	repo := repository.SetupTest(t)
-	userService := service.NewUserService(repo)
-	handler := handlers.NewHandler(userService)
-	router := NewRouter(handler)
+	auth.SetupTestKeys(t)
+	userService := service.NewUserService(repo, repository.NewSessionRepository(database.DB), nil, service.DefaultAccountDeletionGracePeriod, nil)
+	handler := handlers.NewHandler(userService, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	router := NewRouter(handler, nil, nil, nil)
 
 	username := "testuser_update_pwd"
 	oldPassword := "ValidPasswd123!"
@@ -335,7 +475,7 @@ func TestUpdateMeRoute_Password(t *testing.T) {
 	defer repository.CleanUpUser(t, user.ID, repo)
 
 	// Authenticate user
-	token, err := auth.GenerateToken(user)
+	token, err := auth.GenerateToken(user, uuid.New())
 	if err != nil {
 		t.Fatalf("Failed to generate token: %v", err)
 	}
@@ -374,3 +514,91 @@ func TestUpdateMeRoute_Password(t *testing.T) {
 		t.Errorf("Authenticated user ID mismatch, expected %s, got %s", user.ID, loggedInUser.ID)
 	}
 }
+
+func TestGetUserProfileRoute(t *testing.T) {
+	repo := repository.SetupTest(t)
+	auth.SetupTestKeys(t)
+	userService := service.NewUserService(repo, nil, nil, service.DefaultAccountDeletionGracePeriod, nil)
+	handler := handlers.NewHandler(userService, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	router := NewRouter(handler, nil, nil, nil)
+
+	viewer, err := userService.RegisterUser(context.Background(), "testuser_profile_viewer", "ValidPasswd123!")
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	defer repository.CleanUpUser(t, viewer.ID, repo)
+
+	target, err := userService.RegisterUser(context.Background(), "testuser_profile_target", "ValidPasswd123!")
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	defer repository.CleanUpUser(t, target.ID, repo)
+
+	token, err := auth.GenerateToken(viewer, uuid.New())
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/users/"+target.ID.String(), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 OK, got %d", rr.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response["username"] != target.Username {
+		t.Errorf("Expected username %s, got %v", target.Username, response["username"])
+	}
+
+	for key := range response {
+		if strings.Contains(strings.ToLower(key), "password") {
+			t.Errorf("Public profile must not expose %s", key)
+		}
+	}
+}
+
+func TestSearchUsersRoute(t *testing.T) {
+	repo := repository.SetupTest(t)
+	auth.SetupTestKeys(t)
+	userService := service.NewUserService(repo, nil, nil, service.DefaultAccountDeletionGracePeriod, nil)
+	handler := handlers.NewHandler(userService, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	router := NewRouter(handler, nil, nil, nil)
+
+	user, err := userService.RegisterUser(context.Background(), "testuser_search_Needle", "ValidPasswd123!")
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	defer repository.CleanUpUser(t, user.ID, repo)
+
+	token, err := auth.GenerateToken(user, uuid.New())
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/users/search?q=TESTUSER_SEARCH_n", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 OK, got %d", rr.Code)
+	}
+
+	var response struct {
+		Users []map[string]interface{} `json:"users"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(response.Users) != 1 || response.Users[0]["id"] != user.ID.String() {
+		t.Errorf("Expected to find user %s, got %v", user.ID, response.Users)
+	}
+}
@@ -0,0 +1,402 @@
+package markdown
+
+import (
+	"errors"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Supported subset: **bold**, *italic* / _italic_, `code`, fenced code blocks,
+// [links](https://example.com) and flat ordered or unordered lists. A backslash
+// escapes any ASCII punctuation character outside code.
+// Anything else is rendered as escaped text, so the output never contains
+// markup that was not produced by this package.
+
+type EntityType string
+
+const (
+	EntityBold	EntityType = "bold"
+	EntityItalic	EntityType = "italic"
+	EntityCode	EntityType = "code"
+	EntityCodeBlock	EntityType = "code_block"
+	EntityLink	EntityType = "link"
+	EntityListItem	EntityType = "list_item"
+)
+
+// Formatted span of the raw text.
+// Offset and Length are counted in Unicode code points and cover the markup characters.
+type Entity struct {
+	Type		EntityType	`json:"type"`
+	Offset		int		`json:"offset"`
+	Length		int		`json:"length"`
+	URL		string		`json:"url,omitempty"`
+	Language	string		`json:"language,omitempty"`
+}
+
+// Result of rendering a message
+type Document struct {
+	HTML		string
+	Entities	[]Entity
+}
+
+var ErrUnsafeLink = errors.New("links must use the http, https or mailto scheme")
+
+// Emphasis nested deeper than this is rendered as plain text
+const maxInlineDepth = 8
+
+var (
+	allowedSchemes	= map[string]bool{"http": true, "https": true, "mailto": true}
+	listItemRegex	= regexp.MustCompile(`^\s*([-*+]|\d{1,9}[.)])\s+`)
+	languageRegex	= regexp.MustCompile(`^[A-Za-z0-9_+-]{1,32}$`)
+)
+
+// Parses the source text and returns its sanitized HTML rendering with the entity list
+func Render(src string) (*Document, error) {
+	r := &renderer{}
+	if err := r.renderBlocks([]rune(src)); err != nil {
+		return nil, err
+	}
+
+	return &Document {
+		HTML:		r.out.String(),
+		Entities:	r.entities,
+	}, nil
+}
+
+type renderer struct {
+	out		strings.Builder
+	entities	[]Entity
+	// Set while rendering a link label, links cannot nest
+	inLink		bool
+}
+
+type line struct {
+	text	[]rune
+	offset	int
+}
+
+func splitLines(src []rune) []line {
+	lines := []line{}
+	start := 0
+	for i, c := range src {
+		if c == '\n' {
+			lines = append(lines, line{text: src[start:i], offset: start})
+			start = i + 1
+		}
+	}
+	return append(lines, line{text: src[start:], offset: start})
+}
+
+func (r *renderer) renderBlocks(src []rune) error {
+	lines := splitLines(src)
+	paragraph := []line{}
+	listTag := ""
+
+	flushParagraph := func() error {
+		if len(paragraph) == 0 {
+			return nil
+		}
+		r.out.WriteString("<p>")
+		for i, l := range paragraph {
+			if i > 0 {
+				r.out.WriteString("<br>")
+			}
+			if err := r.renderInline(l.text, l.offset, 0); err != nil {
+				return err
+			}
+		}
+		r.out.WriteString("</p>")
+		paragraph = paragraph[:0]
+		return nil
+	}
+
+	closeList := func() {
+		if listTag != "" {
+			r.out.WriteString("</" + listTag + ">")
+			listTag = ""
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		trimmed := strings.TrimSpace(string(l.text))
+
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			if err := flushParagraph(); err != nil {
+				return err
+			}
+			closeList()
+			i = r.renderCodeBlock(lines, i)
+
+		case listItemRegex.MatchString(string(l.text)):
+			if err := flushParagraph(); err != nil {
+				return err
+			}
+			marker := listItemRegex.FindStringSubmatch(string(l.text))
+			tag := "ul"
+			if unicode.IsDigit([]rune(marker[1])[0]) {
+				tag = "ol"
+			}
+			if tag != listTag {
+				closeList()
+				r.out.WriteString("<" + tag + ">")
+				listTag = tag
+			}
+
+			prefixLen := len([]rune(marker[0]))
+			r.entities = append(r.entities, Entity{Type: EntityListItem, Offset: l.offset, Length: len(l.text)})
+			r.out.WriteString("<li>")
+			if err := r.renderInline(l.text[prefixLen:], l.offset+prefixLen, 0); err != nil {
+				return err
+			}
+			r.out.WriteString("</li>")
+
+		case trimmed == "":
+			if err := flushParagraph(); err != nil {
+				return err
+			}
+			closeList()
+
+		default:
+			closeList()
+			paragraph = append(paragraph, l)
+		}
+	}
+
+	if err := flushParagraph(); err != nil {
+		return err
+	}
+	closeList()
+	return nil
+}
+
+// Renders the fenced block opened at lines[start] and returns the index of its closing fence.
+// An unterminated fence extends to the end of the text.
+func (r *renderer) renderCodeBlock(lines []line, start int) int {
+	language := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(lines[start].text)), "```"))
+	if !languageRegex.MatchString(language) {
+		language = ""
+	}
+
+	end := len(lines) - 1
+	closed := false
+	for j := start + 1; j < len(lines); j++ {
+		if strings.TrimSpace(string(lines[j].text)) == "```" {
+			end = j
+			closed = true
+			break
+		}
+	}
+
+	bodyEnd := end
+	if !closed {
+		bodyEnd = len(lines)
+	}
+	body := []string{}
+	for j := start + 1; j < bodyEnd; j++ {
+		body = append(body, string(lines[j].text))
+	}
+
+	if language != "" {
+		r.out.WriteString(`<pre><code class="language-` + language + `">`)
+	} else {
+		r.out.WriteString("<pre><code>")
+	}
+	r.out.WriteString(html.EscapeString(strings.Join(body, "\n")))
+	r.out.WriteString("</code></pre>")
+
+	blockEnd := lines[end].offset + len(lines[end].text)
+	r.entities = append(r.entities, Entity {
+		Type:		EntityCodeBlock,
+		Offset:		lines[start].offset,
+		Length:		blockEnd - lines[start].offset,
+		Language:	language,
+	})
+
+	return end
+}
+
+func isWordRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// ASCII punctuation, which a backslash escapes as in CommonMark
+func isEscapable(c rune) bool {
+	return c <= unicode.MaxASCII && (unicode.IsPunct(c) || unicode.IsSymbol(c))
+}
+
+// Whether text[i] starts a backslash escape
+func isEscape(text []rune, i int) bool {
+	return text[i] == '\\' && i+1 < len(text) && isEscapable(text[i+1])
+}
+
+// Returns the index of the next occurrence of delim in text at or after from, or -1.
+// Backslash escapes are taken literally, as in code spans.
+func indexFrom(text []rune, delim string, from int) int {
+	return index(text, delim, from, false)
+}
+
+// Same as indexFrom, skipping escaped characters
+func indexUnescaped(text []rune, delim string, from int) int {
+	return index(text, delim, from, true)
+}
+
+func index(text []rune, delim string, from int, skipEscapes bool) int {
+	d := []rune(delim)
+	for i := from; i+len(d) <= len(text); i++ {
+		if skipEscapes && isEscape(text, i) {
+			i++
+			continue
+		}
+		match := true
+		for k := range d {
+			if text[i+k] != d[k] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *renderer) renderInline(text []rune, offset int, depth int) error {
+	plain := strings.Builder{}
+	flush := func() {
+		r.out.WriteString(html.EscapeString(plain.String()))
+		plain.Reset()
+	}
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		// Backslash escapes the next ASCII punctuation character
+		if isEscape(text, i) {
+			plain.WriteRune(text[i+1])
+			i++
+			continue
+		}
+
+		switch {
+		case c == '`':
+			end := indexFrom(text, "`", i+1)
+			if end <= i+1 {
+				break
+			}
+			flush()
+			r.entities = append(r.entities, Entity{Type: EntityCode, Offset: offset + i, Length: end - i + 1})
+			r.out.WriteString("<code>" + html.EscapeString(string(text[i+1:end])) + "</code>")
+			i = end
+			continue
+
+		case c == '*' && i+1 < len(text) && text[i+1] == '*' && depth < maxInlineDepth:
+			end := indexUnescaped(text, "**", i+2)
+			if end <= i+2 {
+				break
+			}
+			flush()
+			r.entities = append(r.entities, Entity{Type: EntityBold, Offset: offset + i, Length: end - i + 2})
+			r.out.WriteString("<strong>")
+			if err := r.renderInline(text[i+2:end], offset+i+2, depth+1); err != nil {
+				return err
+			}
+			r.out.WriteString("</strong>")
+			i = end + 1
+			continue
+
+		case (c == '*' || c == '_') && depth < maxInlineDepth:
+			// Underscores inside words (snake_case) are not emphasis
+			if c == '_' && i > 0 && isWordRune(text[i-1]) {
+				break
+			}
+			end := r.findEmphasisEnd(text, c, i+1)
+			if end <= i+1 {
+				break
+			}
+			flush()
+			r.entities = append(r.entities, Entity{Type: EntityItalic, Offset: offset + i, Length: end - i + 1})
+			r.out.WriteString("<em>")
+			if err := r.renderInline(text[i+1:end], offset+i+1, depth+1); err != nil {
+				return err
+			}
+			r.out.WriteString("</em>")
+			i = end
+			continue
+
+		case c == '[' && depth < maxInlineDepth && !r.inLink:
+			labelEnd := indexUnescaped(text, "]", i+1)
+			if labelEnd <= i+1 || labelEnd+1 >= len(text) || text[labelEnd+1] != '(' {
+				break
+			}
+			urlEnd := indexUnescaped(text, ")", labelEnd+2)
+			if urlEnd <= labelEnd+2 {
+				break
+			}
+			href, err := sanitizeURL(string(text[labelEnd+2 : urlEnd]))
+			if err != nil {
+				return err
+			}
+			flush()
+			r.entities = append(r.entities, Entity{Type: EntityLink, Offset: offset + i, Length: urlEnd - i + 1, URL: href})
+			r.out.WriteString(`<a href="` + html.EscapeString(href) + `" rel="nofollow noopener noreferrer" target="_blank">`)
+			// Links in the label are rendered as text
+			r.inLink = true
+			err = r.renderInline(text[i+1:labelEnd], offset+i+1, depth+1)
+			r.inLink = false
+			if err != nil {
+				return err
+			}
+			r.out.WriteString("</a>")
+			i = urlEnd
+			continue
+		}
+
+		plain.WriteRune(c)
+	}
+
+	flush()
+	return nil
+}
+
+// Finds the closing single delimiter, skipping "**" pairs which belong to bold spans
+func (r *renderer) findEmphasisEnd(text []rune, delim rune, from int) int {
+	for j := from; j < len(text); j++ {
+		if isEscape(text, j) {
+			j++
+			continue
+		}
+		if text[j] != delim {
+			continue
+		}
+		if delim == '*' && j+1 < len(text) && text[j+1] == '*' {
+			j++
+			continue
+		}
+		if delim == '_' && j+1 < len(text) && isWordRune(text[j+1]) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// Only absolute http(s) and mailto URLs are allowed as link targets
+func sanitizeURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+
+	parsed, err := url.Parse(raw)
+	if err != nil || !allowedSchemes[parsed.Scheme] {
+		return "", ErrUnsafeLink
+	}
+	if parsed.Scheme != "mailto" && parsed.Host == "" {
+		return "", ErrUnsafeLink
+	}
+
+	return parsed.String(), nil
+}
@@ -0,0 +1,150 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name	string
+		src	string
+		want	string
+	}{
+		{
+			name:	"Plain text is escaped",
+			src:	`<script>alert("x")</script>`,
+			want:	`<p>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;</p>`,
+		},
+		{
+			name:	"Bold and italic",
+			src:	"**bold** and *italic* and _also_",
+			want:	"<p><strong>bold</strong> and <em>italic</em> and <em>also</em></p>",
+		},
+		{
+			name:	"Nested emphasis",
+			src:	"**bold *and italic* text**",
+			want:	"<p><strong>bold <em>and italic</em> text</strong></p>",
+		},
+		{
+			name:	"Snake case is not emphasis",
+			src:	"some_variable_name",
+			want:	"<p>some_variable_name</p>",
+		},
+		{
+			name:	"Code span is not parsed",
+			src:	"run `**x** <b>`",
+			want:	"<p>run <code>**x** &lt;b&gt;</code></p>",
+		},
+		{
+			name:	"Fenced code block",
+			src:	"```go\nfmt.Println(\"<hi>\")\n```",
+			want:	`<pre><code class="language-go">fmt.Println(&#34;&lt;hi&gt;&#34;)</code></pre>`,
+		},
+		{
+			name:	"Invalid code block language is dropped",
+			src:	"```\"><script>\ncode\n```",
+			want:	"<pre><code>code</code></pre>",
+		},
+		{
+			name:	"Link",
+			src:	"[docs](https://example.com/a?b=1&c=2)",
+			want:	`<p><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener noreferrer" target="_blank">docs</a></p>`,
+		},
+		{
+			name:	"Lists",
+			src:	"- one\n- two\n1. first",
+			want:	"<ul><li>one</li><li>two</li></ul><ol><li>first</li></ol>",
+		},
+		{
+			name:	"Paragraphs and line breaks",
+			src:	"a\nb\n\nc",
+			want:	"<p>a<br>b</p><p>c</p>",
+		},
+		{
+			name:	"Escaped delimiter",
+			src:	`\*not italic\*`,
+			want:	"<p>*not italic*</p>",
+		},
+		{
+			name:	"Escaped backtick",
+			src:	"\\`not code\\`",
+			want:	"<p>`not code`</p>",
+		},
+		{
+			name:	"Escaped closing delimiter",
+			src:	`**a\*\*b** \~ \+`,
+			want:	"<p><strong>a**b</strong> ~ +</p>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Render(tt.src)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if doc.HTML != tt.want {
+				t.Errorf("Expected HTML %q, got %q", tt.want, doc.HTML)
+			}
+		})
+	}
+}
+
+func TestRender_UnsafeLinks(t *testing.T) {
+	unsafe := []string{
+		"[x](javascript:alert(1))",
+		"[x](JavaScript:alert(1))",
+		"[x](data:text/html;base64,PHNjcmlwdD4=)",
+		"[x](/relative/path)",
+	}
+
+	for _, src := range unsafe {
+		if _, err := Render(src); err != ErrUnsafeLink {
+			t.Errorf("Expected ErrUnsafeLink for %q, got %v", src, err)
+		}
+	}
+}
+
+func TestRender_LinksDoNotNest(t *testing.T) {
+	for _, src := range []string{
+		"[[a](https://a.io)](https://b.io)",
+		"[a \\] [b](https://a.io)](https://b.io)",
+	} {
+		doc, err := Render(src)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", src, err)
+		}
+		if n := strings.Count(doc.HTML, "<a "); n > 1 {
+			t.Errorf("Expected at most one link for %q, got %q", src, doc.HTML)
+		}
+	}
+}
+
+func TestRender_Entities(t *testing.T) {
+	doc, err := Render("hé **bold** [l](https://a.io)")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(doc.Entities) != 2 {
+		t.Fatalf("Expected 2 entities, got %d: %v", len(doc.Entities), doc.Entities)
+	}
+
+	bold := doc.Entities[0]
+	if bold.Type != EntityBold || bold.Offset != 3 || bold.Length != 8 {
+		t.Errorf("Unexpected bold entity: %+v", bold)
+	}
+
+	link := doc.Entities[1]
+	if link.Type != EntityLink || link.Offset != 12 || link.URL != "https://a.io" {
+		t.Errorf("Unexpected link entity: %+v", link)
+	}
+}
+
+func TestRender_DeepNestingIsBounded(t *testing.T) {
+	src := strings.Repeat("[", 10000) + strings.Repeat("*", 10000)
+	if _, err := Render(src); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
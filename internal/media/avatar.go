@@ -0,0 +1,60 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+
+	// Registers the accepted upload formats
+	_ "image/gif"
+	_ "image/jpeg"
+
+	"golang.org/x/image/draw"
+)
+
+// Side of the square avatars stored, in pixels
+const AvatarSize = 256
+
+// Uploads larger than this, in pixels, are refused before being decoded
+const maxSourcePixels = 40_000_000
+
+var ErrUnsupportedImage = errors.New("avatar must be a PNG, JPEG or GIF image")
+
+// Decodes an uploaded image, crops it to a centered square and
+// resizes it to AvatarSize, returning the result PNG encoded.
+// Re-encoding also strips any metadata the upload carried.
+func ProcessAvatar(r io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	tee := io.TeeReader(r, &buf)
+
+	config, _, err := image.DecodeConfig(tee)
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxSourcePixels {
+		return nil, ErrUnsupportedImage
+	}
+
+	src, _, err := image.Decode(io.MultiReader(&buf, r))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Point {
+		X:	bounds.Min.X + (bounds.Dx()-side)/2,
+		Y:	bounds.Min.Y + (bounds.Dy()-side)/2,
+	})
+
+	dst := image.NewRGBA(image.Rect(0, 0, AvatarSize, AvatarSize))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, dst); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,45 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestProcessAvatar(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 800, 400))
+	for x := 0; x < 800; x++ {
+		for y := 0; y < 400; y++ {
+			src.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+
+	var upload bytes.Buffer
+	if err := jpeg.Encode(&upload, src, nil); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	out, err := ProcessAvatar(&upload)
+	if err != nil {
+		t.Fatalf("ProcessAvatar failed: %v", err)
+	}
+
+	avatar, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Expected a PNG avatar: %v", err)
+	}
+
+	if avatar.Bounds().Dx() != AvatarSize || avatar.Bounds().Dy() != AvatarSize {
+		t.Errorf("Expected a %dx%d avatar, got %v", AvatarSize, AvatarSize, avatar.Bounds())
+	}
+}
+
+func TestProcessAvatar_NotAnImage(t *testing.T) {
+	if _, err := ProcessAvatar(strings.NewReader("<svg onload=alert(1)>")); err != ErrUnsupportedImage {
+		t.Errorf("Expected ErrUnsupportedImage, got %v", err)
+	}
+}
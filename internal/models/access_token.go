@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Long-lived token for bots and scripts, restricted to a set of scopes
+type AccessToken struct {
+	ID		uuid.UUID	`db:"id"`
+	UserID		uuid.UUID	`db:"user_id"`
+	Name		string		`db:"name"`
+	TokenHash	string		`db:"token_hash"`
+	Scopes		[]string	`db:"scopes"`
+	CreatedAt	time.Time	`db:"created_at"`
+	// Nil when the token never expires
+	ExpiresAt	*time.Time	`db:"expires_at"`
+	LastUsedAt	*time.Time	`db:"last_used_at"`
+}
+
+func (t *AccessToken) IsExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Block placed by a user, only ever visible to the blocker
+type Block struct {
+	BlockerID	uuid.UUID	`db:"blocker_id"`
+	BlockedID	uuid.UUID	`db:"blocked_id"`
+	// Username of the blocked user, filled when listing
+	BlockedUsername	string		`db:"username"`
+	HideMessages	bool		`db:"hide_messages"`
+	CreatedAt	time.Time	`db:"created_at"`
+}
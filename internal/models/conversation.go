@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Conversation struct {
+	ID		uuid.UUID	`db:"id"`
+	IsPublic	bool		`db:"is_public"`
+	// Private conversation between exactly two users
+	IsDirect	bool		`db:"is_direct"`
+	Name		*string		`db:"name"`
+	// Set by admins with /topic
+	Topic		*string		`db:"topic"`
+	// Minimum delay between two messages of a regular member, 0 disables slow mode
+	SlowModeSeconds	int		`db:"slow_mode_seconds"`
+	CreatedAt	time.Time	`db:"created_at"`
+}
+
+// Conversation as listed for one of its members
+type UserConversation struct {
+	Conversation
+	Role		MemberRole
+	// Other member of a direct conversation, empty otherwise
+	PeerUsername	string
+}
+
+// Conversation as listed for server administrators
+type ConversationOverview struct {
+	Conversation
+	MemberCount	int
+	MessageCount	int
+	// Nil when nothing was posted yet
+	LastMessageAt	*time.Time
+}
+
+// Role of a user within a conversation
+type MemberRole string
+
+const (
+	RoleOwner	MemberRole = "owner"
+	RoleAdmin	MemberRole = "admin"
+	RoleMember	MemberRole = "member"
+)
+
+// Owners and admins moderate the conversation
+func (r MemberRole) IsAdmin() bool {
+	return r == RoleOwner || r == RoleAdmin
+}
+
+// Named content filter along with its filter-specific settings
+type ContentFilterConfig struct {
+	Name	string		`db:"name" json:"name"`
+	Config	json.RawMessage	`db:"config" json:"config,omitempty"`
+}
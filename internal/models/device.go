@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Device taking part in end-to-end encryption, along with its public keys.
+// Keys are base64-encoded and never interpreted by the server.
+type Device struct {
+	ID			uuid.UUID	`db:"id"`
+	UserID			uuid.UUID	`db:"user_id"`
+	IdentityKey		string		`db:"identity_key"`
+	SignedPreKeyID		int		`db:"signed_prekey_id"`
+	SignedPreKey		string		`db:"signed_prekey"`
+	SignedPreKeySignature	string		`db:"signed_prekey_signature"`
+	CreatedAt		time.Time	`db:"created_at"`
+	UpdatedAt		time.Time	`db:"updated_at"`
+	// Number of one-time prekeys left, only filled when listing the owner's devices
+	OneTimePreKeyCount	int		`db:"-"`
+}
+
+type PreKey struct {
+	KeyID		int	`db:"key_id"`
+	PublicKey	string	`db:"public_key"`
+}
+
+// Keys another user needs to start an encrypted session with a device
+type PreKeyBundle struct {
+	Device		*Device
+	// Nil once the device ran out of one-time prekeys
+	OneTimePreKey	*PreKey
+}
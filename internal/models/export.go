@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Progress of a personal data export
+type ExportStatus string
+
+const (
+	ExportPending	ExportStatus = "pending"
+	ExportRunning	ExportStatus = "running"
+	ExportCompleted	ExportStatus = "completed"
+	ExportFailed	ExportStatus = "failed"
+)
+
+// Asynchronous export of everything stored about a user
+type DataExport struct {
+	ID		uuid.UUID	`db:"id"`
+	UserID		uuid.UUID	`db:"user_id"`
+	Status		ExportStatus	`db:"status"`
+	// Storage key of the ZIP archive, empty until completed
+	ObjectKey	string		`db:"object_key"`
+	CreatedAt	time.Time	`db:"created_at"`
+	StartedAt	*time.Time	`db:"started_at"`
+	CompletedAt	*time.Time	`db:"completed_at"`
+	// Set once completed, the archive is purged afterwards
+	ExpiresAt	*time.Time	`db:"expires_at"`
+}
+
+// Conversation the user belongs to, along with their role in it
+type Membership struct {
+	ConversationID		uuid.UUID	`db:"conversation_id"`
+	ConversationName	*string		`db:"name"`
+	IsDirect		bool		`db:"is_direct"`
+	Role			MemberRole	`db:"role"`
+	JoinedAt		time.Time	`db:"joined_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How message content is meant to be displayed
+type ContentType string
+
+const (
+	ContentTypeText		ContentType = "text"
+	// Sent with /me, displayed after the sender's name
+	ContentTypeAction	ContentType = "action"
+	// End-to-end encrypted ciphertext, stored and relayed without being inspected
+	ContentTypeEncrypted	ContentType = "encrypted"
+)
+
+type Message struct {
+	ID		uuid.UUID	`db:"id"`
+	ConversationID	uuid.UUID	`db:"conversation_id"`
+	SenderID	uuid.UUID	`db:"sender_id"`
+	Content		string		`db:"content"`
+	ContentType	ContentType	`db:"content_type"`
+	MentionIDs	[]uuid.UUID	`db:"mention_ids"`
+	CreatedAt	time.Time	`db:"created_at"`
+	// Nil unless the message was sent with a TTL, and until it is read
+	// when the TTL counts from the first read
+	ExpiresAt	*time.Time	`db:"expires_at"`
+	// Nil unless the message was sent with a TTL
+	TTLSeconds	*int		`db:"ttl_seconds"`
+}
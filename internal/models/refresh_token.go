@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Single-use token exchanged for a new access token and its successor
+type RefreshToken struct {
+	ID		uuid.UUID	`db:"id"`
+	// Shared by every token rotated out of the same login
+	FamilyID	uuid.UUID	`db:"family_id"`
+	UserID		uuid.UUID	`db:"user_id"`
+	TokenHash	string		`db:"token_hash"`
+	CreatedAt	time.Time	`db:"created_at"`
+	ExpiresAt	time.Time	`db:"expires_at"`
+	// Set once the token has been rotated
+	UsedAt		*time.Time	`db:"used_at"`
+	RevokedAt	*time.Time	`db:"revoked_at"`
+}
+
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JWT revoked before its expiry, on logout
+type RevokedToken struct {
+	// jti claim of the token
+	JTI		string		`db:"jti"`
+	UserID		uuid.UUID	`db:"user_id"`
+	ExpiresAt	time.Time	`db:"expires_at"`
+	RevokedAt	time.Time	`db:"revoked_at"`
+}
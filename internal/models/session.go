@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Login of a user on a device, kept alive by refreshing its tokens.
+// Its ID is the family ID of the refresh tokens and the sid claim of the JWTs.
+type Session struct {
+	ID		uuid.UUID	`db:"id"`
+	UserID		uuid.UUID	`db:"user_id"`
+	DeviceName	string		`db:"device_name"`
+	UserAgent	string		`db:"user_agent"`
+	// Address of the last login or refresh
+	IPAddress	string		`db:"ip_address"`
+	CreatedAt	time.Time	`db:"created_at"`
+	LastSeenAt	time.Time	`db:"last_seen_at"`
+	ExpiresAt	time.Time	`db:"expires_at"`
+	RevokedAt	*time.Time	`db:"revoked_at"`
+}
@@ -6,10 +6,87 @@ import (
 	"github.com/google/uuid"
 )
 
+// Display name shown in place of anonymized accounts
+const DeletedUserDisplayName = "Deleted user"
+
 type User struct {
 	ID		uuid.UUID 	`db:"id"`
 	Username	string		`db:"username"`
 	Password	string		`db:"password_hash"`
+	DisplayName	string		`db:"display_name"`
+	Bio		string		`db:"bio"`
+	Pronouns	string		`db:"pronouns"`
+	Timezone	string		`db:"timezone"`
+	AvatarKey	string		`db:"avatar_key"`
+	// Server administrator, manages server-wide settings
+	IsAdmin		bool		`db:"is_admin"`
+	// Identity of an incoming webhook, cannot log in
+	IsBot		bool		`db:"is_bot"`
 	CreatedAt	time.Time	`db:"created_at"`
 	UpdatedAt	time.Time	`db:"updated_at"`	
+	// Set while the account is pending deletion
+	DeletionScheduledAt	*time.Time	`db:"deletion_scheduled_at"`
+	// Set once the account has been anonymized
+	DeletedAt	*time.Time	`db:"deleted_at"`
+	// Set by server administrators, disabled accounts cannot log in
+	DisabledAt	*time.Time	`db:"disabled_at"`
+	// Sessions opened before this instant are refused, set when the password changes
+	TokensValidAfter	*time.Time	`db:"tokens_valid_after"`
+}
+
+// Profile columns changed by an update, nil ones are left as they are
+type UserUpdate struct {
+	Username	*string
+	DisplayName	*string
+	Bio		*string
+	Pronouns	*string
+	Timezone	*string
+	UpdatedAt	time.Time
+}
+
+// User data safe to expose to other users
+type PublicUser struct {
+	ID		uuid.UUID	`json:"id"`
+	Username	string		`json:"username"`
+	DisplayName	string		`json:"displayName"`
+	Bio		string		`json:"bio"`
+	Pronouns	string		`json:"pronouns"`
+	Timezone	string		`json:"timezone"`
+	AvatarURL	string		`json:"avatarUrl,omitempty"`
+	Bot		bool		`json:"bot,omitempty"`
+	Deleted		bool		`json:"deleted,omitempty"`
+	CreatedAt	time.Time	`json:"createdAt"`
+}
+
+// Path serving the user's avatar, empty when none was uploaded
+func (u *User) AvatarURL() string {
+	if u.AvatarKey == "" {
+		return ""
+	}
+	return "/users/" + u.ID.String() + "/avatar"
+}
+
+func (u *User) Public() PublicUser {
+	return PublicUser {
+		ID:		u.ID,
+		Username:	u.Username,
+		DisplayName:	u.DisplayName,
+		Bio:		u.Bio,
+		Pronouns:	u.Pronouns,
+		Timezone:	u.Timezone,
+		AvatarURL:	u.AvatarURL(),
+		Bot:		u.IsBot,
+		Deleted:	u.IsDeleted(),
+		CreatedAt:	u.CreatedAt,
+	}
+}
+
+// Anonymized accounts only remain as the sender of their messages
+func (u *User) IsDeleted() bool {
+	return u.DeletedAt != nil
+}
+
+// Disabled accounts keep their data but cannot authenticate anymore
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
 }
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Events webhooks can subscribe to
+const (
+	WebhookMessageCreated	= "message.created"
+	WebhookMemberJoined	= "member.joined"
+	WebhookUserRegistered	= "user.registered"
+)
+
+// Subscription forwarding events to an HTTP endpoint
+type Webhook struct {
+	ID		uuid.UUID	`db:"id"`
+	OwnerID		uuid.UUID	`db:"owner_id"`
+	// Nil for server-wide webhooks
+	ConversationID	*uuid.UUID	`db:"conversation_id"`
+	URL		string		`db:"url"`
+	Secret		string		`db:"secret"`
+	Events		[]string	`db:"events"`
+	CreatedAt	time.Time	`db:"created_at"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending		DeliveryStatus = "pending"
+	DeliverySucceeded	DeliveryStatus = "succeeded"
+	DeliveryFailed		DeliveryStatus = "failed"
+)
+
+// One event to deliver to a webhook, along with the outcome of its attempts
+type WebhookDelivery struct {
+	ID		uuid.UUID	`db:"id"`
+	WebhookID	uuid.UUID	`db:"webhook_id"`
+	Event		string		`db:"event"`
+	Payload		json.RawMessage	`db:"payload"`
+	Status		DeliveryStatus	`db:"status"`
+	Attempts	int		`db:"attempts"`
+	NextAttemptAt	time.Time	`db:"next_attempt_at"`
+	LastStatusCode	*int		`db:"last_status_code"`
+	LastError	string		`db:"last_error"`
+	CreatedAt	time.Time	`db:"created_at"`
+	DeliveredAt	*time.Time	`db:"delivered_at"`
+	// Expiry of the disappearing message in the payload, if any
+	ExpiresAt	*time.Time	`db:"expires_at"`
+}
+
+// Secret URL through which external tools post into a conversation
+type IncomingWebhook struct {
+	ID		uuid.UUID	`db:"id"`
+	ConversationID	uuid.UUID	`db:"conversation_id"`
+	CreatorID	uuid.UUID	`db:"creator_id"`
+	BotUserID	uuid.UUID	`db:"bot_user_id"`
+	TokenHash	string		`db:"token_hash"`
+	CreatedAt	time.Time	`db:"created_at"`
+	LastUsedAt	*time.Time	`db:"last_used_at"`
+	// Display name of the bot user, filled when reading
+	Name		string		`db:"display_name"`
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/google/uuid"
+)
+
+// Contract for any kind of personal access token data access implementation.
+type AccessTokenRepository interface {
+	CreateAccessToken(ctx context.Context, token *models.AccessToken) error
+	GetAccessTokenByHash(ctx context.Context, hash string) (*models.AccessToken, error)
+	GetAccessTokensByUser(ctx context.Context, userID uuid.UUID) ([]*models.AccessToken, error)
+	DeleteAccessToken(ctx context.Context, userID, id uuid.UUID) error
+	TouchAccessToken(ctx context.Context, id uuid.UUID) error
+}
+
+// Concrete implementation of AccessTokenRepository
+type accessTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+// Constructor, returns a new instance of the repository
+func NewAccessTokenRepository(db *pgxpool.Pool) AccessTokenRepository {
+	return &accessTokenRepository{db: db}
+}
+
+const accessTokenColumns = `id, user_id, name, token_hash, scopes, created_at, expires_at, last_used_at`
+
+func scanAccessToken(row pgx.Row) (*models.AccessToken, error) {
+	var token models.AccessToken
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.TokenHash,
+		&token.Scopes,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&token.LastUsedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *accessTokenRepository) CreateAccessToken(ctx context.Context, token *models.AccessToken) error {
+	query := `
+		INSERT INTO personal_access_tokens (id, user_id, name, token_hash, scopes, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		token.ID,
+		token.UserID,
+		token.Name,
+		token.TokenHash,
+		token.Scopes,
+		token.CreatedAt,
+		token.ExpiresAt,
+	)
+
+	return err
+}
+
+func (r *accessTokenRepository) GetAccessTokenByHash(ctx context.Context, hash string) (*models.AccessToken, error) {
+	// Tokens of disabled accounts are not found, they work again once the account is enabled
+	query := `
+		SELECT ` + accessTokenColumns + `
+		FROM personal_access_tokens
+		WHERE token_hash = $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM users u
+			WHERE u.id = personal_access_tokens.user_id AND u.disabled_at IS NOT NULL
+		  )
+	`
+	return scanAccessToken(r.db.QueryRow(ctx, query, hash))
+}
+
+// Retrieves the user's tokens, newest first
+func (r *accessTokenRepository) GetAccessTokensByUser(ctx context.Context, userID uuid.UUID) ([]*models.AccessToken, error) {
+	query := `
+		SELECT ` + accessTokenColumns + `
+		FROM personal_access_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*models.AccessToken{}
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// Deletes one of the user's tokens, which revokes it immediately
+func (r *accessTokenRepository) DeleteAccessToken(ctx context.Context, userID, id uuid.UUID) error {
+	query := `DELETE FROM personal_access_tokens WHERE id = $1 AND user_id = $2`
+	result, err := r.db.Exec(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// Records that the token was used, at most once a minute to spare writes
+func (r *accessTokenRepository) TouchAccessToken(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET last_used_at = now()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')
+	`
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
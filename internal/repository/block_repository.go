@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/google/uuid"
+)
+
+// Contract for any kind of user block data access implementation.
+type BlockRepository interface {
+	CreateBlock(ctx context.Context, block *models.Block) error
+	DeleteBlock(ctx context.Context, blockerID, blockedID uuid.UUID) error
+	GetBlocksByBlocker(ctx context.Context, blockerID uuid.UUID) ([]*models.Block, error)
+	GetBlock(ctx context.Context, blockerID, blockedID uuid.UUID) (*models.Block, error)
+	IsBlockedEitherWay(ctx context.Context, userID, otherID uuid.UUID) (bool, error)
+	IsDirectConversationBlocked(ctx context.Context, conversationID, userID uuid.UUID) (bool, error)
+}
+
+// Concrete implementation of BlockRepository
+type blockRepository struct {
+	db *pgxpool.Pool
+}
+
+// Constructor, returns a new instance of the repository
+func NewBlockRepository(db *pgxpool.Pool) BlockRepository {
+	return &blockRepository{db: db}
+}
+
+// Inserts the block, or updates its options if it already exists
+func (r *blockRepository) CreateBlock(ctx context.Context, block *models.Block) error {
+	query := `
+		INSERT INTO user_blocks (blocker_id, blocked_id, hide_messages, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (blocker_id, blocked_id) DO UPDATE SET hide_messages = EXCLUDED.hide_messages
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		block.BlockerID,
+		block.BlockedID,
+		block.HideMessages,
+		block.CreatedAt,
+	)
+
+	return err
+}
+
+// Removing a block that does not exist is not an error
+func (r *blockRepository) DeleteBlock(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	query := `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`
+	_, err := r.db.Exec(ctx, query, blockerID, blockedID)
+	return err
+}
+
+func (r *blockRepository) GetBlocksByBlocker(ctx context.Context, blockerID uuid.UUID) ([]*models.Block, error) {
+	query := `
+		SELECT b.blocker_id, b.blocked_id, u.username, b.hide_messages, b.created_at
+		FROM user_blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := []*models.Block{}
+	for rows.Next() {
+		var block models.Block
+		err := rows.Scan(
+			&block.BlockerID,
+			&block.BlockedID,
+			&block.BlockedUsername,
+			&block.HideMessages,
+			&block.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, &block)
+	}
+
+	return blocks, rows.Err()
+}
+
+// Returns pgx.ErrNoRows when the blocker did not block that user
+func (r *blockRepository) GetBlock(ctx context.Context, blockerID, blockedID uuid.UUID) (*models.Block, error) {
+	query := `
+		SELECT blocker_id, blocked_id, hide_messages, created_at
+		FROM user_blocks
+		WHERE blocker_id = $1 AND blocked_id = $2
+	`
+
+	var block models.Block
+	err := r.db.QueryRow(ctx, query, blockerID, blockedID).Scan(
+		&block.BlockerID,
+		&block.BlockedID,
+		&block.HideMessages,
+		&block.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &block, nil
+}
+
+func (r *blockRepository) IsBlockedEitherWay(ctx context.Context, userID, otherID uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = $1 AND blocked_id = $2)
+			   OR (blocker_id = $2 AND blocked_id = $1)
+		)
+	`
+
+	var blocked bool
+	if err := r.db.QueryRow(ctx, query, userID, otherID).Scan(&blocked); err != nil {
+		return false, err
+	}
+
+	return blocked, nil
+}
+
+// Whether the conversation is a direct one in which the user and the other
+// member blocked each other, in either direction
+func (r *blockRepository) IsDirectConversationBlocked(ctx context.Context, conversationID, userID uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM conversations c
+			JOIN conversation_members cm ON cm.conversation_id = c.id AND cm.user_id <> $2
+			JOIN user_blocks b
+			  ON (b.blocker_id = cm.user_id AND b.blocked_id = $2)
+			  OR (b.blocker_id = $2 AND b.blocked_id = cm.user_id)
+			WHERE c.id = $1 AND c.is_direct
+		)
+	`
+
+	var blocked bool
+	if err := r.db.QueryRow(ctx, query, conversationID, userID).Scan(&blocked); err != nil {
+		return false, err
+	}
+
+	return blocked, nil
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/database"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestBlockLifecycle(t *testing.T) {
+	userRepo := SetupTest(t)
+	blockRepo := NewBlockRepository(database.DB)
+	ctx := context.Background()
+
+	blocker := NewTestUser(t, "testuser_blocker")
+	blocked := NewTestUser(t, "testuser_blocked")
+	for _, user := range []*models.User{blocker, blocked} {
+		if err := userRepo.CreateUser(ctx, user); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		defer CleanUpUser(t, user.ID, userRepo)
+	}
+
+	block := &models.Block {
+		BlockerID:	blocker.ID,
+		BlockedID:	blocked.ID,
+		HideMessages:	true,
+		CreatedAt:	time.Now(),
+	}
+	if err := blockRepo.CreateBlock(ctx, block); err != nil {
+		t.Fatalf("CreateBlock failed: %v", err)
+	}
+
+	// Blocks are checked in both directions
+	isBlocked, err := blockRepo.IsBlockedEitherWay(ctx, blocked.ID, blocker.ID)
+	if err != nil {
+		t.Fatalf("IsBlockedEitherWay failed: %v", err)
+	}
+	if !isBlocked {
+		t.Error("Expected users to be blocked")
+	}
+
+	// Only the blocker sees the block
+	blocks, err := blockRepo.GetBlocksByBlocker(ctx, blocked.ID)
+	if err != nil {
+		t.Fatalf("GetBlocksByBlocker failed: %v", err)
+	}
+	if len(blocks) != 0 {
+		t.Errorf("Expected the blocked user to see no blocks, got %d", len(blocks))
+	}
+
+	blocks, err = blockRepo.GetBlocksByBlocker(ctx, blocker.ID)
+	if err != nil {
+		t.Fatalf("GetBlocksByBlocker failed: %v", err)
+	}
+	if len(blocks) != 1 || blocks[0].BlockedID != blocked.ID || !blocks[0].HideMessages {
+		t.Errorf("Expected the blocker to see the block hiding %v, got %+v", blocked.ID, blocks)
+	}
+
+	if err := blockRepo.DeleteBlock(ctx, blocker.ID, blocked.ID); err != nil {
+		t.Fatalf("DeleteBlock failed: %v", err)
+	}
+
+	isBlocked, err = blockRepo.IsBlockedEitherWay(ctx, blocker.ID, blocked.ID)
+	if err != nil {
+		t.Fatalf("IsBlockedEitherWay failed: %v", err)
+	}
+	if isBlocked {
+		t.Error("Expected users to no longer be blocked")
+	}
+}
+
+func TestDirectConversationBlocks(t *testing.T) {
+	userRepo := SetupTest(t)
+	blockRepo := NewBlockRepository(database.DB)
+	conversationRepo := NewConversationRepository(database.DB)
+	ctx := context.Background()
+
+	blocker := NewTestUser(t, "testuser_dm_blocker")
+	blocked := NewTestUser(t, "testuser_dm_blocked")
+	for _, user := range []*models.User{blocker, blocked} {
+		if err := userRepo.CreateUser(ctx, user); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		defer CleanUpUser(t, user.ID, userRepo)
+	}
+
+	direct := &models.Conversation{ID: uuid.New(), IsDirect: true, CreatedAt: time.Now().UTC()}
+	if _, err := conversationRepo.CreateDirectConversation(ctx, direct, blocker.ID, blocked.ID); err != nil {
+		t.Fatalf("CreateDirectConversation failed: %v", err)
+	}
+	defer CleanUpConversation(t, direct.ID, conversationRepo)
+
+	// Opening it again returns the same conversation
+	again, err := conversationRepo.CreateDirectConversation(ctx, &models.Conversation{ID: uuid.New(), IsDirect: true, CreatedAt: time.Now().UTC()}, blocked.ID, blocker.ID)
+	if err != nil {
+		t.Fatalf("CreateDirectConversation failed: %v", err)
+	}
+	if again.ID != direct.ID {
+		t.Errorf("Expected the existing conversation %v, got %v", direct.ID, again.ID)
+	}
+
+	if err := blockRepo.CreateBlock(ctx, &models.Block{BlockerID: blocker.ID, BlockedID: blocked.ID, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("CreateBlock failed: %v", err)
+	}
+
+	// Both members are refused, whoever placed the block
+	for _, user := range []*models.User{blocker, blocked} {
+		isBlocked, err := blockRepo.IsDirectConversationBlocked(ctx, direct.ID, user.ID)
+		if err != nil {
+			t.Fatalf("IsDirectConversationBlocked failed: %v", err)
+		}
+		if !isBlocked {
+			t.Errorf("Expected the conversation to be blocked for %s", user.Username)
+		}
+	}
+
+	block, err := blockRepo.GetBlock(ctx, blocker.ID, blocked.ID)
+	if err != nil {
+		t.Fatalf("GetBlock failed: %v", err)
+	}
+	if block.HideMessages {
+		t.Error("Expected the block to keep messages visible")
+	}
+}
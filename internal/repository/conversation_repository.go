@@ -0,0 +1,529 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"context"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/google/uuid"
+)
+
+// Contract for any kind of conversation data access implementation.
+type ConversationRepository interface {
+	CreateConversation(ctx context.Context, conversation *models.Conversation) error
+	GetConversationByID(ctx context.Context, id uuid.UUID) (*models.Conversation, error)
+	DeleteConversation(ctx context.Context, id uuid.UUID) error
+	AddMember(ctx context.Context, conversationID, userID uuid.UUID, role models.MemberRole) error
+	IsMember(ctx context.Context, conversationID, userID uuid.UUID) (bool, error)
+	GetMemberRole(ctx context.Context, conversationID, userID uuid.UUID) (models.MemberRole, error)
+	GetContentFilters(ctx context.Context, conversationID uuid.UUID) ([]models.ContentFilterConfig, error)
+	SetContentFilters(ctx context.Context, conversationID uuid.UUID, filters []models.ContentFilterConfig) error
+	SetSlowMode(ctx context.Context, conversationID uuid.UUID, seconds int) error
+	ClaimPostingSlot(ctx context.Context, conversationID, userID uuid.UUID, interval time.Duration) (time.Duration, error)
+	ReleasePostingSlot(ctx context.Context, conversationID, userID uuid.UUID) error
+	FindDirectConversation(ctx context.Context, userID, otherID uuid.UUID) (*models.Conversation, error)
+	CreateDirectConversation(ctx context.Context, conversation *models.Conversation, userID, otherID uuid.UUID) (*models.Conversation, error)
+	GetMembershipsByUser(ctx context.Context, userID uuid.UUID) ([]*models.Membership, error)
+	GetConversationsByUser(ctx context.Context, userID uuid.UUID) ([]*models.UserConversation, error)
+	GetConversations(ctx context.Context, limit int) ([]*models.ConversationOverview, error)
+	SetTopic(ctx context.Context, conversationID uuid.UUID, topic *string) error
+	GetMemberMutedUntil(ctx context.Context, conversationID, userID uuid.UUID) (*time.Time, error)
+	SetMemberMutedUntil(ctx context.Context, conversationID, userID uuid.UUID, until *time.Time) error
+}
+
+// Concrete implementation of ConversationRepository
+type conversationRepository struct {
+	db *pgxpool.Pool
+}
+
+// Constructor, returns a new instance of the repository
+func NewConversationRepository(db *pgxpool.Pool) ConversationRepository {
+	return &conversationRepository{db: db}
+}
+
+// Insert a new conversation into the database.
+func (r *conversationRepository) CreateConversation(ctx context.Context, conversation *models.Conversation) error {
+	query := `
+		INSERT INTO conversations (id, is_public, is_direct, name, topic, slow_mode_seconds, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		conversation.ID,
+		conversation.IsPublic,
+		conversation.IsDirect,
+		conversation.Name,
+		conversation.Topic,
+		conversation.SlowModeSeconds,
+		conversation.CreatedAt,
+	)
+
+	return err
+}
+
+func (r *conversationRepository) GetConversationByID(ctx context.Context, id uuid.UUID) (*models.Conversation, error) {
+	query := `
+		SELECT id, is_public, is_direct, name, topic, slow_mode_seconds, created_at
+		FROM conversations
+		WHERE id = $1
+	`
+
+	var conversation models.Conversation
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&conversation.ID,
+		&conversation.IsPublic,
+		&conversation.IsDirect,
+		&conversation.Name,
+		&conversation.Topic,
+		&conversation.SlowModeSeconds,
+		&conversation.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &conversation, nil
+}
+
+func (r *conversationRepository) DeleteConversation(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM conversations WHERE id = $1`
+	result, err := r.db.Exec(ctx, query, id)
+
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("no conversation found with id: %s", id)
+	}
+
+	return nil
+}
+
+func (r *conversationRepository) AddMember(ctx context.Context, conversationID, userID uuid.UUID, role models.MemberRole) error {
+	query := `
+		INSERT INTO conversation_members (user_id, conversation_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`
+	_, err := r.db.Exec(ctx, query, userID, conversationID, role)
+	return err
+}
+
+func (r *conversationRepository) IsMember(ctx context.Context, conversationID, userID uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM conversation_members
+			WHERE conversation_id = $1 AND user_id = $2
+		)
+	`
+
+	var isMember bool
+	if err := r.db.QueryRow(ctx, query, conversationID, userID).Scan(&isMember); err != nil {
+		return false, err
+	}
+
+	return isMember, nil
+}
+
+// Returns pgx.ErrNoRows when the user is not a member
+func (r *conversationRepository) GetMemberRole(ctx context.Context, conversationID, userID uuid.UUID) (models.MemberRole, error) {
+	query := `
+		SELECT role FROM conversation_members
+		WHERE conversation_id = $1 AND user_id = $2
+	`
+
+	var role models.MemberRole
+	if err := r.db.QueryRow(ctx, query, conversationID, userID).Scan(&role); err != nil {
+		return "", err
+	}
+
+	return role, nil
+}
+
+// Retrieves the conversation's content filters in execution order
+func (r *conversationRepository) GetContentFilters(ctx context.Context, conversationID uuid.UUID) ([]models.ContentFilterConfig, error) {
+	query := `
+		SELECT name, config
+		FROM conversation_content_filters
+		WHERE conversation_id = $1
+		ORDER BY position
+	`
+
+	rows, err := r.db.Query(ctx, query, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	filters := []models.ContentFilterConfig{}
+	for rows.Next() {
+		var filter models.ContentFilterConfig
+		if err := rows.Scan(&filter.Name, &filter.Config); err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+
+	return filters, rows.Err()
+}
+
+// Replaces the conversation's content filters, keeping the given order
+func (r *conversationRepository) SetContentFilters(ctx context.Context, conversationID uuid.UUID, filters []models.ContentFilterConfig) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM conversation_content_filters WHERE conversation_id = $1`, conversationID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO conversation_content_filters (conversation_id, position, name, config)
+		VALUES ($1, $2, $3, $4)
+	`
+	for position, filter := range filters {
+		config := filter.Config
+		if len(config) == 0 {
+			config = []byte("{}")
+		}
+		if _, err := tx.Exec(ctx, query, conversationID, position, filter.Name, config); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *conversationRepository) SetSlowMode(ctx context.Context, conversationID uuid.UUID, seconds int) error {
+	query := `UPDATE conversations SET slow_mode_seconds = $1 WHERE id = $2`
+	result, err := r.db.Exec(ctx, query, seconds, conversationID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("no conversation found with id: %s", conversationID)
+	}
+
+	return nil
+}
+
+// Sets or clears (nil) the conversation's topic
+func (r *conversationRepository) SetTopic(ctx context.Context, conversationID uuid.UUID, topic *string) error {
+	query := `UPDATE conversations SET topic = $1 WHERE id = $2`
+	result, err := r.db.Exec(ctx, query, topic, conversationID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("no conversation found with id: %s", conversationID)
+	}
+
+	return nil
+}
+
+// Returns nil when the user is not currently muted, or not a member
+func (r *conversationRepository) GetMemberMutedUntil(ctx context.Context, conversationID, userID uuid.UUID) (*time.Time, error) {
+	query := `
+		SELECT muted_until FROM conversation_members
+		WHERE conversation_id = $1 AND user_id = $2 AND muted_until > now()
+	`
+
+	var mutedUntil *time.Time
+	err := r.db.QueryRow(ctx, query, conversationID, userID).Scan(&mutedUntil)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return mutedUntil, nil
+}
+
+// Mutes the member until the given time, nil unmutes them
+func (r *conversationRepository) SetMemberMutedUntil(ctx context.Context, conversationID, userID uuid.UUID, until *time.Time) error {
+	query := `
+		UPDATE conversation_members SET muted_until = $1
+		WHERE conversation_id = $2 AND user_id = $3
+	`
+	result, err := r.db.Exec(ctx, query, until, conversationID, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// Records a new post for the member if the interval elapsed since their last one.
+// Returns 0 on success, otherwise how long the member has to wait.
+// The check and update happen in one statement, so concurrent posts from
+// different server instances cannot both succeed.
+func (r *conversationRepository) ClaimPostingSlot(ctx context.Context, conversationID, userID uuid.UUID, interval time.Duration) (time.Duration, error) {
+	query := `
+		WITH claimed AS (
+			UPDATE conversation_members
+			SET last_posted_at = now()
+			WHERE conversation_id = $1 AND user_id = $2
+			  AND (last_posted_at IS NULL OR last_posted_at + make_interval(secs => $3) <= now())
+			RETURNING 1
+		)
+		SELECT
+			EXISTS (SELECT 1 FROM claimed),
+			COALESCE((
+				SELECT EXTRACT(EPOCH FROM last_posted_at + make_interval(secs => $3) - now())::float8
+				FROM conversation_members
+				WHERE conversation_id = $1 AND user_id = $2
+			), 0)
+	`
+
+	var claimed bool
+	var remaining float64
+	if err := r.db.QueryRow(ctx, query, conversationID, userID, interval.Seconds()).Scan(&claimed, &remaining); err != nil {
+		return 0, err
+	}
+
+	if claimed {
+		return 0, nil
+	}
+
+	// The slot may free up between the update and the read
+	wait := time.Duration(remaining * float64(time.Second))
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait, nil
+}
+
+// Gives back a slot claimed for a post that was not stored after all. The
+// slot was free when claimed, so the member may post right away again.
+func (r *conversationRepository) ReleasePostingSlot(ctx context.Context, conversationID, userID uuid.UUID) error {
+	query := `UPDATE conversation_members SET last_posted_at = NULL WHERE conversation_id = $1 AND user_id = $2`
+	_, err := r.db.Exec(ctx, query, conversationID, userID)
+	return err
+}
+
+// Returns pgx.ErrNoRows when both users do not share a direct conversation yet
+func (r *conversationRepository) FindDirectConversation(ctx context.Context, userID, otherID uuid.UUID) (*models.Conversation, error) {
+	return findDirectConversation(ctx, r.db, userID, otherID)
+}
+
+// Implemented by both the pool and transactions
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func findDirectConversation(ctx context.Context, db rowQuerier, userID, otherID uuid.UUID) (*models.Conversation, error) {
+	query := `
+		SELECT c.id, c.is_public, c.is_direct, c.name, c.topic, c.slow_mode_seconds, c.created_at
+		FROM conversations c
+		JOIN conversation_members a ON a.conversation_id = c.id AND a.user_id = $1
+		JOIN conversation_members b ON b.conversation_id = c.id AND b.user_id = $2
+		WHERE c.is_direct
+		LIMIT 1
+	`
+
+	var conversation models.Conversation
+	err := db.QueryRow(ctx, query, userID, otherID).Scan(
+		&conversation.ID,
+		&conversation.IsPublic,
+		&conversation.IsDirect,
+		&conversation.Name,
+		&conversation.Topic,
+		&conversation.SlowModeSeconds,
+		&conversation.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &conversation, nil
+}
+
+// Creates the conversation along with both of its members, unless both users
+// already share one which is returned instead. Concurrent calls for the same
+// pair of users are serialized, so that only one conversation is created.
+func (r *conversationRepository) CreateDirectConversation(ctx context.Context, conversation *models.Conversation, userID, otherID uuid.UUID) (*models.Conversation, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Same key whichever user opens the conversation, released on commit or rollback
+	first, second := userID.String(), otherID.String()
+	if second < first {
+		first, second = second, first
+	}
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, "direct:"+first+":"+second); err != nil {
+		return nil, err
+	}
+
+	existing, err := findDirectConversation(ctx, tx, userID, otherID)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO conversations (id, is_public, is_direct, name, created_at)
+		VALUES ($1, false, true, NULL, $2)
+	`
+	if _, err := tx.Exec(ctx, query, conversation.ID, conversation.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	memberQuery := `
+		INSERT INTO conversation_members (user_id, conversation_id, role)
+		VALUES ($1, $2, $3)
+	`
+	for _, memberID := range []uuid.UUID{userID, otherID} {
+		if _, err := tx.Exec(ctx, memberQuery, memberID, conversation.ID, models.RoleMember); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return conversation, nil
+}
+
+// Retrieves every conversation the user belongs to, oldest membership first
+func (r *conversationRepository) GetMembershipsByUser(ctx context.Context, userID uuid.UUID) ([]*models.Membership, error) {
+	query := `
+		SELECT c.id, c.name, c.is_direct, cm.role, cm.joined_at
+		FROM conversation_members cm
+		JOIN conversations c ON c.id = cm.conversation_id
+		WHERE cm.user_id = $1
+		ORDER BY cm.joined_at
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memberships := []*models.Membership{}
+	for rows.Next() {
+		var membership models.Membership
+		err := rows.Scan(
+			&membership.ConversationID,
+			&membership.ConversationName,
+			&membership.IsDirect,
+			&membership.Role,
+			&membership.JoinedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, &membership)
+	}
+
+	return memberships, rows.Err()
+}
+
+// Retrieves every conversation the user belongs to, latest membership first
+func (r *conversationRepository) GetConversationsByUser(ctx context.Context, userID uuid.UUID) ([]*models.UserConversation, error) {
+	query := `
+		SELECT c.id, c.is_public, c.is_direct, c.name, c.topic, c.slow_mode_seconds, c.created_at, cm.role,
+			COALESCE((
+				SELECT u.username
+				FROM conversation_members peer
+				JOIN users u ON u.id = peer.user_id
+				WHERE c.is_direct AND peer.conversation_id = c.id AND peer.user_id <> $1
+				LIMIT 1
+			), '')
+		FROM conversation_members cm
+		JOIN conversations c ON c.id = cm.conversation_id
+		WHERE cm.user_id = $1
+		ORDER BY cm.joined_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []*models.UserConversation{}
+	for rows.Next() {
+		var conversation models.UserConversation
+		err := rows.Scan(
+			&conversation.ID,
+			&conversation.IsPublic,
+			&conversation.IsDirect,
+			&conversation.Name,
+			&conversation.Topic,
+			&conversation.SlowModeSeconds,
+			&conversation.CreatedAt,
+			&conversation.Role,
+			&conversation.PeerUsername,
+		)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, &conversation)
+	}
+
+	return conversations, rows.Err()
+}
+
+// Retrieves the most recently created conversations along with their activity
+func (r *conversationRepository) GetConversations(ctx context.Context, limit int) ([]*models.ConversationOverview, error) {
+	query := `
+		SELECT c.id, c.is_public, c.is_direct, c.name, c.topic, c.slow_mode_seconds, c.created_at,
+			(SELECT count(*) FROM conversation_members cm WHERE cm.conversation_id = c.id),
+			(SELECT count(*) FROM messages m WHERE m.conversation_id = c.id),
+			(SELECT max(m.created_at) FROM messages m WHERE m.conversation_id = c.id)
+		FROM conversations c
+		ORDER BY c.created_at DESC
+		LIMIT $1
+	`
+
+	rows, err := r.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []*models.ConversationOverview{}
+	for rows.Next() {
+		var conversation models.ConversationOverview
+		err := rows.Scan(
+			&conversation.ID,
+			&conversation.IsPublic,
+			&conversation.IsDirect,
+			&conversation.Name,
+			&conversation.Topic,
+			&conversation.SlowModeSeconds,
+			&conversation.CreatedAt,
+			&conversation.MemberCount,
+			&conversation.MessageCount,
+			&conversation.LastMessageAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, &conversation)
+	}
+
+	return conversations, rows.Err()
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/database"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestGetMemberRole(t *testing.T) {
+	userRepo := SetupTest(t)
+	conversationRepo := NewConversationRepository(database.DB)
+
+	user := NewTestUser(t, "testuser_member_role")
+	if err := userRepo.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	defer CleanUpUser(t, user.ID, userRepo)
+
+	conversation := CreateTestConversation(t, conversationRepo, user.ID)
+	defer CleanUpConversation(t, conversation.ID, conversationRepo)
+
+	role, err := conversationRepo.GetMemberRole(context.Background(), conversation.ID, user.ID)
+	if err != nil {
+		t.Fatalf("GetMemberRole failed: %v", err)
+	}
+
+	if role != models.RoleMember {
+		t.Errorf("Expected role %s, got %s", models.RoleMember, role)
+	}
+}
+
+func TestClaimPostingSlot(t *testing.T) {
+	userRepo := SetupTest(t)
+	conversationRepo := NewConversationRepository(database.DB)
+
+	user := NewTestUser(t, "testuser_slow_mode")
+	if err := userRepo.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	defer CleanUpUser(t, user.ID, userRepo)
+
+	conversation := CreateTestConversation(t, conversationRepo, user.ID)
+	defer CleanUpConversation(t, conversation.ID, conversationRepo)
+
+	interval := 30 * time.Second
+
+	wait, err := conversationRepo.ClaimPostingSlot(context.Background(), conversation.ID, user.ID, interval)
+	if err != nil {
+		t.Fatalf("ClaimPostingSlot failed: %v", err)
+	}
+	if wait != 0 {
+		t.Fatalf("Expected first post to be allowed, got wait of %s", wait)
+	}
+
+	wait, err = conversationRepo.ClaimPostingSlot(context.Background(), conversation.ID, user.ID, interval)
+	if err != nil {
+		t.Fatalf("ClaimPostingSlot failed: %v", err)
+	}
+	if wait <= 0 || wait > interval {
+		t.Errorf("Expected a wait between 0 and %s, got %s", interval, wait)
+	}
+
+	// A released slot can be claimed again right away
+	if err := conversationRepo.ReleasePostingSlot(context.Background(), conversation.ID, user.ID); err != nil {
+		t.Fatalf("ReleasePostingSlot failed: %v", err)
+	}
+	wait, err = conversationRepo.ClaimPostingSlot(context.Background(), conversation.ID, user.ID, interval)
+	if err != nil {
+		t.Fatalf("ClaimPostingSlot failed: %v", err)
+	}
+	if wait != 0 {
+		t.Errorf("Expected the released slot to be free, got wait of %s", wait)
+	}
+}
+
+func TestGetConversationsByUser(t *testing.T) {
+	userRepo := SetupTest(t)
+	conversationRepo := NewConversationRepository(database.DB)
+
+	user := NewTestUser(t, "testuser_list_convs")
+	other := NewTestUser(t, "testuser_list_peer")
+	for _, u := range []*models.User{user, other} {
+		if err := userRepo.CreateUser(context.Background(), u); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		defer CleanUpUser(t, u.ID, userRepo)
+	}
+
+	group := CreateTestConversation(t, conversationRepo, user.ID)
+	defer CleanUpConversation(t, group.ID, conversationRepo)
+
+	direct := &models.Conversation{ID: uuid.New(), IsDirect: true, CreatedAt: time.Now().UTC()}
+	if _, err := conversationRepo.CreateDirectConversation(context.Background(), direct, user.ID, other.ID); err != nil {
+		t.Fatalf("CreateDirectConversation failed: %v", err)
+	}
+	defer CleanUpConversation(t, direct.ID, conversationRepo)
+
+	conversations, err := conversationRepo.GetConversationsByUser(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("GetConversationsByUser failed: %v", err)
+	}
+	if len(conversations) != 2 {
+		t.Fatalf("Expected 2 conversations, got %d", len(conversations))
+	}
+
+	// Latest joined first
+	if conversations[0].ID != direct.ID || conversations[0].PeerUsername != other.Username {
+		t.Errorf("Expected the direct conversation with %s first, got %+v", other.Username, conversations[0])
+	}
+	if conversations[1].ID != group.ID || conversations[1].PeerUsername != "" || conversations[1].Role != models.RoleMember {
+		t.Errorf("Unexpected group conversation: %+v", conversations[1])
+	}
+}
@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/google/uuid"
+)
+
+// Contract for any kind of end-to-end encryption key data access implementation.
+type DeviceRepository interface {
+	UpsertDevice(ctx context.Context, device *models.Device, preKeys []models.PreKey) error
+	GetDevice(ctx context.Context, id uuid.UUID) (*models.Device, error)
+	GetDevicesByUser(ctx context.Context, userID uuid.UUID) ([]*models.Device, error)
+	DeleteDevice(ctx context.Context, userID, id uuid.UUID) error
+	AddOneTimePreKeys(ctx context.Context, deviceID uuid.UUID, preKeys []models.PreKey) error
+	CountOneTimePreKeys(ctx context.Context, deviceID uuid.UUID) (int, error)
+	ClaimPreKeyBundles(ctx context.Context, userID uuid.UUID) ([]*models.PreKeyBundle, error)
+}
+
+// Concrete implementation of DeviceRepository
+type deviceRepository struct {
+	db *pgxpool.Pool
+}
+
+// Constructor, returns a new instance of the repository
+func NewDeviceRepository(db *pgxpool.Pool) DeviceRepository {
+	return &deviceRepository{db: db}
+}
+
+// Columns read by every device query, in scanDevice order
+const deviceColumns = `id, user_id, identity_key, signed_prekey_id, signed_prekey, signed_prekey_signature, created_at, updated_at`
+
+func scanDevice(row pgx.Row) (*models.Device, error) {
+	var device models.Device
+	err := row.Scan(
+		&device.ID,
+		&device.UserID,
+		&device.IdentityKey,
+		&device.SignedPreKeyID,
+		&device.SignedPreKey,
+		&device.SignedPreKeySignature,
+		&device.CreatedAt,
+		&device.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &device, nil
+}
+
+// Registers the device or rotates its signed prekey, then stores the one-time prekeys.
+// Returns pgx.ErrNoRows when the ID is taken by another user's device
+// or by a device with a different identity key.
+func (r *deviceRepository) UpsertDevice(ctx context.Context, device *models.Device, preKeys []models.PreKey) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO e2e_devices (id, user_id, identity_key, signed_prekey_id, signed_prekey, signed_prekey_signature, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (id) DO UPDATE
+		SET signed_prekey_id = EXCLUDED.signed_prekey_id,
+		    signed_prekey = EXCLUDED.signed_prekey,
+		    signed_prekey_signature = EXCLUDED.signed_prekey_signature,
+		    updated_at = EXCLUDED.updated_at
+		WHERE e2e_devices.user_id = EXCLUDED.user_id AND e2e_devices.identity_key = EXCLUDED.identity_key
+		RETURNING created_at
+	`
+
+	err = tx.QueryRow(ctx, query,
+		device.ID,
+		device.UserID,
+		device.IdentityKey,
+		device.SignedPreKeyID,
+		device.SignedPreKey,
+		device.SignedPreKeySignature,
+		device.UpdatedAt,
+	).Scan(&device.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := addOneTimePreKeys(ctx, tx, device.ID, preKeys); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Keys whose ID is already stored are ignored, so uploads can be retried
+func addOneTimePreKeys(ctx context.Context, tx pgx.Tx, deviceID uuid.UUID, preKeys []models.PreKey) error {
+	query := `
+		INSERT INTO e2e_one_time_prekeys (device_id, key_id, public_key)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`
+
+	batch := &pgx.Batch{}
+	for _, key := range preKeys {
+		batch.Queue(query, deviceID, key.KeyID, key.PublicKey)
+	}
+
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+func (r *deviceRepository) GetDevice(ctx context.Context, id uuid.UUID) (*models.Device, error) {
+	query := `SELECT ` + deviceColumns + ` FROM e2e_devices WHERE id = $1`
+	return scanDevice(r.db.QueryRow(ctx, query, id))
+}
+
+// Retrieves the user's devices along with their number of one-time prekeys left, oldest first
+func (r *deviceRepository) GetDevicesByUser(ctx context.Context, userID uuid.UUID) ([]*models.Device, error) {
+	query := `
+		SELECT ` + deviceColumns + `,
+		       (SELECT count(*) FROM e2e_one_time_prekeys k WHERE k.device_id = e2e_devices.id)
+		FROM e2e_devices
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := []*models.Device{}
+	for rows.Next() {
+		var device models.Device
+		err := rows.Scan(
+			&device.ID,
+			&device.UserID,
+			&device.IdentityKey,
+			&device.SignedPreKeyID,
+			&device.SignedPreKey,
+			&device.SignedPreKeySignature,
+			&device.CreatedAt,
+			&device.UpdatedAt,
+			&device.OneTimePreKeyCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, &device)
+	}
+
+	return devices, rows.Err()
+}
+
+// Returns pgx.ErrNoRows when the user has no such device
+func (r *deviceRepository) DeleteDevice(ctx context.Context, userID, id uuid.UUID) error {
+	query := `DELETE FROM e2e_devices WHERE id = $1 AND user_id = $2`
+	result, err := r.db.Exec(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *deviceRepository) AddOneTimePreKeys(ctx context.Context, deviceID uuid.UUID, preKeys []models.PreKey) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := addOneTimePreKeys(ctx, tx, deviceID, preKeys); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *deviceRepository) CountOneTimePreKeys(ctx context.Context, deviceID uuid.UUID) (int, error) {
+	query := `SELECT count(*) FROM e2e_one_time_prekeys WHERE device_id = $1`
+
+	var count int
+	if err := r.db.QueryRow(ctx, query, deviceID).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Returns a bundle for each of the user's devices, removing the one-time prekey it hands out.
+// Concurrent claims skip each other's keys, so a key is never handed out twice.
+func (r *deviceRepository) ClaimPreKeyBundles(ctx context.Context, userID uuid.UUID) ([]*models.PreKeyBundle, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `SELECT `+deviceColumns+` FROM e2e_devices WHERE user_id = $1 ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	bundles := []*models.PreKeyBundle{}
+	for rows.Next() {
+		device, err := scanDevice(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		bundles = append(bundles, &models.PreKeyBundle{Device: device})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	claimQuery := `
+		DELETE FROM e2e_one_time_prekeys
+		WHERE (device_id, key_id) = (
+			SELECT device_id, key_id FROM e2e_one_time_prekeys
+			WHERE device_id = $1
+			ORDER BY key_id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING key_id, public_key
+	`
+
+	for _, bundle := range bundles {
+		var key models.PreKey
+		err := tx.QueryRow(ctx, claimQuery, bundle.Device.ID).Scan(&key.KeyID, &key.PublicKey)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		bundle.OneTimePreKey = &key
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return bundles, nil
+}
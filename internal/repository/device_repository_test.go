@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/database"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestClaimPreKeyBundles_HandsOutKeysOnce(t *testing.T) {
+	userRepo := SetupTest(t)
+	deviceRepo := NewDeviceRepository(database.DB)
+	ctx := context.Background()
+
+	user := NewTestUser(t, "testuser_devices")
+	if err := userRepo.CreateUser(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	defer CleanUpUser(t, user.ID, userRepo)
+
+	key := base64.StdEncoding.EncodeToString(make([]byte, 33))
+	device := &models.Device {
+		ID:			uuid.New(),
+		UserID:			user.ID,
+		IdentityKey:		key,
+		SignedPreKeyID:		1,
+		SignedPreKey:		key,
+		SignedPreKeySignature:	base64.StdEncoding.EncodeToString(make([]byte, 64)),
+		UpdatedAt:		time.Now().UTC(),
+	}
+	preKeys := []models.PreKey{{KeyID: 1, PublicKey: key}, {KeyID: 2, PublicKey: key}}
+	if err := deviceRepo.UpsertDevice(ctx, device, preKeys); err != nil {
+		t.Fatalf("UpsertDevice failed: %v", err)
+	}
+
+	claimed := map[int]bool{}
+	for i := 0; i < 3; i++ {
+		bundles, err := deviceRepo.ClaimPreKeyBundles(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("ClaimPreKeyBundles failed: %v", err)
+		}
+		if len(bundles) != 1 {
+			t.Fatalf("Expected 1 bundle, got %d", len(bundles))
+		}
+
+		key := bundles[0].OneTimePreKey
+		if i < len(preKeys) {
+			if key == nil || claimed[key.KeyID] {
+				t.Fatalf("Expected a fresh one-time prekey on claim %d, got %+v", i+1, key)
+			}
+			claimed[key.KeyID] = true
+		} else if key != nil {
+			t.Errorf("Expected no one-time prekey once exhausted, got %+v", key)
+		}
+	}
+
+	// The identity key of a registered device cannot change
+	device.IdentityKey = base64.StdEncoding.EncodeToString(make([]byte, 32))
+	if err := deviceRepo.UpsertDevice(ctx, device, nil); err == nil {
+		t.Error("Expected registering a new identity key under the same device ID to fail")
+	}
+}
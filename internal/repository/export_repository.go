@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/google/uuid"
+)
+
+// Contract for any kind of data export access implementation.
+type ExportRepository interface {
+	CreateExport(ctx context.Context, export *models.DataExport) (bool, error)
+	GetExportByID(ctx context.Context, id uuid.UUID) (*models.DataExport, error)
+	GetActiveExport(ctx context.Context, userID uuid.UUID) (*models.DataExport, error)
+	ClaimPendingExport(ctx context.Context) (*models.DataExport, error)
+	CompleteExport(ctx context.Context, id uuid.UUID, objectKey string, completedAt, expiresAt time.Time) error
+	FailExport(ctx context.Context, id uuid.UUID) error
+	RequeueStaleExports(ctx context.Context, startedBefore time.Time) (int64, error)
+	GetExportsExpiredBefore(ctx context.Context, before time.Time) ([]*models.DataExport, error)
+	DeleteExport(ctx context.Context, id uuid.UUID) error
+	ExpireUserExports(ctx context.Context, userID uuid.UUID, now time.Time) ([]*models.DataExport, error)
+}
+
+// Concrete implementation of ExportRepository
+type exportRepository struct {
+	db *pgxpool.Pool
+}
+
+// Constructor, returns a new instance of the repository
+func NewExportRepository(db *pgxpool.Pool) ExportRepository {
+	return &exportRepository{db: db}
+}
+
+const exportColumns = `id, user_id, status, object_key, created_at, started_at, completed_at, expires_at`
+
+func scanExport(row pgx.Row) (*models.DataExport, error) {
+	var export models.DataExport
+	err := row.Scan(
+		&export.ID,
+		&export.UserID,
+		&export.Status,
+		&export.ObjectKey,
+		&export.CreatedAt,
+		&export.StartedAt,
+		&export.CompletedAt,
+		&export.ExpiresAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+// Returns false when the user already has an export in progress
+func (r *exportRepository) CreateExport(ctx context.Context, export *models.DataExport) (bool, error) {
+	query := `
+		INSERT INTO data_exports (id, user_id, status, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) WHERE status IN ('pending', 'running') DO NOTHING
+	`
+
+	result, err := r.db.Exec(ctx, query, export.ID, export.UserID, export.Status, export.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+func (r *exportRepository) GetExportByID(ctx context.Context, id uuid.UUID) (*models.DataExport, error) {
+	query := `SELECT ` + exportColumns + ` FROM data_exports WHERE id = $1`
+	return scanExport(r.db.QueryRow(ctx, query, id))
+}
+
+// Retrieves the user's pending or running export, if any
+func (r *exportRepository) GetActiveExport(ctx context.Context, userID uuid.UUID) (*models.DataExport, error) {
+	query := `
+		SELECT ` + exportColumns + `
+		FROM data_exports
+		WHERE user_id = $1 AND status IN ('pending', 'running')
+		ORDER BY created_at
+		LIMIT 1
+	`
+	return scanExport(r.db.QueryRow(ctx, query, userID))
+}
+
+// Marks the oldest pending export as running and returns it.
+// Concurrent workers never claim the same export. Returns pgx.ErrNoRows when there is none.
+func (r *exportRepository) ClaimPendingExport(ctx context.Context) (*models.DataExport, error) {
+	query := `
+		UPDATE data_exports
+		SET status = 'running', started_at = now()
+		WHERE id = (
+			SELECT id FROM data_exports
+			WHERE status = 'pending'
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + exportColumns
+
+	return scanExport(r.db.QueryRow(ctx, query))
+}
+
+// Returns pgx.ErrNoRows when the export was deleted in the meantime
+func (r *exportRepository) CompleteExport(ctx context.Context, id uuid.UUID, objectKey string, completedAt, expiresAt time.Time) error {
+	query := `
+		UPDATE data_exports
+		SET status = 'completed', object_key = $1, completed_at = $2, expires_at = $3
+		WHERE id = $4
+	`
+	result, err := r.db.Exec(ctx, query, objectKey, completedAt, expiresAt, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *exportRepository) FailExport(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE data_exports SET status = 'failed', completed_at = now() WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
+
+// Puts back in the queue running exports whose worker most likely died
+func (r *exportRepository) RequeueStaleExports(ctx context.Context, startedBefore time.Time) (int64, error) {
+	query := `
+		UPDATE data_exports
+		SET status = 'pending', started_at = NULL
+		WHERE status = 'running' AND started_at <= $1
+	`
+	result, err := r.db.Exec(ctx, query, startedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// Retrieves completed exports whose archive should be purged
+func (r *exportRepository) GetExportsExpiredBefore(ctx context.Context, before time.Time) ([]*models.DataExport, error) {
+	query := `
+		SELECT ` + exportColumns + `
+		FROM data_exports
+		WHERE expires_at <= $1
+		ORDER BY expires_at
+	`
+
+	rows, err := r.db.Query(ctx, query, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := []*models.DataExport{}
+	for rows.Next() {
+		export, err := scanExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, export)
+	}
+
+	return exports, rows.Err()
+}
+
+func (r *exportRepository) DeleteExport(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM data_exports WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
+
+// Deletes the user's exports without an archive and expires the others, returning those.
+// Their rows are deleted along with the archive, or by the next purge should that fail.
+func (r *exportRepository) ExpireUserExports(ctx context.Context, userID uuid.UUID, now time.Time) ([]*models.DataExport, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Exports still being built are dropped, their worker then deletes the archive
+	if _, err := tx.Exec(ctx, `DELETE FROM data_exports WHERE user_id = $1 AND object_key = ''`, userID); err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE data_exports
+		SET expires_at = LEAST(expires_at, $2)
+		WHERE user_id = $1
+		RETURNING ` + exportColumns
+
+	rows, err := tx.Query(ctx, query, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := []*models.DataExport{}
+	for rows.Next() {
+		export, err := scanExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, export)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return exports, tx.Commit(ctx)
+}
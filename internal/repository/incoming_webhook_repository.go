@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/google/uuid"
+)
+
+// Contract for any kind of incoming webhook data access implementation.
+type IncomingWebhookRepository interface {
+	CreateIncomingWebhook(ctx context.Context, webhook *models.IncomingWebhook, bot *models.User) error
+	GetIncomingWebhookByID(ctx context.Context, id uuid.UUID) (*models.IncomingWebhook, error)
+	GetIncomingWebhookByTokenHash(ctx context.Context, hash string) (*models.IncomingWebhook, error)
+	GetIncomingWebhooksByConversation(ctx context.Context, conversationID uuid.UUID) ([]*models.IncomingWebhook, error)
+	DeleteIncomingWebhook(ctx context.Context, id uuid.UUID) error
+	TouchIncomingWebhook(ctx context.Context, id uuid.UUID) error
+}
+
+// Concrete implementation of IncomingWebhookRepository
+type incomingWebhookRepository struct {
+	db *pgxpool.Pool
+}
+
+// Constructor, returns a new instance of the repository
+func NewIncomingWebhookRepository(db *pgxpool.Pool) IncomingWebhookRepository {
+	return &incomingWebhookRepository{db: db}
+}
+
+const incomingWebhookQuery = `
+	SELECT w.id, w.conversation_id, w.creator_id, w.bot_user_id, w.token_hash, w.created_at, w.last_used_at, u.display_name
+	FROM incoming_webhooks w
+	JOIN users u ON u.id = w.bot_user_id
+`
+
+func scanIncomingWebhook(row pgx.Row) (*models.IncomingWebhook, error) {
+	var webhook models.IncomingWebhook
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.ConversationID,
+		&webhook.CreatorID,
+		&webhook.BotUserID,
+		&webhook.TokenHash,
+		&webhook.CreatedAt,
+		&webhook.LastUsedAt,
+		&webhook.Name,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+// Creates the webhook along with the bot user its messages are attributed to
+func (r *incomingWebhookRepository) CreateIncomingWebhook(ctx context.Context, webhook *models.IncomingWebhook, bot *models.User) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// An empty password hash never matches, so the bot cannot be logged into
+	_, err = tx.Exec(ctx, `
+		INSERT INTO users (id, username, password_hash, display_name, is_bot, created_at, updated_at)
+		VALUES ($1, $2, '', $3, true, $4, $4)
+	`, bot.ID, bot.Username, bot.DisplayName, bot.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO incoming_webhooks (id, conversation_id, creator_id, bot_user_id, token_hash, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, webhook.ID, webhook.ConversationID, webhook.CreatorID, bot.ID, webhook.TokenHash, webhook.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *incomingWebhookRepository) GetIncomingWebhookByID(ctx context.Context, id uuid.UUID) (*models.IncomingWebhook, error) {
+	return scanIncomingWebhook(r.db.QueryRow(ctx, incomingWebhookQuery + `WHERE w.id = $1`, id))
+}
+
+func (r *incomingWebhookRepository) GetIncomingWebhookByTokenHash(ctx context.Context, hash string) (*models.IncomingWebhook, error) {
+	return scanIncomingWebhook(r.db.QueryRow(ctx, incomingWebhookQuery + `WHERE w.token_hash = $1`, hash))
+}
+
+func (r *incomingWebhookRepository) GetIncomingWebhooksByConversation(ctx context.Context, conversationID uuid.UUID) ([]*models.IncomingWebhook, error) {
+	rows, err := r.db.Query(ctx, incomingWebhookQuery + `WHERE w.conversation_id = $1 ORDER BY w.created_at`, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*models.IncomingWebhook{}
+	for rows.Next() {
+		webhook, err := scanIncomingWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// Revokes the webhook, its bot user is kept so that past messages stay attributed
+func (r *incomingWebhookRepository) DeleteIncomingWebhook(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM incoming_webhooks WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
+
+// Records that the webhook was used, at most once a minute to spare writes
+func (r *incomingWebhookRepository) TouchIncomingWebhook(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE incoming_webhooks
+		SET last_used_at = now()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')
+	`
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/google/uuid"
+)
+
+// Contract for any kind of message data access implementation.
+type MessageRepository interface {
+	CreateMessage(ctx context.Context, message *models.Message) error
+	GetMessagesByConversation(ctx context.Context, conversationID, viewerID uuid.UUID, before time.Time, limit int) ([]*models.Message, error)
+	StartReadExpiry(ctx context.Context, conversationID, messageID, readerID uuid.UUID) error
+	MarkExpiredMessagesNotified(ctx context.Context, now time.Time) ([]*models.Message, error)
+	DeleteNotifiedExpiredMessages(ctx context.Context) (int64, error)
+	ResolveMentions(ctx context.Context, conversationID uuid.UUID, usernames []string) ([]uuid.UUID, error)
+	ForEachMessageBySender(ctx context.Context, senderID uuid.UUID, fn func(*models.Message) error) error
+}
+
+// Concrete implementation of MessageRepository
+type messageRepository struct {
+	db *pgxpool.Pool
+}
+
+// Constructor, returns a new instance of the repository
+func NewMessageRepository(db *pgxpool.Pool) MessageRepository {
+	return &messageRepository{db: db}
+}
+
+// Insert a new message into the database.
+func (r *messageRepository) CreateMessage(ctx context.Context, message *models.Message) error {
+	query := `
+		INSERT INTO messages (id, conversation_id, sender_id, content, content_type, mention_ids, created_at, expires_at, ttl_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	mentionIDs := message.MentionIDs
+	if mentionIDs == nil {
+		mentionIDs = []uuid.UUID{}
+	}
+
+	contentType := message.ContentType
+	if contentType == "" {
+		contentType = models.ContentTypeText
+	}
+
+	_, err := r.db.Exec(ctx, query,
+		message.ID,
+		message.ConversationID,
+		message.SenderID,
+		message.Content,
+		contentType,
+		mentionIDs,
+		message.CreatedAt,
+		message.ExpiresAt,
+		message.TTLSeconds,
+	)
+
+	return err
+}
+
+// Retrieves the newest messages of a conversation sent before a given time, as seen by the viewer.
+// Expired messages are filtered out even if the sweeper did not purge them yet,
+// as are messages from users the viewer blocked and chose to hide.
+func (r *messageRepository) GetMessagesByConversation(ctx context.Context, conversationID, viewerID uuid.UUID, before time.Time, limit int) ([]*models.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages m
+		WHERE conversation_id = $1
+		  AND created_at < $2
+		  AND (expires_at IS NULL OR expires_at > now())
+		  AND NOT EXISTS (
+			SELECT 1 FROM user_blocks b
+			WHERE b.blocker_id = $4 AND b.blocked_id = m.sender_id AND b.hide_messages
+		  )
+		ORDER BY created_at DESC
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, conversationID, before, limit, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanMessages(rows)
+}
+
+// Starts the countdown of a message whose TTL counts from the first read,
+// unless the reader is its sender. Other messages are left as they are.
+func (r *messageRepository) StartReadExpiry(ctx context.Context, conversationID, messageID, readerID uuid.UUID) error {
+	query := `
+		UPDATE messages
+		SET expires_at = now() + ttl_seconds * interval '1 second'
+		WHERE id = $1 AND conversation_id = $2 AND sender_id <> $3
+		  AND ttl_seconds IS NOT NULL AND expires_at IS NULL
+	`
+	_, err := r.db.Exec(ctx, query, messageID, conversationID, readerID)
+	return err
+}
+
+// Flags the messages expired at the given time that subscribers were not told
+// about yet, and returns them. Each expiry is only returned once, even when
+// the notification was missed while the server was down.
+func (r *messageRepository) MarkExpiredMessagesNotified(ctx context.Context, now time.Time) ([]*models.Message, error) {
+	query := `
+		UPDATE messages
+		SET expiry_notified_at = $1
+		WHERE expires_at <= $1 AND expiry_notified_at IS NULL
+		RETURNING ` + messageColumns
+
+	rows, err := r.db.Query(ctx, query, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanMessages(rows)
+}
+
+// Physically removes the expired messages which subscribers were notified about
+func (r *messageRepository) DeleteNotifiedExpiredMessages(ctx context.Context) (int64, error) {
+	query := `DELETE FROM messages WHERE expiry_notified_at IS NOT NULL`
+	result, err := r.db.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// Returns the IDs of conversation members with the given usernames
+func (r *messageRepository) ResolveMentions(ctx context.Context, conversationID uuid.UUID, usernames []string) ([]uuid.UUID, error) {
+	query := `
+		SELECT u.id
+		FROM users u
+		JOIN conversation_members cm ON cm.user_id = u.id AND cm.conversation_id = $1
+		WHERE u.username = ANY($2)
+	`
+
+	rows, err := r.db.Query(ctx, query, conversationID, usernames)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Columns read by every message query, in scanMessage order
+const messageColumns = `id, conversation_id, sender_id, content, content_type, mention_ids, created_at, expires_at, ttl_seconds`
+
+func scanMessage(row pgx.Row) (*models.Message, error) {
+	var message models.Message
+	err := row.Scan(
+		&message.ID,
+		&message.ConversationID,
+		&message.SenderID,
+		&message.Content,
+		&message.ContentType,
+		&message.MentionIDs,
+		&message.CreatedAt,
+		&message.ExpiresAt,
+		&message.TTLSeconds,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
+
+func scanMessages(rows pgx.Rows) ([]*models.Message, error) {
+	defer rows.Close()
+
+	messages := []*models.Message{}
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
+
+// Calls fn for every message sent by the user, oldest first, without loading them all in memory.
+// Iteration stops at the first error returned by fn.
+func (r *messageRepository) ForEachMessageBySender(ctx context.Context, senderID uuid.UUID, fn func(*models.Message) error) error {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE sender_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Query(ctx, query, senderID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(message); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
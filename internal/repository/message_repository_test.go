@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/database"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// Creates a sender and a conversation, returning the message repository
+func setupMessageTest(t *testing.T) (MessageRepository, *models.User, *models.Conversation) {
+	t.Helper()
+
+	userRepo := SetupTest(t)
+	conversationRepo := NewConversationRepository(database.DB)
+
+	sender := NewTestUser(t, "testuser_messages_" + uuid.NewString()[:8])
+	if err := userRepo.CreateUser(context.Background(), sender); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	t.Cleanup(func() { CleanUpUser(t, sender.ID, userRepo) })
+
+	conversation := CreateTestConversation(t, conversationRepo, sender.ID)
+	t.Cleanup(func() { CleanUpConversation(t, conversation.ID, conversationRepo) })
+
+	return NewMessageRepository(database.DB), sender, conversation
+}
+
+func newTestMessage(sender *models.User, conversation *models.Conversation, expiresAt *time.Time) *models.Message {
+	return &models.Message {
+		ID:		uuid.New(),
+		ConversationID:	conversation.ID,
+		SenderID:	sender.ID,
+		Content:	"hello",
+		CreatedAt:	time.Now().UTC().Add(-time.Minute),
+		ExpiresAt:	expiresAt,
+	}
+}
+
+func TestGetMessagesByConversation_ExcludesExpired(t *testing.T) {
+	repo, sender, conversation := setupMessageTest(t)
+	ctx := context.Background()
+
+	past := time.Now().UTC().Add(-time.Second)
+	future := time.Now().UTC().Add(time.Hour)
+
+	expired := newTestMessage(sender, conversation, &past)
+	alive := newTestMessage(sender, conversation, &future)
+	permanent := newTestMessage(sender, conversation, nil)
+
+	for _, message := range []*models.Message{expired, alive, permanent} {
+		if err := repo.CreateMessage(ctx, message); err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+	}
+
+	messages, err := repo.GetMessagesByConversation(ctx, conversation.ID, sender.ID, time.Now().UTC(), 10)
+	if err != nil {
+		t.Fatalf("GetMessagesByConversation failed: %v", err)
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+
+	for _, message := range messages {
+		if message.ID == expired.ID {
+			t.Errorf("Expired message %v should not be returned", expired.ID)
+		}
+	}
+}
+
+func TestDeleteNotifiedExpiredMessages(t *testing.T) {
+	repo, sender, conversation := setupMessageTest(t)
+	ctx := context.Background()
+
+	past := time.Now().UTC().Add(-time.Second)
+	expired := newTestMessage(sender, conversation, &past)
+	permanent := newTestMessage(sender, conversation, nil)
+
+	for _, message := range []*models.Message{expired, permanent} {
+		if err := repo.CreateMessage(ctx, message); err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+	}
+
+	// Not notified yet, so kept
+	if _, err := repo.DeleteNotifiedExpiredMessages(ctx); err != nil {
+		t.Fatalf("DeleteNotifiedExpiredMessages failed: %v", err)
+	}
+
+	notified, err := repo.MarkExpiredMessagesNotified(ctx, time.Now().UTC())
+	if err != nil {
+		t.Fatalf("MarkExpiredMessagesNotified failed: %v", err)
+	}
+	found := false
+	for _, message := range notified {
+		if message.ID == permanent.ID {
+			t.Errorf("Permanent message %v should not be reported as expired", permanent.ID)
+		}
+		found = found || message.ID == expired.ID
+	}
+	if !found {
+		t.Errorf("Expected message %v to be reported as expired, got %v", expired.ID, notified)
+	}
+
+	again, err := repo.MarkExpiredMessagesNotified(ctx, time.Now().UTC())
+	if err != nil {
+		t.Fatalf("MarkExpiredMessagesNotified failed: %v", err)
+	}
+	for _, message := range again {
+		if message.ID == expired.ID {
+			t.Errorf("Message %v should only be reported once", expired.ID)
+		}
+	}
+
+	deleted, err := repo.DeleteNotifiedExpiredMessages(ctx)
+	if err != nil {
+		t.Fatalf("DeleteNotifiedExpiredMessages failed: %v", err)
+	}
+	if deleted < 1 {
+		t.Errorf("Expected at least 1 deleted message, got %d", deleted)
+	}
+
+	messages, err := repo.GetMessagesByConversation(ctx, conversation.ID, sender.ID, time.Now().UTC(), 10)
+	if err != nil {
+		t.Fatalf("GetMessagesByConversation failed: %v", err)
+	}
+	if len(messages) != 1 || messages[0].ID != permanent.ID {
+		t.Errorf("Expected only the permanent message to remain, got %v", messages)
+	}
+}
+
+func TestStartReadExpiry(t *testing.T) {
+	repo, sender, conversation := setupMessageTest(t)
+	ctx := context.Background()
+
+	ttlSeconds := 60
+	message := newTestMessage(sender, conversation, nil)
+	message.TTLSeconds = &ttlSeconds
+	if err := repo.CreateMessage(ctx, message); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	readExpiresAt := func() *time.Time {
+		t.Helper()
+		messages, err := repo.GetMessagesByConversation(ctx, conversation.ID, sender.ID, time.Now().UTC(), 10)
+		if err != nil {
+			t.Fatalf("GetMessagesByConversation failed: %v", err)
+		}
+		if len(messages) != 1 {
+			t.Fatalf("Expected 1 message, got %d", len(messages))
+		}
+		return messages[0].ExpiresAt
+	}
+
+	// Reads by the sender do not count
+	if err := repo.StartReadExpiry(ctx, conversation.ID, message.ID, sender.ID); err != nil {
+		t.Fatalf("StartReadExpiry failed: %v", err)
+	}
+	if expiresAt := readExpiresAt(); expiresAt != nil {
+		t.Fatalf("Expected no expiry before another member read the message, got %v", expiresAt)
+	}
+
+	if err := repo.StartReadExpiry(ctx, conversation.ID, message.ID, uuid.New()); err != nil {
+		t.Fatalf("StartReadExpiry failed: %v", err)
+	}
+	expiresAt := readExpiresAt()
+	if expiresAt == nil {
+		t.Fatal("Expected the countdown to start on read")
+	}
+	if until := time.Until(*expiresAt); until <= 0 || until > time.Duration(ttlSeconds)*time.Second {
+		t.Errorf("Expected the message to expire within %ds, expires in %v", ttlSeconds, until)
+	}
+}
+
+func TestAnonymizeUser_KeepsMessages(t *testing.T) {
+	repo, sender, conversation := setupMessageTest(t)
+	userRepo := NewUserRepository(database.DB)
+	ctx := context.Background()
+
+	message := newTestMessage(sender, conversation, nil)
+	if err := repo.CreateMessage(ctx, message); err != nil {
+		t.Fatalf("CreateMessage failed: %v", err)
+	}
+
+	now := time.Now().UTC()
+	if err := userRepo.ScheduleUserDeletion(ctx, sender.ID, now.Add(-time.Second)); err != nil {
+		t.Fatalf("ScheduleUserDeletion failed: %v", err)
+	}
+
+	anonymized, err := userRepo.AnonymizeUser(ctx, sender.ID, now)
+	if err != nil {
+		t.Fatalf("AnonymizeUser failed: %v", err)
+	}
+	if !anonymized {
+		t.Fatal("Expected the user to be anonymized")
+	}
+
+	user, err := userRepo.GetUserByID(ctx, sender.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID failed: %v", err)
+	}
+	if !user.IsDeleted() || user.Username == sender.Username || user.DisplayName != models.DeletedUserDisplayName {
+		t.Errorf("Expected anonymized user, got %+v", user)
+	}
+
+	// The sender is no longer a member, read the history as another member
+	reader := NewTestUser(t, "testuser_anonymize_reader")
+	if err := userRepo.CreateUser(ctx, reader); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	defer CleanUpUser(t, reader.ID, userRepo)
+
+	messages, err := repo.GetMessagesByConversation(ctx, conversation.ID, reader.ID, time.Now().UTC(), 10)
+	if err != nil {
+		t.Fatalf("GetMessagesByConversation failed: %v", err)
+	}
+	if len(messages) != 1 || messages[0].SenderID != sender.ID {
+		t.Errorf("Expected the message to be kept, got %v", messages)
+	}
+}
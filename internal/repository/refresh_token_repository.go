@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/google/uuid"
+)
+
+// Contract for any kind of refresh token data access implementation.
+type RefreshTokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, usedID uuid.UUID, next *models.RefreshToken) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error
+	DeleteExpiredRefreshTokens(ctx context.Context, userID uuid.UUID) error
+}
+
+// Concrete implementation of RefreshTokenRepository
+type refreshTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+// Constructor, returns a new instance of the repository
+func NewRefreshTokenRepository(db *pgxpool.Pool) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+const refreshTokenColumns = `id, family_id, user_id, token_hash, created_at, expires_at, used_at, revoked_at`
+
+func scanRefreshToken(row pgx.Row) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := row.Scan(
+		&token.ID,
+		&token.FamilyID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.RevokedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+const insertRefreshTokenQuery = `
+	INSERT INTO refresh_tokens (id, family_id, user_id, token_hash, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+`
+
+func (r *refreshTokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	_, err := r.db.Exec(ctx, insertRefreshTokenQuery,
+		token.ID,
+		token.FamilyID,
+		token.UserID,
+		token.TokenHash,
+		token.CreatedAt,
+		token.ExpiresAt,
+	)
+
+	return err
+}
+
+func (r *refreshTokenRepository) GetRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	// Tokens of disabled and deleted accounts are not found, nor those
+	// issued before the password last changed or of revoked sessions
+	query := `
+		SELECT ` + refreshTokenColumns + `
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM users u
+			WHERE u.id = refresh_tokens.user_id
+			  AND (u.disabled_at IS NOT NULL OR u.deleted_at IS NOT NULL
+			       OR u.tokens_valid_after > refresh_tokens.created_at)
+		  )
+		  AND NOT EXISTS (
+			SELECT 1 FROM sessions s
+			WHERE s.id = refresh_tokens.family_id AND s.revoked_at IS NOT NULL
+		  )
+	`
+	return scanRefreshToken(r.db.QueryRow(ctx, query, hash))
+}
+
+// Marks the token as used and stores its successor in one transaction.
+// Returns pgx.ErrNoRows when the token was used or revoked in the meantime.
+func (r *refreshTokenRepository) RotateRefreshToken(ctx context.Context, usedID uuid.UUID, next *models.RefreshToken) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens
+		SET used_at = now()
+		WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL
+	`, usedID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	_, err = tx.Exec(ctx, insertRefreshTokenQuery,
+		next.ID,
+		next.FamilyID,
+		next.UserID,
+		next.TokenHash,
+		next.CreatedAt,
+		next.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Revokes every token of the family, the one in use included
+func (r *refreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL`
+	_, err := r.db.Exec(ctx, query, familyID)
+	return err
+}
+
+// Deletes the user's expired tokens, which are only kept until then to detect reuse
+func (r *refreshTokenRepository) DeleteExpiredRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	query := `DELETE FROM refresh_tokens WHERE user_id = $1 AND expires_at < now()`
+	_, err := r.db.Exec(ctx, query, userID)
+	return err
+}
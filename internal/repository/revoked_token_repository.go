@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/google/uuid"
+)
+
+// Contract for any kind of JWT revocation data access implementation.
+type RevokedTokenRepository interface {
+	RevokeToken(ctx context.Context, token *models.RevokedToken) error
+	GetRevokedTokens(ctx context.Context, now time.Time) ([]*models.RevokedToken, error)
+	DeleteExpiredRevokedTokens(ctx context.Context, now time.Time) (int64, error)
+	GetTokensValidAfter(ctx context.Context, since time.Time) (map[uuid.UUID]time.Time, error)
+}
+
+// Concrete implementation of RevokedTokenRepository
+type revokedTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+// Constructor, returns a new instance of the repository
+func NewRevokedTokenRepository(db *pgxpool.Pool) RevokedTokenRepository {
+	return &revokedTokenRepository{db: db}
+}
+
+// Adds the token to the denylist, revoking it twice is not an error
+func (r *revokedTokenRepository) RevokeToken(ctx context.Context, token *models.RevokedToken) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, user_id, expires_at, revoked_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (jti) DO NOTHING
+	`
+	_, err := r.db.Exec(ctx, query, token.JTI, token.UserID, token.ExpiresAt, token.RevokedAt)
+	return err
+}
+
+// Retrieves the revoked tokens that have not expired yet
+func (r *revokedTokenRepository) GetRevokedTokens(ctx context.Context, now time.Time) ([]*models.RevokedToken, error) {
+	query := `
+		SELECT jti, user_id, expires_at, revoked_at
+		FROM revoked_tokens
+		WHERE expires_at > $1
+	`
+
+	rows, err := r.db.Query(ctx, query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*models.RevokedToken{}
+	for rows.Next() {
+		var token models.RevokedToken
+		if err := rows.Scan(&token.JTI, &token.UserID, &token.ExpiresAt, &token.RevokedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, &token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// Expired tokens are refused anyway, they no longer need to be listed
+func (r *revokedTokenRepository) DeleteExpiredRevokedTokens(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.Exec(ctx, `DELETE FROM revoked_tokens WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// Returns the users whose sessions were invalidated after since, by user ID
+func (r *revokedTokenRepository) GetTokensValidAfter(ctx context.Context, since time.Time) (map[uuid.UUID]time.Time, error) {
+	query := `SELECT id, tokens_valid_after FROM users WHERE tokens_valid_after > $1`
+
+	rows, err := r.db.Query(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	validAfter := map[uuid.UUID]time.Time{}
+	for rows.Next() {
+		var id uuid.UUID
+		var at time.Time
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, err
+		}
+		validAfter[id] = at
+	}
+
+	return validAfter, rows.Err()
+}
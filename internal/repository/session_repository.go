@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/google/uuid"
+)
+
+// Contract for any kind of session data access implementation.
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *models.Session) error
+	GetActiveSessionsByUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error)
+	TouchSession(ctx context.Context, id uuid.UUID, ipAddress string, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, userID, id uuid.UUID) error
+	RevokeOtherSessions(ctx context.Context, userID, keptID uuid.UUID) ([]uuid.UUID, error)
+	RevokeUserSessions(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	GetSessionsRevokedSince(ctx context.Context, since time.Time) (map[uuid.UUID]time.Time, error)
+	DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error)
+}
+
+// Concrete implementation of SessionRepository
+type sessionRepository struct {
+	db *pgxpool.Pool
+}
+
+// Constructor, returns a new instance of the repository
+func NewSessionRepository(db *pgxpool.Pool) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+const sessionColumns = `s.id, s.user_id, s.device_name, s.user_agent, s.ip_address, s.created_at, s.last_seen_at, s.expires_at, s.revoked_at`
+
+func scanSession(row pgx.Row) (*models.Session, error) {
+	var session models.Session
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.DeviceName,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.ExpiresAt,
+		&session.RevokedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (r *sessionRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	query := `
+		INSERT INTO sessions (id, user_id, device_name, user_agent, ip_address, created_at, last_seen_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := r.db.Exec(ctx, query,
+		session.ID,
+		session.UserID,
+		session.DeviceName,
+		session.UserAgent,
+		session.IPAddress,
+		session.CreatedAt,
+		session.LastSeenAt,
+		session.ExpiresAt,
+	)
+
+	return err
+}
+
+// Retrieves the sessions that can still be refreshed, most recently seen first.
+// Sessions started before the password last changed are left out.
+func (r *sessionRepository) GetActiveSessionsByUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.user_id = $1
+		  AND s.revoked_at IS NULL
+		  AND s.expires_at > now()
+		  AND (u.tokens_valid_after IS NULL OR s.created_at >= u.tokens_valid_after)
+		ORDER BY s.last_seen_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Records a refresh of the session
+func (r *sessionRepository) TouchSession(ctx context.Context, id uuid.UUID, ipAddress string, expiresAt time.Time) error {
+	query := `UPDATE sessions SET last_seen_at = now(), ip_address = $2, expires_at = $3 WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, id, ipAddress, expiresAt)
+	return err
+}
+
+// Returns pgx.ErrNoRows when the user has no such session or it is already revoked
+func (r *sessionRepository) RevokeSession(ctx context.Context, userID, id uuid.UUID) error {
+	query := `UPDATE sessions SET revoked_at = now() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	result, err := r.db.Exec(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// Revokes every session of the user but keptID, returns the IDs of the revoked ones
+func (r *sessionRepository) RevokeOtherSessions(ctx context.Context, userID, keptID uuid.UUID) ([]uuid.UUID, error) {
+	query := `
+		UPDATE sessions SET revoked_at = now()
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL AND expires_at > now()
+		RETURNING id
+	`
+	return r.revokeSessions(ctx, query, userID, keptID)
+}
+
+// Revokes every session of the user, returns the IDs of the revoked ones
+func (r *sessionRepository) RevokeUserSessions(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	query := `
+		UPDATE sessions SET revoked_at = now()
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+		RETURNING id
+	`
+	return r.revokeSessions(ctx, query, userID)
+}
+
+func (r *sessionRepository) revokeSessions(ctx context.Context, query string, args ...any) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Returns the sessions revoked after since, with their revocation time
+func (r *sessionRepository) GetSessionsRevokedSince(ctx context.Context, since time.Time) (map[uuid.UUID]time.Time, error) {
+	rows, err := r.db.Query(ctx, `SELECT id, revoked_at FROM sessions WHERE revoked_at > $1`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revoked := map[uuid.UUID]time.Time{}
+	for rows.Next() {
+		var id uuid.UUID
+		var at time.Time
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, err
+		}
+		revoked[id] = at
+	}
+
+	return revoked, rows.Err()
+}
+
+// Deletes the sessions which cannot be refreshed anymore, along with their refresh tokens
+func (r *sessionRepository) DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.Exec(ctx, `DELETE FROM sessions WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -62,3 +62,35 @@ func NewTestUser(t *testing.T, username string) *models.User {
 	}
 }
 
+// Helper function used to create and persist a conversation
+// whose members are the given users
+func CreateTestConversation(t *testing.T, repo ConversationRepository, members ...uuid.UUID) *models.Conversation {
+	t.Helper()
+
+	conversation := &models.Conversation {
+		ID:		uuid.New(),
+		IsPublic:	false,
+		CreatedAt:	time.Now().UTC(),
+	}
+
+	if err := repo.CreateConversation(context.Background(), conversation); err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+
+	for _, userID := range members {
+		if err := repo.AddMember(context.Background(), conversation.ID, userID, models.RoleMember); err != nil {
+			t.Fatalf("Failed to add conversation member: %v", err)
+		}
+	}
+
+	return conversation
+}
+
+// Helper function used to clean database by deleting a conversation
+func CleanUpConversation(t *testing.T, id uuid.UUID, repo ConversationRepository) {
+	t.Logf("Now deleting the newly added conversation...")
+	err := repo.DeleteConversation(context.Background(), id)
+	if err != nil {
+		t.Logf("Warning: failed to clean up conversation: %v", err)
+	}
+}
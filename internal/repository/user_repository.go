@@ -3,8 +3,11 @@ package repository
 import (
 	"fmt"
 	"context"
+	"strings"
+	"time"
 	
 	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/google/uuid"
 )
@@ -15,7 +18,17 @@ type UserRepository interface {
 	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
 	DeleteUser(ctx context.Context, id uuid.UUID) error
 	GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error)
-	UpdateUser(ctx context.Context, user *models.User) error 
+	UpdateUser(ctx context.Context, id uuid.UUID, update *models.UserUpdate) error
+	SearchUsers(ctx context.Context, prefix, after string, limit int) ([]*models.User, error)
+	ScheduleUserDeletion(ctx context.Context, id uuid.UUID, at time.Time) error
+	CancelUserDeletion(ctx context.Context, id uuid.UUID) error
+	GetUsersDueForDeletion(ctx context.Context, now time.Time, limit int) ([]*models.User, error)
+	AnonymizeUser(ctx context.Context, id uuid.UUID, now time.Time) (bool, error)
+	EnsureBotUser(ctx context.Context, username, displayName string) (*models.User, error)
+	SetUserDisabled(ctx context.Context, id uuid.UUID, at *time.Time) error
+	SetUserAdmin(ctx context.Context, id uuid.UUID, isAdmin bool) error
+	SetUserAvatarKey(ctx context.Context, id uuid.UUID, key string) error
+	SetUserPassword(ctx context.Context, id uuid.UUID, hash string, validAfter time.Time) error
 }
 
 // Concrete implementation of UserRepository
@@ -23,6 +36,37 @@ type userRepository struct {
 	db *pgxpool.Pool
 }
 
+// Columns read by every user query, in scanUser order
+const userColumns = `id, username, password_hash, display_name, bio, pronouns, timezone, avatar_key, is_admin, is_bot, created_at, updated_at, deletion_scheduled_at, deleted_at, disabled_at, tokens_valid_after`
+
+func scanUser(row pgx.Row) (*models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.Password,
+		&user.DisplayName,
+		&user.Bio,
+		&user.Pronouns,
+		&user.Timezone,
+		&user.AvatarKey,
+		&user.IsAdmin,
+		&user.IsBot,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.DeletionScheduledAt,
+		&user.DeletedAt,
+		&user.DisabledAt,
+		&user.TokensValidAfter,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
 // Constructor, returns a new instance of the repository
 func NewUserRepository(db *pgxpool.Pool) UserRepository {
 	return &userRepository{db: db}
@@ -31,14 +75,19 @@ func NewUserRepository(db *pgxpool.Pool) UserRepository {
 // Insert a new user into the database.
 func (r *userRepository) CreateUser(ctx context.Context, user *models.User) error {
 	query := `
-		INSERT INTO users (id, username, password_hash, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO users (id, username, password_hash, display_name, bio, pronouns, timezone, avatar_key, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	`
 
 	_, err := r.db.Exec(ctx, query,
 		user.ID,
 		user.Username,
 		user.Password,
+		user.DisplayName,
+		user.Bio,
+		user.Pronouns,
+		user.Timezone,
+		user.AvatarKey,
 		user.CreatedAt,
 		user.UpdatedAt,
 	)
@@ -46,28 +95,30 @@ func (r *userRepository) CreateUser(ctx context.Context, user *models.User) erro
 	return err
 }
 
+// Creates the bot user if it does not exist yet, otherwise updates its display name.
+// Returns pgx.ErrNoRows when the username belongs to a regular user.
+func (r *userRepository) EnsureBotUser(ctx context.Context, username, displayName string) (*models.User, error) {
+	query := `
+		INSERT INTO users (id, username, password_hash, display_name, is_bot, created_at, updated_at)
+		VALUES ($1, $2, '', $3, true, now(), now())
+		ON CONFLICT (username) DO UPDATE
+		SET display_name = EXCLUDED.display_name, updated_at = now()
+		WHERE users.is_bot
+		RETURNING ` + userColumns + `
+	`
+
+	return scanUser(r.db.QueryRow(ctx, query, uuid.New(), username, displayName))
+}
+
 // Retrieves a user by its username
 func (r *userRepository) GetUserByUsername(ctx context.Context, username string ) (*models.User, error) {
 	query := `
-		SELECT id, username, password_hash, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE username = $1
 	`
 
-	var user models.User
-	err := r.db.QueryRow(ctx, query, username).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &user, nil
+	return scanUser(r.db.QueryRow(ctx, query, username))
 }
 
 func (r *userRepository) DeleteUser(ctx context.Context, id uuid.UUID) error {
@@ -87,33 +138,252 @@ func (r *userRepository) DeleteUser(ctx context.Context, id uuid.UUID) error {
 
 func (r *userRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	query := `
-		SELECT id, username, password_hash, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE id = $1
 	`
 
-	var user models.User
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
+	return scanUser(r.db.QueryRow(ctx, query, id))
+}
+
+// Only writes the given columns, so that overlapping updates of other columns are kept.
+// Anonymized accounts are left untouched, pgx.ErrNoRows is returned for them.
+func (r *userRepository) UpdateUser(ctx context.Context, id uuid.UUID, update *models.UserUpdate) error {
+	query := `
+		UPDATE users
+		SET username = COALESCE($1, username),
+		    display_name = COALESCE($2, display_name),
+		    bio = COALESCE($3, bio),
+		    pronouns = COALESCE($4, pronouns),
+		    timezone = COALESCE($5, timezone),
+		    updated_at = $6
+		WHERE id = $7 AND deleted_at IS NULL
+	`
+	result, err := r.db.Exec(ctx, query,
+		update.Username,
+		update.DisplayName,
+		update.Bio,
+		update.Pronouns,
+		update.Timezone,
+		update.UpdatedAt,
+		id,
 	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// Disables the account at the given time, refusing the tokens issued until then,
+// or enables it back when at is nil
+func (r *userRepository) SetUserDisabled(ctx context.Context, id uuid.UUID, at *time.Time) error {
+	query := `
+		UPDATE users
+		SET disabled_at = $1, tokens_valid_after = COALESCE($1, tokens_valid_after), updated_at = now()
+		WHERE id = $2 AND deleted_at IS NULL
+	`
+	result, err := r.db.Exec(ctx, query, at, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
 
+func (r *userRepository) SetUserAdmin(ctx context.Context, id uuid.UUID, isAdmin bool) error {
+	query := `UPDATE users SET is_admin = $1, updated_at = now() WHERE id = $2 AND deleted_at IS NULL`
+	result, err := r.db.Exec(ctx, query, isAdmin, id)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
 
-	return &user, nil
+// Only touches the avatar, so that a concurrent password change is not written back stale
+func (r *userRepository) SetUserAvatarKey(ctx context.Context, id uuid.UUID, key string) error {
+	query := `UPDATE users SET avatar_key = $1, updated_at = now() WHERE id = $2 AND deleted_at IS NULL`
+	result, err := r.db.Exec(ctx, query, key, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
 }
 
-func (r *userRepository) UpdateUser(ctx context.Context, user *models.User) error {
+// Tokens issued before validAfter are refused. It never moves back, so that an
+// overlapping change or disabling of the account is not undone.
+func (r *userRepository) SetUserPassword(ctx context.Context, id uuid.UUID, hash string, validAfter time.Time) error {
 	query := `
 		UPDATE users
-		SET username = $1, password_hash = $2, updated_at = $3
-		WHERE id = $4
+		SET password_hash = $1, tokens_valid_after = GREATEST(tokens_valid_after, $2), updated_at = now()
+		WHERE id = $3 AND deleted_at IS NULL
+	`
+	result, err := r.db.Exec(ctx, query, hash, validAfter, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// Escapes LIKE wildcards so that user input is matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Retrieves users whose username or display name starts with prefix, case-insensitively.
+// Results are ordered by lowercased username, starting after the given username.
+func (r *userRepository) SearchUsers(ctx context.Context, prefix, after string, limit int) ([]*models.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE (lower(username) LIKE lower($1) || '%' OR lower(display_name) LIKE lower($1) || '%')
+		  AND (lower(username), username) > (lower($2), $2)
+		  AND deleted_at IS NULL
+		  AND NOT is_bot
+		ORDER BY lower(username), username
+		LIMIT $3
 	`
-	_, err := r.db.Exec(ctx, query, user.Username, user.Password, user.UpdatedAt, user.ID)
+
+	rows, err := r.db.Query(ctx, query, likeEscaper.Replace(prefix), after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// Also revokes the account's refresh tokens and deletes its personal access tokens,
+// logging in again is what cancels the deletion. Sessions are revoked by the caller.
+func (r *userRepository) ScheduleUserDeletion(ctx context.Context, id uuid.UUID, at time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `UPDATE users SET deletion_scheduled_at = $1 WHERE id = $2 AND deleted_at IS NULL`, at, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM personal_access_tokens WHERE user_id = $1`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *userRepository) CancelUserDeletion(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE users SET deletion_scheduled_at = NULL WHERE id = $1 AND deleted_at IS NULL`
+	_, err := r.db.Exec(ctx, query, id)
 	return err
 }
+
+// Retrieves accounts whose grace period is over, oldest first
+func (r *userRepository) GetUsersDueForDeletion(ctx context.Context, now time.Time, limit int) ([]*models.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE deletion_scheduled_at <= $1 AND deleted_at IS NULL
+		ORDER BY deletion_scheduled_at
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// Wipes the account's personal data while keeping the row, so that its messages
+// survive under a placeholder instead of being cascaded away.
+// Memberships, blocks, access and refresh tokens are removed, sessions are revoked. Returns false when the deletion was cancelled
+// or is not due anymore.
+func (r *userRepository) AnonymizeUser(ctx context.Context, id uuid.UUID, now time.Time) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	// An empty password hash never matches, so the account cannot be logged into
+	result, err := tx.Exec(ctx, `
+		UPDATE users
+		SET username = 'deleted-' || replace(id::text, '-', ''), password_hash = '',
+		    display_name = $1, bio = '', pronouns = '', timezone = '', avatar_key = '',
+		    deletion_scheduled_at = NULL, deleted_at = $2, updated_at = $2, tokens_valid_after = $2
+		WHERE id = $3 AND deleted_at IS NULL AND deletion_scheduled_at <= $2
+	`, models.DeletedUserDisplayName, now, id)
+	if err != nil {
+		return false, err
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM conversation_members WHERE user_id = $1`, id); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM user_blocks WHERE blocker_id = $1 OR blocked_id = $1`, id); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM personal_access_tokens WHERE user_id = $1`, id); err != nil {
+		return false, err
+	}
+	// Sessions are revoked rather than deleted, so that servers close their connections
+	// when syncing. Their device details are wiped, the rows go once expired.
+	if _, err := tx.Exec(ctx, `DELETE FROM refresh_tokens WHERE user_id = $1`, id); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE sessions
+		SET revoked_at = COALESCE(revoked_at, $2), device_name = '', user_agent = '', ip_address = ''
+		WHERE user_id = $1
+	`, id, now); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM e2e_devices WHERE user_id = $1`, id); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
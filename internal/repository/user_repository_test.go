@@ -2,9 +2,14 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/EliasLd/gotalk-backend/internal/database"
+	"github.com/EliasLd/gotalk-backend/internal/models"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
 func TestCreateUser(t *testing.T) {
@@ -119,3 +124,155 @@ func TestCreateUserSameUsername(t *testing.T) {
 		t.Errorf("Expected error when adding second user with the same name")
 	}
 }
+
+func TestSetUserDisabled(t *testing.T) {
+	repo := SetupTest(t)
+
+	user := NewTestUser(t, "testuser_set_disabled")
+	if err := repo.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	defer CleanUpUser(t, user.ID, repo)
+
+	now := time.Now().UTC()
+	if err := repo.SetUserDisabled(context.Background(), user.ID, &now); err != nil {
+		t.Fatalf("Failed to disable user: %v", err)
+	}
+
+	fetched, err := repo.GetUserByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch user: %v", err)
+	}
+	if !fetched.IsDisabled() {
+		t.Errorf("Expected the user to be disabled")
+	}
+
+	if err := repo.SetUserDisabled(context.Background(), user.ID, nil); err != nil {
+		t.Fatalf("Failed to enable user: %v", err)
+	}
+
+	fetched, err = repo.GetUserByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch user: %v", err)
+	}
+	if fetched.IsDisabled() {
+		t.Errorf("Expected the user to be enabled, disabled at %v", fetched.DisabledAt)
+	}
+
+	if err := repo.SetUserDisabled(context.Background(), uuid.New(), &now); err == nil {
+		t.Errorf("Expected error when disabling a non-existent user, got nil")
+	}
+}
+
+func TestUpdateUser_OnlyChangedColumns(t *testing.T) {
+	repo := SetupTest(t)
+	ctx := context.Background()
+
+	user := NewTestUser(t, "testuser_update")
+	if err := repo.CreateUser(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	defer CleanUpUser(t, user.ID, repo)
+
+	bio := "hello"
+	if err := repo.UpdateUser(ctx, user.ID, &models.UserUpdate{Bio: &bio, UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("UpdateUser failed: %v", err)
+	}
+
+	fetched, err := repo.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch user: %v", err)
+	}
+	if fetched.Bio != bio || fetched.Username != user.Username || fetched.Password != user.Password {
+		t.Errorf("Expected only the bio to change, got %+v", fetched)
+	}
+
+	// Anonymized accounts are not written anymore
+	if _, err := repo.AnonymizeUser(ctx, user.ID, time.Now()); err != nil {
+		t.Fatalf("AnonymizeUser failed: %v", err)
+	}
+	err = repo.UpdateUser(ctx, user.ID, &models.UserUpdate{Bio: &bio, UpdatedAt: time.Now()})
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("Expected pgx.ErrNoRows when updating an anonymized user, got %v", err)
+	}
+}
+
+func TestScheduleUserDeletion_RevokesAccessTokens(t *testing.T) {
+	repo := SetupTest(t)
+	tokenRepo := NewAccessTokenRepository(database.DB)
+	ctx := context.Background()
+
+	user := NewTestUser(t, "testuser_schedule_deletion")
+	if err := repo.CreateUser(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	defer CleanUpUser(t, user.ID, repo)
+
+	token := &models.AccessToken {
+		ID:		uuid.New(),
+		UserID:		user.ID,
+		Name:		"script",
+		TokenHash:	uuid.NewString(),
+		Scopes:		[]string{"messages:read"},
+		CreatedAt:	time.Now().UTC(),
+	}
+	if err := tokenRepo.CreateAccessToken(ctx, token); err != nil {
+		t.Fatalf("CreateAccessToken failed: %v", err)
+	}
+
+	if err := repo.ScheduleUserDeletion(ctx, user.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("ScheduleUserDeletion failed: %v", err)
+	}
+
+	tokens, err := tokenRepo.GetAccessTokensByUser(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetAccessTokensByUser failed: %v", err)
+	}
+	if len(tokens) != 0 {
+		t.Errorf("Expected the access tokens to be revoked, got %d", len(tokens))
+	}
+
+	if err := repo.ScheduleUserDeletion(ctx, uuid.New(), time.Now()); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("Expected pgx.ErrNoRows for a non-existent user, got %v", err)
+	}
+}
+
+func TestSetUserPassword_KeepsLaterValidAfter(t *testing.T) {
+	repo := SetupTest(t)
+	ctx := context.Background()
+
+	user := NewTestUser(t, "testuser_set_password")
+	if err := repo.CreateUser(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	defer CleanUpUser(t, user.ID, repo)
+
+	// Disabled after the password change started
+	disabledAt := time.Now().UTC().Truncate(time.Microsecond)
+	if err := repo.SetUserDisabled(ctx, user.ID, &disabledAt); err != nil {
+		t.Fatalf("Failed to disable user: %v", err)
+	}
+
+	if err := repo.SetUserPassword(ctx, user.ID, "new-hash", disabledAt.Add(-time.Second)); err != nil {
+		t.Fatalf("SetUserPassword failed: %v", err)
+	}
+
+	fetched, err := repo.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch user: %v", err)
+	}
+	if fetched.Password != "new-hash" {
+		t.Errorf("Expected the password hash to be replaced, got %q", fetched.Password)
+	}
+	if fetched.TokensValidAfter == nil || !fetched.TokensValidAfter.Equal(disabledAt) {
+		t.Errorf("Expected tokens valid after %v, got %v", disabledAt, fetched.TokensValidAfter)
+	}
+
+	if err := repo.SetUserPassword(ctx, uuid.New(), "new-hash", time.Now()); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("Expected pgx.ErrNoRows for a non-existent user, got %v", err)
+	}
+}
@@ -0,0 +1,318 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/google/uuid"
+)
+
+// Contract for any kind of webhook data access implementation.
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	GetWebhookByID(ctx context.Context, id uuid.UUID) (*models.Webhook, error)
+	GetWebhooksByOwner(ctx context.Context, ownerID uuid.UUID) ([]*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	EnqueueDeliveries(ctx context.Context, event string, conversationID *uuid.UUID, payload []byte, expiresAt *time.Time) (int64, error)
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetDeliveryByID(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error)
+	GetDeliveriesByWebhook(ctx context.Context, webhookID uuid.UUID, limit int) ([]*models.WebhookDelivery, error)
+	ClaimDueDeliveries(ctx context.Context, lease time.Duration, limit int) ([]*models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	DeleteFinishedDeliveries(ctx context.Context, before time.Time) (int64, error)
+	DeleteExpiredDeliveries(ctx context.Context, now time.Time) (int64, error)
+	IsWebhookOwnerAuthorized(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+// Concrete implementation of WebhookRepository
+type webhookRepository struct {
+	db *pgxpool.Pool
+}
+
+// Constructor, returns a new instance of the repository
+func NewWebhookRepository(db *pgxpool.Pool) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+// Matches webhooks whose creator may still manage them: an admin of their conversation,
+// or a server administrator for server-wide ones. Expects the webhooks table aliased as w.
+const webhookOwnerAuthorized = `
+	EXISTS (
+		SELECT 1 FROM users u
+		WHERE u.id = w.owner_id AND u.deleted_at IS NULL AND u.disabled_at IS NULL
+		  AND CASE
+			WHEN w.conversation_id IS NULL THEN u.is_admin
+			ELSE EXISTS (
+				SELECT 1 FROM conversation_members m
+				WHERE m.conversation_id = w.conversation_id AND m.user_id = w.owner_id
+				  AND m.role IN ('owner', 'admin')
+			)
+		  END
+	)`
+
+const webhookColumns = `id, owner_id, conversation_id, url, secret, events, created_at`
+
+func scanWebhook(row pgx.Row) (*models.Webhook, error) {
+	var webhook models.Webhook
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.OwnerID,
+		&webhook.ConversationID,
+		&webhook.URL,
+		&webhook.Secret,
+		&webhook.Events,
+		&webhook.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+const deliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at, expires_at`
+
+func scanDelivery(row pgx.Row) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.Event,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastStatusCode,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&delivery.DeliveredAt,
+		&delivery.ExpiresAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+func scanDeliveries(rows pgx.Rows) ([]*models.WebhookDelivery, error) {
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (r *webhookRepository) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	query := `
+		INSERT INTO webhooks (id, owner_id, conversation_id, url, secret, events, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		webhook.ID,
+		webhook.OwnerID,
+		webhook.ConversationID,
+		webhook.URL,
+		webhook.Secret,
+		webhook.Events,
+		webhook.CreatedAt,
+	)
+
+	return err
+}
+
+func (r *webhookRepository) GetWebhookByID(ctx context.Context, id uuid.UUID) (*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1`
+	return scanWebhook(r.db.QueryRow(ctx, query, id))
+}
+
+func (r *webhookRepository) GetWebhooksByOwner(ctx context.Context, ownerID uuid.UUID) ([]*models.Webhook, error) {
+	query := `
+		SELECT ` + webhookColumns + `
+		FROM webhooks
+		WHERE owner_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*models.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// Deletes the webhook along with its delivery log
+func (r *webhookRepository) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM webhooks WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
+
+// Queues a delivery of the event for every subscribed webhook: those of the conversation,
+// if any, and the server-wide ones. Events of direct conversations are private to their
+// two members and never sent to server-wide webhooks. Returns the number of queued deliveries.
+// expiresAt is set when the payload holds a disappearing message.
+func (r *webhookRepository) EnqueueDeliveries(ctx context.Context, event string, conversationID *uuid.UUID, payload []byte, expiresAt *time.Time) (int64, error) {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, expires_at)
+		SELECT w.id, $1, $2, $4
+		FROM webhooks w
+		WHERE $1 = ANY(w.events)
+		  AND (
+			w.conversation_id = $3
+			OR (w.conversation_id IS NULL AND NOT EXISTS (
+				SELECT 1 FROM conversations c WHERE c.id = $3 AND c.is_direct
+			))
+		  )
+		  AND ` + webhookOwnerAuthorized
+
+	result, err := r.db.Exec(ctx, query, event, payload, conversationID, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (r *webhookRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (id, webhook_id, event, payload, status, next_attempt_at, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		delivery.ID,
+		delivery.WebhookID,
+		delivery.Event,
+		delivery.Payload,
+		delivery.Status,
+		delivery.NextAttemptAt,
+		delivery.CreatedAt,
+		delivery.ExpiresAt,
+	)
+
+	return err
+}
+
+func (r *webhookRepository) GetDeliveryByID(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE id = $1`
+	return scanDelivery(r.db.QueryRow(ctx, query, id))
+}
+
+// Retrieves the webhook's most recent deliveries, newest first
+func (r *webhookRepository) GetDeliveriesByWebhook(ctx context.Context, webhookID uuid.UUID, limit int) ([]*models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanDeliveries(rows)
+}
+
+// Returns pending deliveries whose next attempt is due, pushing that attempt back by lease
+// so that other workers leave them alone while they are being sent.
+// Those holding an expired message are left out, they are about to be deleted.
+func (r *webhookRepository) ClaimDueDeliveries(ctx context.Context, lease time.Duration, limit int) ([]*models.WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = now() + make_interval(secs => $1)
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			  AND (expires_at IS NULL OR expires_at > now())
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + deliveryColumns
+
+	rows, err := r.db.Query(ctx, query, lease.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanDeliveries(rows)
+}
+
+// Records the outcome of an attempt
+func (r *webhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, next_attempt_at = $3, last_status_code = $4,
+		    last_error = $5, delivered_at = $6
+		WHERE id = $7
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.LastStatusCode,
+		delivery.LastError,
+		delivery.DeliveredAt,
+		delivery.ID,
+	)
+
+	return err
+}
+
+// Deletes the succeeded and failed deliveries created before the given instant
+func (r *webhookRepository) DeleteFinishedDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < $1`
+	result, err := r.db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// Deletes the deliveries whose disappearing message has expired, whatever their status
+func (r *webhookRepository) DeleteExpiredDeliveries(ctx context.Context, now time.Time) (int64, error) {
+	query := `DELETE FROM webhook_deliveries WHERE expires_at <= $1`
+	result, err := r.db.Exec(ctx, query, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// Reports whether the webhook's creator still holds the role needed to create it
+func (r *webhookRepository) IsWebhookOwnerAuthorized(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `SELECT ` + webhookOwnerAuthorized + ` FROM webhooks w WHERE w.id = $1`
+
+	var authorized bool
+	err := r.db.QueryRow(ctx, query, id).Scan(&authorized)
+	return authorized, err
+}
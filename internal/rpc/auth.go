@@ -0,0 +1,104 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/EliasLd/gotalk-backend/internal/auth"
+	"github.com/EliasLd/gotalk-backend/internal/service"
+	"github.com/google/uuid"
+)
+
+type contextKey struct{}
+
+type claimsKey struct{}
+
+// RPCs reachable without a JWT, by full method name
+var publicMethods = map[string]bool {
+	"/gotalk.v1.UserService/Register":	true,
+	"/gotalk.v1.UserService/Login":		true,
+	"/gotalk.v1.UserService/RefreshToken":	true,
+}
+
+// Authenticates RPCs, rejecting revoked JWTs when revocations is not nil
+type authInterceptor struct {
+	revocations	service.TokenRevocationService
+}
+
+// Validates the JWT of the "authorization" metadata, formatted as "Bearer <token>",
+// and returns a context carrying the user's ID
+func (a *authInterceptor) authenticate(ctx context.Context) (context.Context, *auth.Claims, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return nil, nil, status.Error(codes.Unauthenticated, "missing or invalid authorization metadata")
+	}
+
+	claims, err := auth.ValidateToken(strings.TrimPrefix(values[0], "Bearer "))
+	if err != nil || (a.revocations != nil && a.revocations.IsRevoked(claims)) {
+		return nil, nil, status.Error(codes.Unauthenticated, "invalid or expired token")
+	}
+
+	ctx = context.WithValue(ctx, contextKey{}, claims.UserID)
+	return context.WithValue(ctx, claimsKey{}, claims), claims, nil
+}
+
+// Returns the ID of the user authenticated by the interceptors
+func userIDFromContext(ctx context.Context) (uuid.UUID, error) {
+	userID, ok := ctx.Value(contextKey{}).(uuid.UUID)
+	if !ok {
+		return uuid.Nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	return userID, nil
+}
+
+// Returns the claims of the JWT authenticated by the interceptors
+func claimsFromContext(ctx context.Context) (*auth.Claims, error) {
+	claims, ok := ctx.Value(claimsKey{}).(*auth.Claims)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	return claims, nil
+}
+
+func (a *authInterceptor) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if publicMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	ctx, _, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// Overrides the context of a server stream
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx	context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (a *authInterceptor) stream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, claims, err := a.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	// Streams end as soon as the session is revoked
+	if a.revocations != nil {
+		var release context.CancelFunc
+		ctx, release = a.revocations.WatchSession(ctx, claims.SessionID)
+		defer release()
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
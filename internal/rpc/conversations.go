@@ -0,0 +1,69 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/rpc/gotalkv1"
+	"github.com/EliasLd/gotalk-backend/internal/service"
+)
+
+type conversationServer struct {
+	gotalkv1.UnimplementedConversationServiceServer
+	conversations	service.ConversationService
+}
+
+func newConversation(conversation *models.Conversation) *gotalkv1.Conversation {
+	return &gotalkv1.Conversation {
+		Id:			conversation.ID.String(),
+		IsPublic:		conversation.IsPublic,
+		IsDirect:		conversation.IsDirect,
+		Name:			conversation.Name,
+		Topic:			conversation.Topic,
+		SlowModeSeconds:	int32(conversation.SlowModeSeconds),
+		CreatedAt:		timestamppb.New(conversation.CreatedAt),
+	}
+}
+
+func (s *conversationServer) OpenDirectConversation(ctx context.Context, req *gotalkv1.OpenDirectConversationRequest) (*gotalkv1.OpenDirectConversationResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	otherID, err := parseID("user_id", req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+
+	conversation, created, err := s.conversations.OpenDirectConversation(ctx, userID, otherID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &gotalkv1.OpenDirectConversationResponse {
+		Conversation:	newConversation(conversation),
+		Created:	created,
+	}, nil
+}
+
+func (s *conversationServer) SetSlowMode(ctx context.Context, req *gotalkv1.SetSlowModeRequest) (*gotalkv1.SetSlowModeResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conversationID, err := parseID("conversation_id", req.GetConversationId())
+	if err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(req.GetIntervalSeconds()) * time.Second
+	if err := s.conversations.SetSlowMode(ctx, userID, conversationID, interval); err != nil {
+		return nil, toStatus(err)
+	}
+	return &gotalkv1.SetSlowModeResponse{}, nil
+}
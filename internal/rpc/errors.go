@@ -0,0 +1,90 @@
+package rpc
+
+import (
+	"errors"
+	"log"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+// Maps service errors to gRPC status errors, along the lines of the REST status codes.
+// Unexpected errors are logged and reported as internal.
+func toStatus(err error) error {
+	var slowModeErr *appErr.SlowModeError
+
+	switch {
+	case errors.As(err, &slowModeErr):
+		st := status.New(codes.ResourceExhausted, err.Error())
+		if detailed, detailsErr := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(slowModeErr.RetryAfter)}); detailsErr == nil {
+			st = detailed
+		}
+		return st.Err()
+	case errors.Is(err, appErr.ErrUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, appErr.ErrInvalidCredentials):
+		return status.Error(codes.Unauthenticated, "invalid username or password")
+	case errors.Is(err, appErr.ErrInvalidRefreshToken),
+		errors.Is(err, appErr.ErrRefreshTokenReused):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, appErr.ErrUserDisabled):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, appErr.ErrNotConversationMember),
+		errors.Is(err, appErr.ErrConversationAdminRequired),
+		errors.Is(err, appErr.ErrMemberMuted),
+		errors.Is(err, appErr.ErrDirectConversationClosed),
+		errors.Is(err, appErr.ErrCannotMuteAdmin):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, appErr.ErrUserAlreadyExists),
+		errors.Is(err, appErr.ErrAlreadyMember):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, appErr.ErrCommandFailed):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, appErr.ErrMessageRejected):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, appErr.ErrPasswordTooShort),
+		errors.Is(err, appErr.ErrPasswordMissingDigit),
+		errors.Is(err, appErr.ErrPasswordMissingUpper),
+		errors.Is(err, appErr.ErrPasswordMissingLower),
+		errors.Is(err, appErr.ErrPasswordMissingSymbol),
+		errors.Is(err, appErr.ErrDisplayNameInvalid),
+		errors.Is(err, appErr.ErrBioInvalid),
+		errors.Is(err, appErr.ErrPronounsInvalid),
+		errors.Is(err, appErr.ErrTimezoneInvalid),
+		errors.Is(err, appErr.ErrInvalidSearchQuery),
+		errors.Is(err, appErr.ErrMessageEmpty),
+		errors.Is(err, appErr.ErrMessageTooLong),
+		errors.Is(err, appErr.ErrMessageUnsafeLink),
+		errors.Is(err, appErr.ErrMessageMalformed),
+		errors.Is(err, appErr.ErrMessageTTLOutOfRange),
+		errors.Is(err, appErr.ErrSlowModeOutOfRange),
+		errors.Is(err, appErr.ErrCannotMessageSelf),
+		errors.Is(err, appErr.ErrUnknownCommand),
+		errors.Is(err, appErr.ErrCommandUsage),
+		errors.Is(err, appErr.ErrTopicInvalid),
+		errors.Is(err, appErr.ErrDirectConversationMembers),
+		errors.Is(err, appErr.ErrMuteDurationOutOfRange),
+		errors.Is(err, appErr.ErrContentTypeInvalid),
+		errors.Is(err, appErr.ErrEncryptedRequiresDirect),
+		errors.Is(err, appErr.ErrEncryptedMessageTooLarge),
+		errors.Is(err, appErr.ErrDeviceNameInvalid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		log.Printf("rpc: %v", err)
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
+
+// Parses an ID field of a request
+func parseID(field, raw string) (uuid.UUID, error) {
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "invalid %s", field)
+	}
+	return id, nil
+}
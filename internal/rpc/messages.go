@@ -0,0 +1,176 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/EliasLd/gotalk-backend/internal/events"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/rpc/gotalkv1"
+	"github.com/EliasLd/gotalk-backend/internal/service"
+)
+
+type messageServer struct {
+	gotalkv1.UnimplementedMessageServiceServer
+	messages	service.MessageService
+}
+
+func newMessage(message *models.Message) *gotalkv1.Message {
+	resp := &gotalkv1.Message {
+		Id:		message.ID.String(),
+		ConversationId:	message.ConversationID.String(),
+		SenderId:	message.SenderID.String(),
+		Content:	message.Content,
+		ContentType:	string(message.ContentType),
+		MentionIds:	make([]string, 0, len(message.MentionIDs)),
+		CreatedAt:	timestamppb.New(message.CreatedAt),
+	}
+
+	for _, id := range message.MentionIDs {
+		resp.MentionIds = append(resp.MentionIds, id.String())
+	}
+
+	if message.ExpiresAt != nil {
+		resp.ExpiresAt = timestamppb.New(*message.ExpiresAt)
+	}
+
+	return resp
+}
+
+func newConversationEvent(event events.Event) *gotalkv1.ConversationEvent {
+	resp := &gotalkv1.ConversationEvent {
+		Type:		event.Type,
+		ConversationId:	event.ConversationID.String(),
+	}
+
+	switch payload := event.Payload.(type) {
+	case *models.Message:
+		if event.Type == events.MessageDeleted {
+			resp.Payload = &gotalkv1.ConversationEvent_DeletedMessageId{DeletedMessageId: payload.ID.String()}
+		} else {
+			resp.Payload = &gotalkv1.ConversationEvent_Message{Message: newMessage(payload)}
+		}
+	case *models.Conversation:
+		resp.Payload = &gotalkv1.ConversationEvent_Conversation{Conversation: newConversation(payload)}
+	}
+
+	return resp
+}
+
+func (s *messageServer) SendMessage(ctx context.Context, req *gotalkv1.SendMessageRequest) (*gotalkv1.SendMessageResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conversationID, err := parseID("conversation_id", req.GetConversationId())
+	if err != nil {
+		return nil, err
+	}
+
+	input := service.SendMessageInput {
+		Content:	req.GetContent(),
+		ContentType:	models.ContentType(req.GetContentType()),
+	}
+	if req.TtlSeconds != nil {
+		ttl := time.Duration(req.GetTtlSeconds()) * time.Second
+		input.TTL = &ttl
+	}
+
+	result, err := s.messages.SendMessage(ctx, userID, conversationID, input)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &gotalkv1.SendMessageResponse {
+		Command:	result.Command,
+		Reply:		result.Reply,
+	}
+	if result.Message != nil {
+		resp.Message = newMessage(result.Message)
+	}
+	return resp, nil
+}
+
+func (s *messageServer) ListMessages(ctx context.Context, req *gotalkv1.ListMessagesRequest) (*gotalkv1.ListMessagesResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conversationID, err := parseID("conversation_id", req.GetConversationId())
+	if err != nil {
+		return nil, err
+	}
+
+	var before *time.Time
+	if req.Before != nil {
+		parsed := req.GetBefore().AsTime()
+		before = &parsed
+	}
+
+	messages, err := s.messages.GetMessages(ctx, userID, conversationID, before, int(req.GetLimit()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &gotalkv1.ListMessagesResponse{Messages: make([]*gotalkv1.Message, 0, len(messages))}
+	for _, message := range messages {
+		resp.Messages = append(resp.Messages, newMessage(message))
+	}
+	return resp, nil
+}
+
+func (s *messageServer) ListCommands(ctx context.Context, req *gotalkv1.ListCommandsRequest) (*gotalkv1.ListCommandsResponse, error) {
+	resp := &gotalkv1.ListCommandsResponse{}
+	for _, command := range s.messages.ListCommands() {
+		resp.Commands = append(resp.Commands, &gotalkv1.Command {
+			Name:		command.Name,
+			Usage:		command.Usage,
+			Description:	command.Description,
+		})
+	}
+	return resp, nil
+}
+
+// Streams the conversation's events until the client cancels or the server stops
+func (s *messageServer) SubscribeConversation(req *gotalkv1.SubscribeConversationRequest, stream gotalkv1.MessageService_SubscribeConversationServer) error {
+	ctx := stream.Context()
+
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	conversationID, err := parseID("conversation_id", req.GetConversationId())
+	if err != nil {
+		return err
+	}
+
+	eventStream, unsubscribe, err := s.messages.Subscribe(ctx, userID, conversationID)
+	if err != nil {
+		return toStatus(err)
+	}
+	defer unsubscribe()
+
+	// Headers are sent right away so that clients know the subscription is live
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-eventStream:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(newConversationEvent(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
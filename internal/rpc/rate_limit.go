@@ -0,0 +1,42 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/EliasLd/gotalk-backend/internal/http/middleware"
+)
+
+// Limits RPCs per authenticated user, by full method name.
+// Must run after the auth interceptor.
+type rateLimitInterceptor struct {
+	limiters	map[string]*middleware.RateLimiter
+}
+
+func (l *rateLimitInterceptor) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	limiter, ok := l.limiters[info.FullMethod]
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, wait := limiter.Allow(userID.String())
+	if !allowed {
+		st := status.New(codes.ResourceExhausted, "too many requests")
+		if detailed, detailsErr := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)}); detailsErr == nil {
+			st = detailed
+		}
+		return nil, st.Err()
+	}
+
+	return handler(ctx, req)
+}
@@ -0,0 +1,40 @@
+// Package rpc serves the gRPC API, a typed counterpart of the REST endpoints
+// built on the same services.
+package rpc
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=github.com/EliasLd/gotalk-backend --go-grpc_out=../.. --go-grpc_opt=module=github.com/EliasLd/gotalk-backend gotalk/v1/gotalk.proto
+
+import (
+	"google.golang.org/grpc"
+
+	"github.com/EliasLd/gotalk-backend/internal/http/middleware"
+	"github.com/EliasLd/gotalk-backend/internal/rpc/gotalkv1"
+	"github.com/EliasLd/gotalk-backend/internal/service"
+)
+
+// Creates a gRPC server exposing the user, conversation and message services.
+// Every RPC but Register, Login and RefreshToken requires a JWT, not revoked when revocations is not nil.
+// Login opens a session through refreshTokenService, which renews its tokens, and Logout ends it through sessionService.
+// searchLimiter limits SearchUsers, shared with the REST API. A new one is used when nil.
+func NewServer(userService service.UserService, conversationService service.ConversationService, messageService service.MessageService, refreshTokenService service.RefreshTokenService, sessionService service.SessionService, revocations service.TokenRevocationService, searchLimiter *middleware.RateLimiter) *grpc.Server {
+	if searchLimiter == nil {
+		searchLimiter = middleware.NewSearchRateLimiter()
+	}
+
+	interceptor := &authInterceptor{revocations: revocations}
+	limits := &rateLimitInterceptor {
+		limiters:	map[string]*middleware.RateLimiter {
+			"/gotalk.v1.UserService/SearchUsers":	searchLimiter,
+		},
+	}
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(interceptor.unary, limits.unary),
+		grpc.ChainStreamInterceptor(interceptor.stream),
+	)
+
+	gotalkv1.RegisterUserServiceServer(server, &userServer{users: userService, refreshTokens: refreshTokenService, sessions: sessionService, revocations: revocations})
+	gotalkv1.RegisterConversationServiceServer(server, &conversationServer{conversations: conversationService})
+	gotalkv1.RegisterMessageServiceServer(server, &messageServer{messages: messageService})
+
+	return server
+}
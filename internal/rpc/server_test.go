@@ -0,0 +1,346 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/EliasLd/gotalk-backend/internal/auth"
+	"github.com/EliasLd/gotalk-backend/internal/events"
+	"github.com/EliasLd/gotalk-backend/internal/http/middleware"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/rpc/gotalkv1"
+	"github.com/EliasLd/gotalk-backend/internal/service"
+	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+// MessageService relaying the events of an in-memory broker to members only
+type fakeMessageService struct {
+	service.MessageService
+	broker	events.Broker
+	member	uuid.UUID
+}
+
+func (s *fakeMessageService) Subscribe(ctx context.Context, userID, conversationID uuid.UUID) (<-chan events.Event, func(), error) {
+	if userID != s.member {
+		return nil, nil, appErr.ErrNotConversationMember
+	}
+	stream, unsubscribe := s.broker.Subscribe(conversationID)
+	return stream, unsubscribe, nil
+}
+
+func (s *fakeMessageService) ListCommands() []service.CommandInfo {
+	return []service.CommandInfo{{Name: "me", Usage: "/me <action>"}}
+}
+
+// Starts the server on an in-memory listener and returns a connected client
+func newTestClient(t *testing.T, messages service.MessageService) *grpc.ClientConn {
+	auth.SetupTestKeys(t)
+	return dialTestServer(t, NewServer(nil, nil, messages, nil, nil, nil, nil))
+}
+
+func dialTestServer(t *testing.T, server *grpc.Server) *grpc.ClientConn {
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial test server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func withToken(t *testing.T, ctx context.Context, userID uuid.UUID) context.Context {
+	token, err := auth.GenerateToken(&models.User{ID: userID}, uuid.New())
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func TestAuthentication(t *testing.T) {
+	client := gotalkv1.NewMessageServiceClient(newTestClient(t, &fakeMessageService{}))
+
+	_, err := client.ListCommands(context.Background(), &gotalkv1.ListCommandsRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without a token, got %v", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer invalid")
+	_, err = client.ListCommands(ctx, &gotalkv1.ListCommandsRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated with an invalid token, got %v", err)
+	}
+
+	resp, err := client.ListCommands(withToken(t, context.Background(), uuid.New()), &gotalkv1.ListCommandsRequest{})
+	if err != nil {
+		t.Fatalf("ListCommands failed: %v", err)
+	}
+	if len(resp.Commands) != 1 || resp.Commands[0].Name != "me" {
+		t.Errorf("Unexpected commands: %v", resp.Commands)
+	}
+}
+
+func TestSubscribeConversation(t *testing.T) {
+	broker := events.NewBroker()
+	member := uuid.New()
+	client := gotalkv1.NewMessageServiceClient(newTestClient(t, &fakeMessageService{broker: broker, member: member}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conversationID := uuid.New()
+	req := &gotalkv1.SubscribeConversationRequest{ConversationId: conversationID.String()}
+
+	// Errors surface on the first receive
+	stream, err := client.SubscribeConversation(withToken(t, ctx, uuid.New()), req)
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied for non-members, got %v", err)
+	}
+
+	stream, err = client.SubscribeConversation(withToken(t, ctx, member), req)
+	if err != nil {
+		t.Fatalf("SubscribeConversation failed: %v", err)
+	}
+	// Headers are sent once the subscription is registered
+	if _, err := stream.Header(); err != nil {
+		t.Fatalf("Failed to receive headers: %v", err)
+	}
+
+	message := &models.Message{ID: uuid.New(), ConversationID: conversationID, Content: "hello", ContentType: models.ContentTypeText}
+	broker.Publish(events.Event{Type: events.MessageCreated, ConversationID: conversationID, Payload: message})
+	broker.Publish(events.Event{Type: events.MessageDeleted, ConversationID: conversationID, Payload: message})
+
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if event.Type != events.MessageCreated || event.GetMessage().GetContent() != "hello" {
+		t.Errorf("Unexpected event: %v", event)
+	}
+
+	event, err = stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if event.Type != events.MessageDeleted || event.GetDeletedMessageId() != message.ID.String() {
+		t.Errorf("Unexpected event: %v", event)
+	}
+}
+
+func TestRateLimitInterceptor(t *testing.T) {
+	limits := &rateLimitInterceptor {
+		limiters:	map[string]*middleware.RateLimiter {
+			"/gotalk.v1.UserService/SearchUsers":	middleware.NewRateLimiter(1, time.Minute, 1),
+		},
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+	ctx := context.WithValue(context.Background(), contextKey{}, uuid.New())
+	search := &grpc.UnaryServerInfo{FullMethod: "/gotalk.v1.UserService/SearchUsers"}
+
+	if _, err := limits.unary(ctx, nil, search, handler); err != nil {
+		t.Fatalf("Expected the first search to pass, got %v", err)
+	}
+
+	st := status.Convert(func() error {
+		_, err := limits.unary(ctx, nil, search, handler)
+		return err
+	}())
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("Expected ResourceExhausted, got %v", st.Code())
+	}
+	if details := st.Details(); len(details) != 1 {
+		t.Errorf("Expected a RetryInfo detail, got %v", details)
+	}
+
+	// Other users and other RPCs have their own budget
+	other := context.WithValue(context.Background(), contextKey{}, uuid.New())
+	if _, err := limits.unary(other, nil, search, handler); err != nil {
+		t.Errorf("Expected another user's search to pass, got %v", err)
+	}
+	if _, err := limits.unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/gotalk.v1.UserService/GetUser"}, handler); err != nil {
+		t.Errorf("Expected unlimited RPCs to pass, got %v", err)
+	}
+}
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		err	error
+		want	codes.Code
+	}{
+		{appErr.ErrUserNotFound, codes.NotFound},
+		{appErr.ErrInvalidCredentials, codes.Unauthenticated},
+		{appErr.ErrNotConversationMember, codes.PermissionDenied},
+		{appErr.ErrUserAlreadyExists, codes.AlreadyExists},
+		{appErr.ErrMessageTooLong, codes.InvalidArgument},
+		{&appErr.UnknownCommandError{Name: "shrug"}, codes.InvalidArgument},
+		{appErr.ErrMessageRejected, codes.FailedPrecondition},
+		{appErr.ErrCommandFailed, codes.Unavailable},
+		{context.DeadlineExceeded, codes.Internal},
+	}
+
+	for _, tt := range tests {
+		if got := status.Code(toStatus(tt.err)); got != tt.want {
+			t.Errorf("toStatus(%v): expected %v, got %v", tt.err, tt.want, got)
+		}
+	}
+
+	// Slow mode tells clients when to retry
+	st := status.Convert(toStatus(&appErr.SlowModeError{RetryAfter: 3 * time.Second}))
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("Expected ResourceExhausted, got %v", st.Code())
+	}
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("Expected a RetryInfo detail, got %v", details)
+	}
+	if info, ok := details[0].(*errdetails.RetryInfo); !ok || info.RetryDelay.AsDuration() != 3*time.Second {
+		t.Errorf("Expected a 3s retry delay, got %v", details[0])
+	}
+}
+
+// UserService accepting a single password
+type fakeUserService struct {
+	service.UserService
+	user	*models.User
+}
+
+func (s *fakeUserService) AuthenticateUser(ctx context.Context, username, password string) (*models.User, error) {
+	if username != s.user.Username || password != "secret" {
+		return nil, appErr.ErrInvalidCredentials
+	}
+	return s.user, nil
+}
+
+// RefreshTokenService issuing numbered refresh tokens, each usable once
+type fakeRefreshTokenService struct {
+	service.RefreshTokenService
+	issued		int
+	valid		map[string]uuid.UUID
+	revoked		[]string
+	sessionID	uuid.UUID
+}
+
+func (s *fakeRefreshTokenService) issue(userID uuid.UUID) (*service.TokenPair, error) {
+	token, err := auth.GenerateToken(&models.User{ID: userID}, s.sessionID)
+	if err != nil {
+		return nil, err
+	}
+	s.issued++
+	refreshToken := "refresh-" + strconv.Itoa(s.issued)
+	s.valid[refreshToken] = userID
+	return &service.TokenPair {
+		AccessToken:		token,
+		ExpiresIn:		15 * time.Minute,
+		RefreshToken:		refreshToken,
+		RefreshTokenExpiresAt:	time.Now().Add(time.Hour),
+		SessionID:		s.sessionID,
+	}, nil
+}
+
+func (s *fakeRefreshTokenService) IssueTokens(ctx context.Context, user *models.User, device service.SessionDevice) (*service.TokenPair, error) {
+	if err := service.ValidateDeviceName(device.Name); err != nil {
+		return nil, err
+	}
+	return s.issue(user.ID)
+}
+
+func (s *fakeRefreshTokenService) RefreshTokens(ctx context.Context, refreshToken string, ipAddress string) (*service.TokenPair, error) {
+	userID, ok := s.valid[refreshToken]
+	if !ok {
+		return nil, appErr.ErrInvalidRefreshToken
+	}
+	delete(s.valid, refreshToken)
+	return s.issue(userID)
+}
+
+func (s *fakeRefreshTokenService) RevokeRefreshToken(ctx context.Context, userID uuid.UUID, refreshToken string) error {
+	delete(s.valid, refreshToken)
+	s.revoked = append(s.revoked, refreshToken)
+	return nil
+}
+
+// SessionService recording revoked sessions
+type fakeSessionService struct {
+	service.SessionService
+	revoked	[]uuid.UUID
+}
+
+func (s *fakeSessionService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	s.revoked = append(s.revoked, sessionID)
+	return nil
+}
+
+func TestLoginRefreshLogout(t *testing.T) {
+	auth.SetupTestKeys(t)
+	user := &models.User{ID: uuid.New(), Username: "alice"}
+	refreshTokens := &fakeRefreshTokenService{valid: map[string]uuid.UUID{}, sessionID: uuid.New()}
+	sessions := &fakeSessionService{}
+	client := gotalkv1.NewUserServiceClient(dialTestServer(t, NewServer(&fakeUserService{user: user}, nil, nil, refreshTokens, sessions, nil, nil)))
+	ctx := context.Background()
+
+	_, err := client.Login(ctx, &gotalkv1.LoginRequest{Username: "alice", Password: "secret", DeviceName: "phone\n"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for an invalid device name, got %v", err)
+	}
+
+	login, err := client.Login(ctx, &gotalkv1.LoginRequest{Username: "alice", Password: "secret", DeviceName: "phone"})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if login.Token == "" || login.RefreshToken != "refresh-1" || login.ExpiresIn != 900 || login.RefreshTokenExpiresAt == nil || login.SessionId != refreshTokens.sessionID.String() {
+		t.Errorf("Expected a token pair, got %v", login)
+	}
+
+	// Reachable without a JWT, the refresh token is the credential
+	refreshed, err := client.RefreshToken(ctx, &gotalkv1.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	if err != nil {
+		t.Fatalf("RefreshToken failed: %v", err)
+	}
+	if refreshed.RefreshToken != "refresh-2" {
+		t.Errorf("Expected a rotated refresh token, got %q", refreshed.RefreshToken)
+	}
+
+	_, err = client.RefreshToken(ctx, &gotalkv1.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated for a used refresh token, got %v", err)
+	}
+
+	if _, err := client.Logout(ctx, &gotalkv1.LogoutRequest{RefreshToken: refreshed.RefreshToken}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated when logging out without a token, got %v", err)
+	}
+
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+refreshed.Token)
+	if _, err := client.Logout(authCtx, &gotalkv1.LogoutRequest{RefreshToken: refreshed.RefreshToken}); err != nil {
+		t.Fatalf("Logout failed: %v", err)
+	}
+	if len(refreshTokens.revoked) != 1 || refreshTokens.revoked[0] != refreshed.RefreshToken {
+		t.Errorf("Expected the refresh token to be revoked, got %v", refreshTokens.revoked)
+	}
+	if len(sessions.revoked) != 1 || sessions.revoked[0] != refreshTokens.sessionID {
+		t.Errorf("Expected the session to be revoked, got %v", sessions.revoked)
+	}
+}
@@ -0,0 +1,192 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/rpc/gotalkv1"
+	"github.com/EliasLd/gotalk-backend/internal/service"
+	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/jackc/pgx/v5"
+)
+
+type userServer struct {
+	gotalkv1.UnimplementedUserServiceServer
+	users		service.UserService
+	refreshTokens	service.RefreshTokenService
+	sessions	service.SessionService
+	revocations	service.TokenRevocationService
+}
+
+func newUser(user models.PublicUser) *gotalkv1.User {
+	return &gotalkv1.User {
+		Id:		user.ID.String(),
+		Username:	user.Username,
+		DisplayName:	user.DisplayName,
+		Bio:		user.Bio,
+		Pronouns:	user.Pronouns,
+		Timezone:	user.Timezone,
+		AvatarUrl:	user.AvatarURL,
+		Bot:		user.Bot,
+		Deleted:	user.Deleted,
+		CreatedAt:	timestamppb.New(user.CreatedAt),
+	}
+}
+
+func (s *userServer) Register(ctx context.Context, req *gotalkv1.RegisterRequest) (*gotalkv1.User, error) {
+	user, err := s.users.RegisterUser(ctx, req.GetUsername(), req.GetPassword())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return newUser(user.Public()), nil
+}
+
+func (s *userServer) Login(ctx context.Context, req *gotalkv1.LoginRequest) (*gotalkv1.LoginResponse, error) {
+	user, err := s.users.AuthenticateUser(ctx, req.GetUsername(), req.GetPassword())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	device := service.SessionDevice {
+		Name:		req.GetDeviceName(),
+		IPAddress:	peerIP(ctx),
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("user-agent"); len(values) > 0 {
+			device.UserAgent = values[0]
+		}
+	}
+
+	tokens, err := s.refreshTokens.IssueTokens(ctx, user, device)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return newLoginResponse(tokens), nil
+}
+
+func newLoginResponse(tokens *service.TokenPair) *gotalkv1.LoginResponse {
+	return &gotalkv1.LoginResponse {
+		Token:			tokens.AccessToken,
+		ExpiresIn:		int32(tokens.ExpiresIn.Seconds()),
+		RefreshToken:		tokens.RefreshToken,
+		RefreshTokenExpiresAt:	timestamppb.New(tokens.RefreshTokenExpiresAt),
+		SessionId:		tokens.SessionID.String(),
+	}
+}
+
+func (s *userServer) RefreshToken(ctx context.Context, req *gotalkv1.RefreshTokenRequest) (*gotalkv1.LoginResponse, error) {
+	tokens, err := s.refreshTokens.RefreshTokens(ctx, req.GetRefreshToken(), peerIP(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return newLoginResponse(tokens), nil
+}
+
+// Revokes the access token of the call along with its session and, when
+// given, the refresh token of the same login
+func (s *userServer) Logout(ctx context.Context, req *gotalkv1.LogoutRequest) (*gotalkv1.LogoutResponse, error) {
+	claims, err := claimsFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetRefreshToken() != "" {
+		if err := s.refreshTokens.RevokeRefreshToken(ctx, claims.UserID, req.GetRefreshToken()); err != nil {
+			return nil, toStatus(err)
+		}
+	}
+
+	if s.revocations != nil {
+		if err := s.revocations.RevokeToken(ctx, claims); err != nil {
+			return nil, toStatus(err)
+		}
+	}
+
+	// Already revoked by another call of the session
+	err = s.sessions.RevokeSession(ctx, claims.UserID, claims.SessionID)
+	if err != nil && !errors.Is(err, appErr.ErrSessionNotFound) {
+		return nil, toStatus(err)
+	}
+	return &gotalkv1.LogoutResponse{}, nil
+}
+
+func (s *userServer) GetMe(ctx context.Context, req *gotalkv1.GetMeRequest) (*gotalkv1.User, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.GetUserByID(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, toStatus(appErr.ErrUserNotFound)
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return newUser(user.Public()), nil
+}
+
+func (s *userServer) UpdateMe(ctx context.Context, req *gotalkv1.UpdateMeRequest) (*gotalkv1.User, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.UpdateUser(ctx, userID, service.UpdateUserInput {
+		Username:	req.Username,
+		Password:	req.Password,
+		DisplayName:	req.DisplayName,
+		Bio:		req.Bio,
+		Pronouns:	req.Pronouns,
+		Timezone:	req.Timezone,
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return newUser(user.Public()), nil
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *gotalkv1.GetUserRequest) (*gotalkv1.User, error) {
+	userID, err := parseID("id", req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := s.users.GetPublicProfile(ctx, userID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return newUser(*profile), nil
+}
+
+func (s *userServer) SearchUsers(ctx context.Context, req *gotalkv1.SearchUsersRequest) (*gotalkv1.SearchUsersResponse, error) {
+	users, nextCursor, err := s.users.SearchUsers(ctx, req.GetQuery(), req.GetCursor(), int(req.GetLimit()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &gotalkv1.SearchUsersResponse{NextCursor: nextCursor}
+	for _, user := range users {
+		resp.Users = append(resp.Users, newUser(user))
+	}
+	return resp, nil
+}
+
+// Address of the client, without its port
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
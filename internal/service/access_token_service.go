@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	stdErrors "errors"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/EliasLd/gotalk-backend/internal/auth"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const MaxAccessTokenNameLength = 64
+
+// Defines business logic operations related to personal access tokens.
+type AccessTokenService interface {
+	CreateToken(ctx context.Context, userID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*models.AccessToken, string, error)
+	ListTokens(ctx context.Context, userID uuid.UUID) ([]*models.AccessToken, error)
+	RevokeToken(ctx context.Context, userID, tokenID uuid.UUID) error
+	ValidateAccessToken(ctx context.Context, token string) (uuid.UUID, []string, error)
+}
+
+// Concrete implementation of AccessTokenService.
+type accessTokenService struct {
+	repo repository.AccessTokenRepository
+}
+
+// Creates a new AccessTokenService instance.
+func NewAccessTokenService(repo repository.AccessTokenRepository) AccessTokenService {
+	return &accessTokenService{repo: repo}
+}
+
+// Returns the stored token along with its plain value, which is not retrievable afterwards
+func (s *accessTokenService) CreateToken(ctx context.Context, userID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*models.AccessToken, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxAccessTokenNameLength || hasForbiddenRunes(name, false) {
+		return nil, "", errors.ErrTokenNameInvalid
+	}
+
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, "", errors.ErrTokenExpiryInvalid
+	}
+
+	plain, hash, err := auth.GenerateAccessToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	token := &models.AccessToken {
+		ID:		uuid.New(),
+		UserID:		userID,
+		Name:		name,
+		TokenHash:	hash,
+		Scopes:		scopes,
+		CreatedAt:	now,
+		ExpiresAt:	expiresAt,
+	}
+
+	if err := s.repo.CreateAccessToken(ctx, token); err != nil {
+		return nil, "", err
+	}
+
+	return token, plain, nil
+}
+
+// Rejects unknown scopes and drops duplicates, keeping the first occurrence
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, errors.ErrTokenScopesInvalid
+	}
+
+	seen := map[string]bool{}
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !auth.IsValidScope(scope) {
+			return nil, errors.ErrTokenScopesInvalid
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+
+	return normalized, nil
+}
+
+func (s *accessTokenService) ListTokens(ctx context.Context, userID uuid.UUID) ([]*models.AccessToken, error) {
+	return s.repo.GetAccessTokensByUser(ctx, userID)
+}
+
+func (s *accessTokenService) RevokeToken(ctx context.Context, userID, tokenID uuid.UUID) error {
+	err := s.repo.DeleteAccessToken(ctx, userID, tokenID)
+	if stdErrors.Is(err, pgx.ErrNoRows) {
+		return errors.ErrTokenNotFound
+	}
+	return err
+}
+
+// Returns the owner and granted scopes of a valid token
+func (s *accessTokenService) ValidateAccessToken(ctx context.Context, plain string) (uuid.UUID, []string, error) {
+	if !auth.IsAccessToken(plain) {
+		return uuid.Nil, nil, errors.ErrInvalidAccessToken
+	}
+
+	token, err := s.repo.GetAccessTokenByHash(ctx, auth.HashAccessToken(plain))
+	if err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, nil, errors.ErrInvalidAccessToken
+		}
+		return uuid.Nil, nil, err
+	}
+
+	if token.IsExpired(time.Now()) {
+		return uuid.Nil, nil, errors.ErrInvalidAccessToken
+	}
+
+	if err := s.repo.TouchAccessToken(ctx, token.ID); err != nil {
+		log.Printf("Failed to record use of access token %s: %v", token.ID, err)
+	}
+
+	return token.UserID, token.Scopes, nil
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/auth"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+func TestCreateToken_Validation(t *testing.T) {
+	// Input is validated before the repository is used
+	s := NewAccessTokenService(nil)
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name		string
+		tokenName	string
+		scopes		[]string
+		expiresAt	*time.Time
+		wantErr		error
+	}{
+		{"Empty name", "  ", []string{auth.ScopeMessagesRead}, nil, errors.ErrTokenNameInvalid},
+		{"Name too long", strings.Repeat("a", MaxAccessTokenNameLength+1), []string{auth.ScopeMessagesRead}, nil, errors.ErrTokenNameInvalid},
+		{"No scopes", "bot", nil, nil, errors.ErrTokenScopesInvalid},
+		{"Unknown scope", "bot", []string{"admin:all"}, nil, errors.ErrTokenScopesInvalid},
+		{"Expired", "bot", []string{auth.ScopeMessagesRead}, &past, errors.ErrTokenExpiryInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := s.CreateToken(context.Background(), uuid.New(), tt.tokenName, tt.scopes, tt.expiresAt)
+			if err != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateAccessToken_RejectsJWTs(t *testing.T) {
+	s := NewAccessTokenService(nil)
+
+	if _, _, err := s.ValidateAccessToken(context.Background(), "eyJhbGciOiJIUzI1NiJ9.e30.sig"); err != errors.ErrInvalidAccessToken {
+		t.Errorf("Expected ErrInvalidAccessToken, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	stdErrors "errors"
+	"log"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// Accounts anonymized per query, the rest is picked up by the next run
+const accountDeletionBatchSize = 100
+
+// Background job anonymizing accounts whose deletion grace period is over.
+// The rows are kept so that messages survive under a "deleted user" placeholder.
+type AccountDeletionWorker struct {
+	userRepo	repository.UserRepository
+	exportRepo	repository.ExportRepository
+	store		storage.Store
+	interval	time.Duration
+}
+
+// Creates a new AccountDeletionWorker instance.
+func NewAccountDeletionWorker(userRepo repository.UserRepository, exportRepo repository.ExportRepository, store storage.Store, interval time.Duration) *AccountDeletionWorker {
+	return &AccountDeletionWorker {
+		userRepo:	userRepo,
+		exportRepo:	exportRepo,
+		store:		store,
+		interval:	interval,
+	}
+}
+
+// Blocks until ctx is cancelled
+func (w *AccountDeletionWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.AnonymizeDue(ctx); err != nil {
+				log.Printf("Failed to anonymize deleted accounts: %v", err)
+			}
+		}
+	}
+}
+
+// Anonymizes every account whose deletion is due
+func (w *AccountDeletionWorker) AnonymizeDue(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	users, err := w.userRepo.GetUsersDueForDeletion(ctx, now, accountDeletionBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		// The user may have logged in since the query, cancelling the deletion
+		if _, err := w.anonymize(ctx, user, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Anonymizes the account right away, skipping the grace period.
+// Returns false when it was already anonymized.
+func (w *AccountDeletionWorker) AnonymizeNow(ctx context.Context, user *models.User) (bool, error) {
+	if user.IsDeleted() {
+		return false, nil
+	}
+
+	now := time.Now().UTC()
+	if err := w.userRepo.ScheduleUserDeletion(ctx, user.ID, now); err != nil {
+		// Anonymized since it was read
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return w.anonymize(ctx, user, now)
+}
+
+func (w *AccountDeletionWorker) anonymize(ctx context.Context, user *models.User, now time.Time) (bool, error) {
+	anonymized, err := w.userRepo.AnonymizeUser(ctx, user.ID, now)
+	if err != nil || !anonymized {
+		return false, err
+	}
+
+	if user.AvatarKey != "" {
+		if err := w.store.Delete(ctx, user.AvatarKey); err != nil && !stdErrors.Is(err, storage.ErrObjectNotFound) {
+			log.Printf("Failed to delete avatar of anonymized user %s: %v", user.ID, err)
+		}
+	}
+
+	// Archives hold the personal data that was just wiped. Each row goes once its
+	// archive is deleted, expired rows left behind are retried by the export purge.
+	exports, err := w.exportRepo.ExpireUserExports(ctx, user.ID, now)
+	if err != nil {
+		log.Printf("Failed to expire data exports of anonymized user %s: %v", user.ID, err)
+	}
+	for _, export := range exports {
+		if err := w.store.Delete(ctx, export.ObjectKey); err != nil && !stdErrors.Is(err, storage.ErrObjectNotFound) {
+			log.Printf("Failed to delete data export %s of anonymized user %s: %v", export.ID, user.ID, err)
+			continue
+		}
+		if err := w.exportRepo.DeleteExport(ctx, export.ID); err != nil {
+			log.Printf("Failed to delete data export %s of anonymized user %s: %v", export.ID, user.ID, err)
+		}
+	}
+
+	log.Printf("Anonymized deleted account %s", user.ID)
+	return true, nil
+}
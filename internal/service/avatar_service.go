@@ -0,0 +1,137 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stdErrors "errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/media"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/EliasLd/gotalk-backend/internal/storage"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Defines business logic operations related to user avatars.
+type AvatarService interface {
+	UploadAvatar(ctx context.Context, userID uuid.UUID, r io.Reader) (*models.User, error)
+	DeleteAvatar(ctx context.Context, userID uuid.UUID) error
+	GetAvatar(ctx context.Context, userID uuid.UUID) (io.ReadCloser, error)
+}
+
+// Concrete implementation of AvatarService.
+type avatarService struct {
+	userRepo	repository.UserRepository
+	store		storage.Store
+}
+
+// Creates a new AvatarService instance.
+func NewAvatarService(userRepo repository.UserRepository, store storage.Store) AvatarService {
+	return &avatarService {
+		userRepo:	userRepo,
+		store:		store,
+	}
+}
+
+func (s *avatarService) getUser(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if stdErrors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.ErrUserNotFound
+	}
+	if err == nil && user.IsDeleted() {
+		return nil, errors.ErrUserNotFound
+	}
+	return user, err
+}
+
+// Resizes and stores the uploaded image, then replaces the user's previous avatar
+func (s *avatarService) UploadAvatar(ctx context.Context, userID uuid.UUID, r io.Reader) (*models.User, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	avatar, err := media.ProcessAvatar(r)
+	if err != nil {
+		if stdErrors.Is(err, media.ErrUnsupportedImage) {
+			return nil, errors.ErrAvatarInvalid
+		}
+		return nil, err
+	}
+
+	// A new key per upload keeps cached copies of the old avatar from being served
+	key := "avatars/" + userID.String() + "/" + uuid.NewString() + ".png"
+	if err := s.store.Put(ctx, key, bytes.NewReader(avatar)); err != nil {
+		return nil, err
+	}
+
+	previousKey := user.AvatarKey
+	if err := s.setAvatarKey(ctx, userID, key); err != nil {
+		s.store.Delete(ctx, key)
+		return nil, err
+	}
+	user.AvatarKey = key
+	user.UpdatedAt = time.Now()
+
+	s.deleteObject(ctx, previousKey)
+	return user, nil
+}
+
+func (s *avatarService) DeleteAvatar(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.AvatarKey == "" {
+		return nil
+	}
+
+	previousKey := user.AvatarKey
+	if err := s.setAvatarKey(ctx, userID, ""); err != nil {
+		return err
+	}
+
+	s.deleteObject(ctx, previousKey)
+	return nil
+}
+
+// Writes the avatar column alone, the rest of the row may have changed since it was read
+func (s *avatarService) setAvatarKey(ctx context.Context, userID uuid.UUID, key string) error {
+	err := s.userRepo.SetUserAvatarKey(ctx, userID, key)
+	if stdErrors.Is(err, pgx.ErrNoRows) {
+		return errors.ErrUserNotFound
+	}
+	return err
+}
+
+// Returns the stored PNG, the caller must close it
+func (s *avatarService) GetAvatar(ctx context.Context, userID uuid.UUID) (io.ReadCloser, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.AvatarKey == "" {
+		return nil, errors.ErrAvatarNotFound
+	}
+
+	avatar, err := s.store.Get(ctx, user.AvatarKey)
+	if stdErrors.Is(err, storage.ErrObjectNotFound) {
+		return nil, errors.ErrAvatarNotFound
+	}
+	return avatar, err
+}
+
+// Orphaned objects only waste space, failures are logged but not returned
+func (s *avatarService) deleteObject(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+	if err := s.store.Delete(ctx, key); err != nil {
+		log.Printf("Failed to delete avatar %s: %v", key, err)
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	stdErrors "errors"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/events"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Defines business logic operations related to user blocks.
+// Blocks are private to the blocker, nothing here tells a user they were blocked.
+type BlockService interface {
+	BlockUser(ctx context.Context, blockerID, blockedID uuid.UUID, hideMessages bool) error
+	UnblockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error
+	ListBlocks(ctx context.Context, blockerID uuid.UUID) ([]*models.Block, error)
+}
+
+// Concrete implementation of BlockService.
+type blockService struct {
+	repo		repository.BlockRepository
+	userRepo	repository.UserRepository
+	// Tells the blocker's open streams to reload their blocks
+	broker		events.Broker
+}
+
+// Creates a new BlockService instance.
+func NewBlockService(repo repository.BlockRepository, userRepo repository.UserRepository, broker events.Broker) BlockService {
+	return &blockService {
+		repo:		repo,
+		userRepo:	userRepo,
+		broker:		broker,
+	}
+}
+
+func (s *blockService) BlockUser(ctx context.Context, blockerID, blockedID uuid.UUID, hideMessages bool) error {
+	if blockerID == blockedID {
+		return errors.ErrCannotBlockSelf
+	}
+
+	if _, err := s.userRepo.GetUserByID(ctx, blockedID); err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return errors.ErrUserNotFound
+		}
+		return err
+	}
+
+	err := s.repo.CreateBlock(ctx, &models.Block {
+		BlockerID:	blockerID,
+		BlockedID:	blockedID,
+		HideMessages:	hideMessages,
+		CreatedAt:	time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.broker.PublishToUser(blockerID, events.Event{Type: events.BlocksUpdated})
+	return nil
+}
+
+func (s *blockService) UnblockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	if err := s.repo.DeleteBlock(ctx, blockerID, blockedID); err != nil {
+		return err
+	}
+
+	s.broker.PublishToUser(blockerID, events.Event{Type: events.BlocksUpdated})
+	return nil
+}
+
+func (s *blockService) ListBlocks(ctx context.Context, blockerID uuid.UUID) ([]*models.Block, error) {
+	return s.repo.GetBlocksByBlocker(ctx, blockerID)
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+// Decision taken by a content filter
+type FilterAction int
+
+const (
+	FilterAccept FilterAction = iota
+	FilterRewrite
+	FilterReject
+)
+
+// Message about to be stored, as seen by content filters
+type OutgoingMessage struct {
+	SenderID	uuid.UUID
+	ConversationID	uuid.UUID
+	Content		string
+}
+
+type FilterResult struct {
+	Action	FilterAction
+	// Replacement content when Action is FilterRewrite
+	Content	string
+	// Shown to the sender when Action is FilterReject
+	Reason	string
+}
+
+func Accept() FilterResult {
+	return FilterResult{Action: FilterAccept}
+}
+
+func Rewrite(content string) FilterResult {
+	return FilterResult{Action: FilterRewrite, Content: content}
+}
+
+func Reject(reason string) FilterResult {
+	return FilterResult{Action: FilterReject, Reason: reason}
+}
+
+// Pre-send hook inspecting every outgoing message.
+type ContentFilter interface {
+	Filter(ctx context.Context, message OutgoingMessage) FilterResult
+}
+
+// Builds a filter from its JSON settings
+type ContentFilterFactory func(config json.RawMessage) (ContentFilter, error)
+
+// Filters available to the server-wide and per-conversation configurations
+var contentFilterFactories = map[string]ContentFilterFactory{}
+
+// Makes a filter available under the given name.
+// Meant to be called from init functions, not safe for concurrent use.
+func RegisterContentFilter(name string, factory ContentFilterFactory) {
+	contentFilterFactories[name] = factory
+}
+
+// Instantiates a configured filter
+func BuildContentFilter(config models.ContentFilterConfig) (ContentFilter, error) {
+	factory, ok := contentFilterFactories[config.Name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown filter %q", errors.ErrInvalidContentFilter, config.Name)
+	}
+
+	raw := config.Config
+	if len(raw) == 0 {
+		raw = json.RawMessage("{}")
+	}
+
+	filter, err := factory(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errors.ErrInvalidContentFilter, config.Name, err)
+	}
+
+	return filter, nil
+}
+
+// Instantiates a list of configured filters, keeping their order
+func BuildContentFilters(configs []models.ContentFilterConfig) ([]ContentFilter, error) {
+	filters := make([]ContentFilter, 0, len(configs))
+	for _, config := range configs {
+		filter, err := BuildContentFilter(config)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// Parses the server-wide filters, formatted as a JSON list of {"name", "config"} objects
+func ParseContentFilters(raw string) ([]ContentFilter, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var configs []models.ContentFilterConfig
+	if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrInvalidContentFilter, err)
+	}
+
+	return BuildContentFilters(configs)
+}
+
+// Compiled conversation filters are reused for this long. Changes made on this
+// server apply right away, those made on other servers once the entry expires.
+const contentFilterCacheTTL = 30 * time.Second
+
+// Runs the server-wide filters followed by the conversation's own filters.
+type FilterPipeline struct {
+	global			[]ContentFilter
+	conversationRepo	repository.ConversationRepository
+
+	mu			sync.Mutex
+	// Compiled filters of each conversation, building them compiles regexes
+	cache			map[uuid.UUID]cachedContentFilters
+	// Bumped by Invalidate, so that filters loaded before are not cached
+	version			uint64
+}
+
+type cachedContentFilters struct {
+	filters		[]ContentFilter
+	loadedAt	time.Time
+}
+
+// Creates a new FilterPipeline instance.
+func NewFilterPipeline(conversationRepo repository.ConversationRepository, global []ContentFilter) *FilterPipeline {
+	return &FilterPipeline {
+		global:			global,
+		conversationRepo:	conversationRepo,
+		cache:			map[uuid.UUID]cachedContentFilters{},
+	}
+}
+
+// Drops the conversation's compiled filters, called once they are replaced
+func (p *FilterPipeline) Invalidate(conversationID uuid.UUID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.cache, conversationID)
+	p.version++
+}
+
+func (p *FilterPipeline) conversationFilters(ctx context.Context, conversationID uuid.UUID) ([]ContentFilter, error) {
+	now := time.Now()
+
+	p.mu.Lock()
+	cached, ok := p.cache[conversationID]
+	version := p.version
+	p.mu.Unlock()
+	if ok && now.Sub(cached.loadedAt) < contentFilterCacheTTL {
+		return cached.filters, nil
+	}
+
+	configs, err := p.conversationRepo.GetContentFilters(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	filters, err := BuildContentFilters(configs)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.version != version {
+		return filters, nil
+	}
+	// Expired entries of idle conversations are dropped along the way
+	for id, entry := range p.cache {
+		if now.Sub(entry.loadedAt) >= contentFilterCacheTTL {
+			delete(p.cache, id)
+		}
+	}
+	p.cache[conversationID] = cachedContentFilters{filters: filters, loadedAt: now}
+	return filters, nil
+}
+
+// Returns the content to store, possibly rewritten.
+// Rejections are reported as errors.ErrMessageRejected carrying the filter's reason.
+func (p *FilterPipeline) Run(ctx context.Context, message OutgoingMessage) (string, error) {
+	conversationFilters, err := p.conversationFilters(ctx, message.ConversationID)
+	if err != nil {
+		return "", err
+	}
+
+	filters := append(append([]ContentFilter{}, p.global...), conversationFilters...)
+	for _, filter := range filters {
+		result := filter.Filter(ctx, message)
+
+		switch result.Action {
+		case FilterReject:
+			return "", fmt.Errorf("%w: %s", errors.ErrMessageRejected, result.Reason)
+		case FilterRewrite:
+			message.Content = result.Content
+		}
+	}
+
+	return message.Content, nil
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Built-in content filters, enabled by name in the filter configurations:
+//
+//	banned_words        {"words": ["foo"], "action": "reject" | "mask"}
+//	max_links           {"max": 3}
+//	suspicious_unicode  {"action": "strip" | "reject"}
+func init() {
+	RegisterContentFilter("banned_words", newBannedWordsFilter)
+	RegisterContentFilter("max_links", newMaxLinksFilter)
+	RegisterContentFilter("suspicious_unicode", newSuspiciousUnicodeFilter)
+}
+
+// Rejects or masks messages containing any of the configured words.
+// Words only match whole, between characters which are not letters or digits
+// in any script: RE2's \b only knows ASCII word characters.
+type bannedWordsFilter struct {
+	// Matches the words anywhere, boundaries are checked separately
+	pattern	*regexp.Regexp
+	mask	bool
+}
+
+func newBannedWordsFilter(config json.RawMessage) (ContentFilter, error) {
+	var settings struct {
+		Words	[]string	`json:"words"`
+		Action	string		`json:"action"`
+	}
+	if err := json.Unmarshal(config, &settings); err != nil {
+		return nil, err
+	}
+
+	if len(settings.Words) == 0 {
+		return nil, stdErrors.New("at least one word is required")
+	}
+	if settings.Action != "" && settings.Action != "reject" && settings.Action != "mask" {
+		return nil, fmt.Errorf("unknown action %q", settings.Action)
+	}
+
+	words := make([]string, 0, len(settings.Words))
+	for _, word := range settings.Words {
+		if word = strings.TrimSpace(word); word != "" {
+			words = append(words, word)
+		}
+	}
+	if len(words) == 0 {
+		return nil, stdErrors.New("at least one word is required")
+	}
+
+	// Longest first so that a word is not shadowed by one of its prefixes
+	sort.Slice(words, func(i, j int) bool {
+		return len(words[i]) > len(words[j])
+	})
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		quoted = append(quoted, regexp.QuoteMeta(word))
+	}
+
+	return &bannedWordsFilter {
+		pattern:	regexp.MustCompile(`(?i)(?:` + strings.Join(quoted, "|") + `)`),
+		mask:		settings.Action == "mask",
+	}, nil
+}
+
+func (f *bannedWordsFilter) Filter(ctx context.Context, message OutgoingMessage) FilterResult {
+	found := f.find(message.Content)
+	if len(found) == 0 {
+		return Accept()
+	}
+
+	if !f.mask {
+		return Reject("message contains a banned word")
+	}
+
+	masked := strings.Builder{}
+	last := 0
+	for _, loc := range found {
+		masked.WriteString(message.Content[last:loc[0]])
+		masked.WriteString(strings.Repeat("*", utf8.RuneCountInString(message.Content[loc[0]:loc[1]])))
+		last = loc[1]
+	}
+	masked.WriteString(message.Content[last:])
+
+	return Rewrite(masked.String())
+}
+
+// Returns the byte ranges of the banned words standing as whole words in content
+func (f *bannedWordsFilter) find(content string) [][]int {
+	var found [][]int
+	for pos := 0; pos < len(content); {
+		loc := f.pattern.FindStringIndex(content[pos:])
+		if loc == nil {
+			break
+		}
+		start, end := pos+loc[0], pos+loc[1]
+
+		if !wordRuneBefore(content, start) && !wordRuneAt(content, end) {
+			found = append(found, []int{start, end})
+			pos = end
+			continue
+		}
+		// A match may still start within this one
+		_, size := utf8.DecodeRuneInString(content[start:])
+		pos = start + size
+	}
+	return found
+}
+
+// Whether the rune ending at byte offset i of s, if any, is a letter or a digit
+func wordRuneBefore(s string, i int) bool {
+	c, size := utf8.DecodeLastRuneInString(s[:i])
+	return size > 0 && isLetterOrDigit(c)
+}
+
+// Whether the rune starting at byte offset i of s, if any, is a letter or a digit
+func wordRuneAt(s string, i int) bool {
+	c, size := utf8.DecodeRuneInString(s[i:])
+	return size > 0 && isLetterOrDigit(c)
+}
+
+func isLetterOrDigit(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsNumber(c)
+}
+
+var linkRegex = regexp.MustCompile(`(?i)\b(https?://|www\.)`)
+
+// Rejects messages with more links than allowed
+type maxLinksFilter struct {
+	max int
+}
+
+func newMaxLinksFilter(config json.RawMessage) (ContentFilter, error) {
+	var settings struct {
+		Max *int `json:"max"`
+	}
+	if err := json.Unmarshal(config, &settings); err != nil {
+		return nil, err
+	}
+
+	if settings.Max == nil || *settings.Max < 0 {
+		return nil, stdErrors.New("max must be zero or more")
+	}
+
+	return &maxLinksFilter{max: *settings.Max}, nil
+}
+
+func (f *maxLinksFilter) Filter(ctx context.Context, message OutgoingMessage) FilterResult {
+	if len(linkRegex.FindAllStringIndex(message.Content, -1)) > f.max {
+		return Reject(fmt.Sprintf("message contains more than %d links", f.max))
+	}
+	return Accept()
+}
+
+// Invisible characters commonly used to evade filters or spoof text direction
+func isSuspiciousRune(c rune) bool {
+	switch {
+	// Zero-width space, non-joiner and joiner
+	case c >= '\u200B' && c <= '\u200D':
+		return true
+	// Word joiner and byte order mark
+	case c == '\u2060' || c == '\uFEFF':
+		return true
+	// Bidirectional embeddings, overrides and isolates
+	case c >= '\u202A' && c <= '\u202E', c >= '\u2066' && c <= '\u2069':
+		return true
+	}
+	return false
+}
+
+// Strips or rejects zero-width and bidi control characters
+type suspiciousUnicodeFilter struct {
+	reject bool
+}
+
+func newSuspiciousUnicodeFilter(config json.RawMessage) (ContentFilter, error) {
+	var settings struct {
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal(config, &settings); err != nil {
+		return nil, err
+	}
+
+	if settings.Action != "" && settings.Action != "strip" && settings.Action != "reject" {
+		return nil, fmt.Errorf("unknown action %q", settings.Action)
+	}
+
+	return &suspiciousUnicodeFilter{reject: settings.Action == "reject"}, nil
+}
+
+func (f *suspiciousUnicodeFilter) Filter(ctx context.Context, message OutgoingMessage) FilterResult {
+	if strings.IndexFunc(message.Content, isSuspiciousRune) < 0 {
+		return Accept()
+	}
+
+	if f.reject {
+		return Reject("message contains invisible or direction-changing characters")
+	}
+
+	return Rewrite(strings.Map(func(c rune) rune {
+		if isSuspiciousRune(c) {
+			return -1
+		}
+		return c
+	}, message.Content))
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"testing"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+// Conversation repository stub only serving content filters
+type stubFilterRepository struct {
+	repository.ConversationRepository
+	filters []models.ContentFilterConfig
+	loads   int
+}
+
+func (r *stubFilterRepository) GetContentFilters(ctx context.Context, conversationID uuid.UUID) ([]models.ContentFilterConfig, error) {
+	r.loads++
+	return r.filters, nil
+}
+
+func mustBuildFilter(t *testing.T, name, config string) ContentFilter {
+	t.Helper()
+
+	filter, err := BuildContentFilter(models.ContentFilterConfig{Name: name, Config: json.RawMessage(config)})
+	if err != nil {
+		t.Fatalf("Failed to build filter %s: %v", name, err)
+	}
+	return filter
+}
+
+func TestBuiltinContentFilters(t *testing.T) {
+	tests := []struct {
+		name		string
+		filter		string
+		config		string
+		content		string
+		wantAction	FilterAction
+		wantContent	string
+	}{
+		{"Banned word rejected", "banned_words", `{"words":["spam"]}`, "buy SPAM now", FilterReject, ""},
+		{"Banned word inside another word", "banned_words", `{"words":["spam"]}`, "spammer", FilterAccept, ""},
+		{"Banned word masked", "banned_words", `{"words":["spam"],"action":"mask"}`, "buy spam now", FilterRewrite, "buy **** now"},
+		{"Non-ASCII banned word rejected", "banned_words", `{"words":["café"]}`, "un CAFÉ noir", FilterReject, ""},
+		{"Non-ASCII banned word inside another word", "banned_words", `{"words":["café"]}`, "cafés", FilterAccept, ""},
+		{"Banned word after a non-ASCII letter", "banned_words", `{"words":["spam"]}`, "éspam", FilterAccept, ""},
+		{"Cyrillic banned words masked", "banned_words", `{"words":["спам"],"action":"mask"}`, "спам, спам и спамер", FilterRewrite, "****, **** и спамер"},
+		{"Links under limit", "max_links", `{"max":1}`, "see https://a.io", FilterAccept, ""},
+		{"Too many links", "max_links", `{"max":1}`, "https://a.io and www.b.io", FilterReject, ""},
+		{"Zero-width stripped", "suspicious_unicode", `{}`, "pay\u200Bpal", FilterRewrite, "paypal"},
+		{"Bidi override rejected", "suspicious_unicode", `{"action":"reject"}`, "file\u202Etxt.exe", FilterReject, ""},
+		{"Clean text accepted", "suspicious_unicode", `{}`, "héllo", FilterAccept, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := mustBuildFilter(t, tt.filter, tt.config)
+
+			result := filter.Filter(context.Background(), OutgoingMessage{Content: tt.content})
+			if result.Action != tt.wantAction {
+				t.Fatalf("Expected action %v, got %v", tt.wantAction, result.Action)
+			}
+			if tt.wantAction == FilterRewrite && result.Content != tt.wantContent {
+				t.Errorf("Expected rewritten content %q, got %q", tt.wantContent, result.Content)
+			}
+			if tt.wantAction == FilterReject && result.Reason == "" {
+				t.Error("Expected a rejection reason")
+			}
+		})
+	}
+}
+
+func TestBuildContentFilter_InvalidConfig(t *testing.T) {
+	configs := []models.ContentFilterConfig{
+		{Name: "unknown_filter"},
+		{Name: "banned_words", Config: json.RawMessage(`{"words":[]}`)},
+		{Name: "max_links", Config: json.RawMessage(`{}`)},
+		{Name: "suspicious_unicode", Config: json.RawMessage(`{"action":"explode"}`)},
+	}
+
+	for _, config := range configs {
+		if _, err := BuildContentFilter(config); !stdErrors.Is(err, errors.ErrInvalidContentFilter) {
+			t.Errorf("Expected ErrInvalidContentFilter for %s, got %v", config.Name, err)
+		}
+	}
+}
+
+func TestFilterPipeline_GlobalThenConversation(t *testing.T) {
+	global := []ContentFilter{mustBuildFilter(t, "suspicious_unicode", `{}`)}
+	repo := &stubFilterRepository{
+		filters: []models.ContentFilterConfig{
+			{Name: "banned_words", Config: json.RawMessage(`{"words":["paypal"]}`)},
+		},
+	}
+	pipeline := NewFilterPipeline(repo, global)
+
+	// The conversation filter only catches the word once the global filter stripped it
+	_, err := pipeline.Run(context.Background(), OutgoingMessage{Content: "pay\u200Bpal"})
+	if !stdErrors.Is(err, errors.ErrMessageRejected) {
+		t.Fatalf("Expected ErrMessageRejected, got %v", err)
+	}
+
+	content, err := pipeline.Run(context.Background(), OutgoingMessage{Content: "hello\u200B"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if content != "hello" {
+		t.Errorf("Expected content %q, got %q", "hello", content)
+	}
+}
+
+func TestFilterPipeline_CachesUntilInvalidated(t *testing.T) {
+	conversationID := uuid.New()
+	repo := &stubFilterRepository{
+		filters: []models.ContentFilterConfig{
+			{Name: "banned_words", Config: json.RawMessage(`{"words":["spam"]}`)},
+		},
+	}
+	pipeline := NewFilterPipeline(repo, nil)
+	msg := OutgoingMessage{ConversationID: conversationID, Content: "hello"}
+
+	for i := 0; i < 3; i++ {
+		if _, err := pipeline.Run(context.Background(), msg); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if repo.loads != 1 {
+		t.Fatalf("Expected filters to be loaded once, got %d", repo.loads)
+	}
+
+	repo.filters = []models.ContentFilterConfig{
+		{Name: "banned_words", Config: json.RawMessage(`{"words":["hello"]}`)},
+	}
+	pipeline.Invalidate(conversationID)
+
+	_, err := pipeline.Run(context.Background(), msg)
+	if !stdErrors.Is(err, errors.ErrMessageRejected) {
+		t.Fatalf("Expected ErrMessageRejected after invalidation, got %v", err)
+	}
+	if repo.loads != 2 {
+		t.Errorf("Expected filters to be reloaded once, got %d", repo.loads)
+	}
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	stdErrors "errors"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Longest slow mode interval an admin can set
+const MaxSlowModeInterval = 6 * time.Hour
+
+// Defines business logic operations related to conversation settings.
+type ConversationService interface {
+	GetContentFilters(ctx context.Context, userID, conversationID uuid.UUID) ([]models.ContentFilterConfig, error)
+	SetContentFilters(ctx context.Context, userID, conversationID uuid.UUID, filters []models.ContentFilterConfig) error
+	SetSlowMode(ctx context.Context, userID, conversationID uuid.UUID, interval time.Duration) error
+	OpenDirectConversation(ctx context.Context, userID, otherID uuid.UUID) (*models.Conversation, bool, error)
+	ListConversations(ctx context.Context, userID uuid.UUID) ([]*models.UserConversation, error)
+}
+
+// Concrete implementation of ConversationService.
+type conversationService struct {
+	repo		repository.ConversationRepository
+	userRepo	repository.UserRepository
+	blockRepo	repository.BlockRepository
+	webhooks	WebhookPublisher
+	filters		*FilterPipeline
+}
+
+// Creates a new ConversationService instance.
+// filters, when not nil, is told when a conversation's filters are replaced.
+func NewConversationService(repo repository.ConversationRepository, userRepo repository.UserRepository, blockRepo repository.BlockRepository, webhooks WebhookPublisher, filters *FilterPipeline) ConversationService {
+	return &conversationService {
+		repo:		repo,
+		userRepo:	userRepo,
+		blockRepo:	blockRepo,
+		webhooks:	webhooks,
+		filters:	filters,
+	}
+}
+
+// Returns the user's role, or ErrNotConversationMember
+func memberRole(ctx context.Context, repo repository.ConversationRepository, userID, conversationID uuid.UUID) (models.MemberRole, error) {
+	role, err := repo.GetMemberRole(ctx, conversationID, userID)
+	if stdErrors.Is(err, pgx.ErrNoRows) {
+		return "", errors.ErrNotConversationMember
+	}
+	return role, err
+}
+
+func (s *conversationService) requireAdmin(ctx context.Context, userID, conversationID uuid.UUID) error {
+	role, err := memberRole(ctx, s.repo, userID, conversationID)
+	if err != nil {
+		return err
+	}
+	if !role.IsAdmin() {
+		return errors.ErrConversationAdminRequired
+	}
+	return nil
+}
+
+func (s *conversationService) GetContentFilters(ctx context.Context, userID, conversationID uuid.UUID) ([]models.ContentFilterConfig, error) {
+	if err := s.requireAdmin(ctx, userID, conversationID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetContentFilters(ctx, conversationID)
+}
+
+// Replaces the conversation's filters once every configuration is known to build
+func (s *conversationService) SetContentFilters(ctx context.Context, userID, conversationID uuid.UUID, filters []models.ContentFilterConfig) error {
+	if err := s.requireAdmin(ctx, userID, conversationID); err != nil {
+		return err
+	}
+
+	if _, err := BuildContentFilters(filters); err != nil {
+		return err
+	}
+
+	if err := s.repo.SetContentFilters(ctx, conversationID, filters); err != nil {
+		return err
+	}
+
+	if s.filters != nil {
+		s.filters.Invalidate(conversationID)
+	}
+	return nil
+}
+
+// Sets the minimum delay between two messages of a regular member, 0 disables slow mode
+func (s *conversationService) SetSlowMode(ctx context.Context, userID, conversationID uuid.UUID, interval time.Duration) error {
+	if interval < 0 || interval > MaxSlowModeInterval || interval%time.Second != 0 {
+		return errors.ErrSlowModeOutOfRange
+	}
+
+	if err := s.requireAdmin(ctx, userID, conversationID); err != nil {
+		return err
+	}
+
+	return s.repo.SetSlowMode(ctx, conversationID, int(interval/time.Second))
+}
+
+// Returns the direct conversation between both users, creating it if needed.
+// The boolean reports whether it was just created.
+// A block in either direction is reported as ErrUserNotFound so that
+// the blocked user cannot tell they were blocked.
+func (s *conversationService) OpenDirectConversation(ctx context.Context, userID, otherID uuid.UUID) (*models.Conversation, bool, error) {
+	if userID == otherID {
+		return nil, false, errors.ErrCannotMessageSelf
+	}
+
+	if _, err := s.userRepo.GetUserByID(ctx, otherID); err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return nil, false, errors.ErrUserNotFound
+		}
+		return nil, false, err
+	}
+
+	blocked, err := s.blockRepo.IsBlockedEitherWay(ctx, userID, otherID)
+	if err != nil {
+		return nil, false, err
+	}
+	if blocked {
+		return nil, false, errors.ErrUserNotFound
+	}
+
+	existing, err := s.repo.FindDirectConversation(ctx, userID, otherID)
+	if err == nil {
+		return existing, false, nil
+	}
+	if !stdErrors.Is(err, pgx.ErrNoRows) {
+		return nil, false, err
+	}
+
+	conversation := &models.Conversation {
+		ID:		uuid.New(),
+		IsPublic:	false,
+		IsDirect:	true,
+		CreatedAt:	time.Now().UTC(),
+	}
+	stored, err := s.repo.CreateDirectConversation(ctx, conversation, userID, otherID)
+	if err != nil {
+		return nil, false, err
+	}
+	// Opened by a concurrent request in the meantime
+	if stored.ID != conversation.ID {
+		return stored, false, nil
+	}
+
+	for _, memberID := range []uuid.UUID{userID, otherID} {
+		publishWebhook(s.webhooks, ctx, models.WebhookMemberJoined, &conversation.ID, webhookMember {
+			ConversationID:	conversation.ID,
+			UserID:		memberID,
+			Role:		models.RoleMember,
+		})
+	}
+
+	return conversation, true, nil
+}
+
+// Returns the conversations the user belongs to, latest joined first
+func (s *conversationService) ListConversations(ctx context.Context, userID uuid.UUID) ([]*models.UserConversation, error) {
+	return s.repo.GetConversationsByUser(ctx, userID)
+}
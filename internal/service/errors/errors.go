@@ -1,12 +1,28 @@
 package errors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
 
 var (
 	// User related 
 	ErrUserAlreadyExists	= errors.New("user already exists")
 	ErrUserNotFound 	= errors.New("user not found")
 	ErrInvalidCredentials	= errors.New("Invalid credentials")
+	ErrUserDisabled		= errors.New("account has been disabled by an administrator")
+	ErrCannotBlockSelf	= errors.New("users cannot block themselves")
+	ErrInvalidSearchQuery	= errors.New("search query must be between 1 and 64 characters long")
+
+	// Profile validation
+	ErrDisplayNameInvalid	= errors.New("display name must be at most 64 characters long and contain no control characters")
+	ErrBioInvalid		= errors.New("bio must be at most 280 characters long and contain no control characters")
+	ErrPronounsInvalid	= errors.New("pronouns must be at most 32 characters long and only contain letters, spaces, '/' or '-'")
+	ErrTimezoneInvalid	= errors.New("timezone must be a valid IANA time zone name")
+	ErrAvatarInvalid	= errors.New("avatar must be a PNG, JPEG or GIF image")
+	ErrAvatarNotFound	= errors.New("user has no avatar")
 
 	// Password hashing
 	ErrPasswordHashingFailed = errors.New("failed to hash password")
@@ -17,4 +33,122 @@ var (
 	ErrPasswordMissingUpper   = errors.New("password must contain at least one uppercase letter")
 	ErrPasswordMissingLower   = errors.New("password must contain at least one lowercase letter")
 	ErrPasswordMissingSymbol  = errors.New("password must contain at least one special character")
+
+	// Conversation related
+	ErrNotConversationMember	= errors.New("user is not a member of this conversation")
+	ErrConversationAdminRequired	= errors.New("only conversation owners and admins can do this")
+	ErrSlowModeOutOfRange		= errors.New("slow mode interval must be between 0 seconds and 6 hours")
+	ErrSlowModeActive		= errors.New("slow mode is enabled in this conversation")
+	ErrCannotMessageSelf		= errors.New("cannot start a direct conversation with yourself")
+	ErrDirectConversationClosed	= errors.New("messages can no longer be sent in this conversation")
+
+	// Message validation
+	ErrMessageEmpty		= errors.New("message content must not be empty")
+	ErrMessageTooLong	= errors.New("message content must be at most 4000 characters long")
+	ErrMessageUnsafeLink	= errors.New("message links must use the http, https or mailto scheme")
+	ErrMessageMalformed	= errors.New("message content could not be rendered")
+	ErrMessageTTLOutOfRange	= errors.New("message TTL must be between 5 seconds and 7 days")
+
+	// Content filtering
+	ErrMessageRejected	= errors.New("message rejected by content filter")
+	ErrInvalidContentFilter	= errors.New("invalid content filter configuration")
+
+	// Data exports
+	ErrExportNotFound	= errors.New("export not found")
+	ErrExportNotReady	= errors.New("export is not ready for download")
+	ErrExportLinkInvalid	= errors.New("download link is invalid or expired")
+
+	// Personal access tokens
+	ErrTokenNameInvalid	= errors.New("token name must be between 1 and 64 characters long")
+	ErrTokenScopesInvalid	= errors.New("token scopes must be a non-empty list of known scopes")
+	ErrTokenExpiryInvalid	= errors.New("token expiry must be in the future")
+	ErrTokenNotFound	= errors.New("access token not found")
+	ErrInvalidAccessToken	= errors.New("invalid or expired access token")
+
+	// Refresh tokens
+	ErrInvalidRefreshToken	= errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused	= errors.New("refresh token already used, every session of its login was revoked")
+
+	// Sessions
+	ErrDeviceNameInvalid	= errors.New("device name must be at most 64 characters long and contain no control characters")
+	ErrSessionNotFound	= errors.New("session not found")
+
+	// Webhooks
+	ErrServerAdminRequired	= errors.New("only server administrators can do this")
+	ErrWebhookURLInvalid	= errors.New("webhook URL must be an absolute http or https URL")
+	ErrWebhookHostForbidden	= errors.New("webhook URL must not point to a loopback, private or link-local address")
+	ErrWebhookEventsInvalid	= errors.New("webhook events must be a non-empty list of supported events")
+	ErrWebhookNotFound	= errors.New("webhook not found")
+	ErrDeliveryNotFound	= errors.New("webhook delivery not found")
+	ErrDeliveryExpired	= errors.New("the disappearing message of this delivery has expired")
+
+	// Incoming webhooks
+	ErrBotNameInvalid		= errors.New("bot name must be between 1 and 64 characters long and contain no control characters")
+	ErrIncomingWebhookNotFound	= errors.New("incoming webhook not found")
+
+	// Slash commands
+	ErrUnknownCommand		= errors.New("unknown command")
+	ErrCommandUsage			= errors.New("invalid command usage")
+	ErrCommandFailed		= errors.New("command failed to respond")
+	ErrInvalidCommandConfig		= errors.New("invalid slash command configuration")
+	ErrTopicInvalid			= errors.New("topic must be at most 250 characters long and contain no control characters")
+	ErrAlreadyMember		= errors.New("user is already a member of this conversation")
+	ErrDirectConversationMembers	= errors.New("members cannot be added to a direct conversation")
+	ErrMemberMuted			= errors.New("you are muted in this conversation")
+	ErrCannotMuteAdmin		= errors.New("conversation owners and admins cannot be muted")
+	ErrMuteDurationOutOfRange	= errors.New("mute duration must be between 1 minute and 30 days")
+
+	// End-to-end encryption
+	ErrKeyInvalid			= errors.New("keys must be base64-encoded public keys of 32 to 64 bytes with non-negative IDs, signatures must be 64 bytes")
+	ErrTooManyPreKeys		= errors.New("at most 100 one-time prekeys can be uploaded at once and 200 stored per device")
+	ErrDeviceNotFound		= errors.New("device not found")
+	ErrDeviceKeyConflict		= errors.New("device is already registered with a different identity key")
+	ErrContentTypeInvalid		= errors.New("content type must be text or encrypted")
+	ErrEncryptedRequiresDirect	= errors.New("encrypted messages can only be sent in direct conversations")
+	ErrEncryptedMessageTooLarge	= errors.New("encrypted content must be at most 64KB")
 )
+
+// Returned when a member posts again before the slow mode interval elapsed.
+// Matches ErrSlowModeActive with errors.Is.
+type SlowModeError struct {
+	RetryAfter time.Duration
+}
+
+func (e *SlowModeError) Error() string {
+	return fmt.Sprintf("%v, retry in %s", ErrSlowModeActive, e.RetryAfter.Round(time.Second))
+}
+
+func (e *SlowModeError) Unwrap() error {
+	return ErrSlowModeActive
+}
+
+// Returned when a message starts with a command nobody registered.
+// Matches ErrUnknownCommand with errors.Is.
+type UnknownCommandError struct {
+	Name		string
+	// Names of the registered commands, sorted
+	Available	[]string
+}
+
+func (e *UnknownCommandError) Error() string {
+	return fmt.Sprintf("unknown command /%s, available commands: /%s (start the message with // to send it as text)",
+		e.Name, strings.Join(e.Available, ", /"))
+}
+
+func (e *UnknownCommandError) Unwrap() error {
+	return ErrUnknownCommand
+}
+
+// Returned when a command is called with invalid arguments.
+// Matches ErrCommandUsage with errors.Is.
+type CommandUsageError struct {
+	Usage	string
+}
+
+func (e *CommandUsageError) Error() string {
+	return "usage: " + e.Usage
+}
+
+func (e *CommandUsageError) Unwrap() error {
+	return ErrCommandUsage
+}
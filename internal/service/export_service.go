@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	stdErrors "errors"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/EliasLd/gotalk-backend/internal/storage"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Lifetimes of export archives and of their download links
+const (
+	ExportRetention		= 7 * 24 * time.Hour
+	ExportLinkLifetime	= time.Hour
+)
+
+// Defines business logic operations related to personal data exports.
+type ExportService interface {
+	RequestExport(ctx context.Context, userID uuid.UUID) (*models.DataExport, error)
+	GetExport(ctx context.Context, userID, exportID uuid.UUID) (*models.DataExport, error)
+	DownloadURL(export *models.DataExport) (string, time.Time)
+	OpenDownload(ctx context.Context, exportID uuid.UUID, expires, signature string) (io.ReadCloser, error)
+}
+
+// Concrete implementation of ExportService.
+type exportService struct {
+	repo		repository.ExportRepository
+	store		storage.Store
+	signingKey	[]byte
+}
+
+// Creates a new ExportService instance.
+// The signing key authenticates download links, which do not require a token.
+func NewExportService(repo repository.ExportRepository, store storage.Store, signingKey []byte) ExportService {
+	return &exportService {
+		repo:		repo,
+		store:		store,
+		signingKey:	signingKey,
+	}
+}
+
+// Queues an export of the user's data, processed by the ExportWorker.
+// A user has at most one export in progress, which is returned instead of queuing another.
+func (s *exportService) RequestExport(ctx context.Context, userID uuid.UUID) (*models.DataExport, error) {
+	active, err := s.repo.GetActiveExport(ctx, userID)
+	if err == nil {
+		return active, nil
+	}
+	if !stdErrors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	export := &models.DataExport {
+		ID:		uuid.New(),
+		UserID:		userID,
+		Status:		models.ExportPending,
+		CreatedAt:	time.Now().UTC(),
+	}
+
+	created, err := s.repo.CreateExport(ctx, export)
+	if err != nil {
+		return nil, err
+	}
+	// A concurrent request queued one first
+	if !created {
+		return s.repo.GetActiveExport(ctx, userID)
+	}
+
+	return export, nil
+}
+
+// Exports are only visible to their owner
+func (s *exportService) GetExport(ctx context.Context, userID, exportID uuid.UUID) (*models.DataExport, error) {
+	export, err := s.repo.GetExportByID(ctx, exportID)
+	if err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrExportNotFound
+		}
+		return nil, err
+	}
+
+	if export.UserID != userID {
+		return nil, errors.ErrExportNotFound
+	}
+
+	return export, nil
+}
+
+// Returns a signed download link valid for ExportLinkLifetime, along with its expiry
+func (s *exportService) DownloadURL(export *models.DataExport) (string, time.Time) {
+	expiresAt := time.Now().Add(ExportLinkLifetime).Truncate(time.Second)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.sign(export.ID, expires))
+
+	return "/exports/" + export.ID.String() + "/download?" + query.Encode(), expiresAt
+}
+
+// Checks the link signature and opens the archive for reading
+func (s *exportService) OpenDownload(ctx context.Context, exportID uuid.UUID, expires, signature string) (io.ReadCloser, error) {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return nil, errors.ErrExportLinkInvalid
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(s.sign(exportID, expires))) {
+		return nil, errors.ErrExportLinkInvalid
+	}
+
+	export, err := s.repo.GetExportByID(ctx, exportID)
+	if err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrExportNotFound
+		}
+		return nil, err
+	}
+
+	if export.Status != models.ExportCompleted {
+		return nil, errors.ErrExportNotReady
+	}
+
+	archive, err := s.store.Get(ctx, export.ObjectKey)
+	if stdErrors.Is(err, storage.ErrObjectNotFound) {
+		return nil, errors.ErrExportNotFound
+	}
+	return archive, err
+}
+
+func (s *exportService) sign(exportID uuid.UUID, expires string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(exportID.String() + ":" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+func TestDownloadURL_Signature(t *testing.T) {
+	// Links are checked before the repository is queried
+	s := NewExportService(nil, nil, []byte("test-signing-key"))
+	export := &models.DataExport{ID: uuid.New(), Status: models.ExportCompleted}
+
+	link, expiresAt := s.DownloadURL(export)
+	if !strings.HasPrefix(link, "/exports/"+export.ID.String()+"/download?") {
+		t.Fatalf("Unexpected download URL %s", link)
+	}
+	if time.Until(expiresAt) > ExportLinkLifetime {
+		t.Errorf("Expected link to expire within %s, got %v", ExportLinkLifetime, expiresAt)
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("Failed to parse download URL: %v", err)
+	}
+	expires := parsed.Query().Get("expires")
+	signature := parsed.Query().Get("signature")
+
+	tests := []struct {
+		name		string
+		exportID	uuid.UUID
+		expires		string
+		signature	string
+	}{
+		{"Tampered signature", export.ID, expires, signature + "x"},
+		{"Other export", uuid.New(), expires, signature},
+		{"Extended expiry", export.ID, strconv.FormatInt(expiresAt.Add(time.Hour).Unix(), 10), signature},
+		{"Expired link", export.ID, strconv.FormatInt(time.Now().Add(-time.Second).Unix(), 10), signature},
+		{"Malformed expiry", export.ID, "soon", signature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.OpenDownload(context.Background(), tt.exportID, tt.expires, tt.signature)
+			if err != errors.ErrExportLinkInvalid {
+				t.Errorf("Expected ErrExportLinkInvalid, got %v", err)
+			}
+		})
+	}
+}
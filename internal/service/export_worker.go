@@ -0,0 +1,318 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/storage"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Running exports not completed after this delay are assumed to be abandoned
+const staleExportTimeout = time.Hour
+
+// Background job building personal data exports.
+// Archives are streamed to the store while being written, so memory use
+// does not grow with the amount of exported data.
+type ExportWorker struct {
+	exportRepo		repository.ExportRepository
+	userRepo		repository.UserRepository
+	conversationRepo	repository.ConversationRepository
+	messageRepo		repository.MessageRepository
+	blockRepo		repository.BlockRepository
+	store			storage.Store
+	interval		time.Duration
+}
+
+// Creates a new ExportWorker instance.
+func NewExportWorker(exportRepo repository.ExportRepository, userRepo repository.UserRepository, conversationRepo repository.ConversationRepository, messageRepo repository.MessageRepository, blockRepo repository.BlockRepository, store storage.Store, interval time.Duration) *ExportWorker {
+	return &ExportWorker {
+		exportRepo:		exportRepo,
+		userRepo:		userRepo,
+		conversationRepo:	conversationRepo,
+		messageRepo:		messageRepo,
+		blockRepo:		blockRepo,
+		store:			store,
+		interval:		interval,
+	}
+}
+
+// Blocks until ctx is cancelled
+func (w *ExportWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.ProcessPending(ctx); err != nil {
+				log.Printf("Failed to process data exports: %v", err)
+			}
+			if err := w.PurgeExpired(ctx); err != nil {
+				log.Printf("Failed to purge expired data exports: %v", err)
+			}
+		}
+	}
+}
+
+// Builds every queued export
+func (w *ExportWorker) ProcessPending(ctx context.Context) error {
+	if _, err := w.exportRepo.RequeueStaleExports(ctx, time.Now().Add(-staleExportTimeout)); err != nil {
+		return err
+	}
+
+	for {
+		export, err := w.exportRepo.ClaimPendingExport(ctx)
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := w.process(ctx, export); err != nil {
+			log.Printf("Data export %s failed: %v", export.ID, err)
+			if err := w.exportRepo.FailExport(ctx, export.ID); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (w *ExportWorker) process(ctx context.Context, export *models.DataExport) error {
+	key := "exports/" + export.UserID.String() + "/" + export.ID.String() + ".zip"
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(w.writeArchive(ctx, pw, export.UserID))
+	}()
+
+	if err := w.store.Put(ctx, key, pr); err != nil {
+		// Unblocks the writer if the store gave up early
+		pr.CloseWithError(err)
+		return err
+	}
+
+	now := time.Now().UTC()
+	err := w.exportRepo.CompleteExport(ctx, export.ID, key, now, now.Add(ExportRetention))
+	if stdErrors.Is(err, pgx.ErrNoRows) {
+		// The account was deleted while the archive was being built
+		if err := w.store.Delete(ctx, key); err != nil && !stdErrors.Is(err, storage.ErrObjectNotFound) {
+			log.Printf("Failed to delete archive of removed export %s: %v", export.ID, err)
+		}
+		return nil
+	}
+	return err
+}
+
+// Deletes archives past their retention period
+func (w *ExportWorker) PurgeExpired(ctx context.Context) error {
+	exports, err := w.exportRepo.GetExportsExpiredBefore(ctx, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		if err := w.store.Delete(ctx, export.ObjectKey); err != nil && !stdErrors.Is(err, storage.ErrObjectNotFound) {
+			return err
+		}
+		if err := w.exportRepo.DeleteExport(ctx, export.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type exportProfile struct {
+	ID			uuid.UUID	`json:"id"`
+	Username		string		`json:"username"`
+	DisplayName		string		`json:"displayName"`
+	Bio			string		`json:"bio"`
+	Pronouns		string		`json:"pronouns"`
+	Timezone		string		`json:"timezone"`
+	CreatedAt		time.Time	`json:"createdAt"`
+	UpdatedAt		time.Time	`json:"updatedAt"`
+	DeletionScheduledAt	*time.Time	`json:"deletionScheduledAt"`
+}
+
+type exportMembership struct {
+	ConversationID		uuid.UUID		`json:"conversationId"`
+	ConversationName	*string			`json:"conversationName"`
+	IsDirect		bool			`json:"isDirect"`
+	Role			models.MemberRole	`json:"role"`
+	JoinedAt		time.Time		`json:"joinedAt"`
+}
+
+type exportMessage struct {
+	ID		uuid.UUID		`json:"id"`
+	ConversationID	uuid.UUID		`json:"conversationId"`
+	Content		string			`json:"content"`
+	ContentType	models.ContentType	`json:"contentType"`
+	MentionIDs	[]uuid.UUID		`json:"mentionIds"`
+	CreatedAt	time.Time		`json:"createdAt"`
+	ExpiresAt	*time.Time		`json:"expiresAt"`
+}
+
+type exportBlock struct {
+	UserID		uuid.UUID	`json:"userId"`
+	Username	string		`json:"username"`
+	HideMessages	bool		`json:"hideMessages"`
+	CreatedAt	time.Time	`json:"createdAt"`
+}
+
+// Writes the ZIP archive of everything stored about the user:
+//
+//	profile.json       account and profile fields
+//	memberships.json   conversations the user belongs to
+//	messages.json      every message sent by the user
+//	blocks.json        users blocked by the user
+//	attachments/       uploaded files, such as the avatar
+func (w *ExportWorker) writeArchive(ctx context.Context, out io.Writer, userID uuid.UUID) error {
+	user, err := w.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(out)
+
+	profile := exportProfile {
+		ID:			user.ID,
+		Username:		user.Username,
+		DisplayName:		user.DisplayName,
+		Bio:			user.Bio,
+		Pronouns:		user.Pronouns,
+		Timezone:		user.Timezone,
+		CreatedAt:		user.CreatedAt,
+		UpdatedAt:		user.UpdatedAt,
+		DeletionScheduledAt:	user.DeletionScheduledAt,
+	}
+	if err := writeJSONFile(zw, "profile.json", profile); err != nil {
+		return err
+	}
+
+	memberships, err := w.conversationRepo.GetMembershipsByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	exportMemberships := make([]exportMembership, 0, len(memberships))
+	for _, membership := range memberships {
+		exportMemberships = append(exportMemberships, exportMembership(*membership))
+	}
+	if err := writeJSONFile(zw, "memberships.json", exportMemberships); err != nil {
+		return err
+	}
+
+	if err := w.writeMessages(ctx, zw, userID); err != nil {
+		return err
+	}
+
+	blocks, err := w.blockRepo.GetBlocksByBlocker(ctx, userID)
+	if err != nil {
+		return err
+	}
+	exportBlocks := make([]exportBlock, 0, len(blocks))
+	for _, block := range blocks {
+		exportBlocks = append(exportBlocks, exportBlock {
+			UserID:		block.BlockedID,
+			Username:	block.BlockedUsername,
+			HideMessages:	block.HideMessages,
+			CreatedAt:	block.CreatedAt,
+		})
+	}
+	if err := writeJSONFile(zw, "blocks.json", exportBlocks); err != nil {
+		return err
+	}
+
+	if user.AvatarKey != "" {
+		if err := w.copyAttachment(ctx, zw, "attachments/avatar.png", user.AvatarKey); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// Messages are encoded one at a time as they are read from the database
+func (w *ExportWorker) writeMessages(ctx context.Context, zw *zip.Writer, userID uuid.UUID) error {
+	f, err := zw.Create("messages.json")
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(f, "["); err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	first := true
+	err = w.messageRepo.ForEachMessageBySender(ctx, userID, func(message *models.Message) error {
+		if !first {
+			if _, err := io.WriteString(f, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		mentionIDs := message.MentionIDs
+		if mentionIDs == nil {
+			mentionIDs = []uuid.UUID{}
+		}
+
+		return enc.Encode(exportMessage {
+			ID:		message.ID,
+			ConversationID:	message.ConversationID,
+			Content:	message.Content,
+			ContentType:	message.ContentType,
+			MentionIDs:	mentionIDs,
+			CreatedAt:	message.CreatedAt,
+			ExpiresAt:	message.ExpiresAt,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(f, "]")
+	return err
+}
+
+func (w *ExportWorker) copyAttachment(ctx context.Context, zw *zip.Writer, name, key string) error {
+	object, err := w.store.Get(ctx, key)
+	if stdErrors.Is(err, storage.ErrObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, object)
+	return err
+}
+
+func writeJSONFile(zw *zip.Writer, name string, v any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
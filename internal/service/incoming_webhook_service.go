@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	stdErrors "errors"
+	"io"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/EliasLd/gotalk-backend/internal/auth"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const MaxBotNameLength = 64
+
+// Defines business logic operations related to incoming webhooks.
+type IncomingWebhookService interface {
+	CreateIncomingWebhook(ctx context.Context, userID, conversationID uuid.UUID, name string) (*models.IncomingWebhook, string, error)
+	ListIncomingWebhooks(ctx context.Context, userID, conversationID uuid.UUID) ([]*models.IncomingWebhook, error)
+	RevokeIncomingWebhook(ctx context.Context, userID, conversationID, webhookID uuid.UUID) error
+	UploadBotAvatar(ctx context.Context, userID, conversationID, webhookID uuid.UUID, r io.Reader) (*models.User, error)
+	PostMessage(ctx context.Context, token, content string) (*models.Message, error)
+}
+
+// Concrete implementation of IncomingWebhookService.
+type incomingWebhookService struct {
+	repo			repository.IncomingWebhookRepository
+	conversationRepo	repository.ConversationRepository
+	messageService		MessageService
+	avatarService		AvatarService
+}
+
+// Creates a new IncomingWebhookService instance.
+func NewIncomingWebhookService(repo repository.IncomingWebhookRepository, conversationRepo repository.ConversationRepository, messageService MessageService, avatarService AvatarService) IncomingWebhookService {
+	return &incomingWebhookService {
+		repo:			repo,
+		conversationRepo:	conversationRepo,
+		messageService:		messageService,
+		avatarService:		avatarService,
+	}
+}
+
+func ValidateBotName(name string) error {
+	if name == "" || utf8.RuneCountInString(name) > MaxBotNameLength || hasForbiddenRunes(name, false) {
+		return errors.ErrBotNameInvalid
+	}
+	return nil
+}
+
+func (s *incomingWebhookService) requireAdmin(ctx context.Context, userID, conversationID uuid.UUID) error {
+	role, err := memberRole(ctx, s.conversationRepo, userID, conversationID)
+	if err != nil {
+		return err
+	}
+	if !role.IsAdmin() {
+		return errors.ErrConversationAdminRequired
+	}
+	return nil
+}
+
+// Returns the webhook if it belongs to the conversation and the user administers it
+func (s *incomingWebhookService) getWebhook(ctx context.Context, userID, conversationID, webhookID uuid.UUID) (*models.IncomingWebhook, error) {
+	if err := s.requireAdmin(ctx, userID, conversationID); err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.repo.GetIncomingWebhookByID(ctx, webhookID)
+	if stdErrors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.ErrIncomingWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if webhook.ConversationID != conversationID {
+		return nil, errors.ErrIncomingWebhookNotFound
+	}
+	return webhook, nil
+}
+
+// Creates the webhook and its bot identity, the returned token is not retrievable afterwards
+func (s *incomingWebhookService) CreateIncomingWebhook(ctx context.Context, userID, conversationID uuid.UUID, name string) (*models.IncomingWebhook, string, error) {
+	name = strings.TrimSpace(name)
+	if err := ValidateBotName(name); err != nil {
+		return nil, "", err
+	}
+
+	if err := s.requireAdmin(ctx, userID, conversationID); err != nil {
+		return nil, "", err
+	}
+
+	plain, hash, err := auth.GenerateIncomingWebhookToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	botID := uuid.New()
+	bot := &models.User {
+		ID:		botID,
+		// Bots share the users table, their username only has to be unique
+		Username:	"bot-" + strings.ReplaceAll(botID.String(), "-", ""),
+		DisplayName:	name,
+		IsBot:		true,
+		CreatedAt:	now,
+		UpdatedAt:	now,
+	}
+
+	webhook := &models.IncomingWebhook {
+		ID:		uuid.New(),
+		ConversationID:	conversationID,
+		CreatorID:	userID,
+		BotUserID:	botID,
+		TokenHash:	hash,
+		CreatedAt:	now,
+		Name:		name,
+	}
+
+	if err := s.repo.CreateIncomingWebhook(ctx, webhook, bot); err != nil {
+		return nil, "", err
+	}
+
+	return webhook, plain, nil
+}
+
+func (s *incomingWebhookService) ListIncomingWebhooks(ctx context.Context, userID, conversationID uuid.UUID) ([]*models.IncomingWebhook, error) {
+	if err := s.requireAdmin(ctx, userID, conversationID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetIncomingWebhooksByConversation(ctx, conversationID)
+}
+
+// Revoked webhooks stop accepting messages, those already posted are kept
+func (s *incomingWebhookService) RevokeIncomingWebhook(ctx context.Context, userID, conversationID, webhookID uuid.UUID) error {
+	webhook, err := s.getWebhook(ctx, userID, conversationID, webhookID)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteIncomingWebhook(ctx, webhook.ID)
+}
+
+// Sets the avatar displayed next to the bot's messages
+func (s *incomingWebhookService) UploadBotAvatar(ctx context.Context, userID, conversationID, webhookID uuid.UUID, r io.Reader) (*models.User, error) {
+	webhook, err := s.getWebhook(ctx, userID, conversationID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	return s.avatarService.UploadAvatar(ctx, webhook.BotUserID, r)
+}
+
+// Posts a message in the webhook's conversation on behalf of its bot
+func (s *incomingWebhookService) PostMessage(ctx context.Context, token, content string) (*models.Message, error) {
+	if !strings.HasPrefix(token, auth.IncomingWebhookTokenPrefix) {
+		return nil, errors.ErrIncomingWebhookNotFound
+	}
+
+	webhook, err := s.repo.GetIncomingWebhookByTokenHash(ctx, auth.HashAccessToken(token))
+	if stdErrors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.ErrIncomingWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := s.messageService.PostBotMessage(ctx, webhook.BotUserID, webhook.ConversationID, content)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.TouchIncomingWebhook(ctx, webhook.ID); err != nil {
+		log.Printf("failed to record incoming webhook %s usage: %v", webhook.ID, err)
+	}
+
+	return message, nil
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+)
+
+func TestValidateBotName(t *testing.T) {
+	tests := []struct {
+		name	string
+		botName	string
+		wantErr	error
+	}{
+		{"Valid", "Alertmanager", nil},
+		{"Empty", "", errors.ErrBotNameInvalid},
+		{"Too long", strings.Repeat("a", MaxBotNameLength+1), errors.ErrBotNameInvalid},
+		{"Control character", "bot\x07", errors.ErrBotNameInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateBotName(tt.botName); err != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPostMessage_RejectsForeignTokens(t *testing.T) {
+	// Tokens without the incoming webhook prefix never reach the repository
+	s := NewIncomingWebhookService(nil, nil, nil, nil)
+
+	if _, err := s.PostMessage(context.Background(), "gtp_notawebhooktoken", "hello"); err != errors.ErrIncomingWebhookNotFound {
+		t.Errorf("Expected ErrIncomingWebhookNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	stdErrors "errors"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Bounds applied to uploaded keys
+const (
+	MinPublicKeySize		= 32
+	MaxPublicKeySize		= 64
+	SignatureSize			= 64
+	MaxOneTimePreKeysPerUpload	= 100
+	MaxOneTimePreKeysPerDevice	= 200
+)
+
+// Defines business logic operations related to end-to-end encryption keys.
+// The server only relays public keys, signatures are verified by the clients.
+type KeyService interface {
+	RegisterDevice(ctx context.Context, userID, deviceID uuid.UUID, input RegisterDeviceInput) (*models.Device, error)
+	AddOneTimePreKeys(ctx context.Context, userID, deviceID uuid.UUID, preKeys []models.PreKey) (int, error)
+	ListDevices(ctx context.Context, userID uuid.UUID) ([]*models.Device, error)
+	DeleteDevice(ctx context.Context, userID, deviceID uuid.UUID) error
+	ClaimPreKeyBundles(ctx context.Context, requesterID, userID uuid.UUID) ([]*models.PreKeyBundle, error)
+}
+
+// Concrete implementation of KeyService.
+type keyService struct {
+	repo		repository.DeviceRepository
+	userRepo	repository.UserRepository
+	blockRepo	repository.BlockRepository
+}
+
+type RegisterDeviceInput struct {
+	IdentityKey		string
+	SignedPreKey		models.PreKey
+	SignedPreKeySignature	string
+	OneTimePreKeys		[]models.PreKey
+}
+
+// Creates a new KeyService instance.
+func NewKeyService(repo repository.DeviceRepository, userRepo repository.UserRepository, blockRepo repository.BlockRepository) KeyService {
+	return &keyService {
+		repo:		repo,
+		userRepo:	userRepo,
+		blockRepo:	blockRepo,
+	}
+}
+
+func decodedSize(encoded string) (int, bool) {
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, false
+	}
+	return len(decoded), true
+}
+
+func validatePublicKey(key string) error {
+	size, ok := decodedSize(key)
+	if !ok || size < MinPublicKeySize || size > MaxPublicKeySize {
+		return errors.ErrKeyInvalid
+	}
+	return nil
+}
+
+// Checks the one-time prekeys of an upload, whose IDs must be distinct
+func validateOneTimePreKeys(preKeys []models.PreKey) error {
+	if len(preKeys) > MaxOneTimePreKeysPerUpload {
+		return errors.ErrTooManyPreKeys
+	}
+
+	seen := make(map[int]bool, len(preKeys))
+	for _, key := range preKeys {
+		if key.KeyID < 0 || seen[key.KeyID] {
+			return errors.ErrKeyInvalid
+		}
+		if err := validatePublicKey(key.PublicKey); err != nil {
+			return err
+		}
+		seen[key.KeyID] = true
+	}
+	return nil
+}
+
+func ValidateRegisterDeviceInput(input RegisterDeviceInput) error {
+	if err := validatePublicKey(input.IdentityKey); err != nil {
+		return err
+	}
+	if input.SignedPreKey.KeyID < 0 {
+		return errors.ErrKeyInvalid
+	}
+	if err := validatePublicKey(input.SignedPreKey.PublicKey); err != nil {
+		return err
+	}
+	if size, ok := decodedSize(input.SignedPreKeySignature); !ok || size != SignatureSize {
+		return errors.ErrKeyInvalid
+	}
+	return validateOneTimePreKeys(input.OneTimePreKeys)
+}
+
+// Registers the device, or rotates its signed prekey when it is already registered.
+// The identity key of a device never changes, a new identity needs a new device.
+func (s *keyService) RegisterDevice(ctx context.Context, userID, deviceID uuid.UUID, input RegisterDeviceInput) (*models.Device, error) {
+	if err := ValidateRegisterDeviceInput(input); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkPreKeyLimit(ctx, deviceID, len(input.OneTimePreKeys)); err != nil {
+		return nil, err
+	}
+
+	device := &models.Device {
+		ID:			deviceID,
+		UserID:			userID,
+		IdentityKey:		input.IdentityKey,
+		SignedPreKeyID:		input.SignedPreKey.KeyID,
+		SignedPreKey:		input.SignedPreKey.PublicKey,
+		SignedPreKeySignature:	input.SignedPreKeySignature,
+		UpdatedAt:		time.Now().UTC(),
+	}
+
+	err := s.repo.UpsertDevice(ctx, device, input.OneTimePreKeys)
+	if stdErrors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.ErrDeviceKeyConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
+
+// Uploads are checked against the keys already stored, which are only counted once
+// the device exists. Re-uploaded IDs count twice, erring on the side of rejecting.
+func (s *keyService) checkPreKeyLimit(ctx context.Context, deviceID uuid.UUID, uploaded int) error {
+	stored, err := s.repo.CountOneTimePreKeys(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if stored + uploaded > MaxOneTimePreKeysPerDevice {
+		return errors.ErrTooManyPreKeys
+	}
+	return nil
+}
+
+// Returns the user's device, or ErrDeviceNotFound
+func (s *keyService) getOwnDevice(ctx context.Context, userID, deviceID uuid.UUID) (*models.Device, error) {
+	device, err := s.repo.GetDevice(ctx, deviceID)
+	if stdErrors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if device.UserID != userID {
+		return nil, errors.ErrDeviceNotFound
+	}
+	return device, nil
+}
+
+// Replenishes the device's one-time prekeys, returning how many it now holds
+func (s *keyService) AddOneTimePreKeys(ctx context.Context, userID, deviceID uuid.UUID, preKeys []models.PreKey) (int, error) {
+	if len(preKeys) == 0 {
+		return 0, errors.ErrKeyInvalid
+	}
+	if err := validateOneTimePreKeys(preKeys); err != nil {
+		return 0, err
+	}
+
+	if _, err := s.getOwnDevice(ctx, userID, deviceID); err != nil {
+		return 0, err
+	}
+
+	if err := s.checkPreKeyLimit(ctx, deviceID, len(preKeys)); err != nil {
+		return 0, err
+	}
+
+	if err := s.repo.AddOneTimePreKeys(ctx, deviceID, preKeys); err != nil {
+		return 0, err
+	}
+
+	return s.repo.CountOneTimePreKeys(ctx, deviceID)
+}
+
+func (s *keyService) ListDevices(ctx context.Context, userID uuid.UUID) ([]*models.Device, error) {
+	return s.repo.GetDevicesByUser(ctx, userID)
+}
+
+// Removes the device along with its keys, other users can no longer start sessions with it
+func (s *keyService) DeleteDevice(ctx context.Context, userID, deviceID uuid.UUID) error {
+	err := s.repo.DeleteDevice(ctx, userID, deviceID)
+	if stdErrors.Is(err, pgx.ErrNoRows) {
+		return errors.ErrDeviceNotFound
+	}
+	return err
+}
+
+// Hands out a bundle for each of the user's devices, consuming one one-time prekey per device.
+// Users who blocked the requester, or were blocked by them, are reported as not found.
+func (s *keyService) ClaimPreKeyBundles(ctx context.Context, requesterID, userID uuid.UUID) ([]*models.PreKeyBundle, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if stdErrors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.IsDeleted() || user.IsBot {
+		return nil, errors.ErrUserNotFound
+	}
+
+	if requesterID != userID {
+		blocked, err := s.blockRepo.IsBlockedEitherWay(ctx, requesterID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, errors.ErrUserNotFound
+		}
+	}
+
+	return s.repo.ClaimPreKeyBundles(ctx, userID)
+}
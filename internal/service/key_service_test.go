@@ -0,0 +1,54 @@
+package service
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+)
+
+func TestValidateRegisterDeviceInput(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, 33))
+	signature := base64.StdEncoding.EncodeToString(make([]byte, SignatureSize))
+
+	valid := func() RegisterDeviceInput {
+		return RegisterDeviceInput {
+			IdentityKey:		key,
+			SignedPreKey:		models.PreKey{KeyID: 1, PublicKey: key},
+			SignedPreKeySignature:	signature,
+			OneTimePreKeys:		[]models.PreKey{{KeyID: 1, PublicKey: key}, {KeyID: 2, PublicKey: key}},
+		}
+	}
+
+	tooMany := make([]models.PreKey, MaxOneTimePreKeysPerUpload+1)
+	for i := range tooMany {
+		tooMany[i] = models.PreKey{KeyID: i, PublicKey: key}
+	}
+
+	tests := []struct {
+		name	string
+		modify	func(*RegisterDeviceInput)
+		wantErr	error
+	}{
+		{"Valid", func(*RegisterDeviceInput) {}, nil},
+		{"Identity key not base64", func(in *RegisterDeviceInput) { in.IdentityKey = "not base64!" }, errors.ErrKeyInvalid},
+		{"Identity key too short", func(in *RegisterDeviceInput) { in.IdentityKey = base64.StdEncoding.EncodeToString(make([]byte, 16)) }, errors.ErrKeyInvalid},
+		{"Negative signed prekey ID", func(in *RegisterDeviceInput) { in.SignedPreKey.KeyID = -1 }, errors.ErrKeyInvalid},
+		{"Short signature", func(in *RegisterDeviceInput) { in.SignedPreKeySignature = key }, errors.ErrKeyInvalid},
+		{"Duplicate one-time prekey IDs", func(in *RegisterDeviceInput) { in.OneTimePreKeys[1].KeyID = 1 }, errors.ErrKeyInvalid},
+		{"Invalid one-time prekey", func(in *RegisterDeviceInput) { in.OneTimePreKeys[0].PublicKey = strings.Repeat("A", 200) }, errors.ErrKeyInvalid},
+		{"Too many one-time prekeys", func(in *RegisterDeviceInput) { in.OneTimePreKeys = tooMany }, errors.ErrTooManyPreKeys},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := valid()
+			tt.modify(&input)
+			if err := ValidateRegisterDeviceInput(input); err != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
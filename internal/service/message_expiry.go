@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/events"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+)
+
+// Background job handling disappearing messages.
+// Subscribers are notified as soon as a message expires, while the row itself
+// is only purged by a less frequent sweep once the notification went out.
+// History queries already hide expired messages in between.
+type MessageExpiryWorker struct {
+	repo		repository.MessageRepository
+	webhookRepo	repository.WebhookRepository
+	broker		events.Broker
+	notifyInterval	time.Duration
+	sweepInterval	time.Duration
+}
+
+// Creates a new MessageExpiryWorker instance.
+func NewMessageExpiryWorker(repo repository.MessageRepository, webhookRepo repository.WebhookRepository, broker events.Broker, notifyInterval, sweepInterval time.Duration) *MessageExpiryWorker {
+	return &MessageExpiryWorker {
+		repo:		repo,
+		webhookRepo:	webhookRepo,
+		broker:		broker,
+		notifyInterval:	notifyInterval,
+		sweepInterval:	sweepInterval,
+	}
+}
+
+// Blocks until ctx is cancelled
+func (w *MessageExpiryWorker) Run(ctx context.Context) {
+	notifyTicker := time.NewTicker(w.notifyInterval)
+	defer notifyTicker.Stop()
+	sweepTicker := time.NewTicker(w.sweepInterval)
+	defer sweepTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-notifyTicker.C:
+			if err := w.NotifyExpired(ctx); err != nil {
+				log.Printf("Failed to notify expired messages: %v", err)
+			}
+		case <-sweepTicker.C:
+			if err := w.Sweep(ctx); err != nil {
+				log.Printf("Failed to sweep expired messages: %v", err)
+			}
+		}
+	}
+}
+
+// Publishes a deletion event for every expired message not announced yet
+func (w *MessageExpiryWorker) NotifyExpired(ctx context.Context) error {
+	expired, err := w.repo.MarkExpiredMessagesNotified(ctx, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	for _, message := range expired {
+		w.broker.Publish(events.Event {
+			Type:		events.MessageDeleted,
+			ConversationID:	message.ConversationID,
+			Payload:	message,
+		})
+	}
+	return nil
+}
+
+// Deletes expired messages which subscribers have already been notified about,
+// along with the webhook deliveries carrying them
+func (w *MessageExpiryWorker) Sweep(ctx context.Context) error {
+	deleted, err := w.repo.DeleteNotifiedExpiredMessages(ctx)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		log.Printf("Swept %d expired messages", deleted)
+	}
+
+	deliveries, err := w.webhookRepo.DeleteExpiredDeliveries(ctx, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	if deliveries > 0 {
+		log.Printf("Swept %d webhook deliveries of expired messages", deliveries)
+	}
+	return nil
+}
@@ -0,0 +1,548 @@
+package service
+
+import (
+	"context"
+	stdErrors "errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/EliasLd/gotalk-backend/internal/events"
+	"github.com/EliasLd/gotalk-backend/internal/markdown"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+// Bounds applied to disappearing messages
+const (
+	MinMessageTTL = 5 * time.Second
+	MaxMessageTTL = 7 * 24 * time.Hour
+)
+
+// Longest accepted message, in characters
+const MaxMessageLength = 4000
+
+// Largest accepted encrypted message, in bytes of ciphertext
+const MaxEncryptedMessageSize = 64 << 10
+
+// Page size bounds for message history
+const (
+	DefaultMessagePageSize	= 50
+	MaxMessagePageSize	= 100
+)
+
+// Matches @username mentions not preceded by a word character (e.g. e-mail addresses)
+var mentionRegex = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_.-]{1,64})`)
+
+// Defines business logic operations related to messages.
+type MessageService interface {
+	SendMessage(ctx context.Context, senderID, conversationID uuid.UUID, input SendMessageInput) (*SendResult, error)
+	PostBotMessage(ctx context.Context, botID, conversationID uuid.UUID, content string) (*models.Message, error)
+	GetMessages(ctx context.Context, userID, conversationID uuid.UUID, before *time.Time, limit int) ([]*models.Message, error)
+	MarkMessageRead(ctx context.Context, userID, conversationID, messageID uuid.UUID) error
+	Subscribe(ctx context.Context, userID, conversationID uuid.UUID) (<-chan events.Event, func(), error)
+	ListCommands() []CommandInfo
+}
+
+// Concrete implementation of MessageService.
+type messageService struct {
+	repo			repository.MessageRepository
+	conversationRepo	repository.ConversationRepository
+	blockRepo		repository.BlockRepository
+	broker			events.Broker
+	filters			*FilterPipeline
+	commands		*CommandRegistry
+	webhooks		WebhookPublisher
+}
+
+type SendMessageInput struct {
+	// Parsed as a slash command when it starts with a single '/', unless encrypted
+	Content		string
+	// Text when empty, encrypted content is stored as-is
+	ContentType	models.ContentType
+	// Optional, the message disappears once it is elapsed
+	TTL		*time.Duration
+	// Counts the TTL from the first time another member reads the message instead of from sending
+	TTLFromRead	bool
+}
+
+type SendResult struct {
+	// Stored message, nil when a command posted nothing
+	Message	*models.Message
+	// Name of the command the content invoked, empty for plain messages
+	Command	string
+	// Command feedback only shown to the sender
+	Reply	string
+}
+
+// Creates a new MessageService instance.
+func NewMessageService(repo repository.MessageRepository, conversationRepo repository.ConversationRepository, blockRepo repository.BlockRepository, broker events.Broker, filters *FilterPipeline, commands *CommandRegistry, webhooks WebhookPublisher) MessageService {
+	return &messageService{
+		repo:			repo,
+		conversationRepo:	conversationRepo,
+		blockRepo:		blockRepo,
+		broker:			broker,
+		filters:		filters,
+		commands:		commands,
+		webhooks:		webhooks,
+	}
+}
+
+func ValidateMessageTTL(ttl time.Duration) error {
+	if ttl < MinMessageTTL || ttl > MaxMessageTTL {
+		return errors.ErrMessageTTLOutOfRange
+	}
+	return nil
+}
+
+// Checks the message length and that its Markdown renders safely
+func ValidateMessageContent(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return errors.ErrMessageEmpty
+	}
+	if utf8.RuneCountInString(content) > MaxMessageLength {
+		return errors.ErrMessageTooLong
+	}
+	if _, err := markdown.Render(content); err != nil {
+		if stdErrors.Is(err, markdown.ErrUnsafeLink) {
+			return errors.ErrMessageUnsafeLink
+		}
+		return errors.ErrMessageMalformed
+	}
+	return nil
+}
+
+// Returns the distinct usernames mentioned in the content
+func parseMentions(content string) []string {
+	seen := map[string]bool{}
+	usernames := []string{}
+	for _, match := range mentionRegex.FindAllStringSubmatch(content, -1) {
+		username := strings.TrimRight(match[1], ".-")
+		if username != "" && !seen[username] {
+			seen[username] = true
+			usernames = append(usernames, username)
+		}
+	}
+	return usernames
+}
+
+func (s *messageService) checkMembership(ctx context.Context, userID, conversationID uuid.UUID) error {
+	_, err := memberRole(ctx, s.conversationRepo, userID, conversationID)
+	return err
+}
+
+// Enforces the conversation's slow mode, owners and admins are exempt.
+// The returned function gives the slot back, for messages which end up not stored.
+func (s *messageService) claimPostingSlot(ctx context.Context, role models.MemberRole, userID, conversationID uuid.UUID) (func(), error) {
+	noop := func() {}
+	if role.IsAdmin() {
+		return noop, nil
+	}
+
+	conversation, err := s.conversationRepo.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conversation.SlowModeSeconds <= 0 {
+		return noop, nil
+	}
+
+	interval := time.Duration(conversation.SlowModeSeconds) * time.Second
+	retryAfter, err := s.conversationRepo.ClaimPostingSlot(ctx, conversationID, userID, interval)
+	if err != nil {
+		return nil, err
+	}
+	if retryAfter > 0 {
+		return nil, &errors.SlowModeError{RetryAfter: retryAfter}
+	}
+
+	return func() {
+		// Still released when the request was cancelled midway
+		if err := s.conversationRepo.ReleasePostingSlot(context.WithoutCancel(ctx), conversationID, userID); err != nil {
+			log.Printf("Failed to release posting slot of user %s in conversation %s: %v", userID, conversationID, err)
+		}
+	}, nil
+}
+
+// Refuses messages in a direct conversation once either member blocked the other
+func (s *messageService) checkDirectBlocks(ctx context.Context, userID, conversationID uuid.UUID) error {
+	blocked, err := s.blockRepo.IsDirectConversationBlocked(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return errors.ErrDirectConversationClosed
+	}
+	return nil
+}
+
+// Enforces mutes placed with /mute
+func (s *messageService) checkMuted(ctx context.Context, userID, conversationID uuid.UUID) error {
+	mutedUntil, err := s.conversationRepo.GetMemberMutedUntil(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if mutedUntil != nil {
+		return errors.ErrMemberMuted
+	}
+	return nil
+}
+
+func (s *messageService) SendMessage(ctx context.Context, senderID, conversationID uuid.UUID, input SendMessageInput) (*SendResult, error) {
+	if strings.TrimSpace(input.Content) == "" {
+		return nil, errors.ErrMessageEmpty
+	}
+
+	role, err := memberRole(ctx, s.conversationRepo, senderID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkMuted(ctx, senderID, conversationID); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkDirectBlocks(ctx, senderID, conversationID); err != nil {
+		return nil, err
+	}
+
+	if input.TTL != nil {
+		if err := ValidateMessageTTL(*input.TTL); err != nil {
+			return nil, err
+		}
+	}
+
+	switch input.ContentType {
+	case "", models.ContentTypeText:
+	case models.ContentTypeEncrypted:
+		message, err := s.postEncrypted(ctx, role, senderID, conversationID, input.Content, input.TTL, input.TTLFromRead)
+		if err != nil {
+			return nil, err
+		}
+		return &SendResult{Message: message}, nil
+	default:
+		return nil, errors.ErrContentTypeInvalid
+	}
+
+	if name, args, ok := ParseCommand(input.Content); ok {
+		return s.runCommand(ctx, &CommandContext {
+			Name:		name,
+			Args:		args,
+			SenderID:	senderID,
+			ConversationID:	conversationID,
+			Role:		role,
+			TTL:		input.TTL,
+			TTLFromRead:	input.TTLFromRead,
+			messages:	s,
+		})
+	}
+
+	message, err := s.post(ctx, role, senderID, conversationID, UnescapeCommand(input.Content), models.ContentTypeText, input.TTL, input.TTLFromRead)
+	if err != nil {
+		return nil, err
+	}
+	return &SendResult{Message: message}, nil
+}
+
+func (s *messageService) runCommand(ctx context.Context, cmd *CommandContext) (*SendResult, error) {
+	command, ok := s.commands.Lookup(cmd.Name)
+	if !ok {
+		return nil, &errors.UnknownCommandError{Name: cmd.Name, Available: s.commands.Names()}
+	}
+
+	result, err := command.Execute(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SendResult {
+		Message:	result.Message,
+		Command:	cmd.Name,
+		Reply:		result.Reply,
+	}, nil
+}
+
+// Filters, validates and stores a message sent by a member
+func (s *messageService) post(ctx context.Context, role models.MemberRole, senderID, conversationID uuid.UUID, content string, contentType models.ContentType, ttl *time.Duration, ttlFromRead bool) (*models.Message, error) {
+	content, err := s.filterContent(ctx, senderID, conversationID, content)
+	if err != nil {
+		return nil, err
+	}
+
+	// Checked last so that rejected messages do not use up the member's slot
+	release, err := s.claimPostingSlot(ctx, role, senderID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := s.createMessage(ctx, senderID, conversationID, content, contentType, ttl, ttlFromRead)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return message, nil
+}
+
+// Stores end-to-end encrypted content without running filters, commands or mention parsing,
+// the server cannot read it. Only direct conversations support encryption.
+func (s *messageService) postEncrypted(ctx context.Context, role models.MemberRole, senderID, conversationID uuid.UUID, content string, ttl *time.Duration, ttlFromRead bool) (*models.Message, error) {
+	if len(content) > MaxEncryptedMessageSize {
+		return nil, errors.ErrEncryptedMessageTooLarge
+	}
+
+	conversation, err := s.conversationRepo.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !conversation.IsDirect {
+		return nil, errors.ErrEncryptedRequiresDirect
+	}
+
+	release, err := s.claimPostingSlot(ctx, role, senderID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := s.createMessage(ctx, senderID, conversationID, content, models.ContentTypeEncrypted, ttl, ttlFromRead)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return message, nil
+}
+
+// Posts on behalf of a bot user, which is not a conversation member.
+// Callers are responsible for authorizing the bot to post in the conversation.
+func (s *messageService) PostBotMessage(ctx context.Context, botID, conversationID uuid.UUID, content string) (*models.Message, error) {
+	if strings.TrimSpace(content) == "" {
+		return nil, errors.ErrMessageEmpty
+	}
+
+	content, err := s.filterContent(ctx, botID, conversationID, content)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.createMessage(ctx, botID, conversationID, content, models.ContentTypeText, nil, false)
+}
+
+// Runs the content filters, then validates what they let through
+// as rewrites may change the content
+func (s *messageService) filterContent(ctx context.Context, senderID, conversationID uuid.UUID, content string) (string, error) {
+	content, err := s.filters.Run(ctx, OutgoingMessage {
+		SenderID:	senderID,
+		ConversationID:	conversationID,
+		Content:	content,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := ValidateMessageContent(content); err != nil {
+		return "", err
+	}
+	return content, nil
+}
+
+// Resolves mentions, stores the message and notifies subscribers
+func (s *messageService) createMessage(ctx context.Context, senderID, conversationID uuid.UUID, content string, contentType models.ContentType, ttl *time.Duration, ttlFromRead bool) (*models.Message, error) {
+	// Encrypted content cannot mention anyone, even if the ciphertext looks like it does
+	var usernames []string
+	if contentType != models.ContentTypeEncrypted {
+		usernames = parseMentions(content)
+	}
+
+	// Members who blocked the sender are resolved like the others, so that the
+	// mentions do not reveal the block. Subscribe leaves out their notification.
+	mentionIDs := []uuid.UUID{}
+	if len(usernames) > 0 {
+		var err error
+		mentionIDs, err = s.repo.ResolveMentions(ctx, conversationID, usernames)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// messages.created_at has no time zone, always store UTC
+	now := time.Now().UTC()
+	message := &models.Message {
+		ID:		uuid.New(),
+		ConversationID:	conversationID,
+		SenderID:	senderID,
+		Content:	content,
+		ContentType:	contentType,
+		MentionIDs:	mentionIDs,
+		CreatedAt:	now,
+	}
+
+	if ttl != nil {
+		ttlSeconds := int(*ttl / time.Second)
+		message.TTLSeconds = &ttlSeconds
+		// Otherwise the countdown starts on the first read
+		if !ttlFromRead {
+			expiresAt := now.Add(*ttl)
+			message.ExpiresAt = &expiresAt
+		}
+	}
+
+	if err := s.repo.CreateMessage(ctx, message); err != nil {
+		return nil, err
+	}
+
+	s.broker.Publish(events.Event {
+		Type:		events.MessageCreated,
+		ConversationID:	conversationID,
+		Payload:	message,
+	})
+	publishWebhook(s.webhooks, ctx, models.WebhookMessageCreated, &conversationID, newWebhookMessage(message))
+
+	return message, nil
+}
+
+func (s *messageService) ListCommands() []CommandInfo {
+	return s.commands.List()
+}
+
+func (s *messageService) GetMessages(ctx context.Context, userID, conversationID uuid.UUID, before *time.Time, limit int) ([]*models.Message, error) {
+	if err := s.checkMembership(ctx, userID, conversationID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultMessagePageSize
+	}
+	if limit > MaxMessagePageSize {
+		limit = MaxMessagePageSize
+	}
+
+	cursor := time.Now().UTC()
+	if before != nil {
+		cursor = before.UTC()
+	}
+
+	return s.repo.GetMessagesByConversation(ctx, conversationID, userID, cursor, limit)
+}
+
+// Starts the countdown of a message whose TTL counts from the first read.
+// Reads of other messages, or by the sender, change nothing.
+func (s *messageService) MarkMessageRead(ctx context.Context, userID, conversationID, messageID uuid.UUID) error {
+	if err := s.checkMembership(ctx, userID, conversationID); err != nil {
+		return err
+	}
+
+	return s.repo.StartReadExpiry(ctx, conversationID, messageID, userID)
+}
+
+// Returns the conversation's real-time events, the caller must invoke the returned function once done.
+// The subscriber's blocks are looked up for every message, so that blocks placed
+// while subscribed apply right away: messages from users the subscriber blocked
+// and chose to hide are left out, and their mentions do not notify the subscriber.
+func (s *messageService) Subscribe(ctx context.Context, userID, conversationID uuid.UUID) (<-chan events.Event, func(), error) {
+	if err := s.checkMembership(ctx, userID, conversationID); err != nil {
+		return nil, nil, err
+	}
+
+	// Subscribed before loading the blocks, so that no change is missed in between
+	updates, unsubscribeUser := s.broker.SubscribeUser(userID)
+	blocks, err := s.loadBlocks(ctx, userID)
+	if err != nil {
+		unsubscribeUser()
+		return nil, nil, err
+	}
+
+	ch, unsubscribeConversation := s.broker.Subscribe(conversationID)
+	unsubscribe := func() {
+		unsubscribeConversation()
+		unsubscribeUser()
+	}
+
+	// The broker closes ch on unsubscribe, which ends the forwarding loop
+	filtered := make(chan events.Event, cap(ch))
+	go func() {
+		defer close(filtered)
+		for {
+			select {
+			case event, ok := <-ch:
+				if !ok {
+					return
+				}
+				event, ok = applyBlocks(userID, blocks, event)
+				if !ok {
+					continue
+				}
+				// Never block, the reader may already be gone
+				select {
+				case filtered <- event:
+				default:
+				}
+			case event, ok := <-updates:
+				if !ok {
+					updates = nil
+					continue
+				}
+				if event.Type != events.BlocksUpdated {
+					continue
+				}
+				reloaded, err := s.loadBlocks(ctx, userID)
+				if err != nil {
+					// The stream is over once ctx is done, no need to log that
+					if ctx.Err() == nil {
+						log.Printf("Failed to reload blocks of user %s, keeping the previous ones: %v", userID, err)
+					}
+					continue
+				}
+				blocks = reloaded
+			}
+		}
+	}()
+
+	return filtered, unsubscribe, nil
+}
+
+// Returns the users blocked by blockerID, mapped to whether their messages are hidden
+func (s *messageService) loadBlocks(ctx context.Context, blockerID uuid.UUID) (map[uuid.UUID]bool, error) {
+	list, err := s.blockRepo.GetBlocksByBlocker(ctx, blockerID)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make(map[uuid.UUID]bool, len(list))
+	for _, block := range list {
+		blocks[block.BlockedID] = block.HideMessages
+	}
+	return blocks, nil
+}
+
+func applyBlocks(subscriberID uuid.UUID, blocks map[uuid.UUID]bool, event events.Event) (events.Event, bool) {
+	message, ok := event.Payload.(*models.Message)
+	if !ok || message.SenderID == subscriberID {
+		return event, true
+	}
+
+	hideMessages, blocked := blocks[message.SenderID]
+	if !blocked {
+		return event, true
+	}
+	if hideMessages {
+		return event, false
+	}
+
+	// Still shown, without notifying the blocker of a mention
+	mentionIDs := make([]uuid.UUID, 0, len(message.MentionIDs))
+	for _, id := range message.MentionIDs {
+		if id != subscriberID {
+			mentionIDs = append(mentionIDs, id)
+		}
+	}
+	if len(mentionIDs) != len(message.MentionIDs) {
+		// Shared with the other subscribers, which must keep the mention
+		copied := *message
+		copied.MentionIDs = mentionIDs
+		event.Payload = &copied
+	}
+	return event, true
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/events"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		content	string
+		want	[]string
+	}{
+		{"hello @alice and @bob", []string{"alice", "bob"}},
+		{"@alice, @alice again", []string{"alice"}},
+		{"mail me at someone@example.com", []string{}},
+		{"thanks @john.doe.", []string{"john.doe"}},
+		{"no mentions here", []string{}},
+	}
+
+	for _, tt := range tests {
+		got := parseMentions(tt.content)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseMentions(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestValidateMessageTTL(t *testing.T) {
+	tests := []struct {
+		ttl	time.Duration
+		wantErr	error
+	}{
+		{time.Second, errors.ErrMessageTTLOutOfRange},
+		{MinMessageTTL, nil},
+		{time.Hour, nil},
+		{MaxMessageTTL, nil},
+		{MaxMessageTTL + time.Second, errors.ErrMessageTTLOutOfRange},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateMessageTTL(tt.ttl); err != tt.wantErr {
+			t.Errorf("ValidateMessageTTL(%s) = %v, want %v", tt.ttl, err, tt.wantErr)
+		}
+	}
+}
+
+// Every user is a member of every conversation
+type memberConversationRepository struct {
+	repository.ConversationRepository
+}
+
+func (r *memberConversationRepository) GetMemberRole(ctx context.Context, conversationID, userID uuid.UUID) (models.MemberRole, error) {
+	return models.RoleMember, nil
+}
+
+// Every user exists
+type existingUserRepository struct {
+	repository.UserRepository
+}
+
+func (r *existingUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return &models.User{ID: id}, nil
+}
+
+// BlockRepository keeping blocks in memory
+type memoryBlockRepository struct {
+	repository.BlockRepository
+
+	mu	sync.Mutex
+	blocks	map[[2]uuid.UUID]*models.Block
+	// Receives the blocker each time its blocks are loaded
+	loaded	chan uuid.UUID
+}
+
+func (r *memoryBlockRepository) CreateBlock(ctx context.Context, block *models.Block) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.blocks[[2]uuid.UUID{block.BlockerID, block.BlockedID}] = block
+	return nil
+}
+
+func (r *memoryBlockRepository) DeleteBlock(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.blocks, [2]uuid.UUID{blockerID, blockedID})
+	return nil
+}
+
+func (r *memoryBlockRepository) GetBlocksByBlocker(ctx context.Context, blockerID uuid.UUID) ([]*models.Block, error) {
+	r.mu.Lock()
+	blocks := []*models.Block{}
+	for key, block := range r.blocks {
+		if key[0] == blockerID {
+			blocks = append(blocks, block)
+		}
+	}
+	r.mu.Unlock()
+
+	r.loaded <- blockerID
+	return blocks, nil
+}
+
+func TestSubscribe_AppliesBlocksWhileSubscribed(t *testing.T) {
+	ctx := context.Background()
+	broker := events.NewBroker()
+	blocks := &memoryBlockRepository{blocks: map[[2]uuid.UUID]*models.Block{}, loaded: make(chan uuid.UUID, 10)}
+	messages := NewMessageService(nil, &memberConversationRepository{}, blocks, broker, nil, nil, nil)
+	blockService := NewBlockService(blocks, &existingUserRepository{}, broker)
+
+	subscriber, sender, other := uuid.New(), uuid.New(), uuid.New()
+	conversationID := uuid.New()
+
+	ch, unsubscribe, err := messages.Subscribe(ctx, subscriber, conversationID)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+
+	// Blocks are loaded once when subscribing, then on each change
+	waitLoaded := func() {
+		t.Helper()
+		select {
+		case <-blocks.loaded:
+		case <-time.After(time.Second):
+			t.Fatal("Expected the blocks to be loaded")
+		}
+	}
+	waitLoaded()
+
+	publish := func(senderID uuid.UUID, content string) *models.Message {
+		message := &models.Message {
+			ID:		uuid.New(),
+			ConversationID:	conversationID,
+			SenderID:	senderID,
+			Content:	content,
+			MentionIDs:	[]uuid.UUID{subscriber, other},
+		}
+		broker.Publish(events.Event{Type: events.MessageCreated, ConversationID: conversationID, Payload: message})
+		return message
+	}
+	receive := func() *models.Message {
+		t.Helper()
+		select {
+		case event := <-ch:
+			return event.Payload.(*models.Message)
+		case <-time.After(time.Second):
+			t.Fatal("Expected an event")
+			return nil
+		}
+	}
+
+	// Blocked after subscribing, messages stay visible without the mention
+	if err := blockService.BlockUser(ctx, subscriber, sender, false); err != nil {
+		t.Fatalf("BlockUser failed: %v", err)
+	}
+	waitLoaded()
+	published := publish(sender, "visible")
+	received := receive()
+	if received.Content != "visible" || !reflect.DeepEqual(received.MentionIDs, []uuid.UUID{other}) {
+		t.Errorf("Expected the message without the blocker's mention, got %+v", received)
+	}
+	if len(published.MentionIDs) != 2 {
+		t.Errorf("The published message must keep its mentions, got %v", published.MentionIDs)
+	}
+
+	// Hidden from then on
+	if err := blockService.BlockUser(ctx, subscriber, sender, true); err != nil {
+		t.Fatalf("BlockUser failed: %v", err)
+	}
+	waitLoaded()
+	publish(sender, "hidden")
+	publish(other, "shown")
+	if received := receive(); received.Content != "shown" {
+		t.Errorf("Expected the hidden message to be left out, got %q", received.Content)
+	}
+
+	// Shown again once unblocked
+	if err := blockService.UnblockUser(ctx, subscriber, sender); err != nil {
+		t.Fatalf("UnblockUser failed: %v", err)
+	}
+	waitLoaded()
+	publish(sender, "unblocked")
+	if received := receive(); received.Content != "unblocked" || len(received.MentionIDs) != 2 {
+		t.Errorf("Expected the message with its mentions, got %+v", received)
+	}
+}
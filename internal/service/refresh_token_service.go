@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	stdErrors "errors"
+	"log"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/auth"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Lifetime of a refresh token, each rotation hands out a token valid this long again
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+// Short-lived access token along with the refresh token renewing it
+type TokenPair struct {
+	AccessToken		string
+	// Lifetime of AccessToken
+	ExpiresIn		time.Duration
+	RefreshToken		string
+	RefreshTokenExpiresAt	time.Time
+	SessionID		uuid.UUID
+}
+
+// Defines business logic operations related to refresh tokens.
+type RefreshTokenService interface {
+	IssueTokens(ctx context.Context, user *models.User, device SessionDevice) (*TokenPair, error)
+	RefreshTokens(ctx context.Context, refreshToken string, ipAddress string) (*TokenPair, error)
+	RevokeRefreshToken(ctx context.Context, userID uuid.UUID, refreshToken string) error
+}
+
+// Concrete implementation of RefreshTokenService.
+type refreshTokenService struct {
+	repo		repository.RefreshTokenRepository
+	sessions	repository.SessionRepository
+}
+
+// Creates a new RefreshTokenService instance.
+func NewRefreshTokenService(repo repository.RefreshTokenRepository, sessions repository.SessionRepository) RefreshTokenService {
+	return &refreshTokenService {
+		repo:		repo,
+		sessions:	sessions,
+	}
+}
+
+// Opens a session on the device and starts its token family, called once the user has logged in
+func (s *refreshTokenService) IssueTokens(ctx context.Context, user *models.User, device SessionDevice) (*TokenPair, error) {
+	if err := ValidateDeviceName(device.Name); err != nil {
+		return nil, err
+	}
+
+	// Expired tokens of earlier logins cannot be reused anymore, no need to keep them
+	if err := s.repo.DeleteExpiredRefreshTokens(ctx, user.ID); err != nil {
+		log.Printf("Failed to delete expired refresh tokens of user %s: %v", user.ID, err)
+	}
+
+	// The session ID doubles as the family ID of its refresh tokens
+	session := newSession(user.ID, device)
+	refreshToken, plain, err := newRefreshToken(user.ID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	session.CreatedAt = refreshToken.CreatedAt
+	session.LastSeenAt = refreshToken.CreatedAt
+	session.ExpiresAt = refreshToken.ExpiresAt
+	if err := s.sessions.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateRefreshToken(ctx, refreshToken); err != nil {
+		return nil, err
+	}
+
+	return newTokenPair(user.ID, refreshToken, plain)
+}
+
+// Exchanges a refresh token for a new pair. A token can only be exchanged
+// once: presenting it again means it leaked, so its whole family is revoked.
+func (s *refreshTokenService) RefreshTokens(ctx context.Context, plain string, ipAddress string) (*TokenPair, error) {
+	if !auth.IsRefreshToken(plain) {
+		return nil, errors.ErrInvalidRefreshToken
+	}
+
+	used, err := s.repo.GetRefreshTokenByHash(ctx, auth.HashAccessToken(plain))
+	if err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if used.RevokedAt != nil || used.IsExpired(time.Now()) {
+		return nil, errors.ErrInvalidRefreshToken
+	}
+	if used.UsedAt != nil {
+		return nil, s.revokeFamily(ctx, used)
+	}
+
+	next, nextPlain, err := newRefreshToken(used.UserID, used.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lost a race with another exchange of the same token, which is reuse too
+	if err := s.repo.RotateRefreshToken(ctx, used.ID, next); err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return nil, s.revokeFamily(ctx, used)
+		}
+		return nil, err
+	}
+
+	if err := s.sessions.TouchSession(ctx, used.FamilyID, ipAddress, next.ExpiresAt); err != nil {
+		log.Printf("Failed to record the activity of session %s: %v", used.FamilyID, err)
+	}
+
+	return newTokenPair(used.UserID, next, nextPlain)
+}
+
+// Revokes the family of one of the user's refresh tokens, on logout.
+// Unknown tokens are ignored, the session is over either way.
+func (s *refreshTokenService) RevokeRefreshToken(ctx context.Context, userID uuid.UUID, plain string) error {
+	if !auth.IsRefreshToken(plain) {
+		return nil
+	}
+
+	token, err := s.repo.GetRefreshTokenByHash(ctx, auth.HashAccessToken(plain))
+	if stdErrors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if token.UserID != userID {
+		return nil
+	}
+
+	return s.repo.RevokeRefreshTokenFamily(ctx, token.FamilyID)
+}
+
+func (s *refreshTokenService) revokeFamily(ctx context.Context, reused *models.RefreshToken) error {
+	log.Printf("Refresh token %s of user %s reused, revoking token family %s", reused.ID, reused.UserID, reused.FamilyID)
+	if err := s.repo.RevokeRefreshTokenFamily(ctx, reused.FamilyID); err != nil {
+		return err
+	}
+	// Its access tokens are refused too, another exchange may have revoked it first
+	if err := s.sessions.RevokeSession(ctx, reused.UserID, reused.FamilyID); err != nil && !stdErrors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	return errors.ErrRefreshTokenReused
+}
+
+func newRefreshToken(userID, familyID uuid.UUID) (*models.RefreshToken, string, error) {
+	plain, hash, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	token := &models.RefreshToken {
+		ID:		uuid.New(),
+		FamilyID:	familyID,
+		UserID:		userID,
+		TokenHash:	hash,
+		CreatedAt:	now,
+		ExpiresAt:	now.Add(RefreshTokenTTL),
+	}
+	return token, plain, nil
+}
+
+func newTokenPair(userID uuid.UUID, refreshToken *models.RefreshToken, plain string) (*TokenPair, error) {
+	accessToken, err := auth.GenerateToken(&models.User{ID: userID}, refreshToken.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair {
+		AccessToken:		accessToken,
+		ExpiresIn:		auth.AccessTokenTTL,
+		RefreshToken:		plain,
+		RefreshTokenExpiresAt:	refreshToken.ExpiresAt,
+		SessionID:		refreshToken.FamilyID,
+	}, nil
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/auth"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// In-memory RefreshTokenRepository
+type memoryRefreshTokenRepository struct {
+	mu	sync.Mutex
+	tokens	map[string]*models.RefreshToken
+}
+
+func (r *memoryRefreshTokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *token
+	r.tokens[token.TokenHash] = &copied
+	return nil
+}
+
+func (r *memoryRefreshTokenRepository) GetRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[hash]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (r *memoryRefreshTokenRepository) RotateRefreshToken(ctx context.Context, usedID uuid.UUID, next *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.ID == usedID && token.UsedAt == nil && token.RevokedAt == nil {
+			now := time.Now()
+			token.UsedAt = &now
+			copied := *next
+			r.tokens[next.TokenHash] = &copied
+			return nil
+		}
+	}
+	return pgx.ErrNoRows
+}
+
+func (r *memoryRefreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *memoryRefreshTokenRepository) DeleteExpiredRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	return nil
+}
+
+// In-memory SessionRepository
+type memorySessionRepository struct {
+	mu		sync.Mutex
+	sessions	map[uuid.UUID]*models.Session
+}
+
+func newMemorySessionRepository() *memorySessionRepository {
+	return &memorySessionRepository{sessions: map[uuid.UUID]*models.Session{}}
+}
+
+func (r *memorySessionRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *session
+	r.sessions[session.ID] = &copied
+	return nil
+}
+
+func (r *memorySessionRepository) GetActiveSessionsByUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sessions []*models.Session
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			copied := *session
+			sessions = append(sessions, &copied)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *memorySessionRepository) TouchSession(ctx context.Context, id uuid.UUID, ipAddress string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if session, ok := r.sessions[id]; ok {
+		session.LastSeenAt = time.Now()
+		session.IPAddress = ipAddress
+		session.ExpiresAt = expiresAt
+	}
+	return nil
+}
+
+func (r *memorySessionRepository) RevokeSession(ctx context.Context, userID, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return pgx.ErrNoRows
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	return nil
+}
+
+func (r *memorySessionRepository) RevokeOtherSessions(ctx context.Context, userID, keptID uuid.UUID) ([]uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	revoked := []uuid.UUID{}
+	for id, session := range r.sessions {
+		if session.UserID == userID && id != keptID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			revoked = append(revoked, id)
+		}
+	}
+	return revoked, nil
+}
+
+func (r *memorySessionRepository) RevokeUserSessions(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	return r.RevokeOtherSessions(ctx, userID, uuid.Nil)
+}
+
+func (r *memorySessionRepository) DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deleted int64
+	for id, session := range r.sessions {
+		if !session.ExpiresAt.After(now) {
+			delete(r.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *memorySessionRepository) GetSessionsRevokedSince(ctx context.Context, since time.Time) (map[uuid.UUID]time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	revoked := map[uuid.UUID]time.Time{}
+	for id, session := range r.sessions {
+		if session.RevokedAt != nil && session.RevokedAt.After(since) {
+			revoked[id] = *session.RevokedAt
+		}
+	}
+	return revoked, nil
+}
+
+func TestRefreshTokens_Rotation(t *testing.T) {
+	auth.SetupTestKeys(t)
+	sessions := newMemorySessionRepository()
+	s := NewRefreshTokenService(&memoryRefreshTokenRepository{tokens: map[string]*models.RefreshToken{}}, sessions)
+	ctx := context.Background()
+	user := &models.User{ID: uuid.New()}
+
+	issued, err := s.IssueTokens(ctx, user, SessionDevice{Name: "Phone", IPAddress: "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("IssueTokens failed: %v", err)
+	}
+	claims, err := auth.ValidateToken(issued.AccessToken)
+	if err != nil || claims.UserID != user.ID {
+		t.Fatalf("Expected an access token for the user, got %v", err)
+	}
+	if claims.SessionID != issued.SessionID || sessions.sessions[issued.SessionID].DeviceName != "Phone" {
+		t.Fatalf("Expected the access token bound to a new session")
+	}
+
+	rotated, err := s.RefreshTokens(ctx, issued.RefreshToken, "192.0.2.2")
+	if err != nil {
+		t.Fatalf("RefreshTokens failed: %v", err)
+	}
+	if rotated.RefreshToken == issued.RefreshToken {
+		t.Errorf("Expected a new refresh token")
+	}
+	if rotated.SessionID != issued.SessionID || sessions.sessions[issued.SessionID].IPAddress != "192.0.2.2" {
+		t.Errorf("Expected the session to be kept and its address updated")
+	}
+
+	// The successor keeps working until the family is revoked
+	next, err := s.RefreshTokens(ctx, rotated.RefreshToken, "")
+	if err != nil {
+		t.Fatalf("RefreshTokens with the rotated token failed: %v", err)
+	}
+
+	if _, err := s.RefreshTokens(ctx, issued.RefreshToken, ""); err != errors.ErrRefreshTokenReused {
+		t.Fatalf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := s.RefreshTokens(ctx, next.RefreshToken, ""); err != errors.ErrInvalidRefreshToken {
+		t.Errorf("Expected the whole family revoked, got %v", err)
+	}
+	if sessions.sessions[issued.SessionID].RevokedAt == nil {
+		t.Errorf("Expected the session revoked along with its family")
+	}
+
+	// Other logins are not affected
+	other, err := s.IssueTokens(ctx, user, SessionDevice{})
+	if err != nil {
+		t.Fatalf("IssueTokens failed: %v", err)
+	}
+	if _, err := s.RefreshTokens(ctx, other.RefreshToken, ""); err != nil {
+		t.Errorf("Expected another login to keep working, got %v", err)
+	}
+}
+
+func TestRefreshTokens_Invalid(t *testing.T) {
+	repo := &memoryRefreshTokenRepository{tokens: map[string]*models.RefreshToken{}}
+	s := NewRefreshTokenService(repo, newMemorySessionRepository())
+	ctx := context.Background()
+
+	plain, hash, _ := auth.GenerateRefreshToken()
+	repo.CreateRefreshToken(ctx, &models.RefreshToken {
+		ID:		uuid.New(),
+		FamilyID:	uuid.New(),
+		UserID:		uuid.New(),
+		TokenHash:	hash,
+		ExpiresAt:	time.Now().Add(-time.Minute),
+	})
+	unknown, _, _ := auth.GenerateRefreshToken()
+
+	tests := []struct {
+		name	string
+		token	string
+	}{
+		{"Expired", plain},
+		{"Unknown", unknown},
+		{"Access token", "gtp_abcdef"},
+		{"Empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.RefreshTokens(ctx, tt.token, ""); err != errors.ErrInvalidRefreshToken {
+				t.Errorf("Expected ErrInvalidRefreshToken, got %v", err)
+			}
+		})
+	}
+}
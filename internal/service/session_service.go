@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	stdErrors "errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const MaxDeviceNameLength = 64
+
+// Longer user agents are truncated
+const maxUserAgentLength = 512
+
+// Describes where a session is opened or refreshed from
+type SessionDevice struct {
+	// Chosen by the client, may be empty
+	Name		string
+	UserAgent	string
+	IPAddress	string
+}
+
+func ValidateDeviceName(name string) error {
+	if utf8.RuneCountInString(name) > MaxDeviceNameLength || hasForbiddenRunes(name, false) {
+		return errors.ErrDeviceNameInvalid
+	}
+	return nil
+}
+
+// Defines business logic operations related to the sessions of a user.
+type SessionService interface {
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]*models.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error
+	RevokeOtherSessions(ctx context.Context, userID, currentID uuid.UUID) (int, error)
+}
+
+// Concrete implementation of SessionService.
+type sessionService struct {
+	repo		repository.SessionRepository
+	revocations	TokenRevocationService
+}
+
+// Creates a new SessionService instance.
+func NewSessionService(repo repository.SessionRepository, revocations TokenRevocationService) SessionService {
+	return &sessionService {
+		repo:		repo,
+		revocations:	revocations,
+	}
+}
+
+func (s *sessionService) ListSessions(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	return s.repo.GetActiveSessionsByUser(ctx, userID)
+}
+
+// Signs the session out: its tokens are refused and its real-time connections closed
+func (s *sessionService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	if err := s.repo.RevokeSession(ctx, userID, sessionID); err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return errors.ErrSessionNotFound
+		}
+		return err
+	}
+
+	s.revocations.SessionsRevoked(sessionID)
+	return nil
+}
+
+// Signs out every session of the user but the current one, returns how many were
+func (s *sessionService) RevokeOtherSessions(ctx context.Context, userID, currentID uuid.UUID) (int, error) {
+	revoked, err := s.repo.RevokeOtherSessions(ctx, userID, currentID)
+	if err != nil {
+		return 0, err
+	}
+
+	s.revocations.SessionsRevoked(revoked...)
+	return len(revoked), nil
+}
+
+// Builds a new session of the user, device details are truncated to fit
+func newSession(userID uuid.UUID, device SessionDevice) *models.Session {
+	// Invalid bytes are dropped first, the cut below only expects a truncated rune
+	userAgent := strings.ToValidUTF8(device.UserAgent, "")
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+		// Cut on a rune boundary
+		for !utf8.ValidString(userAgent) {
+			userAgent = userAgent[:len(userAgent)-1]
+		}
+	}
+
+	return &models.Session {
+		ID:		uuid.New(),
+		UserID:		userID,
+		DeviceName:	device.Name,
+		UserAgent:	userAgent,
+		IPAddress:	device.IPAddress,
+	}
+}
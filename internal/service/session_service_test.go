@@ -0,0 +1,31 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+func TestNewSession_UserAgent(t *testing.T) {
+	tests := []struct {
+		name		string
+		userAgent	string
+		want		string
+	}{
+		{"Short", "gotalk/1.0", "gotalk/1.0"},
+		{"Invalid bytes", "gotalk\xff/1.0", "gotalk/1.0"},
+		{"Cut on a rune", strings.Repeat("a", maxUserAgentLength-1) + "é", strings.Repeat("a", maxUserAgentLength-1)},
+		{"Invalid bytes then long", "\xff" + strings.Repeat("b", maxUserAgentLength*2), strings.Repeat("b", maxUserAgentLength)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := newSession(uuid.New(), SessionDevice{UserAgent: tt.userAgent})
+			if session.UserAgent != tt.want || !utf8.ValidString(session.UserAgent) {
+				t.Errorf("Expected user agent %q, got %q", tt.want, session.UserAgent)
+			}
+		})
+	}
+}
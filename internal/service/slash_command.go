@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+// Command names are matched case-insensitively, against their lowercase form
+var commandNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// Invocation of a slash command, along with the means to post on its behalf
+type CommandContext struct {
+	Name		string
+	// Everything after the command name, trimmed
+	Args		string
+	SenderID	uuid.UUID
+	ConversationID	uuid.UUID
+	// Role of the sender, who is always a conversation member
+	Role		models.MemberRole
+	// Lifetime requested for the messages the command posts as the sender
+	TTL		*time.Duration
+	// Whether TTL counts from the first read instead of from sending
+	TTLFromRead	bool
+
+	messages	*messageService
+}
+
+// Posts a message as the sender, content filters and slow mode apply
+func (c *CommandContext) Post(ctx context.Context, content string, contentType models.ContentType) (*models.Message, error) {
+	return c.messages.post(ctx, c.Role, c.SenderID, c.ConversationID, content, contentType, c.TTL, c.TTLFromRead)
+}
+
+// Posts a message as a bot user, content filters apply
+func (c *CommandContext) PostAsBot(ctx context.Context, botID uuid.UUID, content string) (*models.Message, error) {
+	return c.messages.PostBotMessage(ctx, botID, c.ConversationID, content)
+}
+
+type CommandResult struct {
+	// Message posted by the command, if any
+	Message	*models.Message
+	// Feedback only shown to the sender
+	Reply	string
+}
+
+// Handles messages starting with /<name>.
+type SlashCommand interface {
+	// Shown in the command list and usage errors, e.g. "/invite @user"
+	Usage() string
+	Description() string
+	Execute(ctx context.Context, cmd *CommandContext) (*CommandResult, error)
+}
+
+// Describes a registered command to clients
+type CommandInfo struct {
+	Name		string	`json:"name"`
+	Usage		string	`json:"usage"`
+	Description	string	`json:"description"`
+}
+
+// Commands available in every conversation.
+// Register is meant to be called during startup, not concurrently with lookups.
+type CommandRegistry struct {
+	commands map[string]SlashCommand
+}
+
+// Creates an empty CommandRegistry, see NewBuiltinCommands for the default one
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{commands: make(map[string]SlashCommand)}
+}
+
+// Makes the command available under the given name
+func (r *CommandRegistry) Register(name string, command SlashCommand) error {
+	name = strings.ToLower(name)
+	if !commandNameRegex.MatchString(name) {
+		return fmt.Errorf("%w: invalid command name %q", errors.ErrInvalidCommandConfig, name)
+	}
+	if _, ok := r.commands[name]; ok {
+		return fmt.Errorf("%w: command /%s is already registered", errors.ErrInvalidCommandConfig, name)
+	}
+
+	r.commands[name] = command
+	return nil
+}
+
+// Safe to call on a nil registry, which knows no command
+func (r *CommandRegistry) Lookup(name string) (SlashCommand, bool) {
+	if r == nil {
+		return nil, false
+	}
+	command, ok := r.commands[name]
+	return command, ok
+}
+
+// Returns the registered command names, sorted
+func (r *CommandRegistry) Names() []string {
+	if r == nil {
+		return []string{}
+	}
+
+	names := make([]string, 0, len(r.commands))
+	for name := range r.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Describes the registered commands, sorted by name
+func (r *CommandRegistry) List() []CommandInfo {
+	names := r.Names()
+	infos := make([]CommandInfo, 0, len(names))
+	for _, name := range names {
+		command := r.commands[name]
+		infos = append(infos, CommandInfo {
+			Name:		name,
+			Usage:		command.Usage(),
+			Description:	command.Description(),
+		})
+	}
+	return infos
+}
+
+// Splits a message starting with a single '/' into a command name and its arguments.
+// Messages starting with "//" are text, see UnescapeCommand.
+func ParseCommand(content string) (string, string, bool) {
+	if !strings.HasPrefix(content, "/") || strings.HasPrefix(content, "//") {
+		return "", "", false
+	}
+
+	rest := content[1:]
+	end := strings.IndexFunc(rest, unicode.IsSpace)
+	if end < 0 {
+		end = len(rest)
+	}
+
+	return strings.ToLower(rest[:end]), strings.TrimSpace(rest[end:]), true
+}
+
+// Drops the leading '/' of messages escaped with "//", which are sent as text
+func UnescapeCommand(content string) string {
+	if strings.HasPrefix(content, "//") {
+		return content[1:]
+	}
+	return content
+}
+
+// Parses a single "@username" argument, the '@' being optional
+func parseUsernameArg(arg string) (string, bool) {
+	username := strings.TrimPrefix(arg, "@")
+	if username == "" || strings.ContainsFunc(username, unicode.IsSpace) {
+		return "", false
+	}
+	return username, true
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/EliasLd/gotalk-backend/internal/events"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/jackc/pgx/v5"
+)
+
+const MaxTopicLength = 250
+
+// Bounds applied to /mute
+const (
+	DefaultMuteDuration	= time.Hour
+	MinMuteDuration		= time.Minute
+	MaxMuteDuration		= 30 * 24 * time.Hour
+)
+
+// Creates a registry holding the built-in commands:
+//
+//	/me <action>                  action message, e.g. "/me waves"
+//	/topic [text]                 sets or clears the topic
+//	/invite @user                 adds a member
+//	/mute @user [duration]        prevents a member from posting
+//	/unmute @user                 lifts a mute
+func NewBuiltinCommands(conversationRepo repository.ConversationRepository, userRepo repository.UserRepository, blockRepo repository.BlockRepository, broker events.Broker, webhooks WebhookPublisher) *CommandRegistry {
+	registry := NewCommandRegistry()
+
+	commands := map[string]SlashCommand {
+		"me":		meCommand{},
+		"topic":	&topicCommand{repo: conversationRepo, broker: broker},
+		"invite":	&inviteCommand{repo: conversationRepo, userRepo: userRepo, blockRepo: blockRepo, webhooks: webhooks},
+		"mute":		&muteCommand{repo: conversationRepo, userRepo: userRepo},
+		"unmute":	&muteCommand{repo: conversationRepo, userRepo: userRepo, unmute: true},
+	}
+	for name, command := range commands {
+		// Built-in names are valid and distinct
+		registry.Register(name, command)
+	}
+
+	return registry
+}
+
+// Only owners and admins moderate the conversation
+func requireAdminRole(cmd *CommandContext) error {
+	if !cmd.Role.IsAdmin() {
+		return errors.ErrConversationAdminRequired
+	}
+	return nil
+}
+
+// Returns the user named by a command argument, bots and deleted users excluded
+func lookupCommandTarget(ctx context.Context, userRepo repository.UserRepository, username string) (*models.User, error) {
+	user, err := userRepo.GetUserByUsername(ctx, username)
+	if stdErrors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.IsDeleted() || user.IsBot {
+		return nil, errors.ErrUserNotFound
+	}
+	return user, nil
+}
+
+type meCommand struct{}
+
+func (meCommand) Usage() string {
+	return "/me <action>"
+}
+
+func (meCommand) Description() string {
+	return "Posts an action, displayed after your name"
+}
+
+func (c meCommand) Execute(ctx context.Context, cmd *CommandContext) (*CommandResult, error) {
+	if cmd.Args == "" {
+		return nil, &errors.CommandUsageError{Usage: c.Usage()}
+	}
+
+	message, err := cmd.Post(ctx, cmd.Args, models.ContentTypeAction)
+	if err != nil {
+		return nil, err
+	}
+	return &CommandResult{Message: message}, nil
+}
+
+type topicCommand struct {
+	repo	repository.ConversationRepository
+	broker	events.Broker
+}
+
+func (*topicCommand) Usage() string {
+	return "/topic [text]"
+}
+
+func (*topicCommand) Description() string {
+	return "Sets the conversation topic, or clears it when no text is given"
+}
+
+// Both members of a direct conversation may set its topic, admins otherwise
+func (c *topicCommand) Execute(ctx context.Context, cmd *CommandContext) (*CommandResult, error) {
+	conversation, err := c.repo.GetConversationByID(ctx, cmd.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !conversation.IsDirect {
+		if err := requireAdminRole(cmd); err != nil {
+			return nil, err
+		}
+	}
+
+	if utf8.RuneCountInString(cmd.Args) > MaxTopicLength || hasForbiddenRunes(cmd.Args, false) {
+		return nil, errors.ErrTopicInvalid
+	}
+
+	var topic *string
+	if cmd.Args != "" {
+		topic = &cmd.Args
+	}
+
+	if err := c.repo.SetTopic(ctx, cmd.ConversationID, topic); err != nil {
+		return nil, err
+	}
+
+	conversation.Topic = topic
+	c.broker.Publish(events.Event {
+		Type:		events.ConversationUpdated,
+		ConversationID:	conversation.ID,
+		Payload:	conversation,
+	})
+
+	if topic == nil {
+		return &CommandResult{Reply: "Topic cleared"}, nil
+	}
+	return &CommandResult{Reply: "Topic set"}, nil
+}
+
+type inviteCommand struct {
+	repo		repository.ConversationRepository
+	userRepo	repository.UserRepository
+	blockRepo	repository.BlockRepository
+	webhooks	WebhookPublisher
+}
+
+func (*inviteCommand) Usage() string {
+	return "/invite @user"
+}
+
+func (*inviteCommand) Description() string {
+	return "Adds a user to the conversation"
+}
+
+// Users who blocked the inviter, or were blocked by them, are reported as not found
+func (c *inviteCommand) Execute(ctx context.Context, cmd *CommandContext) (*CommandResult, error) {
+	username, ok := parseUsernameArg(cmd.Args)
+	if !ok {
+		return nil, &errors.CommandUsageError{Usage: c.Usage()}
+	}
+
+	if err := requireAdminRole(cmd); err != nil {
+		return nil, err
+	}
+
+	conversation, err := c.repo.GetConversationByID(ctx, cmd.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conversation.IsDirect {
+		return nil, errors.ErrDirectConversationMembers
+	}
+
+	user, err := lookupCommandTarget(ctx, c.userRepo, username)
+	if err != nil {
+		return nil, err
+	}
+
+	blocked, err := c.blockRepo.IsBlockedEitherWay(ctx, cmd.SenderID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, errors.ErrUserNotFound
+	}
+
+	isMember, err := c.repo.IsMember(ctx, cmd.ConversationID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if isMember {
+		return nil, errors.ErrAlreadyMember
+	}
+
+	if err := c.repo.AddMember(ctx, cmd.ConversationID, user.ID, models.RoleMember); err != nil {
+		return nil, err
+	}
+
+	publishWebhook(c.webhooks, ctx, models.WebhookMemberJoined, &cmd.ConversationID, webhookMember {
+		ConversationID:	cmd.ConversationID,
+		UserID:		user.ID,
+		Role:		models.RoleMember,
+	})
+
+	return &CommandResult{Reply: "Invited @" + user.Username}, nil
+}
+
+type muteCommand struct {
+	repo		repository.ConversationRepository
+	userRepo	repository.UserRepository
+	unmute		bool
+}
+
+func (c *muteCommand) Usage() string {
+	if c.unmute {
+		return "/unmute @user"
+	}
+	return "/mute @user [duration]"
+}
+
+func (c *muteCommand) Description() string {
+	if c.unmute {
+		return "Allows a muted member to post again"
+	}
+	return fmt.Sprintf("Prevents a member from posting, for %s unless a duration such as 10m is given", DefaultMuteDuration)
+}
+
+func (c *muteCommand) Execute(ctx context.Context, cmd *CommandContext) (*CommandResult, error) {
+	args := strings.Fields(cmd.Args)
+	if len(args) == 0 || len(args) > 2 || (c.unmute && len(args) > 1) {
+		return nil, &errors.CommandUsageError{Usage: c.Usage()}
+	}
+	username, ok := parseUsernameArg(args[0])
+	if !ok {
+		return nil, &errors.CommandUsageError{Usage: c.Usage()}
+	}
+
+	duration := DefaultMuteDuration
+	if len(args) == 2 {
+		parsed, err := time.ParseDuration(args[1])
+		if err != nil {
+			return nil, &errors.CommandUsageError{Usage: c.Usage()}
+		}
+		duration = parsed
+	}
+	if duration < MinMuteDuration || duration > MaxMuteDuration {
+		return nil, errors.ErrMuteDurationOutOfRange
+	}
+
+	if err := requireAdminRole(cmd); err != nil {
+		return nil, err
+	}
+
+	user, err := lookupCommandTarget(ctx, c.userRepo, username)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := c.repo.GetMemberRole(ctx, cmd.ConversationID, user.ID)
+	if stdErrors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.unmute {
+		if err := c.repo.SetMemberMutedUntil(ctx, cmd.ConversationID, user.ID, nil); err != nil {
+			return nil, err
+		}
+		return &CommandResult{Reply: "Unmuted @" + user.Username}, nil
+	}
+
+	if role.IsAdmin() {
+		return nil, errors.ErrCannotMuteAdmin
+	}
+
+	until := time.Now().Add(duration).UTC()
+	if err := c.repo.SetMemberMutedUntil(ctx, cmd.ConversationID, user.ID, &until); err != nil {
+		return nil, err
+	}
+
+	return &CommandResult{Reply: fmt.Sprintf("Muted @%s until %s", user.Username, until.Format(time.RFC3339))}, nil
+}
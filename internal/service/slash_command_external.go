@@ -0,0 +1,218 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Bounds applied to external command endpoints
+const (
+	externalCommandTimeout		= 5 * time.Second
+	maxExternalCommandResponseSize	= 64 << 10
+)
+
+// Server-wide command forwarding its invocations to an HTTP endpoint
+type ExternalCommandConfig struct {
+	Name		string	`json:"name"`
+	URL		string	`json:"url"`
+	// Signs requests like webhook deliveries when set
+	Secret		string	`json:"secret,omitempty"`
+	Usage		string	`json:"usage,omitempty"`
+	Description	string	`json:"description,omitempty"`
+	// Restricts the command to conversation owners and admins
+	AdminOnly	bool	`json:"adminOnly,omitempty"`
+	// Display name of the bot posting the responses, defaults to /<name>
+	BotName		string	`json:"botName,omitempty"`
+}
+
+// Username of the bot posting the command's responses
+func (c ExternalCommandConfig) BotUsername() string {
+	return "cmd-" + c.Name
+}
+
+func (c ExternalCommandConfig) BotDisplayName() string {
+	if c.BotName != "" {
+		return c.BotName
+	}
+	return "/" + c.Name
+}
+
+// Parses the external commands, formatted as a JSON list of ExternalCommandConfig objects
+func ParseExternalCommands(raw string) ([]ExternalCommandConfig, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var configs []ExternalCommandConfig
+	if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrInvalidCommandConfig, err)
+	}
+
+	for i, config := range configs {
+		configs[i].Name = strings.ToLower(config.Name)
+		if !commandNameRegex.MatchString(configs[i].Name) {
+			return nil, fmt.Errorf("%w: invalid command name %q", errors.ErrInvalidCommandConfig, config.Name)
+		}
+		if _, err := parseEndpointURL(config.URL); err != nil {
+			return nil, fmt.Errorf("%w: /%s: %v", errors.ErrInvalidCommandConfig, configs[i].Name, err)
+		}
+		if err := ValidateBotName(configs[i].BotDisplayName()); err != nil {
+			return nil, fmt.Errorf("%w: /%s: %v", errors.ErrInvalidCommandConfig, configs[i].Name, err)
+		}
+	}
+
+	return configs, nil
+}
+
+// Registers the external commands, creating the bot users posting their responses
+func RegisterExternalCommands(ctx context.Context, registry *CommandRegistry, userRepo repository.UserRepository, configs []ExternalCommandConfig) error {
+	for _, config := range configs {
+		bot, err := userRepo.EnsureBotUser(ctx, config.BotUsername(), config.BotDisplayName())
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: /%s: username %q is taken by a user", errors.ErrInvalidCommandConfig, config.Name, config.BotUsername())
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := registry.Register(config.Name, NewExternalCommand(config, bot.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Body POSTed to the command endpoint
+type externalCommandRequest struct {
+	Command		string			`json:"command"`
+	Text		string			`json:"text"`
+	UserID		uuid.UUID		`json:"userId"`
+	ConversationID	uuid.UUID		`json:"conversationId"`
+	Role		models.MemberRole	`json:"role"`
+}
+
+// Expected from the command endpoint, an empty body posts nothing
+type externalCommandResponse struct {
+	Content		string	`json:"content"`
+	// Shows the content to the sender only instead of posting it
+	Ephemeral	bool	`json:"ephemeral"`
+}
+
+type externalCommand struct {
+	config	ExternalCommandConfig
+	botID	uuid.UUID
+	client	*http.Client
+}
+
+// Creates a command posting the endpoint's responses as the given bot user
+func NewExternalCommand(config ExternalCommandConfig, botID uuid.UUID) SlashCommand {
+	return &externalCommand {
+		config:	config,
+		botID:	botID,
+		client:	&http.Client {
+			Timeout:	externalCommandTimeout,
+			CheckRedirect:	func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (c *externalCommand) Usage() string {
+	if c.config.Usage != "" {
+		return c.config.Usage
+	}
+	return "/" + c.config.Name + " [text]"
+}
+
+func (c *externalCommand) Description() string {
+	return c.config.Description
+}
+
+func (c *externalCommand) Execute(ctx context.Context, cmd *CommandContext) (*CommandResult, error) {
+	if c.config.AdminOnly {
+		if err := requireAdminRole(cmd); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := c.call(ctx, externalCommandRequest {
+		Command:	cmd.Name,
+		Text:		cmd.Args,
+		UserID:		cmd.SenderID,
+		ConversationID:	cmd.ConversationID,
+		Role:		cmd.Role,
+	})
+	if err != nil {
+		log.Printf("command /%s failed: %v", c.config.Name, err)
+		return nil, errors.ErrCommandFailed
+	}
+
+	if strings.TrimSpace(resp.Content) == "" || resp.Ephemeral {
+		return &CommandResult{Reply: resp.Content}, nil
+	}
+
+	message, err := cmd.PostAsBot(ctx, c.botID, resp.Content)
+	if err != nil {
+		return nil, err
+	}
+	return &CommandResult{Message: message}, nil
+}
+
+func (c *externalCommand) call(ctx context.Context, payload externalCommandRequest) (*externalCommandResponse, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gotalk-commands/1.0")
+	if c.config.Secret != "" {
+		timestamp := time.Now().Unix()
+		req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(c.config.Secret, timestamp, body))
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxExternalCommandResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp externalCommandResponse
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &resp, nil
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
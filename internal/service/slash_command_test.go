@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		content		string
+		wantName	string
+		wantArgs	string
+		wantOK		bool
+	}{
+		{"/me waves", "me", "waves", true},
+		{"/INVITE  @bob ", "invite", "@bob", true},
+		{"/topic", "topic", "", true},
+		{"/topic\nmulti line", "topic", "multi line", true},
+		{"//not a command", "", "", false},
+		{"hello /me", "", "", false},
+	}
+
+	for _, tt := range tests {
+		name, args, ok := ParseCommand(tt.content)
+		if name != tt.wantName || args != tt.wantArgs || ok != tt.wantOK {
+			t.Errorf("ParseCommand(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				tt.content, name, args, ok, tt.wantName, tt.wantArgs, tt.wantOK)
+		}
+	}
+
+	if got := UnescapeCommand("//usr/bin"); got != "/usr/bin" {
+		t.Errorf("Expected escaped content to lose its first slash, got %q", got)
+	}
+}
+
+func TestCommandRegistry(t *testing.T) {
+	registry := NewBuiltinCommands(nil, nil, nil, nil, nil)
+
+	if err := registry.Register("Me", meCommand{}); !stdErrors.Is(err, errors.ErrInvalidCommandConfig) {
+		t.Errorf("Expected duplicate names to be rejected, got %v", err)
+	}
+	if err := registry.Register("bad name", meCommand{}); !stdErrors.Is(err, errors.ErrInvalidCommandConfig) {
+		t.Errorf("Expected invalid names to be rejected, got %v", err)
+	}
+
+	want := []string{"invite", "me", "mute", "topic", "unmute"}
+	if got := registry.Names(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected commands %v, got %v", want, got)
+	}
+
+	// Unknown commands list the available ones
+	s := &messageService{commands: registry}
+	_, err := s.runCommand(context.Background(), &CommandContext{Name: "shrug"})
+	if !stdErrors.Is(err, errors.ErrUnknownCommand) {
+		t.Fatalf("Expected ErrUnknownCommand, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "/invite, /me") {
+		t.Errorf("Expected the error to list the available commands, got %q", err.Error())
+	}
+}
+
+func TestBuiltinCommands_Usage(t *testing.T) {
+	registry := NewBuiltinCommands(nil, nil, nil, nil, nil)
+
+	// Arguments are checked before any repository is used
+	tests := []struct {
+		name	string
+		args	string
+		wantErr	error
+	}{
+		{"me", "", errors.ErrCommandUsage},
+		{"invite", "", errors.ErrCommandUsage},
+		{"invite", "@alice @bob", errors.ErrCommandUsage},
+		{"mute", "@alice soon", errors.ErrCommandUsage},
+		{"mute", "@alice 10s", errors.ErrMuteDurationOutOfRange},
+		{"mute", "@alice 10m", errors.ErrConversationAdminRequired},
+		{"unmute", "@alice 10m", errors.ErrCommandUsage},
+	}
+
+	for _, tt := range tests {
+		command, _ := registry.Lookup(tt.name)
+		_, err := command.Execute(context.Background(), &CommandContext {
+			Name:	tt.name,
+			Args:	tt.args,
+			Role:	models.RoleMember,
+		})
+		if !stdErrors.Is(err, tt.wantErr) {
+			t.Errorf("/%s %s: expected error %v, got %v", tt.name, tt.args, tt.wantErr, err)
+		}
+	}
+}
+
+func TestExternalCommand(t *testing.T) {
+	var received externalCommandRequest
+	var signature, timestamp string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+		signature = r.Header.Get(WebhookSignatureHeader)
+		timestamp = r.Header.Get(WebhookTimestampHeader)
+
+		if received.Text == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(externalCommandResponse{Content: "pong " + received.Text, Ephemeral: true})
+	}))
+	defer server.Close()
+
+	configs, err := ParseExternalCommands(`[{"name": "Ping", "url": "` + server.URL + `", "secret": "s3cret"}]`)
+	if err != nil {
+		t.Fatalf("ParseExternalCommands failed: %v", err)
+	}
+	command := NewExternalCommand(configs[0], uuid.New())
+
+	cmd := &CommandContext {
+		Name:		"ping",
+		Args:		"hello",
+		SenderID:	uuid.New(),
+		ConversationID:	uuid.New(),
+		Role:		models.RoleMember,
+	}
+	result, err := command.Execute(context.Background(), cmd)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	// Ephemeral responses are only shown to the sender
+	if result.Message != nil || result.Reply != "pong hello" {
+		t.Errorf("Expected reply %q and no message, got %+v", "pong hello", result)
+	}
+	if received.Command != "ping" || received.UserID != cmd.SenderID || received.ConversationID != cmd.ConversationID {
+		t.Errorf("Unexpected request payload: %+v", received)
+	}
+
+	ts, _ := strconv.ParseInt(timestamp, 10, 64)
+	body, _ := json.Marshal(received)
+	if signature != SignWebhookPayload("s3cret", ts, body) {
+		t.Error("Expected the request to be signed with the command secret")
+	}
+
+	cmd.Args = "fail"
+	if _, err := command.Execute(context.Background(), cmd); err != errors.ErrCommandFailed {
+		t.Errorf("Expected ErrCommandFailed, got %v", err)
+	}
+}
+
+func TestParseExternalCommands_Invalid(t *testing.T) {
+	for _, raw := range []string {
+		`not json`,
+		`[{"name": "bad name", "url": "https://example.com"}]`,
+		`[{"name": "deploy", "url": "ftp://example.com"}]`,
+	} {
+		if _, err := ParseExternalCommands(raw); !stdErrors.Is(err, errors.ErrInvalidCommandConfig) {
+			t.Errorf("ParseExternalCommands(%s): expected ErrInvalidCommandConfig, got %v", raw, err)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/auth"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Interval between two purges of the expired denylist entries and sessions
+const revokedTokenSweepInterval = time.Minute
+
+// Revokes JWTs before they expire. Checks are answered from memory: the
+// denylist, the revoked sessions and the password change times are reloaded
+// from the database every sync interval, so revocations made by other servers
+// apply after that delay.
+type TokenRevocationService interface {
+	IsRevoked(claims *auth.Claims) bool
+	RevokeToken(ctx context.Context, claims *auth.Claims) error
+	SessionsRevoked(sessionIDs ...uuid.UUID)
+	TokensInvalidated(userID uuid.UUID, validAfter time.Time)
+	WatchSession(ctx context.Context, sessionID uuid.UUID) (context.Context, context.CancelFunc)
+	Sync(ctx context.Context) error
+	Run(ctx context.Context)
+}
+
+// Concrete implementation of TokenRevocationService.
+type tokenRevocationService struct {
+	repo		repository.RevokedTokenRepository
+	sessions	repository.SessionRepository
+	syncInterval	time.Duration
+
+	mu		sync.RWMutex
+	// Expiry of the revoked tokens, by jti
+	revoked		map[string]time.Time
+	// Revocation time of the sessions, only for revocations recent enough
+	// for the sessions' tokens to still be valid
+	revokedSessions	map[uuid.UUID]time.Time
+	// Instant before which the user's tokens are refused, only for changes
+	// recent enough for such tokens to still be valid
+	validAfter	map[uuid.UUID]time.Time
+	// Cancels the real-time connections of each session
+	watches		map[uuid.UUID]map[*sessionWatch]struct{}
+}
+
+// Real-time connection closed when its session is revoked
+type sessionWatch struct {
+	cancel	context.CancelFunc
+}
+
+// Creates a new TokenRevocationService instance.
+func NewTokenRevocationService(repo repository.RevokedTokenRepository, sessions repository.SessionRepository, syncInterval time.Duration) TokenRevocationService {
+	return &tokenRevocationService {
+		repo:			repo,
+		sessions:		sessions,
+		syncInterval:		syncInterval,
+		revoked:		map[string]time.Time{},
+		revokedSessions:	map[uuid.UUID]time.Time{},
+		validAfter:		map[uuid.UUID]time.Time{},
+		watches:		map[uuid.UUID]map[*sessionWatch]struct{}{},
+	}
+}
+
+func (s *tokenRevocationService) IsRevoked(claims *auth.Claims) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.revoked[claims.ID]; ok {
+		return true
+	}
+	if _, ok := s.revokedSessions[claims.SessionID]; ok {
+		return true
+	}
+	if validAfter, ok := s.validAfter[claims.UserID]; ok {
+		return claims.IssuedAt == nil || claims.IssuedAt.Time.Before(validAfter)
+	}
+	return false
+}
+
+// Denylists the token until it expires, used on logout
+func (s *tokenRevocationService) RevokeToken(ctx context.Context, claims *auth.Claims) error {
+	token := &models.RevokedToken {
+		JTI:		claims.ID,
+		UserID:		claims.UserID,
+		ExpiresAt:	claims.ExpiresAt.Time,
+		RevokedAt:	time.Now().UTC(),
+	}
+	if err := s.repo.RevokeToken(ctx, token); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.revoked[token.JTI] = token.ExpiresAt
+	s.mu.Unlock()
+	return nil
+}
+
+// Applies the revocation of sessions already stored as revoked, right away
+// on this server: their tokens are refused and their connections closed
+func (s *tokenRevocationService) SessionsRevoked(sessionIDs ...uuid.UUID) {
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range sessionIDs {
+		s.revokedSessions[id] = now
+		s.closeSession(id)
+	}
+}
+
+// Applies a password change already stored, right away on this server:
+// the user's tokens issued before validAfter are refused
+func (s *tokenRevocationService) TokensInvalidated(userID uuid.UUID, validAfter time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, ok := s.validAfter[userID]; !ok || current.Before(validAfter) {
+		s.validAfter[userID] = validAfter
+	}
+}
+
+// Returns a context cancelled once the session is revoked, for real-time
+// connections. The returned cancel function must be called once done.
+func (s *tokenRevocationService) WatchSession(ctx context.Context, sessionID uuid.UUID) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	watch := &sessionWatch{cancel: cancel}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, revoked := s.revokedSessions[sessionID]; revoked {
+		cancel()
+		return ctx, cancel
+	}
+	if s.watches[sessionID] == nil {
+		s.watches[sessionID] = map[*sessionWatch]struct{}{}
+	}
+	s.watches[sessionID][watch] = struct{}{}
+
+	return ctx, func() {
+		cancel()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.watches[sessionID], watch)
+		if len(s.watches[sessionID]) == 0 {
+			delete(s.watches, sessionID)
+		}
+	}
+}
+
+// Cancels the session's connections, s.mu must be held
+func (s *tokenRevocationService) closeSession(sessionID uuid.UUID) {
+	for watch := range s.watches[sessionID] {
+		watch.cancel()
+	}
+	delete(s.watches, sessionID)
+}
+
+// Reloads the denylist, the revoked sessions and the recent password changes,
+// dropping expired entries. Connections of newly revoked sessions are closed.
+func (s *tokenRevocationService) Sync(ctx context.Context) error {
+	now := time.Now().UTC()
+	// Tokens issued before that are expired whatever the user did
+	since := now.Add(-auth.AccessTokenTTL)
+
+	tokens, err := s.repo.GetRevokedTokens(ctx, now)
+	if err != nil {
+		return err
+	}
+	revokedSessions, err := s.sessions.GetSessionsRevokedSince(ctx, since)
+	if err != nil {
+		return err
+	}
+	validAfter, err := s.repo.GetTokensValidAfter(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	revoked := make(map[string]time.Time, len(tokens))
+	for _, token := range tokens {
+		revoked[token.JTI] = token.ExpiresAt
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Revoked here while the queries ran, not necessarily part of their results
+	for jti, expiresAt := range s.revoked {
+		if expiresAt.After(now) {
+			revoked[jti] = expiresAt
+		}
+	}
+	for id, revokedAt := range s.revokedSessions {
+		if _, ok := revokedSessions[id]; !ok && revokedAt.After(since) {
+			revokedSessions[id] = revokedAt
+		}
+	}
+	for id, at := range s.validAfter {
+		if current, ok := validAfter[id]; (!ok || current.Before(at)) && at.After(since) {
+			validAfter[id] = at
+		}
+	}
+	for id := range revokedSessions {
+		if _, known := s.revokedSessions[id]; !known {
+			s.closeSession(id)
+		}
+	}
+
+	s.revoked = revoked
+	s.revokedSessions = revokedSessions
+	s.validAfter = validAfter
+	return nil
+}
+
+// Blocks until ctx is cancelled
+func (s *tokenRevocationService) Run(ctx context.Context) {
+	syncTicker := time.NewTicker(s.syncInterval)
+	defer syncTicker.Stop()
+	sweepTicker := time.NewTicker(revokedTokenSweepInterval)
+	defer sweepTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-syncTicker.C:
+			if err := s.Sync(ctx); err != nil {
+				log.Printf("Failed to sync revoked tokens: %v", err)
+			}
+		case <-sweepTicker.C:
+			if _, err := s.repo.DeleteExpiredRevokedTokens(ctx, time.Now().UTC()); err != nil {
+				log.Printf("Failed to delete expired revoked tokens: %v", err)
+			}
+			if _, err := s.sessions.DeleteExpiredSessions(ctx, time.Now().UTC()); err != nil {
+				log.Printf("Failed to delete expired sessions: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/auth"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// In-memory RevokedTokenRepository, shared by the services of several servers
+type memoryRevokedTokenRepository struct {
+	mu		sync.Mutex
+	tokens		map[string]*models.RevokedToken
+	validAfter	map[uuid.UUID]time.Time
+}
+
+func (r *memoryRevokedTokenRepository) RevokeToken(ctx context.Context, token *models.RevokedToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *token
+	r.tokens[token.JTI] = &copied
+	return nil
+}
+
+func (r *memoryRevokedTokenRepository) GetRevokedTokens(ctx context.Context, now time.Time) ([]*models.RevokedToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var tokens []*models.RevokedToken
+	for _, token := range r.tokens {
+		if token.ExpiresAt.After(now) {
+			copied := *token
+			tokens = append(tokens, &copied)
+		}
+	}
+	return tokens, nil
+}
+
+func (r *memoryRevokedTokenRepository) DeleteExpiredRevokedTokens(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (r *memoryRevokedTokenRepository) GetTokensValidAfter(ctx context.Context, since time.Time) (map[uuid.UUID]time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	validAfter := map[uuid.UUID]time.Time{}
+	for userID, at := range r.validAfter {
+		if at.After(since) {
+			validAfter[userID] = at
+		}
+	}
+	return validAfter, nil
+}
+
+func testClaims(t *testing.T, userID uuid.UUID) *auth.Claims {
+	auth.SetupTestKeys(t)
+	token, err := auth.GenerateToken(&models.User{ID: userID}, uuid.New())
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	claims, err := auth.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("Failed to validate token: %v", err)
+	}
+	return claims
+}
+
+func TestTokenRevocation_Logout(t *testing.T) {
+	repo := &memoryRevokedTokenRepository{tokens: map[string]*models.RevokedToken{}, validAfter: map[uuid.UUID]time.Time{}}
+	local := NewTokenRevocationService(repo, newMemorySessionRepository(), time.Minute)
+	remote := NewTokenRevocationService(repo, newMemorySessionRepository(), time.Minute)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	revoked := testClaims(t, userID)
+	other := testClaims(t, userID)
+
+	if err := local.RevokeToken(ctx, revoked); err != nil {
+		t.Fatalf("RevokeToken failed: %v", err)
+	}
+	if !local.IsRevoked(revoked) {
+		t.Errorf("Expected the token revoked right away")
+	}
+	if local.IsRevoked(other) {
+		t.Errorf("Expected the other tokens of the user to stay valid")
+	}
+
+	// Other servers see the revocation once synced
+	if remote.IsRevoked(revoked) {
+		t.Errorf("Expected the revocation unknown before syncing")
+	}
+	if err := remote.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !remote.IsRevoked(revoked) {
+		t.Errorf("Expected the token revoked after syncing")
+	}
+}
+
+func TestTokenRevocation_PasswordChange(t *testing.T) {
+	repo := &memoryRevokedTokenRepository{tokens: map[string]*models.RevokedToken{}, validAfter: map[uuid.UUID]time.Time{}}
+	s := NewTokenRevocationService(repo, newMemorySessionRepository(), time.Minute)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	before := testClaims(t, userID)
+	before.IssuedAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	// Issued within the same second as the change, right before it
+	justBefore := testClaims(t, userID)
+	unrelated := testClaims(t, uuid.New())
+
+	repo.validAfter[userID] = time.Now().UTC()
+	time.Sleep(2 * time.Millisecond)
+	after := testClaims(t, userID)
+	if err := s.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if !s.IsRevoked(before) || !s.IsRevoked(justBefore) {
+		t.Errorf("Expected tokens issued before the change revoked")
+	}
+	if s.IsRevoked(after) {
+		t.Errorf("Expected tokens issued after the change to stay valid")
+	}
+	if s.IsRevoked(unrelated) {
+		t.Errorf("Expected the other users' tokens to stay valid")
+	}
+}
+
+func TestTokenRevocation_TokensInvalidated(t *testing.T) {
+	repo := &memoryRevokedTokenRepository{tokens: map[string]*models.RevokedToken{}, validAfter: map[uuid.UUID]time.Time{}}
+	s := NewTokenRevocationService(repo, newMemorySessionRepository(), time.Minute)
+
+	userID := uuid.New()
+	claims := testClaims(t, userID)
+
+	// Applied before the change is stored, and kept by a sync which does not see it yet
+	s.TokensInvalidated(userID, time.Now().UTC())
+	if !s.IsRevoked(claims) {
+		t.Errorf("Expected the token revoked without syncing")
+	}
+	if err := s.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !s.IsRevoked(claims) {
+		t.Errorf("Expected the token to stay revoked after syncing")
+	}
+}
+
+func TestTokenRevocation_Sessions(t *testing.T) {
+	auth.SetupTestKeys(t)
+	repo := &memoryRevokedTokenRepository{tokens: map[string]*models.RevokedToken{}, validAfter: map[uuid.UUID]time.Time{}}
+	sessionRepo := newMemorySessionRepository()
+	local := NewTokenRevocationService(repo, sessionRepo, time.Minute)
+	remote := NewTokenRevocationService(repo, sessionRepo, time.Minute)
+	sessions := NewSessionService(sessionRepo, local)
+	refreshTokens := NewRefreshTokenService(&memoryRefreshTokenRepository{tokens: map[string]*models.RefreshToken{}}, sessionRepo)
+	ctx := context.Background()
+
+	user := &models.User{ID: uuid.New()}
+	claimsOf := func(device string) *auth.Claims {
+		tokens, err := refreshTokens.IssueTokens(ctx, user, SessionDevice{Name: device})
+		if err != nil {
+			t.Fatalf("IssueTokens failed: %v", err)
+		}
+		claims, err := auth.ValidateToken(tokens.AccessToken)
+		if err != nil {
+			t.Fatalf("Failed to validate token: %v", err)
+		}
+		return claims
+	}
+	phone, laptop, tablet := claimsOf("Phone"), claimsOf("Laptop"), claimsOf("Tablet")
+
+	localStream, releaseLocal := local.WatchSession(ctx, phone.SessionID)
+	defer releaseLocal()
+	remoteStream, releaseRemote := remote.WatchSession(ctx, phone.SessionID)
+	defer releaseRemote()
+
+	if err := sessions.RevokeSession(ctx, user.ID, phone.SessionID); err != nil {
+		t.Fatalf("RevokeSession failed: %v", err)
+	}
+	if !local.IsRevoked(phone) || local.IsRevoked(laptop) {
+		t.Errorf("Expected only the phone's token revoked")
+	}
+	if localStream.Err() == nil {
+		t.Errorf("Expected the phone's connections closed right away")
+	}
+
+	// Other servers close the connections once synced
+	if remoteStream.Err() != nil {
+		t.Errorf("Expected the revocation unknown before syncing")
+	}
+	if err := remote.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if remoteStream.Err() == nil || !remote.IsRevoked(phone) {
+		t.Errorf("Expected the phone's session revoked after syncing")
+	}
+	if err := sessions.RevokeSession(ctx, user.ID, phone.SessionID); err != errors.ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound for a revoked session, got %v", err)
+	}
+
+	revoked, err := sessions.RevokeOtherSessions(ctx, user.ID, laptop.SessionID)
+	if err != nil {
+		t.Fatalf("RevokeOtherSessions failed: %v", err)
+	}
+	if revoked != 1 || !local.IsRevoked(tablet) || local.IsRevoked(laptop) {
+		t.Errorf("Expected only the tablet revoked, got %d revoked", revoked)
+	}
+
+	// Connections opened after the revocation are closed right away
+	late, release := local.WatchSession(ctx, tablet.SessionID)
+	defer release()
+	if late.Err() == nil {
+		t.Errorf("Expected the connection of a revoked session closed")
+	}
+}
@@ -2,15 +2,29 @@ package service
 
 import (
 	"context"
+	stdErrors "errors"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/EliasLd/gotalk-backend/internal/models"
 	"github.com/EliasLd/gotalk-backend/internal/repository"
 	"github.com/EliasLd/gotalk-backend/internal/service/errors"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Page size bounds for user search
+const (
+	DefaultUserSearchPageSize	= 20
+	MaxUserSearchPageSize		= 50
+	MaxUserSearchQueryLength	= 64
+)
+
+// Time left to a user to change their mind after requesting the deletion of their account
+const DefaultAccountDeletionGracePeriod = 14 * 24 * time.Hour
+
 // Defines business logic operations related to users.
 type UserService interface {
 	RegisterUser(ctx context.Context, username, password string) (*models.User, error)
@@ -19,21 +33,44 @@ type UserService interface {
 	DeleteUser(ctx context.Context, id uuid.UUID) error
 	UpdateUser(ctx context.Context, id uuid.UUID, input UpdateUserInput) (*models.User, error)
 	AuthenticateUser(ctx context.Context, username, password string) (*models.User, error)
+	GetPublicProfile(ctx context.Context, id uuid.UUID) (*models.PublicUser, error)
+	SearchUsers(ctx context.Context, query, cursor string, limit int) ([]models.PublicUser, string, error)
+	RequestDeletion(ctx context.Context, id uuid.UUID, password string) (time.Time, error)
+	SetDisabled(ctx context.Context, id uuid.UUID, disabled bool) (*models.User, error)
+	SetAdmin(ctx context.Context, id uuid.UUID, isAdmin bool) (*models.User, error)
+	ResetPassword(ctx context.Context, id uuid.UUID) (string, error)
 }
 
 // Concrete implementation of UserService.
 type userService struct {
-	repo repository.UserRepository
+	repo			repository.UserRepository
+	sessions		repository.SessionRepository
+	revocations		TokenRevocationService
+	deletionGracePeriod	time.Duration
+	webhooks		WebhookPublisher
 }
 
+// Nil fields are left unchanged
 type UpdateUserInput struct {
-	Username *string
-	Password *string
+	Username	*string
+	Password	*string
+	DisplayName	*string
+	Bio		*string
+	Pronouns	*string
+	Timezone	*string
 }
 
 // Creates a new UserService instance.
-func NewUserService(repo repository.UserRepository) UserService {
-	return &userService{repo: repo}
+// revocations applies revocations right away on this server, other servers pick them up
+// from the database. It is nil outside of the server, in gotalkctl for instance.
+func NewUserService(repo repository.UserRepository, sessions repository.SessionRepository, revocations TokenRevocationService, deletionGracePeriod time.Duration, webhooks WebhookPublisher) UserService {
+	return &userService {
+		repo:			repo,
+		sessions:		sessions,
+		revocations:		revocations,
+		deletionGracePeriod:	deletionGracePeriod,
+		webhooks:		webhooks,
+	}
 }
 
 func hashPassword(password string) (string, error) {
@@ -71,6 +108,8 @@ func (s *userService) RegisterUser(ctx context.Context, username, password strin
 		return nil, err
 	}
 
+	publishWebhook(s.webhooks, ctx, models.WebhookUserRegistered, nil, user.Public())
+
 	return user, nil
 }
 
@@ -91,9 +130,17 @@ func (s *userService) UpdateUser(ctx context.Context, id uuid.UUID, input Update
 	if err != nil {
 		return nil, err
 	}
+	if user.IsDeleted() {
+		return nil, errors.ErrUserNotFound
+	}
+
+	// Only the changed columns are written, user is updated alongside to be returned
+	update := &models.UserUpdate{}
+	var newPassword *string
 
 	if input.Username != nil {
 		user.Username = *input.Username
+		update.Username = &user.Username
 	}
 
 	if input.Password != nil {
@@ -106,21 +153,90 @@ func (s *userService) UpdateUser(ctx context.Context, id uuid.UUID, input Update
 			return nil, errors.ErrPasswordHashingFailed
 		}
 
-		user.Password = hashedPassword
+		// Written once the profile is, should that fail
+		newPassword = &hashedPassword
+	}
+
+	if input.DisplayName != nil {
+		displayName := strings.TrimSpace(*input.DisplayName)
+		if err := ValidateDisplayName(displayName); err != nil {
+			return nil, err
+		}
+		user.DisplayName = displayName
+		update.DisplayName = &user.DisplayName
+	}
+
+	if input.Bio != nil {
+		bio := strings.TrimSpace(*input.Bio)
+		if err := ValidateBio(bio); err != nil {
+			return nil, err
+		}
+		user.Bio = bio
+		update.Bio = &user.Bio
+	}
+
+	if input.Pronouns != nil {
+		pronouns := strings.TrimSpace(*input.Pronouns)
+		if err := ValidatePronouns(pronouns); err != nil {
+			return nil, err
+		}
+		user.Pronouns = pronouns
+		update.Pronouns = &user.Pronouns
+	}
+
+	if input.Timezone != nil {
+		if err := ValidateTimezone(*input.Timezone); err != nil {
+			return nil, err
+		}
+		user.Timezone = *input.Timezone
+		update.Timezone = &user.Timezone
 	}
 
 	user.UpdatedAt = time.Now()
+	update.UpdatedAt = user.UpdatedAt
 
-	if err := s.repo.UpdateUser(ctx, user); err != nil {
+	if err := s.repo.UpdateUser(ctx, id, update); err != nil {
+		// Anonymized since it was read
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrUserNotFound
+		}
 		return nil, err
 	}
 
+	if newPassword != nil {
+		if err := s.setPassword(ctx, user, *newPassword); err != nil {
+			return nil, err
+		}
+	}
+
 	return user, nil
 }
 
+// Stores the new password hash and refuses the tokens issued before the change,
+// right away on this server instead of after the next sync. The real-time
+// connections of the user's sessions are closed too.
+func (s *userService) setPassword(ctx context.Context, user *models.User, hash string) error {
+	// Not truncated: a token issued earlier in the same second or millisecond must be refused too
+	validAfter := time.Now().UTC()
+	if err := s.repo.SetUserPassword(ctx, user.ID, hash, validAfter); err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return errors.ErrUserNotFound
+		}
+		return err
+	}
+	user.Password = hash
+	user.TokensValidAfter = &validAfter
+
+	if s.revocations != nil {
+		s.revocations.TokensInvalidated(user.ID, validAfter)
+	}
+	// Their refresh tokens are refused already, revoking them closes their connections
+	return s.revokeSessions(ctx, user.ID)
+}
+
 func (s *userService) AuthenticateUser(ctx context.Context, username, password string) (*models.User, error) {
 	user, err := s.repo.GetUserByUsername(ctx, username)
-	if err != nil || user == nil {
+	if err != nil || user == nil || user.IsDeleted() || user.IsBot {
 		return nil, errors.ErrInvalidCredentials
 	}
 
@@ -128,6 +244,209 @@ func (s *userService) AuthenticateUser(ctx context.Context, username, password s
 		return nil, errors.ErrInvalidCredentials
 	}
 
+	// Only told once the password matched, so that it does not reveal which accounts exist
+	if user.IsDisabled() {
+		return nil, errors.ErrUserDisabled
+	}
+
+	// Logging in during the grace period cancels a pending deletion
+	if user.DeletionScheduledAt != nil {
+		if err := s.repo.CancelUserDeletion(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		user.DeletionScheduledAt = nil
+	}
+
 	return user, nil
 }
 
+// Schedules the anonymization of the account once the grace period is over
+// and returns when it will happen. The password is required again.
+// Requesting it twice keeps the original schedule.
+func (s *userService) RequestDeletion(ctx context.Context, id uuid.UUID, password string) (time.Time, error) {
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, errors.ErrUserNotFound
+		}
+		return time.Time{}, err
+	}
+	if user.IsDeleted() {
+		return time.Time{}, errors.ErrUserNotFound
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return time.Time{}, errors.ErrInvalidCredentials
+	}
+
+	if user.DeletionScheduledAt != nil {
+		return *user.DeletionScheduledAt, nil
+	}
+
+	// Refresh and personal access tokens are revoked along with the scheduling
+	scheduledAt := time.Now().UTC().Add(s.deletionGracePeriod)
+	if err := s.repo.ScheduleUserDeletion(ctx, id, scheduledAt); err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, errors.ErrUserNotFound
+		}
+		return time.Time{}, err
+	}
+
+	// Signed out everywhere, logging in again cancels the deletion
+	if err := s.revokeSessions(ctx, id); err != nil {
+		return time.Time{}, err
+	}
+
+	return scheduledAt, nil
+}
+
+
+// Returns the account to update, refusing unknown and anonymized ones
+func (s *userService) getActiveUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrUserNotFound
+		}
+		return nil, err
+	}
+	if user.IsDeleted() {
+		return nil, errors.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// Disabled accounts cannot log in nor use their access tokens until they are enabled again.
+// Their sessions are revoked and their real-time connections closed.
+func (s *userService) SetDisabled(ctx context.Context, id uuid.UUID, disabled bool) (*models.User, error) {
+	user, err := s.getActiveUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Disabling twice keeps the original date
+	if disabled != user.IsDisabled() {
+		var at *time.Time
+		if disabled {
+			now := time.Now().UTC()
+			at = &now
+		}
+		if err := s.repo.SetUserDisabled(ctx, id, at); err != nil {
+			return nil, err
+		}
+		user.DisabledAt = at
+		if at != nil {
+			user.TokensValidAfter = at
+		}
+	}
+
+	// Also done when already disabled, in case a previous attempt failed midway
+	if disabled {
+		if err := s.revokeSessions(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// Revokes every session of the user, closing their connections on this server right away
+func (s *userService) revokeSessions(ctx context.Context, id uuid.UUID) error {
+	revoked, err := s.sessions.RevokeUserSessions(ctx, id)
+	if err != nil {
+		return err
+	}
+	if s.revocations != nil {
+		s.revocations.SessionsRevoked(revoked...)
+	}
+	return nil
+}
+
+func (s *userService) SetAdmin(ctx context.Context, id uuid.UUID, isAdmin bool) (*models.User, error) {
+	user, err := s.getActiveUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetUserAdmin(ctx, id, isAdmin); err != nil {
+		return nil, err
+	}
+
+	user.IsAdmin = isAdmin
+	return user, nil
+}
+
+// Replaces the password with a generated one, which is returned so that it can be handed to the user
+func (s *userService) ResetPassword(ctx context.Context, id uuid.UUID) (string, error) {
+	user, err := s.getActiveUser(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if user.IsBot {
+		return "", errors.ErrUserNotFound
+	}
+
+	password, err := GeneratePassword()
+	if err != nil {
+		return "", err
+	}
+
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return "", errors.ErrPasswordHashingFailed
+	}
+
+	if err := s.setPassword(ctx, user, hashedPassword); err != nil {
+		return "", err
+	}
+
+	return password, nil
+}
+
+func (s *userService) GetPublicProfile(ctx context.Context, id uuid.UUID) (*models.PublicUser, error) {
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	profile := user.Public()
+	return &profile, nil
+}
+
+// Returns users matching the query by prefix along with the cursor of the next page,
+// which is empty on the last page
+func (s *userService) SearchUsers(ctx context.Context, query, cursor string, limit int) ([]models.PublicUser, string, error) {
+	query = strings.TrimSpace(query)
+	if query == "" || utf8.RuneCountInString(query) > MaxUserSearchQueryLength {
+		return nil, "", errors.ErrInvalidSearchQuery
+	}
+
+	if limit <= 0 {
+		limit = DefaultUserSearchPageSize
+	}
+	if limit > MaxUserSearchPageSize {
+		limit = MaxUserSearchPageSize
+	}
+
+	// Fetch one extra row to know whether another page exists
+	users, err := s.repo.SearchUsers(ctx, query, cursor, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(users) > limit {
+		users = users[:limit]
+		nextCursor = users[limit-1].Username
+	}
+
+	profiles := make([]models.PublicUser, 0, len(users))
+	for _, user := range users {
+		profiles = append(profiles, user.Public())
+	}
+
+	return profiles, nextCursor, nil
+}
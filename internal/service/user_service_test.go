@@ -3,7 +3,9 @@ package service
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/EliasLd/gotalk-backend/internal/database"
 	"github.com/EliasLd/gotalk-backend/internal/repository"
 	"github.com/EliasLd/gotalk-backend/internal/service/errors"
 	"github.com/google/uuid"
@@ -22,7 +24,7 @@ func setupService(t *testing.T) testUserService {
 	repo := repository.SetupTest(t)
 
 	return testUserService {
-		UserService:	NewUserService(repo),
+		UserService:	NewUserService(repo, repository.NewSessionRepository(database.DB), nil, DefaultAccountDeletionGracePeriod, nil),
 		repo:		repo,
 	}
 }
@@ -252,3 +254,136 @@ func TestUpdateUser(t *testing.T) {
 		t.Errorf("Password hash does not match new password: %v", err)
 	}
 }
+
+func TestUpdateUser_Profile(t *testing.T) {
+	s := setupService(t)
+
+	user, err := s.RegisterUser(context.Background(), "testuser_update_profile", "ValidPass123!")
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	defer repository.CleanUpUser(t, user.ID, s.repo)
+
+	displayName := "Test User"
+	timezone := "America/New_York"
+
+	_, err = s.UpdateUser(context.Background(), user.ID, UpdateUserInput {
+		DisplayName:	&displayName,
+		Timezone:	&timezone,
+	})
+	if err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+
+	check_user, err := s.GetUserByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if check_user.DisplayName != displayName || check_user.Timezone != timezone {
+		t.Errorf("Expected profile (%s, %s), got (%s, %s)", displayName, timezone, check_user.DisplayName, check_user.Timezone)
+	}
+
+	invalidTimezone := "Not/AZone"
+	_, err = s.UpdateUser(context.Background(), user.ID, UpdateUserInput{Timezone: &invalidTimezone})
+	if err != errors.ErrTimezoneInvalid {
+		t.Errorf("Expected ErrTimezoneInvalid, got %v", err)
+	}
+}
+
+func TestRequestDeletion_LoginCancels(t *testing.T) {
+	s := setupService(t)
+
+	password := "ValidPass123!"
+	user, err := s.RegisterUser(context.Background(), "testuser_request_deletion", password)
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	defer repository.CleanUpUser(t, user.ID, s.repo)
+
+	if _, err := s.RequestDeletion(context.Background(), user.ID, "WrongPass123!"); err != errors.ErrInvalidCredentials {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+
+	scheduledAt, err := s.RequestDeletion(context.Background(), user.ID, password)
+	if err != nil {
+		t.Fatalf("Failed to request deletion: %v", err)
+	}
+	if scheduledAt.Before(time.Now().Add(DefaultAccountDeletionGracePeriod - time.Minute)) {
+		t.Errorf("Expected deletion after the grace period, got %v", scheduledAt)
+	}
+
+	if _, err := s.AuthenticateUser(context.Background(), user.Username, password); err != nil {
+		t.Fatalf("Failed to authenticate: %v", err)
+	}
+
+	check_user, err := s.GetUserByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if check_user.DeletionScheduledAt != nil {
+		t.Errorf("Expected logging in to cancel the deletion, still scheduled at %v", check_user.DeletionScheduledAt)
+	}
+}
+
+func TestSetDisabled_RefusesLogin(t *testing.T) {
+	s := setupService(t)
+
+	password := "ValidPass123!"
+	user, err := s.RegisterUser(context.Background(), "testuser_disabled", password)
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	defer repository.CleanUpUser(t, user.ID, s.repo)
+
+	disabled, err := s.SetDisabled(context.Background(), user.ID, true)
+	if err != nil {
+		t.Fatalf("Failed to disable user: %v", err)
+	}
+	if !disabled.IsDisabled() {
+		t.Errorf("Expected the user to be disabled")
+	}
+	if disabled.TokensValidAfter == nil || disabled.TokensValidAfter.Before(*disabled.DisabledAt) {
+		t.Errorf("Expected the tokens issued until then to be refused, valid after %v", disabled.TokensValidAfter)
+	}
+
+	if _, err := s.AuthenticateUser(context.Background(), user.Username, "WrongPass123!"); err != errors.ErrInvalidCredentials {
+		t.Errorf("Expected ErrInvalidCredentials with a wrong password, got %v", err)
+	}
+	if _, err := s.AuthenticateUser(context.Background(), user.Username, password); err != errors.ErrUserDisabled {
+		t.Errorf("Expected ErrUserDisabled, got %v", err)
+	}
+
+	if _, err := s.SetDisabled(context.Background(), user.ID, false); err != nil {
+		t.Fatalf("Failed to enable user: %v", err)
+	}
+	if _, err := s.AuthenticateUser(context.Background(), user.Username, password); err != nil {
+		t.Errorf("Expected enabled user to log in, got %v", err)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	s := setupService(t)
+
+	user, err := s.RegisterUser(context.Background(), "testuser_reset_password", "ValidPass123!")
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	defer repository.CleanUpUser(t, user.ID, s.repo)
+
+	password, err := s.ResetPassword(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Failed to reset password: %v", err)
+	}
+
+	if _, err := s.AuthenticateUser(context.Background(), user.Username, "ValidPass123!"); err != errors.ErrInvalidCredentials {
+		t.Errorf("Expected the old password to be refused, got %v", err)
+	}
+	if _, err := s.AuthenticateUser(context.Background(), user.Username, password); err != nil {
+		t.Errorf("Expected the generated password to be accepted, got %v", err)
+	}
+}
@@ -1,7 +1,14 @@
 package service
 
 import (
+	"crypto/rand"
+	"math/big"
 	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/EliasLd/gotalk-backend/internal/service/errors"
 )
 
@@ -10,6 +17,14 @@ var (
 	upperRegex	= regexp.MustCompile(`[A-Z]`)
 	lowerRegex	= regexp.MustCompile(`[a-z]`)
 	symbolRegex	= regexp.MustCompile(`[!@#\$%\^&\*\(\)_\+\-=\[\]{};':"\\|,.<>\/?]`)
+	pronounsRegex	= regexp.MustCompile(`^[\p{L} /-]*$`)
+)
+
+// Profile field length limits, in characters
+const (
+	MaxDisplayNameLength	= 64
+	MaxBioLength		= 280
+	MaxPronounsLength	= 32
 )	
 
 func ValidatePassword(pw string) error {
@@ -31,3 +46,99 @@ func ValidatePassword(pw string) error {
 	return nil
 }
 
+// Character classes of generated passwords, ambiguous characters such as O/0 and l/1 are left out
+var passwordAlphabets = []string {
+	"23456789",
+	"ABCDEFGHJKLMNPQRSTUVWXYZ",
+	"abcdefghijkmnopqrstuvwxyz",
+	"!@#$%^&*-_=+?",
+}
+
+// Length of generated passwords
+const GeneratedPasswordLength = 16
+
+// Returns a random password satisfying ValidatePassword, with at least one character of each class
+func GeneratePassword() (string, error) {
+	all := strings.Join(passwordAlphabets, "")
+
+	password := make([]byte, 0, GeneratedPasswordLength)
+	for _, alphabet := range passwordAlphabets {
+		c, err := randomChar(alphabet)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+	for len(password) < GeneratedPasswordLength {
+		c, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	// Fisher-Yates, so that the mandatory characters are not always first
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+
+	return string(password), nil
+}
+
+func randomChar(alphabet string) (byte, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+	if err != nil {
+		return 0, err
+	}
+	return alphabet[i.Int64()], nil
+}
+
+// Control characters are refused, except newlines when allowed
+func hasForbiddenRunes(s string, allowNewlines bool) bool {
+	return strings.IndexFunc(s, func(c rune) bool {
+		if c == '\n' && allowNewlines {
+			return false
+		}
+		return unicode.IsControl(c) || isSuspiciousRune(c)
+	}) >= 0
+}
+
+func ValidateDisplayName(name string) error {
+	if utf8.RuneCountInString(name) > MaxDisplayNameLength || hasForbiddenRunes(name, false) {
+		return errors.ErrDisplayNameInvalid
+	}
+	return nil
+}
+
+func ValidateBio(bio string) error {
+	if utf8.RuneCountInString(bio) > MaxBioLength || hasForbiddenRunes(bio, true) {
+		return errors.ErrBioInvalid
+	}
+	return nil
+}
+
+func ValidatePronouns(pronouns string) error {
+	if utf8.RuneCountInString(pronouns) > MaxPronounsLength || !pronounsRegex.MatchString(pronouns) {
+		return errors.ErrPronounsInvalid
+	}
+	return nil
+}
+
+// Empty clears the time zone, anything else must be an IANA name such as Europe/Paris
+func ValidateTimezone(timezone string) error {
+	if timezone == "" {
+		return nil
+	}
+	// LoadLocation also accepts "Local", which means nothing to other users
+	if timezone == "Local" {
+		return errors.ErrTimezoneInvalid
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return errors.ErrTimezoneInvalid
+	}
+	return nil
+}
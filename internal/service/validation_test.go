@@ -0,0 +1,59 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+)
+
+func TestValidateProfileFields(t *testing.T) {
+	tests := []struct {
+		name		string
+		validate	func(string) error
+		value		string
+		wantErr		error
+	}{
+		{"Display name", ValidateDisplayName, "Élise Durand", nil},
+		{"Display name too long", ValidateDisplayName, strings.Repeat("a", MaxDisplayNameLength+1), errors.ErrDisplayNameInvalid},
+		{"Display name with control character", ValidateDisplayName, "bad\x07name", errors.ErrDisplayNameInvalid},
+		{"Display name with bidi override", ValidateDisplayName, "admin‮", errors.ErrDisplayNameInvalid},
+		{"Bio with newlines", ValidateBio, "Gopher.\nLikes tea.", nil},
+		{"Bio too long", ValidateBio, strings.Repeat("a", MaxBioLength+1), errors.ErrBioInvalid},
+		{"Pronouns", ValidatePronouns, "they/them", nil},
+		{"Pronouns with digits", ValidatePronouns, "he/him1", errors.ErrPronounsInvalid},
+		{"Timezone", ValidateTimezone, "Europe/Paris", nil},
+		{"Empty timezone clears it", ValidateTimezone, "", nil},
+		{"Unknown timezone", ValidateTimezone, "Mars/Olympus_Mons", errors.ErrTimezoneInvalid},
+		{"Local timezone", ValidateTimezone, "Local", errors.ErrTimezoneInvalid},
+		{"Timezone path traversal", ValidateTimezone, "../../etc/passwd", errors.ErrTimezoneInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.validate(tt.value); err != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGeneratePassword(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 200; i++ {
+		password, err := GeneratePassword()
+		if err != nil {
+			t.Fatalf("Failed to generate password: %v", err)
+		}
+		if len(password) != GeneratedPasswordLength {
+			t.Errorf("Expected %d characters, got %q", GeneratedPasswordLength, password)
+		}
+		if err := ValidatePassword(password); err != nil {
+			t.Errorf("Generated password %q is invalid: %v", password, err)
+		}
+		if seen[password] {
+			t.Errorf("Password %q generated twice", password)
+		}
+		seen[password] = true
+	}
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	stdErrors "errors"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Page size bounds of the delivery log
+const (
+	DefaultWebhookDeliveryPageSize	= 50
+	MaxWebhookDeliveryPageSize	= 200
+)
+
+// Events available to conversation webhooks, server-wide webhooks can use any event
+var conversationWebhookEvents = map[string]bool {
+	models.WebhookMessageCreated:	true,
+	models.WebhookMemberJoined:	true,
+}
+
+var serverWebhookEvents = map[string]bool {
+	models.WebhookMessageCreated:	true,
+	models.WebhookMemberJoined:	true,
+	models.WebhookUserRegistered:	true,
+}
+
+// Queues webhook deliveries for an event.
+// conversationID is nil for events which do not belong to a conversation.
+type WebhookPublisher interface {
+	Publish(ctx context.Context, event string, conversationID *uuid.UUID, data any)
+}
+
+// Services may be built without webhooks, in tests for instance
+func publishWebhook(publisher WebhookPublisher, ctx context.Context, event string, conversationID *uuid.UUID, data any) {
+	if publisher != nil {
+		publisher.Publish(ctx, event, conversationID, data)
+	}
+}
+
+// Defines business logic operations related to outgoing webhooks.
+type WebhookService interface {
+	WebhookPublisher
+	CreateWebhook(ctx context.Context, userID uuid.UUID, input CreateWebhookInput) (*models.Webhook, error)
+	ListWebhooks(ctx context.Context, userID uuid.UUID) ([]*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, userID, webhookID uuid.UUID) error
+	ListDeliveries(ctx context.Context, userID, webhookID uuid.UUID, limit int) ([]*models.WebhookDelivery, error)
+	ReplayDelivery(ctx context.Context, userID, webhookID, deliveryID uuid.UUID) (*models.WebhookDelivery, error)
+}
+
+// Concrete implementation of WebhookService.
+type webhookService struct {
+	repo			repository.WebhookRepository
+	conversationRepo	repository.ConversationRepository
+	userRepo		repository.UserRepository
+}
+
+type CreateWebhookInput struct {
+	URL		string
+	Events		[]string
+	// Server-wide webhook when nil, which requires a server administrator
+	ConversationID	*uuid.UUID
+}
+
+// Body of every delivery
+type webhookPayload struct {
+	Event		string		`json:"event"`
+	ConversationID	*uuid.UUID	`json:"conversationId,omitempty"`
+	CreatedAt	time.Time	`json:"createdAt"`
+	Data		any		`json:"data"`
+}
+
+// Data of message.created deliveries
+type webhookMessage struct {
+	ID		uuid.UUID		`json:"id"`
+	ConversationID	uuid.UUID		`json:"conversationId"`
+	SenderID	uuid.UUID		`json:"senderId"`
+	Content		string			`json:"content"`
+	ContentType	models.ContentType	`json:"contentType"`
+	MentionIDs	[]uuid.UUID		`json:"mentionIds"`
+	CreatedAt	time.Time		`json:"createdAt"`
+	ExpiresAt	*time.Time		`json:"expiresAt,omitempty"`
+}
+
+func newWebhookMessage(message *models.Message) webhookMessage {
+	mentionIDs := message.MentionIDs
+	if mentionIDs == nil {
+		mentionIDs = []uuid.UUID{}
+	}
+
+	return webhookMessage {
+		ID:		message.ID,
+		ConversationID:	message.ConversationID,
+		SenderID:	message.SenderID,
+		Content:	message.Content,
+		ContentType:	message.ContentType,
+		MentionIDs:	mentionIDs,
+		CreatedAt:	message.CreatedAt,
+		ExpiresAt:	message.ExpiresAt,
+	}
+}
+
+// Data of member.joined deliveries
+type webhookMember struct {
+	ConversationID	uuid.UUID		`json:"conversationId"`
+	UserID		uuid.UUID		`json:"userId"`
+	Role		models.MemberRole	`json:"role"`
+}
+
+// Creates a new WebhookService instance.
+func NewWebhookService(repo repository.WebhookRepository, conversationRepo repository.ConversationRepository, userRepo repository.UserRepository) WebhookService {
+	return &webhookService {
+		repo:			repo,
+		conversationRepo:	conversationRepo,
+		userRepo:		userRepo,
+	}
+}
+
+// Failures are logged rather than returned, so that they never fail the action which triggered the event
+func (s *webhookService) Publish(ctx context.Context, event string, conversationID *uuid.UUID, data any) {
+	payload, err := json.Marshal(webhookPayload {
+		Event:		event,
+		ConversationID:	conversationID,
+		CreatedAt:	time.Now().UTC(),
+		Data:		data,
+	})
+	if err != nil {
+		log.Printf("Failed to encode %s webhook payload: %v", event, err)
+		return
+	}
+
+	// Deliveries of a disappearing message go along with it
+	var expiresAt *time.Time
+	if message, ok := data.(webhookMessage); ok {
+		expiresAt = message.ExpiresAt
+	}
+
+	if _, err := s.repo.EnqueueDeliveries(ctx, event, conversationID, payload, expiresAt); err != nil {
+		log.Printf("Failed to queue %s webhook deliveries: %v", event, err)
+	}
+}
+
+// Checks the URL is usable as an endpoint, whatever host it points to.
+// External commands are configured by the operator and may run next to the server.
+func parseEndpointURL(raw string) (*url.URL, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || parsed.User != nil {
+		return nil, errors.ErrWebhookURLInvalid
+	}
+	return parsed, nil
+}
+
+// Webhooks are created by users, so their host must not resolve to an address of the server's network.
+// Unresolvable hosts are accepted: the worker checks the address again when connecting,
+// which also catches hosts resolving to another address later on.
+func ValidateWebhookURL(ctx context.Context, raw string) error {
+	parsed, err := parseEndpointURL(raw)
+	if err != nil {
+		return err
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.ErrWebhookHostForbidden
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublicAddress(ip) {
+			return errors.ErrWebhookHostForbidden
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if !isPublicAddress(addr.IP) {
+			return errors.ErrWebhookHostForbidden
+		}
+	}
+	return nil
+}
+
+// Reports whether webhooks may be sent to the address
+func isPublicAddress(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+// Conversation webhooks are managed by the conversation's admins, server-wide ones by server administrators
+func (s *webhookService) CreateWebhook(ctx context.Context, userID uuid.UUID, input CreateWebhookInput) (*models.Webhook, error) {
+	if err := ValidateWebhookURL(ctx, input.URL); err != nil {
+		return nil, err
+	}
+
+	allowed := serverWebhookEvents
+	if input.ConversationID != nil {
+		allowed = conversationWebhookEvents
+	}
+	events, err := normalizeWebhookEvents(input.Events, allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.ConversationID != nil {
+		role, err := memberRole(ctx, s.conversationRepo, userID, *input.ConversationID)
+		if err != nil {
+			return nil, err
+		}
+		if !role.IsAdmin() {
+			return nil, errors.ErrConversationAdminRequired
+		}
+	} else if err := s.requireServerAdmin(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	webhook := &models.Webhook {
+		ID:		uuid.New(),
+		OwnerID:	userID,
+		ConversationID:	input.ConversationID,
+		URL:		input.URL,
+		Secret:		hex.EncodeToString(secret),
+		Events:		events,
+		CreatedAt:	time.Now().UTC(),
+	}
+
+	if err := s.repo.CreateWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+func normalizeWebhookEvents(events []string, allowed map[string]bool) ([]string, error) {
+	if len(events) == 0 {
+		return nil, errors.ErrWebhookEventsInvalid
+	}
+
+	seen := map[string]bool{}
+	normalized := make([]string, 0, len(events))
+	for _, event := range events {
+		if !allowed[event] {
+			return nil, errors.ErrWebhookEventsInvalid
+		}
+		if !seen[event] {
+			seen[event] = true
+			normalized = append(normalized, event)
+		}
+	}
+
+	return normalized, nil
+}
+
+func (s *webhookService) requireServerAdmin(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return errors.ErrUserNotFound
+		}
+		return err
+	}
+
+	if !user.IsAdmin {
+		return errors.ErrServerAdminRequired
+	}
+	return nil
+}
+
+func (s *webhookService) ListWebhooks(ctx context.Context, userID uuid.UUID) ([]*models.Webhook, error) {
+	return s.repo.GetWebhooksByOwner(ctx, userID)
+}
+
+// Webhooks are only visible to the user who created them
+func (s *webhookService) getOwnedWebhook(ctx context.Context, userID, webhookID uuid.UUID) (*models.Webhook, error) {
+	webhook, err := s.repo.GetWebhookByID(ctx, webhookID)
+	if err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrWebhookNotFound
+		}
+		return nil, err
+	}
+
+	if webhook.OwnerID != userID {
+		return nil, errors.ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+func (s *webhookService) DeleteWebhook(ctx context.Context, userID, webhookID uuid.UUID) error {
+	if _, err := s.getOwnedWebhook(ctx, userID, webhookID); err != nil {
+		return err
+	}
+	return s.repo.DeleteWebhook(ctx, webhookID)
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, userID, webhookID uuid.UUID, limit int) ([]*models.WebhookDelivery, error) {
+	if _, err := s.getOwnedWebhook(ctx, userID, webhookID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultWebhookDeliveryPageSize
+	}
+	if limit > MaxWebhookDeliveryPageSize {
+		limit = MaxWebhookDeliveryPageSize
+	}
+
+	return s.repo.GetDeliveriesByWebhook(ctx, webhookID, limit)
+}
+
+// Queues a new delivery of the same payload, keeping the original one in the log
+func (s *webhookService) ReplayDelivery(ctx context.Context, userID, webhookID, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	if _, err := s.getOwnedWebhook(ctx, userID, webhookID); err != nil {
+		return nil, err
+	}
+
+	original, err := s.repo.GetDeliveryByID(ctx, deliveryID)
+	if err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrDeliveryNotFound
+		}
+		return nil, err
+	}
+	if original.WebhookID != webhookID {
+		return nil, errors.ErrDeliveryNotFound
+	}
+
+	now := time.Now().UTC()
+	if original.ExpiresAt != nil && !now.Before(*original.ExpiresAt) {
+		return nil, errors.ErrDeliveryExpired
+	}
+
+	delivery := &models.WebhookDelivery {
+		ID:		uuid.New(),
+		WebhookID:	webhookID,
+		Event:		original.Event,
+		Payload:	original.Payload,
+		Status:		models.DeliveryPending,
+		NextAttemptAt:	now,
+		CreatedAt:	now,
+		ExpiresAt:	original.ExpiresAt,
+	}
+
+	if err := s.repo.CreateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
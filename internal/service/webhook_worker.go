@@ -0,0 +1,263 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	stdErrors "errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Retry policy of webhook deliveries: the delay doubles after each failed attempt
+const (
+	MaxWebhookAttempts	= 8
+	webhookRetryBaseDelay	= 30 * time.Second
+	webhookRetryMaxDelay	= 6 * time.Hour
+	webhookRequestTimeout	= 10 * time.Second
+	webhookBatchSize	= 50
+	// Claimed deliveries are left alone by other workers for this long.
+	// Covers a whole batch of timed out requests, which are sent one after the other.
+	webhookDeliveryLease	= webhookBatchSize*webhookRequestTimeout + time.Minute
+)
+
+// Succeeded and failed deliveries are kept in the log for this long, they hold message content
+const (
+	WebhookDeliveryRetention	= 7 * 24 * time.Hour
+	webhookPurgeInterval		= time.Hour
+)
+
+// Headers sent with every delivery
+const (
+	WebhookEventHeader	= "X-Gotalk-Event"
+	WebhookDeliveryHeader	= "X-Gotalk-Delivery"
+	WebhookTimestampHeader	= "X-Gotalk-Timestamp"
+	WebhookSignatureHeader	= "X-Gotalk-Signature"
+)
+
+// Returns the signature header value of a delivery: the hex-encoded HMAC-SHA256
+// of "<timestamp>.<body>" keyed with the webhook secret, prefixed with "sha256=".
+// Receivers should recompute it and reject stale timestamps to prevent replays.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Delay before the attempt following the given number of failed ones
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := 1; i < attempts && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMaxDelay {
+		delay = webhookRetryMaxDelay
+	}
+	return delay
+}
+
+var errWebhookOwnerUnauthorized = stdErrors.New("webhook creator no longer holds the role needed to manage it")
+
+var errWebhookAddressForbidden = stdErrors.New("webhook host resolves to a loopback, private or link-local address")
+
+// Runs right before connecting, on the resolved address, so that a host cannot
+// pass the check at creation and point to an internal address afterwards.
+func webhookDialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicAddress(ip) {
+		return errWebhookAddressForbidden
+	}
+	return nil
+}
+
+// Returns the client sending deliveries, control is run on every connection when set
+func newWebhookClient(control func(network, address string, c syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer {
+		Timeout:	webhookRequestTimeout,
+		Control:	control,
+	}
+
+	return &http.Client {
+		Timeout:	webhookRequestTimeout,
+		// Connections go straight to the receiver, a proxy would hide its address from the check
+		Transport:	&http.Transport {
+			DialContext:		dialer.DialContext,
+			TLSHandshakeTimeout:	webhookRequestTimeout,
+			MaxIdleConnsPerHost:	4,
+			IdleConnTimeout:	90 * time.Second,
+		},
+		// A redirect is reported as a failure rather than followed to another host
+		CheckRedirect:	func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Background job sending queued webhook deliveries
+type WebhookWorker struct {
+	repo		repository.WebhookRepository
+	client		*http.Client
+	interval	time.Duration
+}
+
+// Creates a new WebhookWorker instance.
+func NewWebhookWorker(repo repository.WebhookRepository, interval time.Duration) *WebhookWorker {
+	return &WebhookWorker {
+		repo:		repo,
+		interval:	interval,
+		client:		newWebhookClient(webhookDialControl),
+	}
+}
+
+// Blocks until ctx is cancelled
+func (w *WebhookWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	purgeTicker := time.NewTicker(webhookPurgeInterval)
+	defer purgeTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.DeliverDue(ctx); err != nil {
+				log.Printf("Failed to deliver webhooks: %v", err)
+			}
+		case <-purgeTicker.C:
+			if err := w.PurgeFinished(ctx); err != nil {
+				log.Printf("Failed to purge webhook deliveries: %v", err)
+			}
+		}
+	}
+}
+
+// Deletes the deliveries which are over and past their retention period
+func (w *WebhookWorker) PurgeFinished(ctx context.Context) error {
+	deleted, err := w.repo.DeleteFinishedDeliveries(ctx, time.Now().UTC().Add(-WebhookDeliveryRetention))
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		log.Printf("Purged %d webhook deliveries", deleted)
+	}
+	return nil
+}
+
+// Sends every delivery whose next attempt is due
+func (w *WebhookWorker) DeliverDue(ctx context.Context) error {
+	for {
+		deliveries, err := w.repo.ClaimDueDeliveries(ctx, webhookDeliveryLease, webhookBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		webhooks := map[uuid.UUID]*models.Webhook{}
+		authorized := map[uuid.UUID]bool{}
+		for _, delivery := range deliveries {
+			webhook, ok := webhooks[delivery.WebhookID]
+			if !ok {
+				webhook, err = w.repo.GetWebhookByID(ctx, delivery.WebhookID)
+				if stdErrors.Is(err, pgx.ErrNoRows) {
+					// Deleted meanwhile, its deliveries are gone too
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				webhooks[delivery.WebhookID] = webhook
+
+				// The creator may have left the conversation or lost their role since it was queued
+				authorized[webhook.ID], err = w.repo.IsWebhookOwnerAuthorized(ctx, webhook.ID)
+				if err != nil {
+					return err
+				}
+			}
+
+			if authorized[webhook.ID] {
+				w.attempt(ctx, webhook, delivery)
+			} else {
+				delivery.Status = models.DeliveryFailed
+				delivery.LastError = errWebhookOwnerUnauthorized.Error()
+			}
+			if err := w.repo.UpdateDelivery(ctx, delivery); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Sends the delivery once and updates it with the outcome
+func (w *WebhookWorker) attempt(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) {
+	now := time.Now().UTC()
+	delivery.Attempts++
+
+	statusCode, err := w.send(ctx, webhook, delivery, now)
+	delivery.LastStatusCode = statusCode
+
+	if err == nil {
+		delivery.Status = models.DeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= MaxWebhookAttempts {
+		delivery.Status = models.DeliveryFailed
+		return
+	}
+	delivery.NextAttemptAt = now.Add(webhookRetryDelay(delivery.Attempts))
+}
+
+func (w *WebhookWorker) send(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery, now time.Time) (*int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gotalk-webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID.String())
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// Drain a bounded amount so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	statusCode := resp.StatusCode
+	if statusCode < 200 || statusCode >= 300 {
+		return &statusCode, fmt.Errorf("unexpected status %d", statusCode)
+	}
+	return &statusCode, nil
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore_PutGetDelete(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "avatars/user/a.png", strings.NewReader("content")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	r, err := store.Get(ctx, "avatars/user/a.png")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "content" {
+		t.Errorf("Expected content %q, got %q", "content", data)
+	}
+
+	if err := store.Delete(ctx, "avatars/user/a.png"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get(ctx, "avatars/user/a.png"); err != ErrObjectNotFound {
+		t.Errorf("Expected ErrObjectNotFound after delete, got %v", err)
+	}
+}
+
+func TestLocalStore_RejectsPathTraversal(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	if err := store.Put(context.Background(), "../escape", strings.NewReader("x")); err == nil {
+		t.Error("Expected an error for a key escaping the root directory")
+	}
+}
@@ -1 +1 @@
-DROP TABLE IF EXISTS conversation_members
+DROP TABLE IF EXISTS conversation_members;
@@ -6,5 +6,4 @@ CREATE TABLE conversation_members (
 	PRIMARY KEY (user_id, conversation_id),
 	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
 	FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
-)
-
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_messages_expiry_notified_at;
+DROP INDEX IF EXISTS idx_messages_expires_at;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS expiry_notified_at;
+ALTER TABLE messages DROP COLUMN IF EXISTS ttl_seconds;
+ALTER TABLE messages DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,9 @@
+ALTER TABLE messages ADD COLUMN expires_at TIMESTAMPTZ;
+-- Lifetime of disappearing messages. When expires_at is still unset, the
+-- countdown starts once another member reads the message.
+ALTER TABLE messages ADD COLUMN ttl_seconds INTEGER;
+-- Set once subscribers were told about the expiry, the row can then be purged
+ALTER TABLE messages ADD COLUMN expiry_notified_at TIMESTAMPTZ;
+
+CREATE INDEX idx_messages_expires_at ON messages (expires_at) WHERE expires_at IS NOT NULL AND expiry_notified_at IS NULL;
+CREATE INDEX idx_messages_expiry_notified_at ON messages (expiry_notified_at) WHERE expiry_notified_at IS NOT NULL;
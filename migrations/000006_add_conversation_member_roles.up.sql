@@ -0,0 +1,4 @@
+ALTER TABLE conversation_members ADD COLUMN role TEXT NOT NULL DEFAULT 'member';
+
+ALTER TABLE conversation_members ADD CONSTRAINT conversation_members_role_check
+	CHECK (role IN ('owner', 'admin', 'member'));
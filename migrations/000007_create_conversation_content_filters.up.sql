@@ -0,0 +1,9 @@
+CREATE TABLE conversation_content_filters (
+	conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+	-- Filters run in ascending position order
+	position INT NOT NULL,
+	name TEXT NOT NULL,
+	config JSONB NOT NULL DEFAULT '{}',
+
+	PRIMARY KEY (conversation_id, position)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE conversation_members DROP COLUMN IF EXISTS last_posted_at;
+
+ALTER TABLE conversations DROP COLUMN IF EXISTS slow_mode_seconds;
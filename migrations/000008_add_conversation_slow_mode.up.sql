@@ -0,0 +1,5 @@
+ALTER TABLE conversations ADD COLUMN slow_mode_seconds INT NOT NULL DEFAULT 0
+	CHECK (slow_mode_seconds >= 0);
+
+-- Shared by every server instance to enforce slow mode
+ALTER TABLE conversation_members ADD COLUMN last_posted_at TIMESTAMPTZ;
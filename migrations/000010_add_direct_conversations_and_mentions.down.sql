@@ -0,0 +1,3 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS mention_ids;
+
+ALTER TABLE conversations DROP COLUMN IF EXISTS is_direct;
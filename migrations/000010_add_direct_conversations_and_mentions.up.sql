@@ -0,0 +1,4 @@
+ALTER TABLE conversations ADD COLUMN is_direct BOOLEAN NOT NULL DEFAULT false;
+
+-- Users notified by the message, resolved from @username when it is sent
+ALTER TABLE messages ADD COLUMN mention_ids UUID[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,2 @@
+-- Serves case-insensitive username prefix searches
+CREATE INDEX idx_users_username_lower ON users (lower(username) text_pattern_ops);
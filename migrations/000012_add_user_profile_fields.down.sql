@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_users_display_name_lower;
+
+ALTER TABLE users
+	DROP COLUMN IF EXISTS display_name,
+	DROP COLUMN IF EXISTS bio,
+	DROP COLUMN IF EXISTS pronouns,
+	DROP COLUMN IF EXISTS timezone,
+	DROP COLUMN IF EXISTS avatar_key;
@@ -0,0 +1,10 @@
+ALTER TABLE users
+	ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+	ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+	ADD COLUMN pronouns TEXT NOT NULL DEFAULT '',
+	-- IANA time zone name, e.g. Europe/Paris
+	ADD COLUMN timezone TEXT NOT NULL DEFAULT '',
+	-- Storage key of the processed avatar, empty when unset
+	ADD COLUMN avatar_key TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_users_display_name_lower ON users (lower(display_name) text_pattern_ops);
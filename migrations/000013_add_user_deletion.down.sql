@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_users_deletion_scheduled_at;
+
+ALTER TABLE users
+	DROP COLUMN IF EXISTS deleted_at,
+	DROP COLUMN IF EXISTS deletion_scheduled_at;
@@ -0,0 +1,7 @@
+ALTER TABLE users
+	-- Set while the account is pending deletion, cleared when the user logs in again
+	ADD COLUMN deletion_scheduled_at TIMESTAMPTZ,
+	-- Set once the account has been anonymized, its messages are kept
+	ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX idx_users_deletion_scheduled_at ON users (deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
@@ -0,0 +1,17 @@
+CREATE TABLE data_exports (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+	-- Storage key of the ZIP archive once completed
+	object_key TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	started_at TIMESTAMPTZ,
+	completed_at TIMESTAMPTZ,
+	-- The archive is purged after this date
+	expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_data_exports_user_id ON data_exports (user_id);
+CREATE INDEX idx_data_exports_pending ON data_exports (created_at) WHERE status = 'pending';
+-- A user has at most one export in progress
+CREATE UNIQUE INDEX idx_data_exports_active ON data_exports (user_id) WHERE status IN ('pending', 'running');
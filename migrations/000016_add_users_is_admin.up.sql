@@ -0,0 +1,2 @@
+-- Server administrators manage server-wide settings such as webhooks
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,36 @@
+CREATE TABLE webhooks (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	-- Server-wide when NULL
+	conversation_id UUID REFERENCES conversations(id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	-- Key of the HMAC-SHA256 delivery signatures
+	secret TEXT NOT NULL,
+	events TEXT[] NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_webhooks_conversation_id ON webhooks (conversation_id);
+CREATE INDEX idx_webhooks_owner_id ON webhooks (owner_id);
+
+CREATE TABLE webhook_deliveries (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	webhook_id UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+	event TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+	attempts INT NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	-- Outcome of the latest attempt
+	last_status_code INT,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	delivered_at TIMESTAMPTZ,
+	-- Set when the payload holds a disappearing message, the delivery is deleted along with it
+	expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, created_at);
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_finished ON webhook_deliveries (created_at) WHERE status <> 'pending';
+CREATE INDEX idx_webhook_deliveries_expires_at ON webhook_deliveries (expires_at) WHERE expires_at IS NOT NULL;
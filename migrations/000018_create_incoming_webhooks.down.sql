@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS incoming_webhooks;
+
+ALTER TABLE users DROP COLUMN IF EXISTS is_bot;
@@ -0,0 +1,16 @@
+-- Bot users post through incoming webhooks and cannot log in
+ALTER TABLE users ADD COLUMN is_bot BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE incoming_webhooks (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+	creator_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	-- Identity the messages are attributed to, kept when the webhook is revoked
+	bot_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	-- SHA-256 of the secret token embedded in the URL
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_incoming_webhooks_conversation_id ON incoming_webhooks (conversation_id);
@@ -0,0 +1,7 @@
+ALTER TABLE conversation_members DROP COLUMN IF EXISTS muted_until;
+
+ALTER TABLE conversations DROP COLUMN IF EXISTS topic;
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_content_type_check;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS content_type;
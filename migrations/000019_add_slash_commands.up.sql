@@ -0,0 +1,10 @@
+-- Action messages are sent with /me and displayed as "<sender> <content>"
+ALTER TABLE messages ADD COLUMN content_type TEXT NOT NULL DEFAULT 'text';
+
+ALTER TABLE messages ADD CONSTRAINT messages_content_type_check
+	CHECK (content_type IN ('text', 'action'));
+
+ALTER TABLE conversations ADD COLUMN topic TEXT;
+
+-- Muted members cannot post until this time
+ALTER TABLE conversation_members ADD COLUMN muted_until TIMESTAMPTZ;
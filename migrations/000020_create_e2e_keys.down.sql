@@ -0,0 +1,10 @@
+DELETE FROM messages WHERE content_type = 'encrypted';
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_content_type_check;
+
+ALTER TABLE messages ADD CONSTRAINT messages_content_type_check
+	CHECK (content_type IN ('text', 'action'));
+
+DROP TABLE IF EXISTS e2e_one_time_prekeys;
+
+DROP TABLE IF EXISTS e2e_devices;
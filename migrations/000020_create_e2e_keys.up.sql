@@ -0,0 +1,29 @@
+-- Public keys of the devices taking part in end-to-end encryption, private keys never leave the devices
+CREATE TABLE e2e_devices (
+	-- Chosen by the device when registering
+	id UUID PRIMARY KEY,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	identity_key TEXT NOT NULL,
+	signed_prekey_id INTEGER NOT NULL,
+	signed_prekey TEXT NOT NULL,
+	signed_prekey_signature TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_e2e_devices_user_id ON e2e_devices (user_id);
+
+-- Deleted as they are handed out, so that each one is used once
+CREATE TABLE e2e_one_time_prekeys (
+	device_id UUID NOT NULL REFERENCES e2e_devices(id) ON DELETE CASCADE,
+	key_id INTEGER NOT NULL,
+	public_key TEXT NOT NULL,
+
+	PRIMARY KEY (device_id, key_id)
+);
+
+-- Encrypted messages are stored as opaque ciphertext
+ALTER TABLE messages DROP CONSTRAINT messages_content_type_check;
+
+ALTER TABLE messages ADD CONSTRAINT messages_content_type_check
+	CHECK (content_type IN ('text', 'action', 'encrypted'));
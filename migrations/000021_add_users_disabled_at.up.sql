@@ -0,0 +1,2 @@
+-- Set by server administrators, disabled accounts cannot log in or use their access tokens
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMPTZ;
@@ -0,0 +1,16 @@
+CREATE TABLE refresh_tokens (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	-- Tokens rotated out of the same login share a family, revoked together on reuse
+	family_id UUID NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	-- SHA-256 of the token, which is only handed out once
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ NOT NULL,
+	-- Set once exchanged for a new token, presenting it again revokes the family
+	used_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens (user_id);
@@ -0,0 +1,13 @@
+-- JWTs issued before this instant are refused, set when the password changes
+ALTER TABLE users ADD COLUMN tokens_valid_after TIMESTAMPTZ;
+
+-- Denylist of JWTs revoked on logout, kept until they would have expired anyway
+CREATE TABLE revoked_tokens (
+	-- jti claim of the token
+	jti TEXT PRIMARY KEY,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	expires_at TIMESTAMPTZ NOT NULL,
+	revoked_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
@@ -0,0 +1,12 @@
+// Package migrations embeds the SQL migrations of the database schema,
+// applied by internal/database/migrate.
+//
+// Files are named NNNNNN_description.up.sql and NNNNNN_description.down.sql.
+// Once released, a migration must not be edited: its checksum is recorded
+// when applied and verified by the later runs.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,299 @@
+// Package client is the Go SDK of the gotalk HTTP API.
+//
+// A Client authenticates either with a static token (a JWT or a personal
+// access token), a refresh token, or a username and password. Sessions
+// opened with Login are renewed on their own with the refresh token returned
+// by the server, and by logging in again when it is no longer valid:
+//
+//	c := client.NewClient("https://gotalk.example.com")
+//	if _, err := c.Login(ctx, "alice", "S3cret-passw0rd"); err != nil {
+//		return err
+//	}
+//	result, err := c.SendMessage(ctx, conversationID, client.SendMessageInput{Content: "hello"})
+//
+// Error responses are returned as *APIError values, which can be matched
+// against the sentinel errors of this package with errors.Is.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sessions expiring within this delay are renewed before sending a request
+const sessionRenewalMargin = time.Minute
+
+type Client struct {
+	baseURL		string
+	httpClient	*http.Client
+
+	mu		sync.Mutex
+	token		string
+	// Expiry of token when it is a JWT, zero otherwise
+	tokenExpiry	time.Time
+	// Single-use token renewing the session, replaced on each renewal
+	refreshToken	string
+	// Set once logged in with a password, used when the refresh token is refused
+	username	string
+	password	string
+	onSession	func(token, refreshToken string)
+
+	// Held while renewing, a refresh token exchanged twice would revoke the session
+	renewMu		sync.Mutex
+}
+
+type Option func(*Client)
+
+// Sends requests with httpClient instead of http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// Authenticates with a JWT or a personal access token, which is never renewed
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.setToken(token)
+	}
+}
+
+// Logs in with these credentials on the first authenticated request,
+// then again whenever the session expires
+func WithCredentials(username, password string) Option {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// Renews the session with this refresh token, e.g. one saved from an
+// earlier run, logging in again only when WithCredentials is also given
+func WithRefreshToken(refreshToken string) Option {
+	return func(c *Client) {
+		c.refreshToken = refreshToken
+	}
+}
+
+// Calls fn after each login and renewal with the new tokens, so that they
+// can be saved: the previous refresh token is no longer valid
+func WithSessionListener(fn func(token, refreshToken string)) Option {
+	return func(c *Client) {
+		c.onSession = fn
+	}
+}
+
+// Creates a new Client instance. baseURL is the root of the API, e.g. "https://gotalk.example.com".
+func NewClient(baseURL string, opts ...Option) *Client {
+	c := &Client {
+		baseURL:	strings.TrimRight(baseURL, "/"),
+		httpClient:	http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Returns the token authenticating requests, empty until logged in
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// Returns the refresh token of the session, empty when there is none
+func (c *Client) RefreshToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refreshToken
+}
+
+func (c *Client) setToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+	c.tokenExpiry = jwtExpiry(token)
+}
+
+// Stores the tokens of a new session and notifies the listener
+func (c *Client) setSession(token, refreshToken string) {
+	c.setToken(token)
+
+	c.mu.Lock()
+	c.refreshToken = refreshToken
+	onSession := c.onSession
+	c.mu.Unlock()
+
+	if onSession != nil {
+		onSession(token, refreshToken)
+	}
+}
+
+// Whether the session can be renewed once the token expires
+func (c *Client) canRenew() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refreshToken != "" || c.username != ""
+}
+
+// Reads the expiry of a JWT without verifying it, only the server can.
+// Returns the zero time for personal access tokens and malformed JWTs.
+func jwtExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+
+	var claims struct {
+		ExpiresAt int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.ExpiresAt, 0)
+}
+
+// Whether the token is missing or about to expire
+func (c *Client) needsRenewal() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token == "" || (!c.tokenExpiry.IsZero() && time.Until(c.tokenExpiry) < sessionRenewalMargin)
+}
+
+// Returns the token to send, renewing the session first when it is missing or about to expire
+func (c *Client) currentToken(ctx context.Context) (string, error) {
+	if c.canRenew() && c.needsRenewal() {
+		return c.renewSession(ctx, c.Token())
+	}
+	return c.Token(), nil
+}
+
+// Renews the session unless another request already replaced the stale token,
+// with the refresh token first and the password when it is refused
+func (c *Client) renewSession(ctx context.Context, stale string) (string, error) {
+	c.renewMu.Lock()
+	defer c.renewMu.Unlock()
+
+	if token := c.Token(); token != stale && !c.needsRenewal() {
+		return token, nil
+	}
+
+	c.mu.Lock()
+	refreshToken, username, password := c.refreshToken, c.username, c.password
+	c.mu.Unlock()
+
+	if refreshToken != "" {
+		err := c.refresh(ctx, refreshToken)
+		if err == nil {
+			return c.Token(), nil
+		}
+		if !errors.Is(err, ErrUnauthorized) || username == "" {
+			return "", fmt.Errorf("renewing session: %w", err)
+		}
+	}
+
+	if _, err := c.Login(ctx, username, password); err != nil {
+		return "", fmt.Errorf("renewing session: %w", err)
+	}
+	return c.Token(), nil
+}
+
+// Builds the URL of an API path, path segments must already be escaped
+func (c *Client) url(path string, query url.Values) string {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// Sends a request with a JSON body when in is not nil, and decodes the
+// JSON response into out when it is not nil. Authenticated requests are
+// sent again once after renewing an expired session.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, authenticated bool, in, out any) (*http.Response, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := c.send(ctx, method, path, query, authenticated, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return resp, newAPIError(resp)
+	}
+
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp, fmt.Errorf("decoding %s %s response: %w", method, path, err)
+		}
+	}
+	return resp, nil
+}
+
+// Sends the request, retrying once with a new session on 401 when it can be renewed
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, authenticated bool, body []byte) (*http.Response, error) {
+	var token string
+	if authenticated {
+		var err error
+		if token, err = c.currentToken(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := c.sendOnce(ctx, method, path, query, token, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if authenticated && c.canRenew() && resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		if token, err = c.renewSession(ctx, token); err != nil {
+			return nil, err
+		}
+		return c.sendOnce(ctx, method, path, query, token, body)
+	}
+	return resp, nil
+}
+
+func (c *Client) sendOnce(ctx context.Context, method, path string, query url.Values, token string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.url(path, query), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return c.httpClient.Do(req)
+}
@@ -0,0 +1,590 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EliasLd/gotalk-backend/internal/auth"
+	"github.com/EliasLd/gotalk-backend/internal/events"
+	"github.com/EliasLd/gotalk-backend/internal/handlers"
+	apphttp "github.com/EliasLd/gotalk-backend/internal/http"
+	"github.com/EliasLd/gotalk-backend/internal/models"
+	"github.com/EliasLd/gotalk-backend/internal/service"
+	appErr "github.com/EliasLd/gotalk-backend/internal/service/errors"
+	"github.com/google/uuid"
+)
+
+// In-memory UserService, passwords are stored as-is
+type fakeUserService struct {
+	service.UserService
+	mu	sync.Mutex
+	users	map[uuid.UUID]*models.User
+	logins	int
+}
+
+func (s *fakeUserService) RegisterUser(ctx context.Context, username, password string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if user.Username == username {
+			return nil, appErr.ErrUserAlreadyExists
+		}
+	}
+	user := &models.User{ID: uuid.New(), Username: username, Password: password, CreatedAt: time.Now()}
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *fakeUserService) AuthenticateUser(ctx context.Context, username, password string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if user.Username == username && user.Password == password {
+			s.logins++
+			return user, nil
+		}
+	}
+	return nil, appErr.ErrInvalidCredentials
+}
+
+func (s *fakeUserService) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, appErr.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *fakeUserService) UpdateUser(ctx context.Context, id uuid.UUID, input service.UpdateUserInput) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user := s.users[id]
+	if input.DisplayName != nil {
+		user.DisplayName = *input.DisplayName
+	}
+	if input.Password != nil {
+		user.Password = *input.Password
+	}
+	user.UpdatedAt = time.Now()
+	return user, nil
+}
+
+// MessageService storing messages in memory and relaying the events of a broker
+type fakeMessageService struct {
+	service.MessageService
+	broker		events.Broker
+	subscribed	chan struct{}
+	mu		sync.Mutex
+	messages	[]*models.Message
+}
+
+func (s *fakeMessageService) SendMessage(ctx context.Context, senderID, conversationID uuid.UUID, input service.SendMessageInput) (*service.SendResult, error) {
+	switch input.Content {
+	case "/slow":
+		return nil, &appErr.SlowModeError{RetryAfter: 3 * time.Second}
+	case "/ping":
+		return &service.SendResult{Command: "ping", Reply: "pong"}, nil
+	}
+
+	message := &models.Message {
+		ID:		uuid.New(),
+		ConversationID:	conversationID,
+		SenderID:	senderID,
+		Content:	input.Content,
+		ContentType:	models.ContentTypeText,
+		CreatedAt:	time.Now(),
+	}
+	if input.TTL != nil {
+		expiresAt := message.CreatedAt.Add(*input.TTL)
+		message.ExpiresAt = &expiresAt
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, message)
+	s.mu.Unlock()
+
+	s.broker.Publish(events.Event{Type: events.MessageCreated, ConversationID: conversationID, Payload: message})
+	return &service.SendResult{Message: message}, nil
+}
+
+func (s *fakeMessageService) GetMessages(ctx context.Context, userID, conversationID uuid.UUID, before *time.Time, limit int) ([]*models.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.messages, nil
+}
+
+func (s *fakeMessageService) Subscribe(ctx context.Context, userID, conversationID uuid.UUID) (<-chan events.Event, func(), error) {
+	if conversationID == uuid.Nil {
+		return nil, nil, appErr.ErrNotConversationMember
+	}
+	stream, unsubscribe := s.broker.Subscribe(conversationID)
+	s.subscribed <- struct{}{}
+	return stream, unsubscribe, nil
+}
+
+type fakeConversationService struct {
+	service.ConversationService
+	mu	sync.Mutex
+	direct	map[uuid.UUID]*models.Conversation
+}
+
+func (s *fakeConversationService) OpenDirectConversation(ctx context.Context, userID, otherID uuid.UUID) (*models.Conversation, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if conversation, ok := s.direct[otherID]; ok {
+		return conversation, false, nil
+	}
+	conversation := &models.Conversation{ID: uuid.New(), IsDirect: true, CreatedAt: time.Now()}
+	s.direct[otherID] = conversation
+	return conversation, true, nil
+}
+
+func (s *fakeConversationService) ListConversations(ctx context.Context, userID uuid.UUID) ([]*models.UserConversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conversations := []*models.UserConversation{}
+	for _, conversation := range s.direct {
+		conversations = append(conversations, &models.UserConversation{Conversation: *conversation, Role: models.RoleMember, PeerUsername: "peer"})
+	}
+	return conversations, nil
+}
+
+// RefreshTokenService handing out single-use refresh tokens
+type fakeRefreshTokenService struct {
+	mu		sync.Mutex
+	sessions	map[string]uuid.UUID
+	refreshes	int
+}
+
+func (s *fakeRefreshTokenService) IssueTokens(ctx context.Context, user *models.User, device service.SessionDevice) (*service.TokenPair, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.issue(user.ID)
+}
+
+func (s *fakeRefreshTokenService) RefreshTokens(ctx context.Context, refreshToken string, ipAddress string) (*service.TokenPair, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	userID, ok := s.sessions[refreshToken]
+	if !ok {
+		return nil, appErr.ErrInvalidRefreshToken
+	}
+	delete(s.sessions, refreshToken)
+	s.refreshes++
+	return s.issue(userID)
+}
+
+func (s *fakeRefreshTokenService) RevokeRefreshToken(ctx context.Context, userID uuid.UUID, refreshToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions[refreshToken] == userID {
+		delete(s.sessions, refreshToken)
+	}
+	return nil
+}
+
+func (s *fakeRefreshTokenService) issue(userID uuid.UUID) (*service.TokenPair, error) {
+	token, err := auth.GenerateToken(&models.User{ID: userID}, uuid.New())
+	if err != nil {
+		return nil, err
+	}
+	refreshToken := auth.RefreshTokenPrefix + uuid.NewString()
+	s.sessions[refreshToken] = userID
+	return &service.TokenPair{AccessToken: token, ExpiresIn: auth.AccessTokenTTL, RefreshToken: refreshToken, RefreshTokenExpiresAt: time.Now().Add(time.Hour)}, nil
+}
+
+// In-memory TokenRevocationService
+type fakeTokenRevocationService struct {
+	service.TokenRevocationService
+	mu	sync.Mutex
+	revoked	map[string]bool
+}
+
+func (s *fakeTokenRevocationService) IsRevoked(claims *auth.Claims) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revoked[claims.ID]
+}
+
+func (s *fakeTokenRevocationService) RevokeToken(ctx context.Context, claims *auth.Claims) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[claims.ID] = true
+	return nil
+}
+
+func (s *fakeTokenRevocationService) WatchSession(ctx context.Context, sessionID uuid.UUID) (context.Context, context.CancelFunc) {
+	return context.WithCancel(ctx)
+}
+
+// SessionService whose sessions are all already revoked, tokens are revoked one by one
+type fakeSessionService struct {
+	service.SessionService
+}
+
+func (s *fakeSessionService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	return appErr.ErrSessionNotFound
+}
+
+type testServer struct {
+	*httptest.Server
+	users		*fakeUserService
+	messages	*fakeMessageService
+	refresh		*fakeRefreshTokenService
+}
+
+func newTestServer(t *testing.T) *testServer {
+	auth.SetupTestKeys(t)
+	users := &fakeUserService{users: map[uuid.UUID]*models.User{}}
+	messages := &fakeMessageService{broker: events.NewBroker(), subscribed: make(chan struct{}, 10)}
+	conversations := &fakeConversationService{direct: map[uuid.UUID]*models.Conversation{}}
+	refresh := &fakeRefreshTokenService{sessions: map[string]uuid.UUID{}}
+	revocations := &fakeTokenRevocationService{revoked: map[string]bool{}}
+
+	handler := handlers.NewHandler(users, messages, conversations, nil, nil, nil, nil, nil, nil, nil, refresh, revocations, &fakeSessionService{})
+	server := httptest.NewServer(apphttp.NewRouter(handler, nil, revocations, nil))
+	t.Cleanup(server.Close)
+
+	return &testServer{Server: server, users: users, messages: messages, refresh: refresh}
+}
+
+const testPassword = "ValidPasswd123!"
+
+func TestAccount(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	c := NewClient(server.URL)
+
+	registered, err := c.Register(ctx, "alice", testPassword)
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if registered.Username != "alice" || registered.ID == uuid.Nil {
+		t.Errorf("Unexpected registered user: %+v", registered)
+	}
+
+	if _, err := c.Register(ctx, "alice", testPassword); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict for a taken username, got %v", err)
+	}
+
+	if _, err := c.Me(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized before logging in, got %v", err)
+	}
+
+	if _, err := c.Login(ctx, "alice", "wrong"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized for a wrong password, got %v", err)
+	}
+	if _, err := c.Login(ctx, "alice", testPassword); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	displayName := "Alice"
+	updated, err := c.UpdateMe(ctx, UpdateMeInput{DisplayName: &displayName})
+	if err != nil {
+		t.Fatalf("UpdateMe failed: %v", err)
+	}
+	if updated.DisplayName != displayName {
+		t.Errorf("Expected display name %q, got %q", displayName, updated.DisplayName)
+	}
+
+	me, err := c.Me(ctx)
+	if err != nil {
+		t.Fatalf("Me failed: %v", err)
+	}
+	if me.ID != registered.ID || me.DisplayName != displayName || me.DeletionScheduledAt != nil {
+		t.Errorf("Unexpected user: %+v", me)
+	}
+
+	if _, err := c.UpdateMe(ctx, UpdateMeInput{}); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest for an empty update, got %v", err)
+	}
+}
+
+func TestSessionRenewal(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	if _, err := NewClient(server.URL).Register(ctx, "bob", testPassword); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	// Rejected token, the client logs in again and retries
+	c := NewClient(server.URL, WithToken("expired"), WithCredentials("bob", testPassword))
+	if _, err := c.Me(ctx); err != nil {
+		t.Fatalf("Me failed: %v", err)
+	}
+	if server.users.logins != 1 {
+		t.Errorf("Expected 1 login, got %d", server.users.logins)
+	}
+	if c.Token() == "expired" {
+		t.Errorf("Expected the token to be replaced")
+	}
+
+	// The new session is reused
+	if _, err := c.Me(ctx); err != nil {
+		t.Fatalf("Me failed: %v", err)
+	}
+	if server.users.logins != 1 {
+		t.Errorf("Expected the session to be reused, got %d logins", server.users.logins)
+	}
+
+	// Static tokens are not renewed
+	c = NewClient(server.URL, WithToken("expired"))
+	if _, err := c.Me(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized, got %v", err)
+	}
+}
+
+func TestSessionRefresh(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	if _, err := NewClient(server.URL).Register(ctx, "carol", testPassword); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	var saved []string
+	c := NewClient(server.URL, WithSessionListener(func(token, refreshToken string) {
+		saved = append(saved, refreshToken)
+	}))
+	if _, err := c.Login(ctx, "carol", testPassword); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if c.RefreshToken() == "" || len(saved) != 1 {
+		t.Fatalf("Expected a refresh token passed to the listener, got %q and %v", c.RefreshToken(), saved)
+	}
+
+	// Rejected token, the refresh token renews the session without a login
+	c.setToken("expired")
+	if _, err := c.Me(ctx); err != nil {
+		t.Fatalf("Me failed: %v", err)
+	}
+	if server.users.logins != 1 || server.refresh.refreshes != 1 {
+		t.Errorf("Expected 1 login and 1 refresh, got %d and %d", server.users.logins, server.refresh.refreshes)
+	}
+	if len(saved) != 2 || saved[1] == saved[0] || c.RefreshToken() != saved[1] {
+		t.Errorf("Expected the rotated refresh token passed to the listener, got %v", saved)
+	}
+
+	// A refresh token saved from an earlier run, without the password
+	restored := NewClient(server.URL, WithRefreshToken(c.RefreshToken()))
+	if _, err := restored.Me(ctx); err != nil {
+		t.Fatalf("Me with a refresh token failed: %v", err)
+	}
+
+	// The token exchanged by restored is spent, c falls back to its password
+	c.setToken("expired")
+	if _, err := c.Me(ctx); err != nil {
+		t.Fatalf("Me failed: %v", err)
+	}
+	if server.users.logins != 2 {
+		t.Errorf("Expected a second login, got %d", server.users.logins)
+	}
+
+	// Without a password, a spent refresh token ends the session
+	spent := NewClient(server.URL, WithRefreshToken(saved[0]))
+	if _, err := spent.Me(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized, got %v", err)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	if _, err := NewClient(server.URL).Register(ctx, "dave", testPassword); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	c := NewClient(server.URL)
+	if _, err := c.Login(ctx, "dave", testPassword); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	token, refreshToken := c.Token(), c.RefreshToken()
+
+	if err := c.Logout(ctx); err != nil {
+		t.Fatalf("Logout failed: %v", err)
+	}
+	if c.Token() != "" || c.RefreshToken() != "" {
+		t.Errorf("Expected the session to be forgotten")
+	}
+	if _, err := c.Me(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized after logging out, got %v", err)
+	}
+
+	// Neither token of the session can be used anymore
+	if _, err := NewClient(server.URL, WithToken(token)).Me(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected the access token revoked, got %v", err)
+	}
+	if _, err := NewClient(server.URL, WithRefreshToken(refreshToken)).Me(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected the refresh token revoked, got %v", err)
+	}
+}
+
+func TestJWTExpiry(t *testing.T) {
+	// {"exp":1700000000}
+	token := "eyJhbGciOiJIUzI1NiJ9.eyJleHAiOjE3MDAwMDAwMDB9.sig"
+	if got := jwtExpiry(token); !got.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Expected expiry 1700000000, got %v", got)
+	}
+	if got := jwtExpiry("gtp_abcdef"); !got.IsZero() {
+		t.Errorf("Expected no expiry for access tokens, got %v", got)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	c := NewClient(server.URL, WithCredentials("carol", testPassword))
+	if _, err := c.Register(ctx, "carol", testPassword); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	other := uuid.New()
+	conversation, created, err := c.OpenDirectConversation(ctx, other)
+	if err != nil {
+		t.Fatalf("OpenDirectConversation failed: %v", err)
+	}
+	if !created || !conversation.IsDirect {
+		t.Errorf("Expected a new direct conversation, got %+v (created %v)", conversation, created)
+	}
+	if again, created, err := c.OpenDirectConversation(ctx, other); err != nil || created || again.ID != conversation.ID {
+		t.Errorf("Expected the existing conversation, got %+v (created %v, err %v)", again, created, err)
+	}
+
+	conversations, err := c.ListConversations(ctx)
+	if err != nil {
+		t.Fatalf("ListConversations failed: %v", err)
+	}
+	if len(conversations) != 1 || conversations[0].ID != conversation.ID || conversations[0].PeerUsername != "peer" {
+		t.Errorf("Unexpected conversations: %+v", conversations)
+	}
+
+	result, err := c.SendMessage(ctx, conversation.ID, SendMessageInput{Content: "**hi**", TTL: time.Minute})
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if result.Command != "" || result.Message == nil || result.Message.Content != "**hi**" {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+	if result.Message.HTML == "" || len(result.Message.Entities) != 1 || result.Message.ExpiresAt == nil {
+		t.Errorf("Expected a rendered disappearing message, got %+v", result.Message)
+	}
+	sent := result.Message
+
+	result, err = c.SendMessage(ctx, conversation.ID, SendMessageInput{Content: "/ping"})
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if result.Command != "ping" || result.Reply != "pong" || result.Message != nil {
+		t.Errorf("Unexpected command result: %+v", result)
+	}
+
+	_, err = c.SendMessage(ctx, conversation.ID, SendMessageInput{Content: "/slow"})
+	var apiErr *APIError
+	if !errors.Is(err, ErrRateLimited) || !errors.As(err, &apiErr) || apiErr.RetryAfter != 3*time.Second {
+		t.Errorf("Expected a rate limit error with a 3s delay, got %v", err)
+	}
+
+	messages, err := c.ListMessages(ctx, conversation.ID, ListMessagesOptions{Before: time.Now(), Limit: 10})
+	if err != nil {
+		t.Fatalf("ListMessages failed: %v", err)
+	}
+	if len(messages) != 1 || messages[0].ID != sent.ID {
+		t.Errorf("Unexpected messages: %+v", messages)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	c := NewClient(server.URL, WithCredentials("dave", testPassword))
+	if _, err := c.Register(ctx, "dave", testPassword); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	conversationID := uuid.New()
+	reconnected := make(chan struct{}, 1)
+	sub := c.Subscribe(ctx, conversationID, SubscribeOptions {
+		MinBackoff:	10 * time.Millisecond,
+		OnReconnect:	func() { reconnected <- struct{}{} },
+	})
+	defer sub.Close()
+
+	waitFor := func(ch <-chan struct{}, what string) {
+		t.Helper()
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+	}
+	receive := func() Event {
+		t.Helper()
+		select {
+		case event := <-sub.Events():
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for an event")
+			return Event{}
+		}
+	}
+
+	waitFor(server.messages.subscribed, "the subscription")
+	waitFor(sub.Connected(), "the connection")
+	message := &models.Message{ID: uuid.New(), ConversationID: conversationID, Content: "hello", ContentType: models.ContentTypeText}
+	server.messages.broker.Publish(events.Event{Type: events.MessageCreated, ConversationID: conversationID, Payload: message})
+
+	event := receive()
+	if event.Type != EventMessageCreated || event.Message == nil || event.Message.Content != "hello" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	// Dropped connections are reopened
+	server.CloseClientConnections()
+	waitFor(server.messages.subscribed, "the new subscription")
+	waitFor(reconnected, "the reconnection callback")
+
+	server.messages.broker.Publish(events.Event{Type: events.MessageDeleted, ConversationID: conversationID, Payload: message})
+	event = receive()
+	if event.Type != EventMessageDeleted || event.DeletedMessageID != message.ID {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	sub.Close()
+	if _, ok := <-sub.Events(); ok {
+		t.Errorf("Expected the events channel to be closed")
+	}
+	if err := sub.Err(); err != nil {
+		t.Errorf("Expected no error once closed, got %v", err)
+	}
+}
+
+func TestSubscribeRefused(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	c := NewClient(server.URL, WithCredentials("erin", testPassword))
+	if _, err := c.Register(ctx, "erin", testPassword); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	// Refusals are not retried
+	sub := c.Subscribe(ctx, uuid.Nil, SubscribeOptions{})
+	select {
+	case _, ok := <-sub.Events():
+		if ok {
+			t.Fatalf("Expected no event")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the subscription to end")
+	}
+	if !errors.Is(sub.Err(), ErrForbidden) {
+		t.Errorf("Expected ErrForbidden, got %v", sub.Err())
+	}
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func conversationPath(conversationID uuid.UUID, suffix string) string {
+	return "/conversations/" + conversationID.String() + suffix
+}
+
+// Lists the conversations the authenticated user belongs to, latest joined first
+func (c *Client) ListConversations(ctx context.Context) ([]UserConversation, error) {
+	var conversations []UserConversation
+	if _, err := c.do(ctx, http.MethodGet, "/conversations", nil, true, nil, &conversations); err != nil {
+		return nil, err
+	}
+	return conversations, nil
+}
+
+// Returns the direct conversation with another user, creating it on first
+// use. The boolean reports whether it was created by this call.
+func (c *Client) OpenDirectConversation(ctx context.Context, userID uuid.UUID) (*Conversation, bool, error) {
+	req := struct {
+		UserID uuid.UUID `json:"userId"`
+	}{userID}
+
+	var conversation Conversation
+	resp, err := c.do(ctx, http.MethodPost, "/conversations/direct", nil, true, req, &conversation)
+	if err != nil {
+		return nil, false, err
+	}
+	return &conversation, resp.StatusCode == http.StatusCreated, nil
+}
+
+// Restricted to the conversation's owners and admins, 0 disables slow mode
+func (c *Client) SetSlowMode(ctx context.Context, conversationID uuid.UUID, interval time.Duration) error {
+	req := struct {
+		IntervalSeconds int `json:"intervalSeconds"`
+	}{int(interval / time.Second)}
+
+	_, err := c.do(ctx, http.MethodPut, conversationPath(conversationID, "/slow-mode"), nil, true, req, nil)
+	return err
+}
+
+type contentFilters struct {
+	Filters []ContentFilter `json:"filters"`
+}
+
+// Returns the conversation's own content filters, restricted to its owners and admins
+func (c *Client) ContentFilters(ctx context.Context, conversationID uuid.UUID) ([]ContentFilter, error) {
+	var resp contentFilters
+	if _, err := c.do(ctx, http.MethodGet, conversationPath(conversationID, "/filters"), nil, true, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Filters, nil
+}
+
+// Replaces the conversation's own content filters, restricted to its owners and admins
+func (c *Client) SetContentFilters(ctx context.Context, conversationID uuid.UUID, filters []ContentFilter) error {
+	if filters == nil {
+		filters = []ContentFilter{}
+	}
+	_, err := c.do(ctx, http.MethodPut, conversationPath(conversationID, "/filters"), nil, true, contentFilters{filters}, nil)
+	return err
+}
+
+func (c *Client) ListIncomingWebhooks(ctx context.Context, conversationID uuid.UUID) ([]IncomingWebhook, error) {
+	var webhooks []IncomingWebhook
+	if _, err := c.do(ctx, http.MethodGet, conversationPath(conversationID, "/incoming-webhooks"), nil, true, nil, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// Requires a password session. The token of the returned webhook is only available now.
+func (c *Client) CreateIncomingWebhook(ctx context.Context, conversationID uuid.UUID, name string) (*IncomingWebhook, error) {
+	req := struct {
+		Name string `json:"name"`
+	}{name}
+
+	var webhook IncomingWebhook
+	if _, err := c.do(ctx, http.MethodPost, conversationPath(conversationID, "/incoming-webhooks"), nil, true, req, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (c *Client) RevokeIncomingWebhook(ctx context.Context, conversationID, webhookID uuid.UUID) error {
+	_, err := c.do(ctx, http.MethodDelete, conversationPath(conversationID, "/incoming-webhooks/"+webhookID.String()), nil, true, nil, nil)
+	return err
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Matched by *APIError values through errors.Is, depending on their status code
+var (
+	ErrBadRequest		= errors.New("bad request")
+	ErrUnauthorized		= errors.New("unauthorized")
+	ErrForbidden		= errors.New("forbidden")
+	ErrNotFound		= errors.New("not found")
+	ErrConflict		= errors.New("conflict")
+	ErrTooLarge		= errors.New("payload too large")
+	ErrRejected		= errors.New("rejected by a content filter")
+	ErrRateLimited		= errors.New("rate limited")
+	ErrCommandFailed	= errors.New("slash command failed")
+	ErrServer		= errors.New("server error")
+)
+
+// Longest error message kept from a response body
+const maxErrorMessageLength = 4096
+
+// Error response of the API
+type APIError struct {
+	StatusCode	int
+	// Message sent by the server
+	Message		string
+	// Delay requested by the Retry-After header of 429 responses
+	RetryAfter	time.Duration
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorMessageLength))
+
+	apiErr := &APIError {
+		StatusCode:	resp.StatusCode,
+		Message:	strings.TrimSpace(string(body)),
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("gotalk: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("gotalk: %d %s", e.StatusCode, e.Message)
+}
+
+// Matches the sentinel error of the status code
+func (e *APIError) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return target == ErrBadRequest
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusForbidden:
+		return target == ErrForbidden
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusConflict:
+		return target == ErrConflict
+	case http.StatusRequestEntityTooLarge:
+		return target == ErrTooLarge
+	case http.StatusUnprocessableEntity:
+		return target == ErrRejected
+	case http.StatusTooManyRequests:
+		return target == ErrRateLimited
+	case http.StatusBadGateway:
+		return target == ErrCommandFailed
+	}
+	return e.StatusCode >= 500 && target == ErrServer
+}
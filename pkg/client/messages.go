@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sends a message, or runs a slash command when the content starts with a single '/'
+func (c *Client) SendMessage(ctx context.Context, conversationID uuid.UUID, input SendMessageInput) (*SendMessageResult, error) {
+	req := struct {
+		SendMessageInput
+		TTLSeconds	*int	`json:"ttlSeconds,omitempty"`
+	}{SendMessageInput: input}
+	if input.TTL > 0 {
+		seconds := int(input.TTL / time.Second)
+		req.TTLSeconds = &seconds
+	}
+
+	// Plain messages and command responses share the endpoint, "command" tells them apart
+	var raw json.RawMessage
+	if _, err := c.do(ctx, http.MethodPost, conversationPath(conversationID, "/messages"), nil, true, req, &raw); err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Command	string		`json:"command"`
+		Reply	string		`json:"reply"`
+		Message	*Message	`json:"message"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Command != "" {
+		return &SendMessageResult{Message: resp.Message, Command: resp.Command, Reply: resp.Reply}, nil
+	}
+
+	var message Message
+	if err := json.Unmarshal(raw, &message); err != nil {
+		return nil, err
+	}
+	return &SendMessageResult{Message: &message}, nil
+}
+
+type ListMessagesOptions struct {
+	// Only returns messages sent before this instant when not zero
+	Before	time.Time
+	// Page size, the server default when 0
+	Limit	int
+}
+
+// Returns a page of conversation history, newest first
+func (c *Client) ListMessages(ctx context.Context, conversationID uuid.UUID, opts ListMessagesOptions) ([]Message, error) {
+	params := url.Values{}
+	if !opts.Before.IsZero() {
+		params.Set("before", opts.Before.Format(time.RFC3339Nano))
+	}
+	if opts.Limit > 0 {
+		params.Set("limit", strconv.Itoa(opts.Limit))
+	}
+
+	var messages []Message
+	if _, err := c.do(ctx, http.MethodGet, conversationPath(conversationID, "/messages"), params, true, nil, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Marks a message as read, which starts its countdown when its TTL counts from the first read
+func (c *Client) MarkMessageRead(ctx context.Context, conversationID, messageID uuid.UUID) error {
+	_, err := c.do(ctx, http.MethodPost, conversationPath(conversationID, "/messages/"+messageID.String()+"/read"), nil, true, nil, nil)
+	return err
+}
+
+// Lists the slash commands available in every conversation
+func (c *Client) ListCommands(ctx context.Context) ([]Command, error) {
+	var commands []Command
+	if _, err := c.do(ctx, http.MethodGet, "/commands", nil, true, nil, &commands); err != nil {
+		return nil, err
+	}
+	return commands, nil
+}
@@ -0,0 +1,240 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of the events streamed by Subscribe
+const (
+	EventMessageCreated		= "message.created"
+	EventMessageDeleted		= "message.deleted"
+	EventConversationUpdated	= "conversation.updated"
+)
+
+// Default reconnection delays of subscriptions
+const (
+	DefaultMinBackoff	= 500 * time.Millisecond
+	DefaultMaxBackoff	= 30 * time.Second
+)
+
+// Largest event accepted, encrypted messages can hold 64KB of ciphertext
+const maxEventSize = 1 << 20
+
+// Real-time event of a conversation, only the field matching Type is set
+type Event struct {
+	Type			string
+	ConversationID		uuid.UUID
+	// Set for EventMessageCreated
+	Message			*Message
+	// Set for EventMessageDeleted
+	DeletedMessageID	uuid.UUID
+	// Set for EventConversationUpdated
+	Conversation		*Conversation
+}
+
+func parseEvent(data []byte) (Event, error) {
+	var raw struct {
+		Type		string		`json:"type"`
+		ConversationID	uuid.UUID	`json:"conversationId"`
+		Payload		json.RawMessage	`json:"payload"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Event{}, err
+	}
+
+	event := Event{Type: raw.Type, ConversationID: raw.ConversationID}
+	var err error
+	switch raw.Type {
+	case EventMessageCreated:
+		err = json.Unmarshal(raw.Payload, &event.Message)
+	case EventMessageDeleted:
+		var deleted struct {
+			ID uuid.UUID `json:"id"`
+		}
+		err = json.Unmarshal(raw.Payload, &deleted)
+		event.DeletedMessageID = deleted.ID
+	case EventConversationUpdated:
+		err = json.Unmarshal(raw.Payload, &event.Conversation)
+	}
+	return event, err
+}
+
+type SubscribeOptions struct {
+	// Delay before the first reconnection attempt, doubled after each failure
+	MinBackoff	time.Duration
+	MaxBackoff	time.Duration
+	// Called once the stream is back after a disconnection. Events sent in
+	// between are lost, ListMessages can be used to catch up.
+	OnReconnect	func()
+}
+
+// Live stream of a conversation's events
+type Subscription struct {
+	events		chan Event
+	cancel		context.CancelFunc
+	done		chan struct{}
+	connected	chan struct{}
+
+	mu	sync.Mutex
+	err	error
+}
+
+// Streams the conversation's events until ctx is done or Close is called,
+// reconnecting with exponential backoff when the connection drops. The HTTP
+// client must not have a timeout, it would cut the stream.
+func (c *Client) Subscribe(ctx context.Context, conversationID uuid.UUID, opts SubscribeOptions) *Subscription {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(DefaultMaxBackoff, opts.MinBackoff)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Subscription {
+		events:		make(chan Event),
+		cancel:		cancel,
+		done:		make(chan struct{}),
+		connected:	make(chan struct{}),
+	}
+
+	go s.run(ctx, c, conversationID, opts)
+	return s
+}
+
+// Closed once the subscription ends, Err then tells why
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Closed once the stream is first opened: events published from then on are received.
+// Never closed when the subscription ends before, Events tells when that happens.
+func (s *Subscription) Connected() <-chan struct{} {
+	return s.connected
+}
+
+// Ends the subscription and waits for the stream to be closed
+func (s *Subscription) Close() {
+	s.cancel()
+	<-s.done
+}
+
+// Returns the error that ended the subscription: nil once closed or
+// cancelled, an *APIError when the server refused the subscription
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *Subscription) run(ctx context.Context, c *Client, conversationID uuid.UUID, opts SubscribeOptions) {
+	defer close(s.done)
+	defer close(s.events)
+
+	backoff := opts.MinBackoff
+	connectedBefore := false
+
+	for {
+		err := c.streamEvents(ctx, conversationID, s.events, func() {
+			backoff = opts.MinBackoff
+			if connectedBefore && opts.OnReconnect != nil {
+				opts.OnReconnect()
+			}
+			if !connectedBefore {
+				close(s.connected)
+			}
+			connectedBefore = true
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if isPermanent(err) {
+			s.mu.Lock()
+			s.err = err
+			s.mu.Unlock()
+			return
+		}
+
+		// Jitter spreads the reconnections of clients dropped at the same time
+		delay := backoff/2 + rand.N(backoff/2+1)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		backoff = min(backoff*2, opts.MaxBackoff)
+	}
+}
+
+// Client errors will not go away by retrying, unlike rate limits and server errors
+func isPermanent(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode < 500 && apiErr.StatusCode != http.StatusTooManyRequests
+}
+
+// Reads Server-Sent Events until the stream ends, calling connected once it is open
+func (c *Client) streamEvents(ctx context.Context, conversationID uuid.UUID, out chan<- Event, connected func()) error {
+	resp, err := c.send(ctx, http.MethodGet, conversationPath(conversationID, "/events"), nil, true, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return newAPIError(resp)
+	}
+	connected()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxEventSize)
+
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			if data.Len() == 0 {
+				continue
+			}
+			event, err := parseEvent([]byte(data.String()))
+			data.Reset()
+			if err != nil {
+				return fmt.Errorf("decoding event: %w", err)
+			}
+
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+
+		// The event name is repeated in the data, other fields are ignored
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(value, " "))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("event stream closed by the server")
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Account returned on registration
+type RegisteredUser struct {
+	ID		uuid.UUID	`json:"id"`
+	Username	string		`json:"username"`
+	CreatedAt	time.Time	`json:"createdAt"`
+}
+
+// The authenticated user
+type Me struct {
+	ID			uuid.UUID	`json:"id"`
+	Username		string		`json:"username"`
+	DisplayName		string		`json:"displayName"`
+	Bio			string		`json:"bio"`
+	Pronouns		string		`json:"pronouns"`
+	Timezone		string		`json:"timezone"`
+	// Empty when no avatar was uploaded
+	AvatarURL		string		`json:"avatarUrl"`
+	CreatedAt		time.Time	`json:"createdAt"`
+	// Nil unless the account is pending deletion
+	DeletionScheduledAt	*time.Time	`json:"deletionScheduledAt"`
+}
+
+// Nil fields are left unchanged, at least one must be set
+type UpdateMeInput struct {
+	Username	*string `json:"username,omitempty"`
+	// Requires a password session, personal access tokens cannot change it
+	Password	*string `json:"password,omitempty"`
+	DisplayName	*string `json:"displayName,omitempty"`
+	Bio		*string `json:"bio,omitempty"`
+	Pronouns	*string `json:"pronouns,omitempty"`
+	Timezone	*string `json:"timezone,omitempty"`
+}
+
+type UpdatedUser struct {
+	ID		uuid.UUID	`json:"id"`
+	Username	string		`json:"username"`
+	DisplayName	string		`json:"displayName"`
+	Bio		string		`json:"bio"`
+	Pronouns	string		`json:"pronouns"`
+	Timezone	string		`json:"timezone"`
+	AvatarURL	string		`json:"avatarUrl"`
+	UpdatedAt	time.Time	`json:"updatedAt"`
+}
+
+// Profile visible to every user
+type PublicUser struct {
+	ID		uuid.UUID	`json:"id"`
+	Username	string		`json:"username"`
+	DisplayName	string		`json:"displayName"`
+	Bio		string		`json:"bio"`
+	Pronouns	string		`json:"pronouns"`
+	Timezone	string		`json:"timezone"`
+	AvatarURL	string		`json:"avatarUrl"`
+	// Set for incoming webhook and command bots
+	Bot		bool		`json:"bot"`
+	// Set once the account has been anonymized
+	Deleted		bool		`json:"deleted"`
+	CreatedAt	time.Time	`json:"createdAt"`
+}
+
+type SearchUsersPage struct {
+	Users		[]PublicUser	`json:"users"`
+	// Empty on the last page
+	NextCursor	string		`json:"nextCursor"`
+}
+
+type Conversation struct {
+	ID		uuid.UUID	`json:"id"`
+	IsPublic	bool		`json:"isPublic"`
+	// Private conversation between exactly two users
+	IsDirect	bool		`json:"isDirect"`
+	Name		*string		`json:"name"`
+	Topic		*string		`json:"topic"`
+	// 0 when slow mode is disabled
+	SlowModeSeconds	int		`json:"slowModeSeconds"`
+	CreatedAt	time.Time	`json:"createdAt"`
+}
+
+// Conversation as listed for one of its members
+type UserConversation struct {
+	Conversation
+	// "owner", "admin" or "member"
+	Role		string	`json:"role"`
+	// Other member of a direct conversation, empty otherwise
+	PeerUsername	string	`json:"peerUsername"`
+}
+
+// How message content is meant to be displayed
+type ContentType string
+
+const (
+	ContentTypeText		ContentType = "text"
+	// Sent with /me, displayed after the sender's name
+	ContentTypeAction	ContentType = "action"
+	// End-to-end encrypted ciphertext
+	ContentTypeEncrypted	ContentType = "encrypted"
+)
+
+// Formatted span of a message, counted in Unicode code points
+type Entity struct {
+	Type		string	`json:"type"`
+	Offset		int	`json:"offset"`
+	Length		int	`json:"length"`
+	URL		string	`json:"url,omitempty"`
+	Language	string	`json:"language,omitempty"`
+}
+
+type Message struct {
+	ID		uuid.UUID	`json:"id"`
+	ConversationID	uuid.UUID	`json:"conversationId"`
+	SenderID	uuid.UUID	`json:"senderId"`
+	// Raw Markdown as sent
+	Content		string		`json:"content"`
+	ContentType	ContentType	`json:"contentType"`
+	// Sanitized rendering of Content, empty for encrypted messages
+	HTML		string		`json:"html"`
+	Entities	[]Entity	`json:"entities"`
+	MentionIDs	[]uuid.UUID	`json:"mentionIds"`
+	CreatedAt	time.Time	`json:"createdAt"`
+	// Nil unless the message disappears, and until read when its TTL counts from the first read
+	ExpiresAt	*time.Time	`json:"expiresAt"`
+	// Zero unless the message disappears
+	TTLSeconds	int		`json:"ttlSeconds"`
+}
+
+type SendMessageInput struct {
+	// Runs a slash command when it starts with a single '/'
+	Content		string		`json:"content"`
+	// Text when empty
+	ContentType	ContentType	`json:"contentType,omitempty"`
+	// Lifetime of a disappearing message, sent in whole seconds
+	TTL		time.Duration	`json:"-"`
+	// Counts TTL from the first read by another member instead of from sending
+	TTLFromRead	bool		`json:"ttlFromRead,omitempty"`
+}
+
+// Outcome of SendMessage
+type SendMessageResult struct {
+	// Stored message, nil when a slash command posted nothing
+	Message	*Message
+	// Name of the slash command the content invoked, empty for plain messages
+	Command	string
+	// Slash command feedback only shown to the sender
+	Reply	string
+}
+
+// Slash command available in every conversation
+type Command struct {
+	Name		string	`json:"name"`
+	Usage		string	`json:"usage"`
+	Description	string	`json:"description"`
+}
+
+// Named content filter along with its filter-specific settings
+type ContentFilter struct {
+	Name	string		`json:"name"`
+	Config	json.RawMessage	`json:"config,omitempty"`
+}
+
+type IncomingWebhook struct {
+	ID		uuid.UUID	`json:"id"`
+	ConversationID	uuid.UUID	`json:"conversationId"`
+	// Display name of the bot posting the messages
+	Name		string		`json:"name"`
+	BotUserID	uuid.UUID	`json:"botUserId"`
+	CreatedAt	time.Time	`json:"createdAt"`
+	LastUsedAt	*time.Time	`json:"lastUsedAt"`
+	// Only returned on creation
+	Token		string		`json:"token"`
+	URL		string		`json:"url"`
+}
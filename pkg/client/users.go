@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (c *Client) Register(ctx context.Context, username, password string) (*RegisteredUser, error) {
+	var user RegisteredUser
+	if _, err := c.do(ctx, http.MethodPost, "/register", nil, false, credentials{username, password}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+type tokenPair struct {
+	Token		string	`json:"token"`
+	RefreshToken	string	`json:"refreshToken"`
+}
+
+// Opens a session, which the client renews with its refresh token once it
+// expires, or by logging in again with the same credentials
+func (c *Client) Login(ctx context.Context, username, password string) (string, error) {
+	var resp tokenPair
+	if _, err := c.do(ctx, http.MethodPost, "/login", nil, false, credentials{username, password}, &resp); err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.username = username
+	c.password = password
+	c.mu.Unlock()
+	c.setSession(resp.Token, resp.RefreshToken)
+
+	return resp.Token, nil
+}
+
+// Exchanges the refresh token for a new session
+func (c *Client) refresh(ctx context.Context, refreshToken string) error {
+	var resp tokenPair
+	body := struct {
+		RefreshToken string `json:"refreshToken"`
+	}{refreshToken}
+	if _, err := c.do(ctx, http.MethodPost, "/token/refresh", nil, false, body, &resp); err != nil {
+		return err
+	}
+
+	c.setSession(resp.Token, resp.RefreshToken)
+	return nil
+}
+
+// Ends the session on the server, then forgets it along with the credentials
+func (c *Client) Logout(ctx context.Context) error {
+	body := struct {
+		RefreshToken string `json:"refreshToken,omitempty"`
+	}{c.RefreshToken()}
+	if _, err := c.do(ctx, http.MethodPost, "/logout", nil, true, body, nil); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.username = ""
+	c.password = ""
+	c.mu.Unlock()
+	c.setSession("", "")
+
+	return nil
+}
+
+func (c *Client) Me(ctx context.Context) (*Me, error) {
+	var me Me
+	if _, err := c.do(ctx, http.MethodGet, "/me", nil, true, nil, &me); err != nil {
+		return nil, err
+	}
+	return &me, nil
+}
+
+func (c *Client) UpdateMe(ctx context.Context, input UpdateMeInput) (*UpdatedUser, error) {
+	var user UpdatedUser
+	if _, err := c.do(ctx, http.MethodPut, "/me/update", nil, true, input, &user); err != nil {
+		return nil, err
+	}
+
+	// The session stays valid, but renewing it needs the new credentials
+	c.mu.Lock()
+	if c.username != "" {
+		c.username = user.Username
+		if input.Password != nil {
+			c.password = *input.Password
+		}
+	}
+	c.mu.Unlock()
+
+	return &user, nil
+}
+
+func (c *Client) GetUser(ctx context.Context, userID uuid.UUID) (*PublicUser, error) {
+	var user PublicUser
+	if _, err := c.do(ctx, http.MethodGet, "/users/"+userID.String(), nil, true, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Searches users by username prefix. cursor is the NextCursor of the
+// previous page, empty for the first one, and limit 0 uses the server default.
+func (c *Client) SearchUsers(ctx context.Context, query, cursor string, limit int) (*SearchUsersPage, error) {
+	params := url.Values{"q": {query}}
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+
+	var page SearchUsersPage
+	if _, err := c.do(ctx, http.MethodGet, "/users/search", params, true, nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}